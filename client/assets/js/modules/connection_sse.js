@@ -15,6 +15,7 @@ export class ConnectionModule {
         this.isMobile = this._detectMobile(); // 是否为移动设备
         this.lastMessageId = 0; // 记录收到的最新消息ID
         this.hasReceivedMessage = false; // 是否收到过消息
+        this.lastEventId = ''; // 最后收到的事件ID，重连时服务端据此补发
     }
 
     // 检测是否为移动设备
@@ -27,7 +28,11 @@ export class ConnectionModule {
     connect() {
         const token = this.auth.getToken();
         // 使用相对路径，受 <base href> 影响
-        const sseUrl = `api/sse/subscribe?token=${token}`;
+        // 重连时带上最后收到的事件ID（重新创建 EventSource 时浏览器不会自动发送 Last-Event-ID）
+        let sseUrl = `api/sse/subscribe?token=${token}`;
+        if (this.lastEventId) {
+            sseUrl += `&last_event_id=${this.lastEventId}`;
+        }
         console.log(`[SSE] Connecting (${this.isMobile ? 'mobile' : 'desktop'} detected):`, sseUrl);
 
         // 关闭旧的连接（防止累积）
@@ -55,6 +60,7 @@ export class ConnectionModule {
         this.eventSource.addEventListener('connected', (event) => {
             const data = JSON.parse(event.data);
             console.log('[SSE] Connected event:', data);
+            this._trackEventId(event);
 
             // 检查是否是重连（有上次连接时间）
             const now = Date.now() / 1000;
@@ -70,7 +76,15 @@ export class ConnectionModule {
             this.hasReceivedMessage = false; // 重置标志，等待第一条真实消息
         });
 
+        // 断线太久，服务端已无法补发，重新拉取数据
+        this.eventSource.addEventListener('resync_required', (event) => {
+            console.log('[SSE] Resync required:', event.data);
+            this._trackEventId(event);
+            this._fetchOfflineMessages();
+        });
+
         this.eventSource.addEventListener('chat', (event) => {
+            this._trackEventId(event);
             const data = JSON.parse(event.data);
             console.log('[SSE] Chat message push:', data);
             // 记录收到的最新消息ID
@@ -115,6 +129,13 @@ export class ConnectionModule {
         };
     }
 
+    // 记录最后收到的事件ID
+    _trackEventId(event) {
+        if (event.lastEventId) {
+            this.lastEventId = event.lastEventId;
+        }
+    }
+
     // 断开连接
     disconnect() {
         if (this.eventSource) {
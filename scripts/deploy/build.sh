@@ -61,7 +61,9 @@ for PLATFORM in "${PLATFORMS[@]}"; do
 
     echo "构建 $PLATFORM..."
 
-    CGO_ENABLED=0 GOOS="$GOOS" GOARCH="$GOARCH" go build \
+    # go-sqlite3 依赖 cgo；交叉编译时需通过 CC 指定目标平台的 C 编译器
+    CGO_ENABLED=1 GOOS="$GOOS" GOARCH="$GOARCH" go build \
+        -tags sqlite_fts5 \
         -ldflags="$LDFLAGS" \
         -o "$OUTPUT_NAME" \
         server/main.go
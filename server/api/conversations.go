@@ -4,19 +4,27 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"zmessage/server/models"
 	"zmessage/server/modules/message"
 	"zmessage/server/modules/user"
 )
 
 // RegisterConversationRoutes 注册会话路由
-func RegisterConversationRoutes(r *gin.Engine, msgSvc message.Service, userSvc user.Service, wsMgr WSManager) {
+func RegisterConversationRoutes(r *gin.Engine, msgSvc message.Service, userSvc user.Service) {
 	conv := r.Group("/api/conversations")
 	conv.Use(AuthMiddleware(userSvc))
 	{
 		conv.GET("", handleGetConversations(msgSvc))
+		conv.GET("/unread", handleGetTotalUnread(msgSvc))
 		conv.GET("/:id", handleGetConversation(msgSvc))
 		conv.GET("/with/:user_id", handleGetConversationWithUser(msgSvc))
 		conv.POST("/:id/read", handleMarkAsRead(msgSvc))
+		conv.PUT("/:id/disappearing", handleSetDisappearingTimer(msgSvc))
+		conv.GET("/:id/pins", handleGetPinnedMessages(msgSvc))
+		conv.PUT("/:id/draft", handleSaveDraft(msgSvc))
+		conv.DELETE("/:id/draft", handleSaveDraft(msgSvc))
+		conv.PUT("/:id/settings", handleUpdateConversationSettings(msgSvc))
+		conv.POST("/:id/clear", handleClearHistory(msgSvc))
 	}
 }
 
@@ -43,8 +51,24 @@ func handleGetConversations(svc message.Service) gin.HandlerFunc {
 			limit = 20
 		}
 
+		// 按个人设置过滤：默认不含已归档和已隐藏的会话，传 all 不限制
+		req := &message.ConversationListRequest{UserID: auth.UserID, Page: page, Limit: limit}
+		var ok bool
+		if req.Archived, ok = parseBoolFilter(c, "archived", "false"); !ok {
+			return
+		}
+		if req.Hidden, ok = parseBoolFilter(c, "hidden", "false"); !ok {
+			return
+		}
+		if req.Muted, ok = parseBoolFilter(c, "muted", "all"); !ok {
+			return
+		}
+		if req.Pinned, ok = parseBoolFilter(c, "pinned", "all"); !ok {
+			return
+		}
+
 		// 调用消息服务
-		conv, total, err := svc.GetConversations(auth.UserID, page, limit)
+		conv, total, err := svc.ListConversations(req)
 		if err != nil {
 			InternalError(c, err)
 			return
@@ -53,14 +77,7 @@ func handleGetConversations(svc message.Service) gin.HandlerFunc {
 		// 转换为响应格式
 		convList := make([]ConversationResponse, len(conv))
 		for i, cw := range conv {
-			var participant *ParticipantResponse
-			if cw.Participant.ID != auth.UserID {
-				participant = &ParticipantResponse{
-					ID:       cw.Participant.ID,
-					Username: cw.Participant.Username,
-					Nickname: cw.Participant.Nickname,
-				}
-			}
+			participant := toParticipantResponse(cw, auth.UserID)
 
 			var lastMsg *LastMessageResponse
 			if cw.LastMessage != nil {
@@ -74,11 +91,19 @@ func handleGetConversations(svc message.Service) gin.HandlerFunc {
 			}
 
 			convList[i] = ConversationResponse{
-				ID:           cw.ID,
-				Participant:   participant,
-				LastMessage:  lastMsg,
-				UnreadCount:  cw.UnreadCount,
-				UpdatedAt:    cw.UpdatedAt,
+				ID:                    cw.ID,
+				Type:                  cw.Type,
+				Title:                 cw.Title,
+				MemberCount:           cw.MemberCount,
+				Participant:           participant,
+				LastMessage:           lastMsg,
+				UnreadCount:           cw.UnreadCount,
+				PeerLastReadMessageID: cw.PeerLastReadMessageID,
+				DisappearAfter:        cw.DisappearAfter,
+				Pins:                  cw.Pins,
+				Draft:                 cw.Draft,
+				Settings:              cw.Settings,
+				UpdatedAt:             cw.UpdatedAt,
 			}
 		}
 
@@ -86,6 +111,38 @@ func handleGetConversations(svc message.Service) gin.HandlerFunc {
 	}
 }
 
+// parseBoolFilter 解析布尔过滤参数（all 表示不限制），格式无效时返回400
+func parseBoolFilter(c *gin.Context, name string, def string) (*bool, bool) {
+	value := c.DefaultQuery(name, def)
+	if value == "all" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		BadRequest(c, "无效的过滤参数: "+name)
+		return nil, false
+	}
+	return &b, true
+}
+
+// handleGetTotalUnread 处理获取未读消息总数（免打扰中的会话不计入）
+func handleGetTotalUnread(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		total, err := svc.GetTotalUnreadCount(auth.UserID)
+		if err != nil {
+			InternalError(c, err)
+			return
+		}
+
+		Success(c, gin.H{"total": total})
+	}
+}
+
 // handleGetConversation 处理获取会话详情
 func handleGetConversation(svc message.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -110,20 +167,22 @@ func handleGetConversation(svc message.Service) gin.HandlerFunc {
 		}
 
 		// 构建参与者信息
-		var participant *ParticipantResponse
-		if conv.Participant.ID != auth.UserID {
-			participant = &ParticipantResponse{
-				ID:       conv.Participant.ID,
-				Username: conv.Participant.Username,
-				Nickname: conv.Participant.Nickname,
-			}
-		}
+		participant := toParticipantResponse(conv, auth.UserID)
 
 		c.JSON(200, ConversationDetailResponse{
-			ID:         conv.ID,
-			Participant: participant,
-			CreatedAt:  conv.CreatedAt,
-			UpdatedAt:   conv.UpdatedAt,
+			ID:                    conv.ID,
+			Type:                  conv.Type,
+			Title:                 conv.Title,
+			Members:               conv.Members,
+			MemberCount:           conv.MemberCount,
+			Participant:           participant,
+			PeerLastReadMessageID: conv.PeerLastReadMessageID,
+			DisappearAfter:        conv.DisappearAfter,
+			Pins:                  conv.Pins,
+			Draft:                 conv.Draft,
+			Settings:              conv.Settings,
+			CreatedAt:             conv.CreatedAt,
+			UpdatedAt:             conv.UpdatedAt,
 		})
 	}
 }
@@ -152,25 +211,25 @@ func handleGetConversationWithUser(svc message.Service) gin.HandlerFunc {
 		// 调用消息服务
 		conv, err := svc.GetConversationWithUser(auth.UserID, otherID)
 		if err != nil {
-			InternalError(c, err)
+			handleMessageError(c, err)
 			return
 		}
 
 		// 构建参与者信息
-		var participant *ParticipantResponse
-		if conv.Participant.ID != auth.UserID {
-			participant = &ParticipantResponse{
-				ID:       conv.Participant.ID,
-				Username: conv.Participant.Username,
-				Nickname: conv.Participant.Nickname,
-			}
-		}
+		participant := toParticipantResponse(conv, auth.UserID)
 
 		c.JSON(200, ConversationDetailResponse{
-			ID:         conv.ID,
-			Participant: participant,
-			CreatedAt:  conv.CreatedAt,
-			UpdatedAt:   conv.UpdatedAt,
+			ID:             conv.ID,
+			Type:           conv.Type,
+			Title:          conv.Title,
+			Members:        conv.Members,
+			MemberCount:    conv.MemberCount,
+			Participant:    participant,
+			DisappearAfter: conv.DisappearAfter,
+			Pins:           conv.Pins,
+			Draft:          conv.Draft,
+			CreatedAt:      conv.CreatedAt,
+			UpdatedAt:      conv.UpdatedAt,
 		})
 	}
 }
@@ -192,9 +251,8 @@ func handleMarkAsRead(svc message.Service) gin.HandlerFunc {
 		}
 
 		// 调用消息服务
-		err = svc.MarkAsRead(id, auth.UserID)
-		if err != nil {
-			InternalError(c, err)
+		if _, err := svc.MarkAsRead(id, auth.UserID); err != nil {
+			handleMessageError(c, err)
 			return
 		}
 
@@ -202,33 +260,275 @@ func handleMarkAsRead(svc message.Service) gin.HandlerFunc {
 	}
 }
 
+// handleGetPinnedMessages 处理获取会话置顶消息
+func handleGetPinnedMessages(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		pins, err := svc.GetPinnedMessages(id, auth.UserID)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		SuccessList(c, pins, len(pins))
+	}
+}
+
+// SaveDraftRequest 保存草稿请求
+type SaveDraftRequest struct {
+	Content   string `json:"content"` // 为空时清除草稿
+	ReplyToID int64  `json:"reply_to_id,omitempty"`
+}
+
+// handleSaveDraft 处理保存或清除草稿（DELETE 等同于保存空草稿）
+// 变更由消息服务同步到用户的其他 SSE/WS 连接
+func handleSaveDraft(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		var req SaveDraftRequest
+		if c.Request.Method != "DELETE" {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				BadRequest(c, "无效的请求格式")
+				return
+			}
+		}
+
+		draft, err := svc.SaveDraft(&message.SaveDraftRequest{
+			UserID:         auth.UserID,
+			ConversationID: id,
+			Content:        req.Content,
+			ReplyToID:      req.ReplyToID,
+		})
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		Success(c, draft)
+	}
+}
+
+// UpdateConversationSettingsRequest 更新会话个人设置请求（未提供的字段保持不变）
+type UpdateConversationSettingsRequest struct {
+	Archived   *bool  `json:"archived"`
+	MutedUntil *int64 `json:"muted_until"` // 免打扰截止时间（Unix秒），0为取消
+	Hidden     *bool  `json:"hidden"`
+	Pinned     *bool  `json:"pinned"`
+}
+
+// handleUpdateConversationSettings 处理更新会话个人设置（归档、免打扰、隐藏、置顶）
+// 变更由消息服务同步到用户的其他 SSE 连接
+func handleUpdateConversationSettings(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		var req UpdateConversationSettingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		settings, err := svc.UpdateConversationSettings(&message.UpdateConversationSettingsRequest{
+			UserID:         auth.UserID,
+			ConversationID: id,
+			Archived:       req.Archived,
+			MutedUntil:     req.MutedUntil,
+			Hidden:         req.Hidden,
+			Pinned:         req.Pinned,
+		})
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		Success(c, settings)
+	}
+}
+
+// ClearHistoryRequest 清空聊天记录请求
+type ClearHistoryRequest struct {
+	UpToMessageID int64 `json:"up_to_message_id"` // 清空到该消息（含），为0时清空到最新消息
+}
+
+// handleClearHistory 处理为自己清空会话的聊天记录（对方的记录和分享链接不受影响）
+func handleClearHistory(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		// 请求体可省略
+		var req ClearHistoryRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				BadRequest(c, "无效的请求格式")
+				return
+			}
+		}
+
+		cleared, err := svc.ClearHistory(id, auth.UserID, req.UpToMessageID)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		Success(c, gin.H{
+			"conversation_id":    id,
+			"cleared_message_id": cleared,
+		})
+	}
+}
+
+// SetDisappearingTimerRequest 设置阅后即焚请求
+type SetDisappearingTimerRequest struct {
+	Seconds int64 `json:"seconds"` // 3600、86400、604800，0为关闭
+}
+
+// DisappearingTimerResponse 设置阅后即焚响应
+type DisappearingTimerResponse struct {
+	ConversationID int64            `json:"conversation_id"`
+	DisappearAfter int64            `json:"disappear_after"`
+	Notice         *MessageResponse `json:"notice,omitempty"` // 发布到会话中的系统通知（未变化时为空）
+}
+
+// handleSetDisappearingTimer 处理设置会话阅后即焚时长
+func handleSetDisappearingTimer(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		var req SetDisappearingTimerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		notice, err := svc.SetDisappearingTimer(id, auth.UserID, req.Seconds)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		resp := DisappearingTimerResponse{ConversationID: id, DisappearAfter: req.Seconds}
+		if notice != nil {
+			noticeResp := toMessageResponse(notice)
+			resp.Notice = &noticeResp
+		}
+
+		c.JSON(200, resp)
+	}
+}
+
 // handleMessageError 处理消息服务错误
 func handleMessageError(c *gin.Context, err error) {
-	switch err.Error() {
-	case "MSG_CONVERSATION_NOT_FOUND":
+	switch err {
+	case message.ErrConversationNotFound:
 		NotFound(c, "会话不存在")
-	case "MSG_USER_NOT_FOUND":
+	case message.ErrUserNotFound:
 		NotFound(c, "用户不存在")
+	case message.ErrMessageNotFound:
+		NotFound(c, "消息不存在")
+	case message.ErrAccessDenied:
+		Forbidden(c, "无权访问该会话")
+	case message.ErrRecallWindowExpired:
+		Forbidden(c, "已超过撤回时限")
+	case message.ErrPinLimitReached:
+		Conflict(c, "置顶数已达上限")
+	case message.ErrInvalidMessageType, message.ErrInvalidMessageContent, message.ErrSendToSelf, message.ErrMessageNotEditable, message.ErrInvalidReply, message.ErrInvalidReaction, message.ErrInvalidClientMsgID, message.ErrInvalidStatus, message.ErrInvalidSearchQuery, message.ErrInvalidDisappearTimer, message.ErrInvalidDraft, message.ErrInvalidForward, message.ErrInvalidSettings:
+		BadRequest(c, err.Error())
 	default:
 		InternalError(c, err)
 	}
 }
 
+// toParticipantResponse 构建单聊对方信息（群聊返回nil）
+func toParticipantResponse(conv *models.ConversationWithInfo, userID int64) *ParticipantResponse {
+	if conv.Participant == nil || conv.Participant.ID == userID {
+		return nil
+	}
+	return &ParticipantResponse{
+		ID:       conv.Participant.ID,
+		Username: conv.Participant.Username,
+		Nickname: conv.Participant.Nickname,
+	}
+}
+
 // ConversationResponse 会话响应
 type ConversationResponse struct {
-	ID           int64                `json:"id"`
-	Participant   *ParticipantResponse   `json:"participant"`
-	LastMessage  *LastMessageResponse  `json:"last_message"`
-	UnreadCount  int                  `json:"unread_count"`
-	UpdatedAt    int64                `json:"updated_at"`
+	ID                    int64                        `json:"id"`
+	Type                  string                       `json:"type"`
+	Title                 string                       `json:"title,omitempty"`
+	MemberCount           int                          `json:"member_count"`
+	Participant           *ParticipantResponse         `json:"participant"`
+	LastMessage           *LastMessageResponse         `json:"last_message"`
+	UnreadCount           int                          `json:"unread_count"`
+	PeerLastReadMessageID int64                        `json:"peer_last_read_message_id,omitempty"` // 单聊对方的已读位置
+	DisappearAfter        int64                        `json:"disappear_after"`
+	Pins                  []*models.PinnedMessage      `json:"pins,omitempty"`
+	Draft                 *models.Draft                `json:"draft,omitempty"`
+	Settings              *models.ConversationSettings `json:"settings,omitempty"`
+	UpdatedAt             int64                        `json:"updated_at"`
 }
 
 // ConversationDetailResponse 会话详情响应
 type ConversationDetailResponse struct {
-	ID         int64              `json:"id"`
-	Participant *ParticipantResponse `json:"participant"`
-	CreatedAt  int64              `json:"created_at"`
-	UpdatedAt  int64              `json:"updated_at"`
+	ID                    int64                        `json:"id"`
+	Type                  string                       `json:"type"`
+	Title                 string                       `json:"title,omitempty"`
+	Members               []*models.MemberInfo         `json:"members,omitempty"`
+	MemberCount           int                          `json:"member_count"`
+	Participant           *ParticipantResponse         `json:"participant"`
+	PeerLastReadMessageID int64                        `json:"peer_last_read_message_id,omitempty"`
+	DisappearAfter        int64                        `json:"disappear_after"`
+	Pins                  []*models.PinnedMessage      `json:"pins,omitempty"`
+	Draft                 *models.Draft                `json:"draft,omitempty"`
+	Settings              *models.ConversationSettings `json:"settings,omitempty"`
+	CreatedAt             int64                        `json:"created_at"`
+	UpdatedAt             int64                        `json:"updated_at"`
 }
 
 // ParticipantResponse 参与者响应
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/models"
+	"zmessage/server/modules/group"
+	"zmessage/server/modules/user"
+)
+
+// RegisterGroupRoutes 注册群聊路由
+func RegisterGroupRoutes(r *gin.Engine, groupSvc group.Service, userSvc user.Service) {
+	groups := r.Group("/api/groups")
+	groups.Use(AuthMiddleware(userSvc))
+	{
+		groups.POST("", handleCreateGroup(groupSvc))
+		groups.PUT("/:id", handleUpdateGroup(groupSvc))
+		groups.GET("/:id/members", handleGetGroupMembers(groupSvc))
+		groups.POST("/:id/members", handleAddGroupMembers(groupSvc))
+		groups.DELETE("/:id/members/:user_id", handleRemoveGroupMember(groupSvc))
+		groups.PUT("/:id/members/:user_id/role", handleSetGroupMemberRole(groupSvc))
+		groups.POST("/:id/leave", handleLeaveGroup(groupSvc))
+	}
+}
+
+// handleCreateGroup 处理创建群聊
+func handleCreateGroup(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		var req group.CreateGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		conv, err := svc.CreateGroup(auth.UserID, &req)
+		if err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		c.JSON(200, toGroupResponse(conv))
+	}
+}
+
+// handleUpdateGroup 处理更新群资料
+func handleUpdateGroup(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		groupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的群ID")
+			return
+		}
+
+		var req group.UpdateGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		conv, err := svc.UpdateGroup(groupID, auth.UserID, &req)
+		if err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		c.JSON(200, toGroupResponse(conv))
+	}
+}
+
+// handleGetGroupMembers 处理获取群成员列表
+func handleGetGroupMembers(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		groupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的群ID")
+			return
+		}
+
+		members, err := svc.GetMembers(groupID, auth.UserID)
+		if err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		SuccessList(c, members, len(members))
+	}
+}
+
+// handleAddGroupMembers 处理添加群成员
+func handleAddGroupMembers(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		groupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的群ID")
+			return
+		}
+
+		var req AddGroupMembersRequest
+		if err := c.ShouldBindJSON(&req); err != nil || len(req.UserIDs) == 0 {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		added, err := svc.AddMembers(groupID, auth.UserID, req.UserIDs)
+		if err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		SuccessList(c, added, len(added))
+	}
+}
+
+// handleRemoveGroupMember 处理移除群成员
+func handleRemoveGroupMember(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		groupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的群ID")
+			return
+		}
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的用户ID")
+			return
+		}
+
+		if err := svc.RemoveMember(groupID, auth.UserID, userID); err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"success": true})
+	}
+}
+
+// handleSetGroupMemberRole 处理设置成员角色
+func handleSetGroupMemberRole(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		groupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的群ID")
+			return
+		}
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的用户ID")
+			return
+		}
+
+		var req SetMemberRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		if err := svc.SetRole(groupID, auth.UserID, userID, req.Role); err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"success": true})
+	}
+}
+
+// handleLeaveGroup 处理退出群聊
+func handleLeaveGroup(svc group.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		groupID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的群ID")
+			return
+		}
+
+		if err := svc.Leave(groupID, auth.UserID); err != nil {
+			handleGroupError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"success": true})
+	}
+}
+
+// handleGroupError 处理群聊服务错误
+func handleGroupError(c *gin.Context, err error) {
+	switch err {
+	case group.ErrGroupNotFound:
+		NotFound(c, "群聊不存在")
+	case group.ErrUserNotFound:
+		NotFound(c, "用户不存在")
+	case group.ErrNotMember:
+		Forbidden(c, "不是群成员")
+	case group.ErrPermissionDenied:
+		Forbidden(c, "权限不足")
+	case group.ErrCannotRemoveOwner:
+		Forbidden(c, "不能移除群主")
+	case group.ErrInvalidTitle, group.ErrInvalidAvatar, group.ErrInvalidRole, group.ErrTooManyMembers, group.ErrAlreadyMember:
+		BadRequest(c, err.Error())
+	default:
+		InternalError(c, err)
+	}
+}
+
+// toGroupResponse 转换群聊响应
+func toGroupResponse(conv *models.Conversation) GroupResponse {
+	return GroupResponse{
+		ID:        conv.ID,
+		Type:      conv.Type,
+		Title:     conv.Title,
+		AvatarID:  conv.AvatarID,
+		CreatedBy: conv.CreatedBy,
+		CreatedAt: conv.CreatedAt,
+		UpdatedAt: conv.UpdatedAt,
+	}
+}
+
+// AddGroupMembersRequest 添加群成员请求
+type AddGroupMembersRequest struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// SetMemberRoleRequest 设置成员角色请求
+type SetMemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// GroupResponse 群聊响应
+type GroupResponse struct {
+	ID        int64  `json:"id"`
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	AvatarID  *int64 `json:"avatar_id,omitempty"`
+	CreatedBy int64  `json:"created_by"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
@@ -1,12 +1,18 @@
 package api
 
 import (
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"zmessage/server/dal"
 	"zmessage/server/models"
 	"zmessage/server/modules/media"
 )
@@ -14,7 +20,7 @@ import (
 // setupTestRouter 设置测试路由
 func setupTestRouter() *gin.Engine {
 	r := gin.New()
-	RegisterMediaRoutes(r, &mockMediaService{})
+	RegisterMediaRoutes(r, &mockMediaService{}, nil)
 	return r
 }
 
@@ -64,3 +70,42 @@ func TestHandleUpload_NoToken(t *testing.T) {
 
 	assert.Equal(t, 401, w.Code)
 }
+
+func TestHandleGetMedia_FileIsDownloaded(t *testing.T) {
+	dataDir := t.TempDir()
+	mgr, err := dal.NewManager(dataDir)
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+	svc := media.NewService(mgr, filepath.Join(dataDir, "media"))
+
+	u := &models.User{Username: "alice", PasswordHash: "hash", Nickname: "Alice"}
+	if err := mgr.User().Create(u); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// 客户端声明为 HTML 的通用文件
+	path := filepath.Join(dataDir, "page.html")
+	os.WriteFile(path, []byte("<script>alert(1)</script>"), 0644)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	header := &multipart.FileHeader{Filename: "page.html", Header: textproto.MIMEHeader{"Content-Type": {"text/html"}}}
+	m, err := svc.Upload(&media.UploadRequest{File: file, Header: header, Type: "file", OwnerID: u.ID})
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	assert.Equal(t, media.FileMimeType, m.MimeType)
+
+	r := gin.New()
+	RegisterMediaRoutes(r, svc, nil)
+	req, _ := http.NewRequest("GET", "/api/media/"+strconv.FormatInt(m.ID, 10), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
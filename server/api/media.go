@@ -60,11 +60,14 @@ func handleUpload(svc media.Service) gin.HandlerFunc {
 			return
 		}
 
-		// 验证文件类型
-		mediaType, err := svc.ValidateType(fileHeader.Filename, fileHeader.Header.Get("Content-Type"))
-		if err != nil {
-			c.JSON(400, ErrorResponse{Error: err.Error()})
-			return
+		// 验证文件类型（通用文件不限扩展名）
+		mediaType := fileType
+		if fileType != "file" {
+			mediaType, err = svc.ValidateType(fileHeader.Filename, fileHeader.Header.Get("Content-Type"))
+			if err != nil {
+				c.JSON(400, ErrorResponse{Error: err.Error()})
+				return
+			}
 		}
 
 		// 获取文件大小（从FileHeader）
@@ -115,7 +118,7 @@ func handleGetMedia(svc media.Service) gin.HandlerFunc {
 		}
 
 		// 获取媒体信息（需要MIME类型）
-		media, err := svc.Get(id)
+		m, err := svc.Get(id)
 		if err != nil {
 			handleMediaError(c, err)
 			return
@@ -128,16 +131,11 @@ func handleGetMedia(svc media.Service) gin.HandlerFunc {
 			return
 		}
 
-		// 手动设置Content-Type（因为文件可能没有扩展名）
-		if media.MimeType != "" {
-			c.Header("Content-Type", media.MimeType)
-		} else {
-			// 根据类型设置默认MIME类型
-			if media.Type == "image" {
-				c.Header("Content-Type", "image/jpeg")
-			} else if media.Type == "voice" {
-				c.Header("Content-Type", "audio/webm")
-			}
+		// 手动设置Content-Type（因为文件可能没有扩展名）；通用文件强制下载，禁止浏览器嗅探内容类型
+		c.Header("Content-Type", media.ContentType(m))
+		c.Header("X-Content-Type-Options", "nosniff")
+		if m.Type == "file" {
+			c.Header("Content-Disposition", "attachment")
 		}
 
 		// 发送文件
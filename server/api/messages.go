@@ -5,17 +5,29 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"zmessage/server/models"
 	"zmessage/server/modules/message"
 	"zmessage/server/modules/user"
 )
 
 // RegisterMessageRoutes 注册消息路由
-func RegisterMessageRoutes(r *gin.Engine, msgSvc message.Service, userSvc user.Service, wsMgr WSManager) {
+func RegisterMessageRoutes(r *gin.Engine, msgSvc message.Service, userSvc user.Service) {
 	msg := r.Group("/api/conversations/:id/messages")
 	msg.Use(AuthMiddleware(userSvc))
 	{
 		msg.GET("", handleGetMessages(msgSvc))
 		msg.POST("", handleSendMessage(msgSvc))
+		msg.POST("/forward", handleForwardMessages(msgSvc))
+		msg.PUT("/:mid", handleEditMessage(msgSvc))
+		msg.GET("/:mid/revisions", handleGetMessageRevisions(msgSvc))
+		msg.POST("/:mid/recall", handleRecallMessage(msgSvc))
+		msg.DELETE("/:mid", handleDeleteMessage(msgSvc))
+		msg.POST("/:mid/reactions", handleAddReaction(msgSvc))
+		msg.DELETE("/:mid/reactions/:emoji", handleRemoveReaction(msgSvc))
+		msg.POST("/:mid/pin", handlePinMessage(msgSvc, true))
+		msg.DELETE("/:mid/pin", handlePinMessage(msgSvc, false))
+		msg.POST("/:mid/star", handleStarMessage(msgSvc, true))
+		msg.DELETE("/:mid/star", handleStarMessage(msgSvc, false))
 	}
 }
 
@@ -50,7 +62,7 @@ func handleGetMessages(svc message.Service) gin.HandlerFunc {
 		// 调用消息服务
 		messages, hasMore, err := svc.GetMessages(convID, auth.UserID, beforeID, limit)
 		if err != nil {
-			InternalError(c, err)
+			handleMessageError(c, err)
 			return
 		}
 
@@ -58,14 +70,21 @@ func handleGetMessages(svc message.Service) gin.HandlerFunc {
 		msgList := make([]MessageResponse, len(messages))
 		for i, m := range messages {
 			msgList[i] = MessageResponse{
-				ID:            m.ID,
+				ID:             m.ID,
 				ConversationID: m.ConversationID,
-				SenderID:      m.SenderID,
-				ReceiverID:    m.ReceiverID,
-				Type:          m.Type,
-				Content:       m.Content,
-				Status:        m.Status,
-				CreatedAt:     m.CreatedAt,
+				SenderID:       m.SenderID,
+				ReceiverID:     m.ReceiverID,
+				Type:           m.Type,
+				Content:        m.Content,
+				Status:         m.Status,
+				CreatedAt:      m.CreatedAt,
+				EditedAt:       m.EditedAt,
+				ReplyToID:      m.ReplyToID,
+				ReplyTo:        m.ReplyTo,
+				Reactions:      m.Reactions,
+				ExpiresAt:      m.ExpiresAt,
+				Starred:        m.Starred,
+				ForwardedFrom:  m.ForwardedFrom,
 			}
 		}
 
@@ -79,25 +98,57 @@ func handleGetMessages(svc message.Service) gin.HandlerFunc {
 // MessagesResponse 消息列表响应
 type MessagesResponse struct {
 	Messages []MessageResponse `json:"messages"`
-	HasMore  bool            `json:"has_more"`
+	HasMore  bool              `json:"has_more"`
 }
 
 // MessageResponse 消息响应
 type MessageResponse struct {
-	ID            int64  `json:"id"`
-	ConversationID int64  `json:"conversation_id"`
-	SenderID      int64  `json:"sender_id"`
-	ReceiverID    int64  `json:"receiver_id"`
-	Type          string `json:"type"`
-	Content       string `json:"content"`
-	Status        string `json:"status"`
-	CreatedAt     int64  `json:"created_at"`
+	ID             int64                     `json:"id"`
+	ConversationID int64                     `json:"conversation_id"`
+	SenderID       int64                     `json:"sender_id"`
+	ReceiverID     int64                     `json:"receiver_id"`
+	Type           string                    `json:"type"`
+	Content        string                    `json:"content"`
+	Status         string                    `json:"status"`
+	CreatedAt      int64                     `json:"created_at"`
+	EditedAt       *int64                    `json:"edited_at,omitempty"`
+	ReplyToID      int64                     `json:"reply_to_id,omitempty"`
+	ReplyTo        *models.ReplyPreview      `json:"reply_to,omitempty"`
+	Reactions      []*models.ReactionSummary `json:"reactions,omitempty"`
+	ClientMsgID    string                    `json:"client_msg_id,omitempty"`
+	ExpiresAt      *int64                    `json:"expires_at,omitempty"`     // 阅后即焚过期时间
+	Starred        bool                      `json:"starred,omitempty"`        // 当前用户是否已收藏
+	ForwardedFrom  *models.ForwardInfo       `json:"forwarded_from,omitempty"` // 转发来源
+}
+
+// toMessageResponse 转换消息响应
+func toMessageResponse(m *models.Message) MessageResponse {
+	return MessageResponse{
+		ID:             m.ID,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		ReceiverID:     m.ReceiverID,
+		Type:           m.Type,
+		Content:        m.Content,
+		Status:         m.Status,
+		CreatedAt:      m.CreatedAt,
+		EditedAt:       m.EditedAt,
+		ReplyToID:      m.ReplyToID,
+		ReplyTo:        m.ReplyTo,
+		Reactions:      m.Reactions,
+		ClientMsgID:    m.ClientMsgID,
+		ExpiresAt:      m.ExpiresAt,
+		Starred:        m.Starred,
+		ForwardedFrom:  m.ForwardedFrom,
+	}
 }
 
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
-	Type    string `json:"type"`
-	Content string `json:"content"`
+	Type        string `json:"type"`
+	Content     string `json:"content"`
+	ReplyToID   int64  `json:"reply_to_id,omitempty"`   // 引用的消息ID（可选）
+	ClientMsgID string `json:"client_msg_id,omitempty"` // 客户端消息ID（可选，重发时返回已存储的消息）
 }
 
 // handleSendMessage 处理发送消息
@@ -132,42 +183,314 @@ func handleSendMessage(svc message.Service) gin.HandlerFunc {
 			return
 		}
 
-		// 获取会话信息以确定接收者
-		conv, err := svc.GetConversation(convID, auth.UserID)
+		// 调用消息服务发送（由服务根据会话确定接收者）
+		msg, err := svc.SendMessage(&message.SendMessageRequest{
+			From:           auth.UserID,
+			ConversationID: convID,
+			Type:           req.Type,
+			Content:        req.Content,
+			ReplyToID:      req.ReplyToID,
+			ClientMsgID:    req.ClientMsgID,
+		})
 		if err != nil {
-			InternalError(c, err)
+			handleMessageError(c, err)
 			return
 		}
 
-		// 确定接收者ID
-		var receiverID int64
-		if conv.UserAID == auth.UserID {
-			receiverID = conv.UserBID
-		} else {
-			receiverID = conv.UserAID
+		c.JSON(200, MessageResponse{
+			ID:             msg.ID,
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			ReceiverID:     msg.ReceiverID,
+			Type:           msg.Type,
+			Content:        msg.Content,
+			Status:         msg.Status,
+			CreatedAt:      msg.CreatedAt,
+			EditedAt:       msg.EditedAt,
+			ReplyToID:      msg.ReplyToID,
+			ReplyTo:        msg.ReplyTo,
+			ClientMsgID:    msg.ClientMsgID,
+			ExpiresAt:      msg.ExpiresAt,
+		})
+	}
+}
+
+// ForwardMessagesRequest 转发消息请求
+type ForwardMessagesRequest struct {
+	TargetConversationID int64   `json:"target_conversation_id"`
+	MessageIDs           []int64 `json:"message_ids"`
+}
+
+// handleForwardMessages 处理将当前会话的消息转发到其他会话
+func handleForwardMessages(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
 		}
 
-		// 调用消息服务发送
-		msg, err := svc.SendMessage(&message.SendMessageRequest{
-			From:    auth.UserID,
-			To:      receiverID,
-			Type:    req.Type,
-			Content: req.Content,
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		var req ForwardMessagesRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.TargetConversationID == 0 || len(req.MessageIDs) == 0 {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		messages, err := svc.ForwardMessages(&message.ForwardMessagesRequest{
+			UserID:               auth.UserID,
+			SourceConversationID: convID,
+			MessageIDs:           req.MessageIDs,
+			TargetConversationID: req.TargetConversationID,
+		})
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		result := make([]MessageResponse, len(messages))
+		for i, msg := range messages {
+			result[i] = toMessageResponse(msg)
+		}
+
+		SuccessList(c, result, len(result))
+	}
+}
+
+// EditMessageRequest 编辑消息请求
+type EditMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// handleEditMessage 处理编辑消息
+func handleEditMessage(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的消息ID")
+			return
+		}
+
+		var req EditMessageRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Content == "" {
+			BadRequest(c, "消息内容不能为空")
+			return
+		}
+
+		msg, err := svc.EditMessage(&message.EditMessageRequest{
+			UserID:         auth.UserID,
+			ConversationID: convID,
+			MessageID:      msgID,
+			Content:        req.Content,
 		})
 		if err != nil {
-			InternalError(c, err)
+			handleMessageError(c, err)
 			return
 		}
 
 		c.JSON(200, MessageResponse{
-			ID:            msg.ID,
+			ID:             msg.ID,
 			ConversationID: msg.ConversationID,
-			SenderID:      msg.SenderID,
-			ReceiverID:    msg.ReceiverID,
-			Type:          msg.Type,
-			Content:       msg.Content,
-			Status:        msg.Status,
-			CreatedAt:     msg.CreatedAt,
+			SenderID:       msg.SenderID,
+			ReceiverID:     msg.ReceiverID,
+			Type:           msg.Type,
+			Content:        msg.Content,
+			Status:         msg.Status,
+			CreatedAt:      msg.CreatedAt,
+			EditedAt:       msg.EditedAt,
+		})
+	}
+}
+
+// handleGetMessageRevisions 处理获取消息修订记录
+func handleGetMessageRevisions(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的消息ID")
+			return
+		}
+
+		revisions, err := svc.GetMessageRevisions(convID, msgID, auth.UserID)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		SuccessList(c, revisions, len(revisions))
+	}
+}
+
+// handleRecallMessage 处理撤回消息（对所有人）
+func handleRecallMessage(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的消息ID")
+			return
+		}
+
+		msg, err := svc.RecallMessage(convID, msgID, auth.UserID)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		Success(c, map[string]interface{}{
+			"message_id":  msg.ID,
+			"recalled_at": msg.RecalledAt,
 		})
 	}
 }
+
+// handleDeleteMessage 处理删除消息（仅自己）
+func handleDeleteMessage(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的消息ID")
+			return
+		}
+
+		if _, err := svc.DeleteMessageForMe(convID, msgID, auth.UserID); err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"success": true})
+	}
+}
+
+// ReactionRequest 表情回应请求
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// handleAddReaction 处理添加表情回应
+func handleAddReaction(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ReactionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+		handleReaction(c, svc, req.Emoji, false)
+	}
+}
+
+// handleRemoveReaction 处理移除表情回应
+func handleRemoveReaction(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handleReaction(c, svc, c.Param("emoji"), true)
+	}
+}
+
+// handleReaction 添加或移除表情回应并通知其他参与者
+func handleReaction(c *gin.Context, svc message.Service, emoji string, remove bool) {
+	auth := GetAuthContext(c)
+	if auth == nil {
+		return
+	}
+
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的会话ID")
+		return
+	}
+	msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的消息ID")
+		return
+	}
+
+	var msg *models.Message
+	if remove {
+		msg, err = svc.RemoveReaction(convID, msgID, auth.UserID, emoji)
+	} else {
+		msg, err = svc.AddReaction(convID, msgID, auth.UserID, emoji)
+	}
+	if err != nil {
+		handleMessageError(c, err)
+		return
+	}
+
+	SuccessList(c, msg.Reactions, len(msg.Reactions))
+}
+
+// handlePinMessage 处理置顶或取消置顶消息，返回最新的置顶列表
+func handlePinMessage(svc message.Service, pin bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的消息ID")
+			return
+		}
+
+		var pins []*models.PinnedMessage
+		if pin {
+			pins, err = svc.PinMessage(convID, msgID, auth.UserID)
+		} else {
+			pins, err = svc.UnpinMessage(convID, msgID, auth.UserID)
+		}
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		SuccessList(c, pins, len(pins))
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/modules/poll"
+	"zmessage/server/modules/user"
+)
+
+// RegisterPollRoutes 注册投票路由（投票消息本身通过发送消息接口创建，type 为 poll）
+func RegisterPollRoutes(r *gin.Engine, pollSvc poll.Service, userSvc user.Service) {
+	p := r.Group("/api/conversations/:id/messages/:mid/poll")
+	p.Use(AuthMiddleware(userSvc))
+	{
+		p.GET("", handleGetPollResults(pollSvc))
+		p.POST("/vote", handleVotePoll(pollSvc))
+		p.POST("/close", handleClosePoll(pollSvc))
+	}
+}
+
+// VotePollRequest 投票请求
+type VotePollRequest struct {
+	Options []int `json:"options"` // 选项下标，为空时撤回投票
+}
+
+// handleVotePoll 处理投票（结果由投票服务推送给参与者）
+func handleVotePoll(svc poll.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, msgID, ok := parsePollParams(c)
+		if !ok {
+			return
+		}
+
+		var req VotePollRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		results, err := svc.Vote(&poll.VoteRequest{
+			UserID:         auth.UserID,
+			ConversationID: convID,
+			MessageID:      msgID,
+			Options:        req.Options,
+		})
+		if err != nil {
+			handlePollError(c, err)
+			return
+		}
+
+		Success(c, results)
+	}
+}
+
+// handleClosePoll 处理关闭投票
+func handleClosePoll(svc poll.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, msgID, ok := parsePollParams(c)
+		if !ok {
+			return
+		}
+
+		results, err := svc.Close(convID, msgID, auth.UserID)
+		if err != nil {
+			handlePollError(c, err)
+			return
+		}
+
+		Success(c, results)
+	}
+}
+
+// handleGetPollResults 处理获取投票结果
+func handleGetPollResults(svc poll.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, msgID, ok := parsePollParams(c)
+		if !ok {
+			return
+		}
+
+		results, err := svc.GetResults(convID, msgID, auth.UserID)
+		if err != nil {
+			handlePollError(c, err)
+			return
+		}
+
+		Success(c, results)
+	}
+}
+
+// parsePollParams 解析会话ID和投票消息ID
+func parsePollParams(c *gin.Context) (int64, int64, bool) {
+	convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的会话ID")
+		return 0, 0, false
+	}
+	msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+	if err != nil {
+		BadRequest(c, "无效的消息ID")
+		return 0, 0, false
+	}
+	return convID, msgID, true
+}
+
+// handlePollError 处理投票服务错误
+func handlePollError(c *gin.Context, err error) {
+	switch err {
+	case poll.ErrPollNotFound:
+		NotFound(c, "投票不存在")
+	case poll.ErrAccessDenied:
+		Forbidden(c, "无权操作该投票")
+	case poll.ErrPollClosed:
+		Conflict(c, "投票已结束")
+	case poll.ErrInvalidVote:
+		BadRequest(c, err.Error())
+	default:
+		InternalError(c, err)
+	}
+}
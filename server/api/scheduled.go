@@ -0,0 +1,135 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/modules/schedule"
+	"zmessage/server/modules/user"
+)
+
+// RegisterScheduledRoutes 注册定时消息路由
+func RegisterScheduledRoutes(r *gin.Engine, schedSvc schedule.Service, userSvc user.Service) {
+	sched := r.Group("/api/scheduled-messages")
+	sched.Use(AuthMiddleware(userSvc))
+	{
+		sched.GET("", handleListScheduled(schedSvc))
+		sched.POST("", handleCreateScheduled(schedSvc))
+		sched.PUT("/:id", handleUpdateScheduled(schedSvc))
+		sched.DELETE("/:id", handleCancelScheduled(schedSvc))
+	}
+}
+
+// handleListScheduled 处理获取定时消息列表（默认只返回待发送的）
+func handleListScheduled(svc schedule.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		status := c.DefaultQuery("status", "pending")
+		if status == "all" {
+			status = ""
+		}
+
+		list, err := svc.List(auth.UserID, status)
+		if err != nil {
+			handleScheduleError(c, err)
+			return
+		}
+
+		SuccessList(c, list, len(list))
+	}
+}
+
+// handleCreateScheduled 处理创建定时消息
+func handleCreateScheduled(svc schedule.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		var req schedule.ScheduleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		sm, err := svc.Schedule(auth.UserID, &req)
+		if err != nil {
+			handleScheduleError(c, err)
+			return
+		}
+
+		c.JSON(200, sm)
+	}
+}
+
+// handleUpdateScheduled 处理修改定时消息
+func handleUpdateScheduled(svc schedule.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的定时消息ID")
+			return
+		}
+
+		var req schedule.UpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		sm, err := svc.Update(id, auth.UserID, &req)
+		if err != nil {
+			handleScheduleError(c, err)
+			return
+		}
+
+		c.JSON(200, sm)
+	}
+}
+
+// handleCancelScheduled 处理取消定时消息
+func handleCancelScheduled(svc schedule.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的定时消息ID")
+			return
+		}
+
+		if err := svc.Cancel(id, auth.UserID); err != nil {
+			handleScheduleError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"success": true})
+	}
+}
+
+// handleScheduleError 处理定时消息服务错误（会话校验错误沿用消息服务的处理）
+func handleScheduleError(c *gin.Context, err error) {
+	switch err {
+	case schedule.ErrScheduledNotFound:
+		NotFound(c, "定时消息不存在")
+	case schedule.ErrNotPending:
+		Conflict(c, "定时消息已发送或已取消")
+	case schedule.ErrInvalidSendTime, schedule.ErrInvalidContent:
+		BadRequest(c, err.Error())
+	default:
+		handleMessageError(c, err)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/modules/message"
+	"zmessage/server/modules/user"
+)
+
+// RegisterSearchRoutes 注册搜索路由
+func RegisterSearchRoutes(r *gin.Engine, msgSvc message.Service, userSvc user.Service) {
+	search := r.Group("/api/search")
+	search.Use(AuthMiddleware(userSvc))
+	{
+		search.GET("/messages", handleSearchMessages(msgSvc))
+	}
+}
+
+// handleSearchMessages 处理消息搜索
+// 查询参数：q 关键词，conversation_id/sender_id/type 过滤，from/to 时间范围（Unix秒），cursor 上一页返回的 next_cursor
+func handleSearchMessages(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		req := &message.SearchMessagesRequest{
+			UserID: auth.UserID,
+			Query:  c.Query("q"),
+			Type:   c.Query("type"),
+		}
+
+		var err error
+		if req.ConversationID, err = parseOptionalInt64(c, "conversation_id"); err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		if req.SenderID, err = parseOptionalInt64(c, "sender_id"); err != nil {
+			BadRequest(c, "无效的发送者ID")
+			return
+		}
+		if req.From, err = parseOptionalInt64(c, "from"); err != nil {
+			BadRequest(c, "无效的起始时间")
+			return
+		}
+		if req.To, err = parseOptionalInt64(c, "to"); err != nil {
+			BadRequest(c, "无效的截止时间")
+			return
+		}
+		if req.BeforeID, err = parseOptionalInt64(c, "cursor"); err != nil {
+			BadRequest(c, "无效的游标")
+			return
+		}
+		req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+		hits, hasMore, err := svc.SearchMessages(req)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		results := make([]SearchResultResponse, len(hits))
+		for i, h := range hits {
+			results[i] = SearchResultResponse{
+				Message: toMessageResponse(h.Message),
+				Snippet: h.Snippet,
+			}
+		}
+
+		resp := SearchMessagesResponse{
+			Results: results,
+			HasMore: hasMore,
+		}
+		if hasMore {
+			resp.NextCursor = hits[len(hits)-1].Message.ID
+		}
+		c.JSON(200, resp)
+	}
+}
+
+// parseOptionalInt64 解析可选的整数查询参数，缺省为0
+func parseOptionalInt64(c *gin.Context, key string) (int64, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// SearchMessagesResponse 消息搜索响应
+type SearchMessagesResponse struct {
+	Results    []SearchResultResponse `json:"results"`
+	HasMore    bool                   `json:"has_more"`
+	NextCursor int64                  `json:"next_cursor,omitempty"`
+}
+
+// SearchResultResponse 单条搜索结果
+type SearchResultResponse struct {
+	Message MessageResponse `json:"message"`
+	Snippet string          `json:"snippet"` // 高亮片段，命中部分以 <mark></mark> 包裹
+}
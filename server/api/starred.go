@@ -0,0 +1,116 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/modules/message"
+	"zmessage/server/modules/user"
+)
+
+// RegisterStarredRoutes 注册收藏路由
+func RegisterStarredRoutes(r *gin.Engine, msgSvc message.Service, userSvc user.Service) {
+	starred := r.Group("/api/starred")
+	starred.Use(AuthMiddleware(userSvc))
+	{
+		starred.GET("", handleGetStarredMessages(msgSvc))
+	}
+}
+
+// handleGetStarredMessages 处理获取收藏列表
+// 查询参数：conversation_id/type 过滤，cursor 上一页返回的 next_cursor
+func handleGetStarredMessages(svc message.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		req := &message.StarredMessagesRequest{
+			UserID: auth.UserID,
+			Type:   c.Query("type"),
+		}
+
+		var err error
+		if req.ConversationID, err = parseOptionalInt64(c, "conversation_id"); err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		if req.BeforeID, err = parseOptionalInt64(c, "cursor"); err != nil {
+			BadRequest(c, "无效的游标")
+			return
+		}
+		req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+		stars, hasMore, err := svc.GetStarredMessages(req)
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		results := make([]StarredMessageResponse, len(stars))
+		for i, s := range stars {
+			results[i] = StarredMessageResponse{
+				ID:        s.ID,
+				Message:   toMessageResponse(s.Message),
+				StarredAt: s.StarredAt,
+			}
+		}
+
+		resp := StarredMessagesResponse{
+			Results: results,
+			HasMore: hasMore,
+		}
+		if hasMore {
+			resp.NextCursor = stars[len(stars)-1].ID
+		}
+		c.JSON(200, resp)
+	}
+}
+
+// handleStarMessage 处理收藏或取消收藏消息（仅自己可见，不通知其他参与者）
+func handleStarMessage(svc message.Service, star bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+		msgID, err := strconv.ParseInt(c.Param("mid"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的消息ID")
+			return
+		}
+
+		if star {
+			err = svc.StarMessage(convID, msgID, auth.UserID)
+		} else {
+			err = svc.UnstarMessage(convID, msgID, auth.UserID)
+		}
+		if err != nil {
+			handleMessageError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"starred": star})
+	}
+}
+
+// StarredMessagesResponse 收藏列表响应
+type StarredMessagesResponse struct {
+	Results    []StarredMessageResponse `json:"results"`
+	HasMore    bool                     `json:"has_more"`
+	NextCursor int64                    `json:"next_cursor,omitempty"`
+}
+
+// StarredMessageResponse 单条收藏
+type StarredMessageResponse struct {
+	ID        int64           `json:"id"`
+	Message   MessageResponse `json:"message"`
+	StarredAt int64           `json:"starred_at"`
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/modules/changelog"
+	"zmessage/server/modules/user"
+)
+
+// RegisterSyncRoutes 注册增量同步路由
+func RegisterSyncRoutes(r *gin.Engine, changeSvc changelog.Service, userSvc user.Service) {
+	sync := r.Group("/api/sync")
+	sync.Use(AuthMiddleware(userSvc))
+	{
+		sync.GET("", handleGetChanges(changeSvc))
+	}
+}
+
+// handleGetChanges 处理增量同步
+// 查询参数：cursor 上次同步返回的 cursor（首次为0），limit 每页数量
+// has_more 为 true 时用返回的 cursor 继续拉取；resync_required 为 true 时需重新拉取数据后从返回的 cursor 继续
+func handleGetChanges(svc changelog.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		cursor, err := parseOptionalInt64(c, "cursor")
+		if err != nil {
+			BadRequest(c, "无效的游标")
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(changelog.DefaultLimit)))
+
+		set, err := svc.GetChanges(auth.UserID, cursor, limit)
+		if err != nil {
+			handleChangeLogError(c, err)
+			return
+		}
+
+		c.JSON(200, set)
+	}
+}
+
+// handleChangeLogError 处理变更日志服务错误
+func handleChangeLogError(c *gin.Context, err error) {
+	switch err {
+	case changelog.ErrInvalidCursor:
+		BadRequest(c, "无效的游标")
+	default:
+		InternalError(c, err)
+	}
+}
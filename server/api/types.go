@@ -31,7 +31,7 @@ type Service interface {
 type WSManager interface {
 	// HandleConnection 处理WebSocket连接
 	HandleConnection(conn interface{})
-	// BroadcastToUser 向用户广播消息
+	// BroadcastToUser 向用户的所有连接广播消息（部分连接失败不影响其他连接）
 	BroadcastToUser(userID int64, message interface{}) error
 	// IsOnline 检查用户是否在线
 	IsOnline(userID int64) bool
@@ -163,6 +163,11 @@ func Forbidden(c *gin.Context, message string) {
 	Error(c, 403, message)
 }
 
+// Conflict 409错误
+func Conflict(c *gin.Context, message string) {
+	Error(c, 409, message)
+}
+
 // InternalError 500错误
 func InternalError(c *gin.Context, err error) {
 	Error(c, 500, "INTERNAL_ERROR")
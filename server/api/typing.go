@@ -0,0 +1,97 @@
+package api
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/modules/typing"
+	"zmessage/server/modules/user"
+)
+
+// RegisterTypingRoutes 注册输入状态路由（供 SSE 客户端使用，WS 客户端直接发送输入状态消息）
+func RegisterTypingRoutes(r *gin.Engine, typingSvc typing.Service, userSvc user.Service) {
+	conv := r.Group("/api/conversations/:id/typing")
+	conv.Use(AuthMiddleware(userSvc))
+	{
+		conv.GET("", handleGetTyping(typingSvc))
+		conv.POST("", handleSetTyping(typingSvc))
+	}
+}
+
+// TypingRequest 输入状态请求
+type TypingRequest struct {
+	Typing bool `json:"typing"` // true 开始输入（需定期重复发送），false 停止输入
+}
+
+// handleSetTyping 处理开始/停止输入
+func handleSetTyping(svc typing.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		var req TypingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			BadRequest(c, "无效的请求格式")
+			return
+		}
+
+		if req.Typing {
+			err = svc.Start(convID, auth.UserID)
+		} else {
+			err = svc.Stop(convID, auth.UserID)
+		}
+		if err != nil {
+			handleTypingError(c, err)
+			return
+		}
+
+		Success(c, map[string]bool{"success": true})
+	}
+}
+
+// handleGetTyping 处理获取会话中正在输入的用户
+func handleGetTyping(svc typing.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := GetAuthContext(c)
+		if auth == nil {
+			return
+		}
+
+		convID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			BadRequest(c, "无效的会话ID")
+			return
+		}
+
+		users, err := svc.GetTyping(convID, auth.UserID)
+		if err != nil {
+			handleTypingError(c, err)
+			return
+		}
+		if users == nil {
+			users = []int64{}
+		}
+
+		Success(c, map[string][]int64{"user_ids": users})
+	}
+}
+
+// handleTypingError 处理输入状态服务错误
+func handleTypingError(c *gin.Context, err error) {
+	switch err {
+	case typing.ErrConversationNotFound:
+		NotFound(c, "会话不存在")
+	case typing.ErrAccessDenied:
+		Forbidden(c, "无权访问该会话")
+	default:
+		InternalError(c, err)
+	}
+}
@@ -20,13 +20,17 @@ func (a *WSAdapter) HandleConnection(conn interface{}) {
 	a.mgr.HandleConnection(conn)
 }
 
-// BroadcastToUser 向用户广播消息
+// BroadcastToUser 向用户的所有连接广播消息，返回发送失败的连接汇总
 func (a *WSAdapter) BroadcastToUser(userID int64, message interface{}) error {
 	wsMsg, ok := message.(*protocol.WSMessage)
 	if !ok {
 		return nil // 忽略非WSMessage类型
 	}
-	return a.mgr.BroadcastToUser(userID, wsMsg)
+	report, err := a.mgr.BroadcastToUser(userID, wsMsg)
+	if err != nil {
+		return err
+	}
+	return report.Err()
 }
 
 // IsOnline 检查用户是否在线
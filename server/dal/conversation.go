@@ -6,6 +6,12 @@ import (
 	"zmessage/server/models"
 )
 
+// conversationColumns 会话查询列（单聊以外的 user_a_id/user_b_id 为空，统一返回0）
+const conversationColumns = `
+	id, type, COALESCE(user_a_id, 0), COALESCE(user_b_id, 0), title, avatar_id,
+	COALESCE(created_by, 0), created_at, updated_at, disappear_after
+`
+
 type conversationDAL struct {
 	db DB
 }
@@ -14,18 +20,29 @@ func NewConversationDAL(db DB) ConversationDAL {
 	return &conversationDAL{db: db}
 }
 
+// Create 创建单聊会话，双方同时登记为会话成员
 func (d *conversationDAL) Create(conv *models.Conversation) error {
 	// 确保 user_a_id < user_b_id 以保证唯一性
 	if conv.UserAID > conv.UserBID {
 		conv.UserAID, conv.UserBID = conv.UserBID, conv.UserAID
 	}
+	conv.Type = models.ConversationTypeDirect
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
 	query := `
-		INSERT INTO conversations (user_a_id, user_b_id, created_at, updated_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO conversations (type, user_a_id, user_b_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
 	`
-	result, err := d.db.Exec(query, conv.UserAID, conv.UserBID, conv.CreatedAt, conv.UpdatedAt)
+	result, err := tx.Exec(query, conv.Type, conv.UserAID, conv.UserBID, conv.CreatedAt, conv.UpdatedAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
 		return fmt.Errorf("create conversation: %w", err)
 	}
 
@@ -34,23 +51,68 @@ func (d *conversationDAL) Create(conv *models.Conversation) error {
 		return fmt.Errorf("get last insert id: %w", err)
 	}
 
+	for _, userID := range []int64{conv.UserAID, conv.UserBID} {
+		if err := insertMember(tx, id, userID, models.MemberRoleMember, conv.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit conversation: %w", err)
+	}
+
 	conv.ID = id
 	return nil
 }
 
-func (d *conversationDAL) GetByID(id int64) (*models.Conversation, error) {
+// CreateGroup 创建群聊会话，创建者为群主，其余用户为普通成员
+func (d *conversationDAL) CreateGroup(conv *models.Conversation, memberIDs []int64) error {
+	conv.Type = models.ConversationTypeGroup
+	conv.UserAID, conv.UserBID = 0, 0
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		SELECT id, user_a_id, user_b_id, created_at, updated_at
-		FROM conversations WHERE id = ?
+		INSERT INTO conversations (type, title, avatar_id, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
-	conv := &models.Conversation{}
-	err := d.db.QueryRow(query, id).Scan(
-		&conv.ID,
-		&conv.UserAID,
-		&conv.UserBID,
-		&conv.CreatedAt,
-		&conv.UpdatedAt,
-	)
+	result, err := tx.Exec(query, conv.Type, conv.Title, conv.AvatarID, conv.CreatedBy, conv.CreatedAt, conv.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("create group: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("get last insert id: %w", err)
+	}
+
+	if err := insertMember(tx, id, conv.CreatedBy, models.MemberRoleOwner, conv.CreatedAt); err != nil {
+		return err
+	}
+	for _, userID := range memberIDs {
+		if userID == conv.CreatedBy {
+			continue
+		}
+		if err := insertMember(tx, id, userID, models.MemberRoleMember, conv.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit group: %w", err)
+	}
+
+	conv.ID = id
+	return nil
+}
+
+func (d *conversationDAL) GetByID(id int64) (*models.Conversation, error) {
+	query := `SELECT ` + conversationColumns + ` FROM conversations WHERE id = ?`
+	conv, err := scanConversation(d.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -67,17 +129,10 @@ func (d *conversationDAL) GetByUsers(userA, userB int64) (*models.Conversation,
 	}
 
 	query := `
-		SELECT id, user_a_id, user_b_id, created_at, updated_at
-		FROM conversations WHERE user_a_id = ? AND user_b_id = ?
+		SELECT ` + conversationColumns + `
+		FROM conversations WHERE type = 'direct' AND user_a_id = ? AND user_b_id = ?
 	`
-	conv := &models.Conversation{}
-	err := d.db.QueryRow(query, userA, userB).Scan(
-		&conv.ID,
-		&conv.UserAID,
-		&conv.UserBID,
-		&conv.CreatedAt,
-		&conv.UpdatedAt,
-	)
+	conv, err := scanConversation(d.db.QueryRow(query, userA, userB))
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
@@ -88,14 +143,44 @@ func (d *conversationDAL) GetByUsers(userA, userB int64) (*models.Conversation,
 }
 
 func (d *conversationDAL) GetByUser(userID int64, page, limit int) ([]*models.Conversation, int, error) {
-	// 获取总数
-	countQuery := `
-		SELECT COUNT(*)
+	return d.ListByUser(&models.ConversationQuery{UserID: userID, Page: page, Limit: limit})
+}
+
+// ListByUser 按个人设置过滤用户的会话，置顶的在前（按置顶时间），其余按更新时间
+func (d *conversationDAL) ListByUser(q *models.ConversationQuery) ([]*models.Conversation, int, error) {
+	where := `cm.user_id = ?`
+	args := []interface{}{q.UserID}
+	if q.Archived != nil {
+		where += ` AND cm.archived = ?`
+		args = append(args, *q.Archived)
+	}
+	if q.Hidden != nil {
+		where += ` AND cm.hidden = ?`
+		args = append(args, *q.Hidden)
+	}
+	if q.Muted != nil {
+		if *q.Muted {
+			where += ` AND cm.muted_until > ?`
+		} else {
+			where += ` AND cm.muted_until <= ?`
+		}
+		args = append(args, q.Now)
+	}
+	if q.Pinned != nil {
+		if *q.Pinned {
+			where += ` AND cm.pinned_at > 0`
+		} else {
+			where += ` AND cm.pinned_at = 0`
+		}
+	}
+	from := `
 		FROM conversations
-		WHERE user_a_id = ? OR user_b_id = ?
-	`
+		JOIN conversation_members cm ON cm.conversation_id = conversations.id
+		WHERE ` + where
+
+	// 获取总数
 	var total int
-	err := d.db.QueryRow(countQuery, userID, userID).Scan(&total)
+	err := d.db.QueryRow(`SELECT COUNT(*) `+from, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("count conversations: %w", err)
 	}
@@ -105,15 +190,13 @@ func (d *conversationDAL) GetByUser(userID int64, page, limit int) ([]*models.Co
 	}
 
 	// 获取列表
-	offset := (page - 1) * limit
+	offset := (q.Page - 1) * q.Limit
 	query := `
-		SELECT id, user_a_id, user_b_id, created_at, updated_at
-		FROM conversations
-		WHERE user_a_id = ? OR user_b_id = ?
-		ORDER BY updated_at DESC
+		SELECT ` + conversationColumns + from + `
+		ORDER BY cm.pinned_at DESC, updated_at DESC
 		LIMIT ? OFFSET ?
 	`
-	rows, err := d.db.Query(query, userID, userID, limit, offset)
+	rows, err := d.db.Query(query, append(args, q.Limit, offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("list conversations: %w", err)
 	}
@@ -121,14 +204,7 @@ func (d *conversationDAL) GetByUser(userID int64, page, limit int) ([]*models.Co
 
 	var convs []*models.Conversation
 	for rows.Next() {
-		conv := &models.Conversation{}
-		err := rows.Scan(
-			&conv.ID,
-			&conv.UserAID,
-			&conv.UserBID,
-			&conv.CreatedAt,
-			&conv.UpdatedAt,
-		)
+		conv, err := scanConversation(rows)
 		if err != nil {
 			return nil, 0, fmt.Errorf("scan conversation: %w", err)
 		}
@@ -141,10 +217,17 @@ func (d *conversationDAL) GetByUser(userID int64, page, limit int) ([]*models.Co
 func (d *conversationDAL) Update(conv *models.Conversation) error {
 	query := `
 		UPDATE conversations
-		SET user_a_id = ?, user_b_id = ?, updated_at = ?
+		SET user_a_id = ?, user_b_id = ?, title = ?, avatar_id = ?, updated_at = ?
 		WHERE id = ?
 	`
-	result, err := d.db.Exec(query, conv.UserAID, conv.UserBID, conv.UpdatedAt, conv.ID)
+	result, err := d.db.Exec(query,
+		nullableID(conv.UserAID),
+		nullableID(conv.UserBID),
+		conv.Title,
+		conv.AvatarID,
+		conv.UpdatedAt,
+		conv.ID,
+	)
 	if err != nil {
 		return fmt.Errorf("update conversation: %w", err)
 	}
@@ -178,9 +261,37 @@ func (d *conversationDAL) UpdateTime(id int64, updatedAt int64) error {
 	return nil
 }
 
+// SetDisappearAfter 设置会话的阅后即焚时长（秒，0为关闭）
+func (d *conversationDAL) SetDisappearAfter(id int64, seconds int64, updatedAt int64) error {
+	query := `UPDATE conversations SET disappear_after = ?, updated_at = ? WHERE id = ?`
+	result, err := d.db.Exec(query, seconds, updatedAt, id)
+	if err != nil {
+		return fmt.Errorf("set disappear after: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 func (d *conversationDAL) Delete(id int64) error {
-	query := `DELETE FROM conversations WHERE id = ?`
-	result, err := d.db.Exec(query, id)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM conversation_members WHERE conversation_id = ?`, id); err != nil {
+		return fmt.Errorf("delete conversation members: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM conversations WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("delete conversation: %w", err)
 	}
@@ -193,5 +304,26 @@ func (d *conversationDAL) Delete(id int64) error {
 		return ErrNotFound
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// scanConversation 扫描一行会话数据
+func scanConversation(row rowScanner) (*models.Conversation, error) {
+	conv := &models.Conversation{}
+	err := row.Scan(
+		&conv.ID,
+		&conv.Type,
+		&conv.UserAID,
+		&conv.UserBID,
+		&conv.Title,
+		&conv.AvatarID,
+		&conv.CreatedBy,
+		&conv.CreatedAt,
+		&conv.UpdatedAt,
+		&conv.DisappearAfter,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return conv, nil
 }
@@ -0,0 +1,72 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"zmessage/server/models"
+)
+
+type draftDAL struct {
+	db DB
+}
+
+func NewDraftDAL(db DB) DraftDAL {
+	return &draftDAL{db: db}
+}
+
+// Save 保存草稿（已存在时覆盖）
+func (d *draftDAL) Save(draft *models.Draft) error {
+	query := `
+		INSERT INTO drafts (user_id, conversation_id, content, reply_to_id, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, conversation_id) DO UPDATE SET
+			content = excluded.content,
+			reply_to_id = excluded.reply_to_id,
+			updated_at = excluded.updated_at
+	`
+	_, err := d.db.Exec(query, draft.UserID, draft.ConversationID, draft.Content, nullableID(draft.ReplyToID), draft.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("save draft: %w", err)
+	}
+	return nil
+}
+
+func (d *draftDAL) Get(userID int64, convID int64) (*models.Draft, error) {
+	query := `
+		SELECT user_id, conversation_id, content, COALESCE(reply_to_id, 0), updated_at
+		FROM drafts WHERE user_id = ? AND conversation_id = ?
+	`
+	draft := &models.Draft{}
+	err := d.db.QueryRow(query, userID, convID).Scan(
+		&draft.UserID,
+		&draft.ConversationID,
+		&draft.Content,
+		&draft.ReplyToID,
+		&draft.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get draft: %w", err)
+	}
+	return draft, nil
+}
+
+// Delete 删除草稿（不存在时返回 ErrNotFound）
+func (d *draftDAL) Delete(userID int64, convID int64) error {
+	result, err := d.db.Exec(`DELETE FROM drafts WHERE user_id = ? AND conversation_id = ?`, userID, convID)
+	if err != nil {
+		return fmt.Errorf("delete draft: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -1,6 +1,10 @@
 package dal
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
 
 var (
 	// ErrNotFound 记录不存在
@@ -25,3 +29,13 @@ func IsDuplicate(err error) bool {
 func IsNotFound(err error) bool {
 	return err == ErrNotFound
 }
+
+// isUniqueViolation 判断数据库错误是否为唯一约束冲突
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
@@ -0,0 +1,83 @@
+package dal
+
+import (
+	"fmt"
+	"zmessage/server/models"
+)
+
+type eventLogDAL struct {
+	db DB
+}
+
+func NewEventLogDAL(db DB) EventLogDAL {
+	return &eventLogDAL{db: db}
+}
+
+// Append 追加事件，事件ID为该用户当前最大ID加一，并清理超出保留数量的旧事件
+func (d *eventLogDAL) Append(entry *models.EventLogEntry, keep int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	if err := tx.QueryRow(`SELECT COALESCE(MAX(event_id), 0) + 1 FROM event_log WHERE user_id = ?`, entry.UserID).Scan(&id); err != nil {
+		return fmt.Errorf("get next event id: %w", err)
+	}
+
+	query := `
+		INSERT INTO event_log (user_id, event_id, type, data, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	if _, err := tx.Exec(query, entry.UserID, id, entry.Type, entry.Data, entry.CreatedAt); err != nil {
+		return fmt.Errorf("insert event: %w", err)
+	}
+
+	// 至少保留最新一条，保证事件ID不会回退
+	if keep < 1 {
+		keep = 1
+	}
+	if _, err := tx.Exec(`DELETE FROM event_log WHERE user_id = ? AND event_id <= ?`, entry.UserID, id-int64(keep)); err != nil {
+		return fmt.Errorf("trim event log: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	entry.ID = id
+	return nil
+}
+
+func (d *eventLogDAL) ListAfter(userID int64, afterID int64, limit int) ([]*models.EventLogEntry, error) {
+	query := `
+		SELECT user_id, event_id, type, data, created_at
+		FROM event_log WHERE user_id = ? AND event_id > ?
+		ORDER BY event_id ASC LIMIT ?
+	`
+	rows, err := d.db.Query(query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.EventLogEntry
+	for rows.Next() {
+		entry := &models.EventLogEntry{}
+		if err := rows.Scan(&entry.UserID, &entry.ID, &entry.Type, &entry.Data, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (d *eventLogDAL) Bounds(userID int64) (int64, int64, error) {
+	var oldest, latest int64
+	query := `SELECT COALESCE(MIN(event_id), 0), COALESCE(MAX(event_id), 0) FROM event_log WHERE user_id = ?`
+	if err := d.db.QueryRow(query, userID).Scan(&oldest, &latest); err != nil {
+		return 0, 0, fmt.Errorf("get event bounds: %w", err)
+	}
+	return oldest, latest, nil
+}
@@ -0,0 +1,22 @@
+package dal
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows 的扫描接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// nullableID 将0值ID转换为NULL（用于可空的外键列）
+func nullableID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullableString 将空字符串转换为NULL（用于可空且带唯一约束的列）
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -25,6 +25,9 @@ type Manager interface {
 	// Conversation 会话数据访问
 	Conversation() ConversationDAL
 
+	// ConversationMember 会话成员数据访问
+	ConversationMember() ConversationMemberDAL
+
 	// Message 消息数据访问
 	Message() MessageDAL
 
@@ -34,6 +37,30 @@ type Manager interface {
 	// SharedConversation 分享数据访问
 	SharedConversation() SharedConversationDAL
 
+	// Reaction 表情回应数据访问
+	Reaction() ReactionDAL
+
+	// Search 消息搜索数据访问
+	Search() SearchDAL
+
+	// ScheduledMessage 定时消息数据访问
+	ScheduledMessage() ScheduledMessageDAL
+
+	// Pin 置顶消息数据访问
+	Pin() PinDAL
+
+	// Star 收藏消息数据访问
+	Star() StarDAL
+
+	// Draft 草稿数据访问
+	Draft() DraftDAL
+
+	// Poll 投票数据访问
+	Poll() PollDAL
+
+	// EventLog 事件日志（按用户的变更日志）数据访问
+	EventLog() EventLogDAL
+
 	// Close 关闭数据库连接
 	Close() error
 }
@@ -53,34 +80,131 @@ type UserDAL interface {
 // ConversationDAL 会话数据访问接口
 type ConversationDAL interface {
 	Create(conv *models.Conversation) error
+	CreateGroup(conv *models.Conversation, memberIDs []int64) error
 	GetByID(id int64) (*models.Conversation, error)
 	GetByUsers(userA, userB int64) (*models.Conversation, error)
 	GetByUser(userID int64, page, limit int) ([]*models.Conversation, int, error)
+	ListByUser(q *models.ConversationQuery) ([]*models.Conversation, int, error)
 	Update(conv *models.Conversation) error
 	UpdateTime(id int64, updatedAt int64) error
+	SetDisappearAfter(id int64, seconds int64, updatedAt int64) error
 	Delete(id int64) error
 }
 
+// ConversationMemberDAL 会话成员数据访问接口
+type ConversationMemberDAL interface {
+	Add(member *models.ConversationMember) error
+	Get(convID int64, userID int64) (*models.ConversationMember, error)
+	GetByConversation(convID int64) ([]*models.ConversationMember, error)
+	UpdateRole(convID int64, userID int64, role string) error
+	UpdateLastRead(convID int64, userID int64, messageID int64) (int64, error)
+	GetSettings(convID int64, userID int64) (*models.ConversationSettings, error)
+	UpdateSettings(convID int64, userID int64, settings *models.ConversationSettings) error
+	CountPinned(userID int64) (int, error)
+	RestoreOnNewMessage(convID int64, now int64) error
+	ClearHistory(convID int64, userID int64, messageID int64) error
+	Remove(convID int64, userID int64) error
+	RemoveOwner(convID int64, ownerID int64, successorID int64) error
+	Count(convID int64) (int, error)
+}
+
 // MessageDAL 消息数据访问接口
 type MessageDAL interface {
 	Create(msg *models.Message) error
 	GetByID(id int64) (*models.Message, error)
+	GetVisibleByID(id int64, userID int64) (*models.Message, error)
+	GetByClientMsgID(senderID int64, clientMsgID string) (*models.Message, error)
 	GetByConversation(convID int64, beforeID int64, limit int) ([]*models.Message, error)
+	GetVisibleByConversation(convID int64, userID int64, beforeID int64, limit int) ([]*models.Message, error)
 	GetOfflineMessages(userID int64, lastID int64, limit int) ([]*models.Message, error)
 	Update(msg *models.Message) error
 	UpdateStatus(id int64, status string) error
-	UpdateMessagesStatus(convID int64, receiverID int64, status string) error
+	AdvanceStatus(receiverID int64, ids []int64, status string) ([]*models.Message, error)
+	GetReadRange(convID int64, receiverID int64, afterID int64, upToID int64) ([]*models.Message, error)
+	Edit(id int64, content string, editedAt int64) error
+	GetRevisions(messageID int64) ([]*models.MessageRevision, error)
+	Recall(id int64, recalledAt int64) error
+	DeleteForUser(id int64, userID int64, deletedAt int64) error
 	CountUnread(convID int64, userID int64) (int, error)
 	CountTotalUnread(userID int64) (int, error)
+	GetExpired(now int64, limit int) ([]*models.Message, error)
 	Delete(id int64) error
 }
 
+// ReactionDAL 表情回应数据访问接口
+type ReactionDAL interface {
+	Add(reaction *models.Reaction) error
+	Remove(messageID int64, userID int64, emoji string) error
+	GetByMessages(messageIDs []int64) ([]*models.Reaction, error)
+}
+
+// PinDAL 置顶消息数据访问接口
+type PinDAL interface {
+	Add(pin *models.MessagePin) error
+	Remove(convID int64, messageID int64) error
+	GetByConversation(convID int64) ([]*models.MessagePin, error)
+	Count(convID int64) (int, error)
+}
+
+// StarDAL 收藏消息数据访问接口
+type StarDAL interface {
+	Add(star *models.MessageStar) error
+	Remove(userID int64, messageID int64) error
+	RemoveByMessage(messageID int64) error
+	GetByUser(q *models.StarredMessageQuery) ([]*models.StarredMessage, error)
+	GetStarredIDs(userID int64, messageIDs []int64) (map[int64]bool, error)
+}
+
+// DraftDAL 草稿数据访问接口
+type DraftDAL interface {
+	Save(draft *models.Draft) error
+	Get(userID int64, convID int64) (*models.Draft, error)
+	Delete(userID int64, convID int64) error
+}
+
+// PollDAL 投票数据访问接口
+type PollDAL interface {
+	SetVotes(messageID int64, userID int64, options []int, votedAt int64) error
+	GetVotes(messageID int64) ([]*models.PollVote, error)
+	Close(closure *models.PollClosure) error
+	GetClosure(messageID int64) (*models.PollClosure, error)
+}
+
+// EventLogDAL 事件日志（按用户的变更日志）数据访问接口
+type EventLogDAL interface {
+	// Append 追加事件并分配用户内递增的事件ID，只保留该用户最近 keep 条事件
+	Append(entry *models.EventLogEntry, keep int) error
+	// ListAfter 获取事件ID大于 afterID 的事件（按ID升序）
+	ListAfter(userID int64, afterID int64, limit int) ([]*models.EventLogEntry, error)
+	// Bounds 获取用户仍保留的最早和最新事件ID（没有事件时均为0）
+	Bounds(userID int64) (oldest int64, latest int64, err error)
+}
+
+// SearchDAL 消息搜索数据访问接口
+type SearchDAL interface {
+	SearchMessages(q *models.MessageSearchQuery) ([]*models.MessageSearchHit, error)
+}
+
+// ScheduledMessageDAL 定时消息数据访问接口
+type ScheduledMessageDAL interface {
+	Create(sm *models.ScheduledMessage) error
+	GetByID(id int64) (*models.ScheduledMessage, error)
+	GetBySender(senderID int64, status string) ([]*models.ScheduledMessage, error)
+	GetDue(now int64, limit int) ([]*models.ScheduledMessage, error)
+	Update(sm *models.ScheduledMessage) error
+	Claim(id int64, now int64) error
+	MarkSent(id int64, messageID int64, now int64) error
+	MarkFailed(id int64, reason string, now int64) error
+	Cancel(id int64, now int64) error
+}
+
 // MediaDAL 媒体数据访问接口
 type MediaDAL interface {
 	Create(media *models.Media) error
 	GetByID(id int64) (*models.Media, error)
 	GetByOwner(ownerID int64) ([]*models.Media, error)
 	Update(media *models.Media) error
+	IsReferenced(id int64) (bool, error)
 	Delete(id int64) error
 }
 
@@ -94,12 +94,21 @@ CREATE INDEX IF NOT EXISTS idx_media_type ON media(type);
 
 // manager 数据库管理器实现
 type manager struct {
-	db    *sql.DB
-	user  UserDAL
-	conv  ConversationDAL
-	msg   MessageDAL
-	media MediaDAL
-	shared SharedConversationDAL
+	db        *sql.DB
+	user      UserDAL
+	conv      ConversationDAL
+	member    ConversationMemberDAL
+	msg       MessageDAL
+	media     MediaDAL
+	shared    SharedConversationDAL
+	reaction  ReactionDAL
+	search    SearchDAL
+	scheduled ScheduledMessageDAL
+	pin       PinDAL
+	star      StarDAL
+	draft     DraftDAL
+	poll      PollDAL
+	eventLog  EventLogDAL
 }
 
 // NewManager 创建数据库管理器
@@ -126,19 +135,41 @@ func NewManager(dataDir string) (Manager, error) {
 		return nil, fmt.Errorf("create schema: %w", err)
 	}
 
+	// 执行迁移
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate schema: %w", err)
+	}
+
 	// 启用外键约束
 	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("enable foreign keys: %w", err)
 	}
 
+	// 全文索引（SQLite 未编译 FTS5 时退化为 LIKE 匹配）
+	fts, err := ensureSearchIndex(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create search index: %w", err)
+	}
+
 	m := &manager{
-		db:    db,
-		user:  NewUserDAL(db),
-		conv:  NewConversationDAL(db),
-		msg:   NewMessageDAL(db),
-		media: NewMediaDAL(db),
-		shared: NewSharedConversationDAL(db),
+		db:        db,
+		user:      NewUserDAL(db),
+		conv:      NewConversationDAL(db),
+		member:    NewConversationMemberDAL(db),
+		msg:       NewMessageDAL(db),
+		media:     NewMediaDAL(db),
+		shared:    NewSharedConversationDAL(db),
+		reaction:  NewReactionDAL(db),
+		search:    NewSearchDAL(db, fts),
+		scheduled: NewScheduledMessageDAL(db),
+		pin:       NewPinDAL(db),
+		star:      NewStarDAL(db),
+		draft:     NewDraftDAL(db),
+		poll:      NewPollDAL(db),
+		eventLog:  NewEventLogDAL(db),
 	}
 
 	return m, nil
@@ -159,6 +190,11 @@ func (m *manager) Conversation() ConversationDAL {
 	return m.conv
 }
 
+// ConversationMember 会话成员数据访问
+func (m *manager) ConversationMember() ConversationMemberDAL {
+	return m.member
+}
+
 // Message 消息数据访问
 func (m *manager) Message() MessageDAL {
 	return m.msg
@@ -174,6 +210,46 @@ func (m *manager) SharedConversation() SharedConversationDAL {
 	return m.shared
 }
 
+// Reaction 表情回应数据访问
+func (m *manager) Reaction() ReactionDAL {
+	return m.reaction
+}
+
+// Search 消息搜索数据访问
+func (m *manager) Search() SearchDAL {
+	return m.search
+}
+
+// ScheduledMessage 定时消息数据访问
+func (m *manager) ScheduledMessage() ScheduledMessageDAL {
+	return m.scheduled
+}
+
+// Pin 置顶消息数据访问
+func (m *manager) Pin() PinDAL {
+	return m.pin
+}
+
+// Star 收藏消息数据访问
+func (m *manager) Star() StarDAL {
+	return m.star
+}
+
+// Draft 草稿数据访问
+func (m *manager) Draft() DraftDAL {
+	return m.draft
+}
+
+// Poll 投票数据访问
+func (m *manager) Poll() PollDAL {
+	return m.poll
+}
+
+// EventLog 事件日志（按用户的变更日志）数据访问
+func (m *manager) EventLog() EventLogDAL {
+	return m.eventLog
+}
+
 // Close 关闭数据库连接
 func (m *manager) Close() error {
 	return m.db.Close()
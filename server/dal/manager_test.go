@@ -1,6 +1,8 @@
 package dal
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,8 +50,76 @@ func TestManager_NewManager(t *testing.T) {
 	if mgr.SharedConversation() == nil {
 		t.Error("SharedConversation() returned nil")
 	}
+	if mgr.ConversationMember() == nil {
+		t.Error("ConversationMember() returned nil")
+	}
 }
 
+func TestManager_MigrateIdempotent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	mgr, err := NewManager(tmpDir)
+	if err != nil {
+		t.Fatalf("create manager: %v", err)
+	}
+	mgr.Close()
+
+	// 重新打开同一数据库，已应用的迁移不应重复执行
+	mgr, err = NewManager(tmpDir)
+	if err != nil {
+		t.Fatalf("reopen manager: %v", err)
+	}
+	defer mgr.Close()
+
+	var count int
+	if err := mgr.DB().QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatalf("count migrations: %v", err)
+	}
+	if count != len(migrations) {
+		t.Errorf("expected %d migrations, got %d", len(migrations), count)
+	}
+}
+
+func TestManager_MigrateReadCursors(t *testing.T) {
+	mgr := setupTestDB(t)
+	defer mgr.Close()
+
+	now := time.Now().Unix()
+	alice := &models.User{Username: "alice", PasswordHash: "hash", Nickname: "Alice", CreatedAt: now}
+	bob := &models.User{Username: "bob", PasswordHash: "hash", Nickname: "Bob", CreatedAt: now}
+	mgr.User().Create(alice)
+	mgr.User().Create(bob)
+	conv := &models.Conversation{UserAID: alice.ID, UserBID: bob.ID, CreatedAt: now, UpdatedAt: now}
+	if err := mgr.Conversation().Create(conv); err != nil {
+		t.Fatalf("create conversation: %v", err)
+	}
+
+	// 旧数据：已读状态写在消息上
+	var ids []int64
+	for _, status := range []string{"read", "read", "delivered"} {
+		msg := &models.Message{ConversationID: conv.ID, SenderID: alice.ID, ReceiverID: bob.ID, Type: "text", Content: "hi", Status: status, CreatedAt: now}
+		if err := mgr.Message().Create(msg); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		ids = append(ids, msg.ID)
+	}
+
+	if _, err := mgr.DB().Exec(readCursorsMigration); err != nil {
+		t.Fatalf("run read cursors migration: %v", err)
+	}
+
+	member, _ := mgr.ConversationMember().Get(conv.ID, bob.ID)
+	if member.LastReadMessageID != ids[1] {
+		t.Errorf("expected cursor backfilled to %d, got %d", ids[1], member.LastReadMessageID)
+	}
+	if count, _ := mgr.Message().CountUnread(conv.ID, bob.ID); count != 1 {
+		t.Errorf("expected 1 unread after migration, got %d", count)
+	}
+	sender, _ := mgr.ConversationMember().Get(conv.ID, alice.ID)
+	if sender.LastReadMessageID != 0 {
+		t.Errorf("expected sender cursor untouched, got %d", sender.LastReadMessageID)
+	}
+}
 
 func TestUserDAL(t *testing.T) {
 	mgr := setupTestDB(t)
@@ -264,6 +334,118 @@ func TestConversationDAL(t *testing.T) {
 	}
 }
 
+func TestConversationMemberDAL(t *testing.T) {
+	mgr := setupTestDB(t)
+	defer mgr.Close()
+	memberDAL := mgr.ConversationMember()
+	userDAL := mgr.User()
+
+	var users []*models.User
+	for _, name := range []string{"owner", "member1", "member2"} {
+		u := &models.User{
+			Username:     name,
+			PasswordHash: "hash",
+			Nickname:     name,
+			CreatedAt:    time.Now().Unix(),
+			LastSeen:     time.Now().Unix(),
+		}
+		if err := userDAL.Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	// 测试创建群聊
+	group := &models.Conversation{
+		Title:     "Test Group",
+		CreatedBy: users[0].ID,
+		CreatedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}
+	if err := mgr.Conversation().CreateGroup(group, []int64{users[1].ID}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	fetched, err := mgr.Conversation().GetByID(group.ID)
+	if err != nil {
+		t.Fatalf("get group: %v", err)
+	}
+	if !fetched.IsGroup() || fetched.Title != "Test Group" {
+		t.Errorf("unexpected group: %+v", fetched)
+	}
+
+	owner, err := memberDAL.Get(group.ID, users[0].ID)
+	if err != nil {
+		t.Fatalf("get owner: %v", err)
+	}
+	if owner.Role != models.MemberRoleOwner {
+		t.Errorf("expected role owner, got %s", owner.Role)
+	}
+
+	// 测试添加成员
+	err = memberDAL.Add(&models.ConversationMember{
+		ConversationID: group.ID,
+		UserID:         users[2].ID,
+		Role:           models.MemberRoleMember,
+		JoinedAt:       time.Now().Unix(),
+	})
+	if err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	// 重复添加应返回ErrDuplicate
+	err = memberDAL.Add(&models.ConversationMember{
+		ConversationID: group.ID,
+		UserID:         users[2].ID,
+		Role:           models.MemberRoleMember,
+		JoinedAt:       time.Now().Unix(),
+	})
+	if err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate, got: %v", err)
+	}
+
+	count, err := memberDAL.Count(group.ID)
+	if err != nil {
+		t.Fatalf("count members: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 members, got %d", count)
+	}
+
+	// 群聊出现在成员的会话列表中
+	_, total, err := mgr.Conversation().GetByUser(users[2].ID, 1, 10)
+	if err != nil {
+		t.Fatalf("get conversations by user: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("expected total 1, got %d", total)
+	}
+
+	// 测试更新角色
+	if err := memberDAL.UpdateRole(group.ID, users[1].ID, models.MemberRoleAdmin); err != nil {
+		t.Fatalf("update role: %v", err)
+	}
+
+	// 已读位置只前进不后退
+	memberDAL.UpdateLastRead(group.ID, users[1].ID, 10)
+	memberDAL.UpdateLastRead(group.ID, users[1].ID, 5)
+	member, _ := memberDAL.Get(group.ID, users[1].ID)
+	if member.Role != models.MemberRoleAdmin {
+		t.Errorf("expected role admin, got %s", member.Role)
+	}
+	if member.LastReadMessageID != 10 {
+		t.Errorf("expected last_read_message_id 10, got %d", member.LastReadMessageID)
+	}
+
+	// 测试移除成员
+	if err := memberDAL.Remove(group.ID, users[2].ID); err != nil {
+		t.Fatalf("remove member: %v", err)
+	}
+	if _, err := memberDAL.Get(group.ID, users[2].ID); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound after remove, got: %v", err)
+	}
+}
+
 func TestMessageDAL(t *testing.T) {
 	mgr := setupTestDB(t)
 	defer mgr.Close()
@@ -352,16 +534,74 @@ func TestMessageDAL(t *testing.T) {
 		t.Errorf("expected 1 unread message, got %d", count)
 	}
 
-	// 测试批量更新消息状态
-	err = dal.UpdateMessagesStatus(conv.ID, user2.ID, "read")
-	if err != nil {
-		t.Fatalf("update messages status: %v", err)
+	// 测试推进已读位置（未读数和已读状态都由已读位置决定）
+	if _, err := mgr.ConversationMember().UpdateLastRead(conv.ID, user2.ID, msg.ID); err != nil {
+		t.Fatalf("update last read: %v", err)
 	}
 
 	count, _ = dal.CountUnread(conv.ID, user2.ID)
 	if count != 0 {
 		t.Errorf("expected 0 unread messages after mark read, got %d", count)
 	}
+	fetched, _ = dal.GetByID(msg.ID)
+	if fetched.Status != "read" {
+		t.Errorf("expected status read below the read cursor, got %s", fetched.Status)
+	}
+
+	// 测试编辑消息（旧内容写入修订记录）
+	editedAt := time.Now().Unix()
+	if err := dal.Edit(msg.ID, "Hello, Go!", editedAt); err != nil {
+		t.Fatalf("edit message: %v", err)
+	}
+
+	fetched, _ = dal.GetByID(msg.ID)
+	if fetched.Content != "Hello, Go!" {
+		t.Errorf("content not edited")
+	}
+	if fetched.EditedAt == nil || *fetched.EditedAt != editedAt {
+		t.Errorf("edited_at not set")
+	}
+
+	revisions, err := dal.GetRevisions(msg.ID)
+	if err != nil {
+		t.Fatalf("get revisions: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Content != "Hello, World!" {
+		t.Errorf("unexpected revisions: %+v", revisions)
+	}
+
+	if err := dal.Edit(99999, "missing", editedAt); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound when editing missing message, got: %v", err)
+	}
+
+	// 测试仅自己删除
+	if err := dal.DeleteForUser(msg.ID, user2.ID, time.Now().Unix()); err != nil {
+		t.Fatalf("delete message for user: %v", err)
+	}
+	msgs, _ = dal.GetVisibleByConversation(conv.ID, user2.ID, 0, 10)
+	if len(msgs) != 0 {
+		t.Errorf("expected message hidden for user2, got %d", len(msgs))
+	}
+	msgs, _ = dal.GetVisibleByConversation(conv.ID, user1.ID, 0, 10)
+	if len(msgs) != 1 {
+		t.Errorf("expected message visible for user1, got %d", len(msgs))
+	}
+
+	// 测试撤回（保留墓碑，列表中不再出现）
+	if err := dal.Recall(msg.ID, time.Now().Unix()); err != nil {
+		t.Fatalf("recall message: %v", err)
+	}
+	if err := dal.Recall(msg.ID, time.Now().Unix()); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound when recalling twice, got: %v", err)
+	}
+	fetched, _ = dal.GetByID(msg.ID)
+	if fetched.RecalledAt == nil || fetched.Content != "" {
+		t.Errorf("expected recalled tombstone, got %+v", fetched)
+	}
+	msgs, _ = dal.GetByConversation(conv.ID, 0, 10)
+	if len(msgs) != 0 {
+		t.Errorf("expected recalled message excluded, got %d", len(msgs))
+	}
 
 	// 测试删除消息
 	err = dal.Delete(msg.ID)
@@ -373,6 +613,211 @@ func TestMessageDAL(t *testing.T) {
 	if err != ErrNotFound {
 		t.Errorf("expected ErrNotFound after delete, got: %v", err)
 	}
+
+	// 测试客户端消息ID（同一发送者唯一，不同发送者可重复）
+	tagged := &models.Message{ConversationID: conv.ID, SenderID: user1.ID, ReceiverID: user2.ID, Type: "text", Content: "once", Status: "sent", CreatedAt: time.Now().Unix(), ClientMsgID: "c-1"}
+	if err := dal.Create(tagged); err != nil {
+		t.Fatalf("create tagged message: %v", err)
+	}
+	dup := &models.Message{ConversationID: conv.ID, SenderID: user1.ID, ReceiverID: user2.ID, Type: "text", Content: "once", Status: "sent", CreatedAt: time.Now().Unix(), ClientMsgID: "c-1"}
+	if err := dal.Create(dup); err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate, got: %v", err)
+	}
+	other := &models.Message{ConversationID: conv.ID, SenderID: user2.ID, ReceiverID: user1.ID, Type: "text", Content: "once", Status: "sent", CreatedAt: time.Now().Unix(), ClientMsgID: "c-1"}
+	if err := dal.Create(other); err != nil {
+		t.Errorf("expected same client id from another sender to succeed, got: %v", err)
+	}
+	fetched, err = dal.GetByClientMsgID(user1.ID, "c-1")
+	if err != nil || fetched.ID != tagged.ID {
+		t.Errorf("expected to find tagged message, got %+v (%v)", fetched, err)
+	}
+}
+
+func TestReactionDAL(t *testing.T) {
+	mgr := setupTestDB(t)
+	defer mgr.Close()
+	dal := mgr.Reaction()
+
+	user1 := &models.User{Username: "user1", PasswordHash: "hash1", Nickname: "User1", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user1)
+	user2 := &models.User{Username: "user2", PasswordHash: "hash2", Nickname: "User2", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user2)
+
+	conv := &models.Conversation{UserAID: user1.ID, UserBID: user2.ID, CreatedAt: time.Now().Unix(), UpdatedAt: time.Now().Unix()}
+	mgr.Conversation().Create(conv)
+
+	msg := &models.Message{ConversationID: conv.ID, SenderID: user1.ID, ReceiverID: user2.ID, Type: "text", Content: "Hi", Status: "sent", CreatedAt: time.Now().Unix()}
+	mgr.Message().Create(msg)
+
+	// 测试添加回应
+	for _, r := range []*models.Reaction{
+		{MessageID: msg.ID, UserID: user1.ID, Emoji: "👍", CreatedAt: time.Now().Unix()},
+		{MessageID: msg.ID, UserID: user2.ID, Emoji: "👍", CreatedAt: time.Now().Unix()},
+		{MessageID: msg.ID, UserID: user2.ID, Emoji: "❤️", CreatedAt: time.Now().Unix()},
+	} {
+		if err := dal.Add(r); err != nil {
+			t.Fatalf("add reaction: %v", err)
+		}
+	}
+
+	// 同一用户重复添加相同表情
+	err := dal.Add(&models.Reaction{MessageID: msg.ID, UserID: user1.ID, Emoji: "👍", CreatedAt: time.Now().Unix()})
+	if err != ErrDuplicate {
+		t.Errorf("expected ErrDuplicate, got: %v", err)
+	}
+
+	reactions, err := dal.GetByMessages([]int64{msg.ID})
+	if err != nil {
+		t.Fatalf("get reactions: %v", err)
+	}
+	if len(reactions) != 3 {
+		t.Errorf("expected 3 reactions, got %d", len(reactions))
+	}
+
+	// 测试移除回应
+	if err := dal.Remove(msg.ID, user2.ID, "❤️"); err != nil {
+		t.Fatalf("remove reaction: %v", err)
+	}
+	if err := dal.Remove(msg.ID, user2.ID, "❤️"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+
+	reactions, _ = dal.GetByMessages([]int64{msg.ID})
+	if len(reactions) != 2 {
+		t.Errorf("expected 2 reactions after remove, got %d", len(reactions))
+	}
+}
+
+func TestSearchDAL(t *testing.T) {
+	mgr := setupTestDB(t)
+	defer mgr.Close()
+	dal := mgr.Search()
+
+	user1 := &models.User{Username: "user1", PasswordHash: "hash1", Nickname: "User1", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user1)
+	user2 := &models.User{Username: "user2", PasswordHash: "hash2", Nickname: "User2", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user2)
+	user3 := &models.User{Username: "user3", PasswordHash: "hash3", Nickname: "User3", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user3)
+
+	conv := &models.Conversation{UserAID: user1.ID, UserBID: user2.ID, CreatedAt: time.Now().Unix(), UpdatedAt: time.Now().Unix()}
+	mgr.Conversation().Create(conv)
+	other := &models.Conversation{UserAID: user2.ID, UserBID: user3.ID, CreatedAt: time.Now().Unix(), UpdatedAt: time.Now().Unix()}
+	mgr.Conversation().Create(other)
+
+	var ids []int64
+	for _, m := range []*models.Message{
+		{ConversationID: conv.ID, SenderID: user1.ID, ReceiverID: user2.ID, Content: "今天我们一起去吃饭吧"},
+		{ConversationID: conv.ID, SenderID: user2.ID, ReceiverID: user1.ID, Content: "好的，<b>吃饭</b>去"},
+		{ConversationID: conv.ID, SenderID: user1.ID, ReceiverID: user2.ID, Content: "hello world"},
+		{ConversationID: other.ID, SenderID: user2.ID, ReceiverID: user3.ID, Content: "明天吃饭"},
+	} {
+		m.Type = "text"
+		m.Status = "sent"
+		m.CreatedAt = time.Now().Unix()
+		if err := mgr.Message().Create(m); err != nil {
+			t.Fatalf("create message: %v", err)
+		}
+		ids = append(ids, m.ID)
+	}
+
+	// 只返回用户所在会话的消息，按ID倒序
+	hits, err := dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "吃饭", Limit: 10})
+	if err != nil {
+		t.Fatalf("search messages: %v", err)
+	}
+	if len(hits) != 2 || hits[0].Message.ID != ids[1] || hits[1].Message.ID != ids[0] {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+	if hits[0].Snippet != "好的，&lt;b&gt;<mark>吃饭</mark>&lt;/b&gt;去" {
+		t.Errorf("unexpected snippet: %s", hits[0].Snippet)
+	}
+
+	// 过滤条件与游标
+	hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "吃饭", SenderID: user1.ID, Limit: 10})
+	if len(hits) != 1 || hits[0].Message.ID != ids[0] {
+		t.Errorf("expected sender filter to match 1 message, got %d", len(hits))
+	}
+	hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "吃饭", BeforeID: ids[1], Limit: 10})
+	if len(hits) != 1 || hits[0].Message.ID != ids[0] {
+		t.Errorf("expected cursor to skip newer messages, got %d", len(hits))
+	}
+	hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "WORLD", Limit: 10})
+	if len(hits) != 1 {
+		t.Errorf("expected case-insensitive match, got %d", len(hits))
+	}
+
+	// 编辑、撤回与仅自己删除后的消息同步到搜索结果
+	mgr.Message().Edit(ids[2], "goodbye", time.Now().Unix())
+	if hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "world", Limit: 10}); len(hits) != 0 {
+		t.Errorf("expected edited content to be reindexed, got %d hits", len(hits))
+	}
+	mgr.Message().Recall(ids[1], time.Now().Unix())
+	mgr.Message().DeleteForUser(ids[0], user1.ID, time.Now().Unix())
+	if hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "吃饭", Limit: 10}); len(hits) != 0 {
+		t.Errorf("expected recalled and deleted messages to be hidden, got %d hits", len(hits))
+	}
+	if hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user2.ID, Query: "吃饭", Limit: 10}); len(hits) != 2 {
+		t.Errorf("expected 2 hits for user2, got %d", len(hits))
+	}
+
+	// 清空历史后水位之前的消息不再出现在搜索结果中
+	if err := mgr.ConversationMember().ClearHistory(conv.ID, user2.ID, ids[2]); err != nil {
+		t.Fatalf("clear history: %v", err)
+	}
+	if hits, _ = dal.SearchMessages(&models.MessageSearchQuery{UserID: user2.ID, Query: "吃饭", Limit: 10}); len(hits) != 1 || hits[0].Message.ID != ids[3] {
+		t.Errorf("expected cleared messages to be hidden, got %d hits", len(hits))
+	}
+
+	// 小写后字节长度变化的非 ASCII 字母（Ⱥ 小写为 3 字节的 ⱥ）不影响片段截取
+	accented := &models.Message{ConversationID: conv.ID, SenderID: user1.ID, ReceiverID: user2.ID, Type: "text", Status: "sent", Content: "ȺȾ OK", CreatedAt: time.Now().Unix()}
+	if err := mgr.Message().Create(accented); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+	hits, err = dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: "ok", Limit: 10})
+	if err != nil {
+		t.Fatalf("search messages: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Snippet != "ȺȾ <mark>OK</mark>" {
+		t.Errorf("unexpected hits for non-ASCII content: %+v", hits)
+	}
+	if got := likeSnippet("Ⱥx", "x"); got != "Ⱥ"+snippetOpen+"x"+snippetClose {
+		t.Errorf("unexpected snippet: %q", got)
+	}
+
+	// 非文本消息只搜索可读字段（文件名、地点名称、投票问题和选项），不匹配 JSON 键名或媒体ID
+	typed := map[string]*models.Message{
+		"file":     {Type: "file", Content: `{"media_id":12345,"name":"report.pdf","size":10}`},
+		"location": {Type: "location", Content: `{"lat":31.2,"lng":121.5,"label":"Central Park"}`},
+		"poll":     {Type: "poll", Content: `{"question":"Lunch today?","options":["pizza","noodles"]}`},
+		"image":    {Type: "image", Content: "12345"},
+	}
+	for _, m := range typed {
+		m.ConversationID, m.SenderID, m.ReceiverID = conv.ID, user1.ID, user2.ID
+		m.Status, m.CreatedAt = "sent", time.Now().Unix()
+		if err := mgr.Message().Create(m); err != nil {
+			t.Fatalf("create %s message: %v", m.Type, err)
+		}
+	}
+	for query, want := range map[string]*models.Message{
+		"report":  typed["file"],
+		"park":    typed["location"],
+		"noodles": typed["poll"],
+		"lunch":   typed["poll"],
+	} {
+		hits, err := dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: query, Limit: 10})
+		if err != nil {
+			t.Fatalf("search messages: %v", err)
+		}
+		if len(hits) != 1 || hits[0].Message.ID != want.ID || strings.Contains(hits[0].Snippet, "{") {
+			t.Errorf("unexpected hits for %q: %+v", query, hits)
+		}
+	}
+	for _, query := range []string{"media_id", "lat", "question", "12345", "options"} {
+		if hits, _ := dal.SearchMessages(&models.MessageSearchQuery{UserID: user1.ID, Query: query, Limit: 10}); len(hits) != 0 {
+			t.Errorf("expected no hits for %q, got %d", query, len(hits))
+		}
+	}
 }
 
 func TestMediaDAL(t *testing.T) {
@@ -440,6 +885,36 @@ func TestMediaDAL(t *testing.T) {
 		t.Errorf("width not updated")
 	}
 
+	// 尚未发出的定时消息引用媒体，发出或取消后不再算作引用
+	if referenced, _ := dal.IsReferenced(media.ID); referenced {
+		t.Error("expected media to be unreferenced")
+	}
+	now := time.Now().Unix()
+	peer := &models.User{Username: "user2", PasswordHash: "hash2", Nickname: "User2", CreatedAt: now, LastSeen: now}
+	userDAL.Create(peer)
+	conv := &models.Conversation{UserAID: user.ID, UserBID: peer.ID, CreatedAt: now, UpdatedAt: now}
+	mgr.Conversation().Create(conv)
+	for _, sm := range []*models.ScheduledMessage{
+		{Type: "image", Content: fmt.Sprintf("%d", media.ID)},
+		{Type: "file", Content: fmt.Sprintf(`{"media_id":%d,"name":"a.jpg"}`, media.ID)},
+	} {
+		sm.SenderID, sm.ConversationID, sm.SendAt, sm.Status, sm.CreatedAt = user.ID, conv.ID, now+3600, models.ScheduledStatusPending, now
+		if err := mgr.ScheduledMessage().Create(sm); err != nil {
+			t.Fatalf("create scheduled message: %v", err)
+		}
+		if referenced, _ := dal.IsReferenced(media.ID); !referenced {
+			t.Errorf("expected media referenced by pending %s message", sm.Type)
+		}
+		mgr.ScheduledMessage().Claim(sm.ID, now)
+		if referenced, _ := dal.IsReferenced(media.ID); !referenced {
+			t.Errorf("expected media referenced by sending %s message", sm.Type)
+		}
+		mgr.ScheduledMessage().MarkFailed(sm.ID, "failed", now)
+		if referenced, _ := dal.IsReferenced(media.ID); referenced {
+			t.Errorf("expected failed %s message not to reference media", sm.Type)
+		}
+	}
+
 	// 测试删除媒体
 	err = dal.Delete(media.ID)
 	if err != nil {
@@ -519,6 +994,53 @@ func TestSharedConversationDAL(t *testing.T) {
 	}
 }
 
+func TestEventLogDAL(t *testing.T) {
+	mgr := setupTestDB(t)
+	defer mgr.Close()
+
+	dal := mgr.EventLog()
+
+	// 事件ID按用户独立递增
+	for i := 0; i < 5; i++ {
+		entry := &models.EventLogEntry{UserID: 1, Type: "chat", Data: `{"n":1}`, CreatedAt: time.Now().Unix()}
+		if err := dal.Append(entry, 3); err != nil {
+			t.Fatalf("append event: %v", err)
+		}
+		if entry.ID != int64(i+1) {
+			t.Errorf("expected event id %d, got %d", i+1, entry.ID)
+		}
+	}
+	other := &models.EventLogEntry{UserID: 2, Type: "typing", Data: `{}`, CreatedAt: time.Now().Unix()}
+	if err := dal.Append(other, 3); err != nil {
+		t.Fatalf("append event: %v", err)
+	}
+	if other.ID != 1 {
+		t.Errorf("expected first event id of another user to be 1, got %d", other.ID)
+	}
+
+	// 只保留最近3条
+	oldest, latest, err := dal.Bounds(1)
+	if err != nil {
+		t.Fatalf("get bounds: %v", err)
+	}
+	if oldest != 3 || latest != 5 {
+		t.Errorf("expected bounds 3-5, got %d-%d", oldest, latest)
+	}
+
+	entries, err := dal.ListAfter(1, 3, 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != 4 || entries[1].ID != 5 || entries[0].Data != `{"n":1}` {
+		t.Errorf("unexpected events: %+v", entries)
+	}
+
+	oldest, latest, _ = dal.Bounds(3)
+	if oldest != 0 || latest != 0 {
+		t.Errorf("expected empty bounds, got %d-%d", oldest, latest)
+	}
+}
+
 // ptr 返回int指针的辅助函数
 func ptr(i int) *int {
 	return &i
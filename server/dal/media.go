@@ -138,6 +138,31 @@ func (d *mediaDAL) Update(media *models.Media) error {
 	return nil
 }
 
+// IsReferenced 媒体是否仍被消息、尚未发出的定时消息或头像引用（媒体消息的内容为媒体ID）
+func (d *mediaDAL) IsReferenced(id int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM messages WHERE type IN ('image', 'voice') AND content = ?
+		) OR EXISTS (
+			SELECT 1 FROM messages WHERE type = 'file' AND json_valid(content) AND json_extract(content, '$.media_id') = ?
+		) OR EXISTS (
+			SELECT 1 FROM scheduled_messages WHERE status IN ('pending', 'sending') AND type IN ('image', 'voice') AND content = ?
+		) OR EXISTS (
+			SELECT 1 FROM scheduled_messages WHERE status IN ('pending', 'sending') AND type = 'file' AND json_valid(content) AND json_extract(content, '$.media_id') = ?
+		) OR EXISTS (
+			SELECT 1 FROM users WHERE avatar_id = ?
+		) OR EXISTS (
+			SELECT 1 FROM conversations WHERE avatar_id = ?
+		)
+	`
+	content := fmt.Sprintf("%d", id)
+	var referenced bool
+	if err := d.db.QueryRow(query, content, id, content, id, id, id).Scan(&referenced); err != nil {
+		return false, fmt.Errorf("check media references: %w", err)
+	}
+	return referenced, nil
+}
+
 func (d *mediaDAL) Delete(id int64) error {
 	query := `DELETE FROM media WHERE id = ?`
 	result, err := d.db.Exec(query, id)
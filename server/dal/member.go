@@ -0,0 +1,311 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"zmessage/server/models"
+)
+
+type conversationMemberDAL struct {
+	db DB
+}
+
+func NewConversationMemberDAL(db DB) ConversationMemberDAL {
+	return &conversationMemberDAL{db: db}
+}
+
+// insertMember 在事务中登记会话成员
+func insertMember(tx *sql.Tx, convID, userID int64, role string, joinedAt int64) error {
+	query := `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at)
+		VALUES (?, ?, ?, ?)
+	`
+	if _, err := tx.Exec(query, convID, userID, role, joinedAt); err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("insert conversation member: %w", err)
+	}
+	return nil
+}
+
+// Add 添加会话成员，加入前的消息对新成员不可见
+func (d *conversationMemberDAL) Add(member *models.ConversationMember) error {
+	query := `
+		INSERT INTO conversation_members (conversation_id, user_id, role, joined_at, last_read_message_id, joined_message_id)
+		VALUES (?, ?, ?, ?, ?, COALESCE((SELECT MAX(id) FROM messages WHERE conversation_id = ?), 0))
+	`
+	_, err := d.db.Exec(query,
+		member.ConversationID,
+		member.UserID,
+		member.Role,
+		member.JoinedAt,
+		member.LastReadMessageID,
+		member.ConversationID,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("add conversation member: %w", err)
+	}
+	return nil
+}
+
+func (d *conversationMemberDAL) Get(convID int64, userID int64) (*models.ConversationMember, error) {
+	query := `
+		SELECT conversation_id, user_id, role, joined_at, last_read_message_id
+		FROM conversation_members WHERE conversation_id = ? AND user_id = ?
+	`
+	member := &models.ConversationMember{}
+	err := d.db.QueryRow(query, convID, userID).Scan(
+		&member.ConversationID,
+		&member.UserID,
+		&member.Role,
+		&member.JoinedAt,
+		&member.LastReadMessageID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conversation member: %w", err)
+	}
+	return member, nil
+}
+
+func (d *conversationMemberDAL) GetByConversation(convID int64) ([]*models.ConversationMember, error) {
+	query := `
+		SELECT conversation_id, user_id, role, joined_at, last_read_message_id
+		FROM conversation_members WHERE conversation_id = ?
+		ORDER BY joined_at ASC, user_id ASC
+	`
+	rows, err := d.db.Query(query, convID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []*models.ConversationMember
+	for rows.Next() {
+		member := &models.ConversationMember{}
+		err := rows.Scan(
+			&member.ConversationID,
+			&member.UserID,
+			&member.Role,
+			&member.JoinedAt,
+			&member.LastReadMessageID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan conversation member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+func (d *conversationMemberDAL) UpdateRole(convID int64, userID int64, role string) error {
+	query := `UPDATE conversation_members SET role = ? WHERE conversation_id = ? AND user_id = ?`
+	result, err := d.db.Exec(query, role, convID, userID)
+	if err != nil {
+		return fmt.Errorf("update member role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateLastRead 更新成员的已读位置（只前进不后退），返回更新前的位置
+func (d *conversationMemberDAL) UpdateLastRead(convID int64, userID int64, messageID int64) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var prev int64
+	query := `SELECT last_read_message_id FROM conversation_members WHERE conversation_id = ? AND user_id = ?`
+	err = tx.QueryRow(query, convID, userID).Scan(&prev)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get member last read: %w", err)
+	}
+	if messageID <= prev {
+		return prev, nil
+	}
+
+	query = `UPDATE conversation_members SET last_read_message_id = ? WHERE conversation_id = ? AND user_id = ?`
+	if _, err := tx.Exec(query, messageID, convID, userID); err != nil {
+		return 0, fmt.Errorf("update member last read: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit transaction: %w", err)
+	}
+	return prev, nil
+}
+
+// GetSettings 获取成员对会话的个人设置
+func (d *conversationMemberDAL) GetSettings(convID int64, userID int64) (*models.ConversationSettings, error) {
+	query := `
+		SELECT archived, muted_until, hidden, pinned_at, cleared_message_id
+		FROM conversation_members WHERE conversation_id = ? AND user_id = ?
+	`
+	settings := &models.ConversationSettings{}
+	err := d.db.QueryRow(query, convID, userID).Scan(
+		&settings.Archived,
+		&settings.MutedUntil,
+		&settings.Hidden,
+		&settings.PinnedAt,
+		&settings.ClearedMessageID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conversation settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateSettings 保存成员对会话的个人设置（清空水位由 ClearHistory 单独维护）
+func (d *conversationMemberDAL) UpdateSettings(convID int64, userID int64, settings *models.ConversationSettings) error {
+	query := `
+		UPDATE conversation_members
+		SET archived = ?, muted_until = ?, hidden = ?, pinned_at = ?
+		WHERE conversation_id = ? AND user_id = ?
+	`
+	result, err := d.db.Exec(query, settings.Archived, settings.MutedUntil, settings.Hidden, settings.PinnedAt, convID, userID)
+	if err != nil {
+		return fmt.Errorf("update conversation settings: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CountPinned 统计用户置顶的会话数
+func (d *conversationMemberDAL) CountPinned(userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM conversation_members WHERE user_id = ? AND pinned_at > 0`
+	var count int
+	if err := d.db.QueryRow(query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count pinned conversations: %w", err)
+	}
+	return count, nil
+}
+
+// RestoreOnNewMessage 会话有新消息时，为所有成员取消隐藏，并为未开启免打扰的成员取消归档
+func (d *conversationMemberDAL) RestoreOnNewMessage(convID int64, now int64) error {
+	query := `
+		UPDATE conversation_members
+		SET hidden = 0, archived = CASE WHEN muted_until > ? THEN archived ELSE 0 END
+		WHERE conversation_id = ? AND (hidden = 1 OR archived = 1)
+	`
+	if _, err := d.db.Exec(query, now, convID); err != nil {
+		return fmt.Errorf("restore conversation members: %w", err)
+	}
+	return nil
+}
+
+// ClearHistory 推进成员的清空水位（只增不减）
+func (d *conversationMemberDAL) ClearHistory(convID int64, userID int64, messageID int64) error {
+	query := `
+		UPDATE conversation_members SET cleared_message_id = MAX(cleared_message_id, ?)
+		WHERE conversation_id = ? AND user_id = ?
+	`
+	result, err := d.db.Exec(query, messageID, convID, userID)
+	if err != nil {
+		return fmt.Errorf("clear history: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (d *conversationMemberDAL) Remove(convID int64, userID int64) error {
+	query := `DELETE FROM conversation_members WHERE conversation_id = ? AND user_id = ?`
+	result, err := d.db.Exec(query, convID, userID)
+	if err != nil {
+		return fmt.Errorf("remove conversation member: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RemoveOwner 移除群主，并在同一事务中将群主转让给继任者
+func (d *conversationMemberDAL) RemoveOwner(convID int64, ownerID int64, successorID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE conversation_members SET role = ? WHERE conversation_id = ? AND user_id = ?`
+	result, err := tx.Exec(query, models.MemberRoleOwner, convID, successorID)
+	if err != nil {
+		return fmt.Errorf("transfer owner: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	} else if rows == 0 {
+		return ErrNotFound
+	}
+
+	query = `DELETE FROM conversation_members WHERE conversation_id = ? AND user_id = ?`
+	result, err = tx.Exec(query, convID, ownerID)
+	if err != nil {
+		return fmt.Errorf("remove conversation member: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	} else if rows == 0 {
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (d *conversationMemberDAL) Count(convID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM conversation_members WHERE conversation_id = ?`
+	var count int
+	err := d.db.QueryRow(query, convID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count conversation members: %w", err)
+	}
+	return count, nil
+}
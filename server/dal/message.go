@@ -3,9 +3,42 @@ package dal
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"zmessage/server/models"
 )
 
+// messageColumns 消息查询列（群聊消息没有单一接收者，receiver_id 统一返回0）
+const messageColumns = `
+	id, conversation_id, sender_id, COALESCE(receiver_id, 0), type, content, status, created_at, synced_at, edited_at, recalled_at,
+	COALESCE(reply_to_id, 0), COALESCE(client_msg_id, ''), expires_at,
+	COALESCE(forwarded_message_id, 0), COALESCE(forwarded_sender_id, 0), COALESCE(forwarded_at, 0)
+`
+
+// notRecalled 排除已撤回消息的条件
+const notRecalled = `recalled_at IS NULL`
+
+// notExpired 排除已过期（阅后即焚）但尚未被清理的消息的条件
+const notExpired = `(expires_at IS NULL OR expires_at > CAST(strftime('%s', 'now') AS INTEGER))`
+
+// notDeletedFor 排除被指定用户"仅自己删除"的消息的条件（需绑定一个 user_id 参数）
+const notDeletedFor = `NOT EXISTS (
+	SELECT 1 FROM message_deletions md WHERE md.message_id = messages.id AND md.user_id = ?
+)`
+
+// notCleared 排除指定用户已清空的历史消息、以及加入群聊之前的消息的条件（需绑定一个 user_id 参数）
+// 清空只推进该用户的水位，不影响其他参与者和分享链接
+const notCleared = `id > COALESCE((
+	SELECT MAX(cleared_message_id, joined_message_id) FROM conversation_members cc
+	WHERE cc.conversation_id = messages.conversation_id AND cc.user_id = ?
+), 0)`
+
+// aboveReadCursor 限定接收者尚未读到的单聊消息的条件
+// 单聊消息的已读状态由接收者的已读位置决定，不再改写 status
+const aboveReadCursor = `id > COALESCE((
+	SELECT rc.last_read_message_id FROM conversation_members rc
+	WHERE rc.conversation_id = messages.conversation_id AND rc.user_id = messages.receiver_id
+), 0)`
+
 type messageDAL struct {
 	db DB
 }
@@ -16,20 +49,34 @@ func NewMessageDAL(db DB) MessageDAL {
 
 func (d *messageDAL) Create(msg *models.Message) error {
 	query := `
-		INSERT INTO messages (conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO messages (conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at, reply_to_id, client_msg_id, expires_at,
+			forwarded_message_id, forwarded_sender_id, forwarded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
+	var fwdMessageID, fwdSenderID, fwdAt interface{}
+	if fwd := msg.ForwardedFrom; fwd != nil {
+		fwdMessageID, fwdSenderID, fwdAt = fwd.MessageID, fwd.SenderID, fwd.CreatedAt
+	}
 	result, err := d.db.Exec(query,
 		msg.ConversationID,
 		msg.SenderID,
-		msg.ReceiverID,
+		nullableID(msg.ReceiverID),
 		msg.Type,
 		msg.Content,
 		msg.Status,
 		msg.CreatedAt,
 		msg.SyncedAt,
+		nullableID(msg.ReplyToID),
+		nullableString(msg.ClientMsgID),
+		msg.ExpiresAt,
+		fwdMessageID,
+		fwdSenderID,
+		fwdAt,
 	)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
 		return fmt.Errorf("create message: %w", err)
 	}
 
@@ -43,36 +90,62 @@ func (d *messageDAL) Create(msg *models.Message) error {
 }
 
 func (d *messageDAL) GetByID(id int64) (*models.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = ?`
+	msg, err := scanMessage(d.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get message by id: %w", err)
+	}
+	if err := applyReadCursors(d.db, []*models.Message{msg}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// GetVisibleByID 获取对指定用户可见的消息（已撤回、已过期、该用户已删除或已清空时返回 ErrNotFound）
+func (d *messageDAL) GetVisibleByID(id int64, userID int64) (*models.Message, error) {
 	query := `
-		SELECT id, conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at
-		FROM messages WHERE id = ?
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE id = ? AND ` + notRecalled + ` AND ` + notExpired + ` AND ` + notDeletedFor + ` AND ` + notCleared + `
 	`
-	msg := &models.Message{}
-	err := d.db.QueryRow(query, id).Scan(
-		&msg.ID,
-		&msg.ConversationID,
-		&msg.SenderID,
-		&msg.ReceiverID,
-		&msg.Type,
-		&msg.Content,
-		&msg.Status,
-		&msg.CreatedAt,
-		&msg.SyncedAt,
-	)
+	msg, err := scanMessage(d.db.QueryRow(query, id, userID, userID))
 	if err == sql.ErrNoRows {
 		return nil, ErrNotFound
 	}
 	if err != nil {
-		return nil, fmt.Errorf("get message by id: %w", err)
+		return nil, fmt.Errorf("get visible message by id: %w", err)
+	}
+	if err := applyReadCursors(d.db, []*models.Message{msg}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// GetByClientMsgID 按发送者与客户端消息ID获取消息
+func (d *messageDAL) GetByClientMsgID(senderID int64, clientMsgID string) (*models.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE sender_id = ? AND client_msg_id = ?`
+	msg, err := scanMessage(d.db.QueryRow(query, senderID, clientMsgID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get message by client msg id: %w", err)
+	}
+	if err := applyReadCursors(d.db, []*models.Message{msg}); err != nil {
+		return nil, err
 	}
 	return msg, nil
 }
 
+// GetByConversation 获取会话消息（不含已撤回和已过期的消息）
 func (d *messageDAL) GetByConversation(convID int64, beforeID int64, limit int) ([]*models.Message, error) {
 	query := `
-		SELECT id, conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at
+		SELECT ` + messageColumns + `
 		FROM messages
-		WHERE conversation_id = ?
+		WHERE conversation_id = ? AND ` + notRecalled + ` AND ` + notExpired + `
 	`
 	args := []interface{}{convID}
 
@@ -84,69 +157,55 @@ func (d *messageDAL) GetByConversation(convID int64, beforeID int64, limit int)
 	query += ` ORDER BY id DESC LIMIT ?`
 	args = append(args, limit)
 
-	rows, err := d.db.Query(query, args...)
+	msgs, err := d.queryMessages(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("get messages by conversation: %w", err)
 	}
-	defer rows.Close()
+	return msgs, nil
+}
 
-	var msgs []*models.Message
-	for rows.Next() {
-		msg := &models.Message{}
-		err := rows.Scan(
-			&msg.ID,
-			&msg.ConversationID,
-			&msg.SenderID,
-			&msg.ReceiverID,
-			&msg.Type,
-			&msg.Content,
-			&msg.Status,
-			&msg.CreatedAt,
-			&msg.SyncedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
-		}
-		msgs = append(msgs, msg)
+// GetVisibleByConversation 获取用户可见的会话消息（不含已撤回、已过期、该用户已删除或已清空的消息）
+func (d *messageDAL) GetVisibleByConversation(convID int64, userID int64, beforeID int64, limit int) ([]*models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE conversation_id = ? AND ` + notRecalled + ` AND ` + notExpired + ` AND ` + notDeletedFor + ` AND ` + notCleared + `
+	`
+	args := []interface{}{convID, userID, userID}
+
+	if beforeID > 0 {
+		query += ` AND id < ?`
+		args = append(args, beforeID)
 	}
 
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	msgs, err := d.queryMessages(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get visible messages by conversation: %w", err)
+	}
 	return msgs, nil
 }
 
+// GetOfflineMessages 获取用户收到的离线消息（包括所在群聊中他人发送的消息）
 func (d *messageDAL) GetOfflineMessages(userID int64, lastID int64, limit int) ([]*models.Message, error) {
 	query := `
-		SELECT id, conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at
+		SELECT ` + messageColumns + `
 		FROM messages
-		WHERE receiver_id = ? AND id > ?
+		WHERE id > ? AND (
+			receiver_id = ?
+			OR (receiver_id IS NULL AND sender_id != ? AND conversation_id IN (
+				SELECT conversation_id FROM conversation_members WHERE user_id = ?
+			))
+		) AND ` + notRecalled + ` AND ` + notExpired + ` AND ` + notDeletedFor + ` AND ` + notCleared + `
 		ORDER BY id ASC
 		LIMIT ?
 	`
-	rows, err := d.db.Query(query, userID, lastID, limit)
+	msgs, err := d.queryMessages(query, lastID, userID, userID, userID, userID, userID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("get offline messages: %w", err)
 	}
-	defer rows.Close()
-
-	var msgs []*models.Message
-	for rows.Next() {
-		msg := &models.Message{}
-		err := rows.Scan(
-			&msg.ID,
-			&msg.ConversationID,
-			&msg.SenderID,
-			&msg.ReceiverID,
-			&msg.Type,
-			&msg.Content,
-			&msg.Status,
-			&msg.CreatedAt,
-			&msg.SyncedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("scan message: %w", err)
-		}
-		msgs = append(msgs, msg)
-	}
-
 	return msgs, nil
 }
 
@@ -190,50 +249,266 @@ func (d *messageDAL) UpdateStatus(id int64, status string) error {
 	return nil
 }
 
-func (d *messageDAL) UpdateMessagesStatus(convID int64, receiverID int64, status string) error {
+// statusRank 消息状态的先后顺序，状态只能前进不能回退
+var statusRank = map[string]int{
+	"sent":      0,
+	"delivered": 1,
+	"read":      2,
+}
+
+// statusRankExpr 在SQL中计算消息当前状态的顺序
+const statusRankExpr = `CASE status WHEN 'delivered' THEN 1 WHEN 'read' THEN 2 ELSE 0 END`
+
+// AdvanceStatus 将接收者的指定消息推进到新状态（已达到或超过该状态的消息不变），返回发生变化的消息
+func (d *messageDAL) AdvanceStatus(receiverID int64, ids []int64, status string) ([]*models.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return d.advanceStatus(`id IN (`+strings.Join(placeholders, ",")+`)`, args, receiverID, status)
+}
+
+// GetReadRange 获取接收者已读位置从 afterID 推进到 upToID 时新读到的单聊消息
+func (d *messageDAL) GetReadRange(convID int64, receiverID int64, afterID int64, upToID int64) ([]*models.Message, error) {
 	query := `
-		UPDATE messages
-		SET status = ?
-		WHERE conversation_id = ? AND receiver_id = ? AND status != 'read'
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE conversation_id = ? AND receiver_id = ? AND id > ? AND id <= ? AND ` + notRecalled + `
+		ORDER BY id ASC
 	`
-	_, err := d.db.Exec(query, status, convID, receiverID)
+	msgs, err := d.queryMessages(query, convID, receiverID, afterID, upToID)
 	if err != nil {
-		return fmt.Errorf("update messages status: %w", err)
+		return nil, fmt.Errorf("get read range: %w", err)
 	}
-	return nil
+	return msgs, nil
 }
 
+// advanceStatus 在事务中查出需要推进的消息并更新状态（已读位置之前的消息视为已读，不再推进）
+func (d *messageDAL) advanceStatus(cond string, args []interface{}, receiverID int64, status string) ([]*models.Message, error) {
+	rank, ok := statusRank[status]
+	if !ok {
+		return nil, fmt.Errorf("unknown message status: %s", status)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE ` + cond + ` AND receiver_id = ? AND ` + notRecalled + ` AND ` + aboveReadCursor + ` AND ` + statusRankExpr + ` < ?
+		ORDER BY id ASC
+	`
+	rows, err := tx.Query(query, append(args, receiverID, rank)...)
+	if err != nil {
+		return nil, fmt.Errorf("get messages to advance: %w", err)
+	}
+	var msgs []*models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, msg := range msgs {
+		if _, err := tx.Exec(`UPDATE messages SET status = ? WHERE id = ?`, status, msg.ID); err != nil {
+			return nil, fmt.Errorf("update message status: %w", err)
+		}
+		msg.Status = status
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit transaction: %w", err)
+	}
+	return msgs, nil
+}
+
+// CountUnread 统计会话未读数（他人发送的、位于用户已读位置之后的消息）
 func (d *messageDAL) CountUnread(convID int64, userID int64) (int, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM messages
-		WHERE conversation_id = ? AND receiver_id = ? AND status != 'read'
+		JOIN conversation_members cm ON cm.conversation_id = messages.conversation_id AND cm.user_id = ?
+		WHERE messages.conversation_id = ? AND messages.sender_id != ?
+			AND messages.id > cm.last_read_message_id AND messages.id > cm.cleared_message_id AND messages.id > cm.joined_message_id
+			AND ` + notRecalled + ` AND ` + notExpired + ` AND ` + notDeletedFor + `
 	`
 	var count int
-	err := d.db.QueryRow(query, convID, userID).Scan(&count)
+	err := d.db.QueryRow(query, userID, convID, userID, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("count unread messages: %w", err)
 	}
 	return count, nil
 }
 
+// CountTotalUnread 统计用户所有会话的未读总数（免打扰中的会话不计入）
 func (d *messageDAL) CountTotalUnread(userID int64) (int, error) {
 	query := `
 		SELECT COUNT(*)
 		FROM messages
-		WHERE receiver_id = ? AND status != 'read'
+		JOIN conversation_members cm ON cm.conversation_id = messages.conversation_id AND cm.user_id = ?
+		WHERE messages.sender_id != ?
+			AND messages.id > cm.last_read_message_id AND messages.id > cm.cleared_message_id AND messages.id > cm.joined_message_id
+			AND cm.muted_until <= CAST(strftime('%s', 'now') AS INTEGER)
+			AND ` + notRecalled + ` AND ` + notExpired + ` AND ` + notDeletedFor + `
 	`
 	var count int
-	err := d.db.QueryRow(query, userID).Scan(&count)
+	err := d.db.QueryRow(query, userID, userID, userID).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("count total unread: %w", err)
 	}
 	return count, nil
 }
 
+// Edit 修改消息内容，并在同一事务中将旧内容写入修订记录
+func (d *messageDAL) Edit(id int64, content string, editedAt int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldContent string
+	err = tx.QueryRow(`SELECT content FROM messages WHERE id = ?`, id).Scan(&oldContent)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get message content: %w", err)
+	}
+
+	query := `INSERT INTO message_revisions (message_id, content, edited_at) VALUES (?, ?, ?)`
+	if _, err := tx.Exec(query, id, oldContent, editedAt); err != nil {
+		return fmt.Errorf("create message revision: %w", err)
+	}
+
+	query = `UPDATE messages SET content = ?, edited_at = ? WHERE id = ?`
+	if _, err := tx.Exec(query, content, editedAt, id); err != nil {
+		return fmt.Errorf("edit message: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit edit: %w", err)
+	}
+	return nil
+}
+
+// GetRevisions 获取消息的修订记录（按编辑顺序）
+func (d *messageDAL) GetRevisions(messageID int64) ([]*models.MessageRevision, error) {
+	query := `
+		SELECT id, message_id, content, edited_at
+		FROM message_revisions
+		WHERE message_id = ?
+		ORDER BY id ASC
+	`
+	rows, err := d.db.Query(query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("get message revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []*models.MessageRevision
+	for rows.Next() {
+		rev := &models.MessageRevision{}
+		if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.Content, &rev.EditedAt); err != nil {
+			return nil, fmt.Errorf("scan message revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, nil
+}
+
+// Recall 撤回消息：清空内容并记录撤回时间，保留墓碑记录
+func (d *messageDAL) Recall(id int64, recalledAt int64) error {
+	query := `UPDATE messages SET content = '', recalled_at = ? WHERE id = ? AND recalled_at IS NULL`
+	result, err := d.db.Exec(query, recalledAt, id)
+	if err != nil {
+		return fmt.Errorf("recall message: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteForUser 为指定用户隐藏消息（仅自己删除，重复删除不报错）
+func (d *messageDAL) DeleteForUser(id int64, userID int64, deletedAt int64) error {
+	query := `INSERT OR IGNORE INTO message_deletions (message_id, user_id, deleted_at) VALUES (?, ?, ?)`
+	if _, err := d.db.Exec(query, id, userID, deletedAt); err != nil {
+		return fmt.Errorf("delete message for user: %w", err)
+	}
+	return nil
+}
+
+// GetExpired 获取已到期的阅后即焚消息（按过期时间先后）
+func (d *messageDAL) GetExpired(now int64, limit int) ([]*models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE expires_at IS NOT NULL AND expires_at <= ?
+		ORDER BY expires_at ASC
+		LIMIT ?
+	`
+	msgs, err := d.queryMessages(query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get expired messages: %w", err)
+	}
+	return msgs, nil
+}
+
 func (d *messageDAL) Delete(id int64) error {
-	query := `DELETE FROM messages WHERE id = ?`
-	result, err := d.db.Exec(query, id)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM message_revisions WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete message revisions: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM message_deletions WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete message deletions: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM message_reactions WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete message reactions: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM message_pins WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete message pins: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM message_stars WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete message stars: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM poll_votes WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete poll votes: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM poll_closures WHERE message_id = ?`, id); err != nil {
+		return fmt.Errorf("delete poll closure: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM messages WHERE id = ?`, id)
 	if err != nil {
 		return fmt.Errorf("delete message: %w", err)
 	}
@@ -246,5 +521,89 @@ func (d *messageDAL) Delete(id int64) error {
 		return ErrNotFound
 	}
 
+	return tx.Commit()
+}
+
+// queryMessages 执行查询并扫描消息列表
+func (d *messageDAL) queryMessages(query string, args ...interface{}) ([]*models.Message, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []*models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := applyReadCursors(d.db, msgs); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// applyReadCursors 单聊消息位于接收者已读位置之前时，状态视为已读
+// 需在结果集关闭后调用（数据库只有一个连接）
+func applyReadCursors(db DB, msgs []*models.Message) error {
+	cursors := make(map[[2]int64]int64)
+	for _, msg := range msgs {
+		if msg.ReceiverID == 0 || msg.Status == models.MessageStatusRead {
+			continue
+		}
+		key := [2]int64{msg.ConversationID, msg.ReceiverID}
+		cursor, ok := cursors[key]
+		if !ok {
+			query := `SELECT last_read_message_id FROM conversation_members WHERE conversation_id = ? AND user_id = ?`
+			err := db.QueryRow(query, msg.ConversationID, msg.ReceiverID).Scan(&cursor)
+			if err != nil && err != sql.ErrNoRows {
+				return fmt.Errorf("get read cursor: %w", err)
+			}
+			cursors[key] = cursor
+		}
+		if msg.ID <= cursor {
+			msg.Status = models.MessageStatusRead
+		}
+	}
 	return nil
 }
+
+// scanMessage 扫描一行消息数据
+func scanMessage(row rowScanner, extra ...interface{}) (*models.Message, error) {
+	msg := &models.Message{}
+	fwd := &models.ForwardInfo{}
+	dest := []interface{}{
+		&msg.ID,
+		&msg.ConversationID,
+		&msg.SenderID,
+		&msg.ReceiverID,
+		&msg.Type,
+		&msg.Content,
+		&msg.Status,
+		&msg.CreatedAt,
+		&msg.SyncedAt,
+		&msg.EditedAt,
+		&msg.RecalledAt,
+		&msg.ReplyToID,
+		&msg.ClientMsgID,
+		&msg.ExpiresAt,
+		&fwd.MessageID,
+		&fwd.SenderID,
+		&fwd.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if fwd.SenderID != 0 {
+		msg.ForwardedFrom = fwd
+	}
+	return msg, nil
+}
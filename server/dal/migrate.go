@@ -0,0 +1,440 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration 数据库迁移步骤
+// 基础Schema之后的所有结构变更都以迁移的形式追加，已有数据库启动时会自动补齐
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// execSQL 将SQL语句包装为迁移函数
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// migrations 迁移列表（按版本号递增，已发布的迁移不可修改）
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "group_conversations",
+		up:      execSQL(groupConversationsMigration),
+	},
+	{
+		version: 2,
+		name:    "message_edits",
+		up:      execSQL(messageEditsMigration),
+	},
+	{
+		version: 3,
+		name:    "message_recall_delete",
+		up:      execSQL(messageRecallDeleteMigration),
+	},
+	{
+		version: 4,
+		name:    "message_reply_to",
+		up:      execSQL(messageReplyToMigration),
+	},
+	{
+		version: 5,
+		name:    "message_reactions",
+		up:      execSQL(messageReactionsMigration),
+	},
+	{
+		version: 6,
+		name:    "message_client_msg_id",
+		up:      execSQL(messageClientMsgIDMigration),
+	},
+	{
+		version: 7,
+		name:    "scheduled_messages",
+		up:      execSQL(scheduledMessagesMigration),
+	},
+	{
+		version: 8,
+		name:    "disappearing_messages",
+		up:      execSQL(disappearingMessagesMigration),
+	},
+	{
+		version: 9,
+		name:    "message_pins",
+		up:      execSQL(messagePinsMigration),
+	},
+	{
+		version: 10,
+		name:    "message_stars",
+		up:      execSQL(messageStarsMigration),
+	},
+	{
+		version: 11,
+		name:    "drafts",
+		up:      execSQL(draftsMigration),
+	},
+	{
+		version: 12,
+		name:    "message_forwarding",
+		up:      execSQL(messageForwardingMigration),
+	},
+	{
+		version: 13,
+		name:    "polls",
+		up:      execSQL(pollsMigration),
+	},
+	{
+		version: 14,
+		name:    "conversation_settings",
+		up:      execSQL(conversationSettingsMigration),
+	},
+	{
+		version: 15,
+		name:    "clear_history",
+		up:      execSQL(clearHistoryMigration),
+	},
+	{
+		version: 16,
+		name:    "read_cursors",
+		up:      execSQL(readCursorsMigration),
+	},
+	{
+		version: 17,
+		name:    "event_log",
+		up:      execSQL(eventLogMigration),
+	},
+	{
+		version: 18,
+		name:    "member_join_watermark",
+		up:      execSQL(memberJoinWatermarkMigration),
+	},
+}
+
+// groupConversationsMigration 群聊支持
+// conversations 表重建以去掉 UNIQUE(user_a_id, user_b_id)，单聊唯一性改由部分索引保证；
+// messages 表重建以允许 receiver_id 为空（群聊消息没有单一接收者）。
+var groupConversationsMigration = `
+CREATE TABLE conversations_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL DEFAULT 'direct',
+    user_a_id INTEGER,
+    user_b_id INTEGER,
+    title TEXT NOT NULL DEFAULT '',
+    avatar_id INTEGER,
+    created_by INTEGER,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    FOREIGN KEY (user_a_id) REFERENCES users(id),
+    FOREIGN KEY (user_b_id) REFERENCES users(id),
+    FOREIGN KEY (avatar_id) REFERENCES media(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+INSERT INTO conversations_new (id, type, user_a_id, user_b_id, created_at, updated_at)
+SELECT id, 'direct', user_a_id, user_b_id, created_at, updated_at FROM conversations;
+DROP TABLE conversations;
+ALTER TABLE conversations_new RENAME TO conversations;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_direct ON conversations(user_a_id, user_b_id) WHERE type = 'direct';
+CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(updated_at DESC);
+
+CREATE TABLE messages_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id INTEGER NOT NULL,
+    sender_id INTEGER NOT NULL,
+    receiver_id INTEGER,
+    type TEXT NOT NULL,
+    content TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'sent',
+    created_at INTEGER NOT NULL,
+    synced_at INTEGER,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id),
+    FOREIGN KEY (sender_id) REFERENCES users(id),
+    FOREIGN KEY (receiver_id) REFERENCES users(id)
+);
+INSERT INTO messages_new (id, conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at)
+SELECT id, conversation_id, sender_id, receiver_id, type, content, status, created_at, synced_at FROM messages;
+DROP TABLE messages;
+ALTER TABLE messages_new RENAME TO messages;
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_receiver_status ON messages(receiver_id, status) WHERE status != 'read';
+
+-- 会话成员表（单聊双方同样登记为成员）
+CREATE TABLE IF NOT EXISTS conversation_members (
+    conversation_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL DEFAULT 'member',
+    joined_at INTEGER NOT NULL,
+    last_read_message_id INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (conversation_id, user_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_conversation_members_user ON conversation_members(user_id);
+
+INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, role, joined_at)
+SELECT id, user_a_id, 'member', created_at FROM conversations WHERE type = 'direct';
+INSERT OR IGNORE INTO conversation_members (conversation_id, user_id, role, joined_at)
+SELECT id, user_b_id, 'member', created_at FROM conversations WHERE type = 'direct';
+`
+
+// messageEditsMigration 消息编辑
+// edited_at 标记最近一次编辑时间，message_revisions 保存每次编辑前的内容用于审计
+var messageEditsMigration = `
+ALTER TABLE messages ADD COLUMN edited_at INTEGER;
+
+CREATE TABLE IF NOT EXISTS message_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    edited_at INTEGER NOT NULL,
+    FOREIGN KEY (message_id) REFERENCES messages(id)
+);
+CREATE INDEX IF NOT EXISTS idx_message_revisions_message ON message_revisions(message_id, id);
+`
+
+// messageRecallDeleteMigration 撤回与仅自己删除
+// 撤回的消息保留一条清空内容的墓碑记录（recalled_at 非空）；仅自己删除记录在 message_deletions 中
+var messageRecallDeleteMigration = `
+ALTER TABLE messages ADD COLUMN recalled_at INTEGER;
+
+CREATE TABLE IF NOT EXISTS message_deletions (
+    message_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    deleted_at INTEGER NOT NULL,
+    PRIMARY KEY (message_id, user_id),
+    FOREIGN KEY (message_id) REFERENCES messages(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+`
+
+// messageReplyToMigration 引用回复
+// 不加外键：被引用的消息即使被物理删除，回复本身仍应保留
+var messageReplyToMigration = `
+ALTER TABLE messages ADD COLUMN reply_to_id INTEGER;
+`
+
+// messageReactionsMigration 表情回应
+var messageReactionsMigration = `
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    emoji TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (message_id, user_id, emoji),
+    FOREIGN KEY (message_id) REFERENCES messages(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+`
+
+// messageClientMsgIDMigration 客户端消息ID
+// 同一发送者的 client_msg_id 唯一，用于重发时去重；未携带的消息为NULL，不参与唯一性检查
+var messageClientMsgIDMigration = `
+ALTER TABLE messages ADD COLUMN client_msg_id TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_client_msg_id ON messages(sender_id, client_msg_id) WHERE client_msg_id IS NOT NULL;
+`
+
+// scheduledMessagesMigration 定时消息
+var scheduledMessagesMigration = `
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sender_id INTEGER NOT NULL,
+    conversation_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    content TEXT NOT NULL,
+    reply_to_id INTEGER,
+    send_at INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    message_id INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    FOREIGN KEY (sender_id) REFERENCES users(id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages(status, send_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_sender ON scheduled_messages(sender_id, status);
+`
+
+// disappearingMessagesMigration 会话阅后即焚时长与消息过期时间
+var disappearingMessagesMigration = `
+ALTER TABLE conversations ADD COLUMN disappear_after INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN expires_at INTEGER;
+CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages(expires_at) WHERE expires_at IS NOT NULL;
+`
+
+// messagePinsMigration 会话置顶消息
+var messagePinsMigration = `
+CREATE TABLE IF NOT EXISTS message_pins (
+    conversation_id INTEGER NOT NULL,
+    message_id INTEGER NOT NULL,
+    pinned_by INTEGER NOT NULL,
+    pinned_at INTEGER NOT NULL,
+    PRIMARY KEY (conversation_id, message_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id),
+    FOREIGN KEY (message_id) REFERENCES messages(id),
+    FOREIGN KEY (pinned_by) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_message_pins_message ON message_pins(message_id);
+`
+
+// messageStarsMigration 用户收藏的消息
+var messageStarsMigration = `
+CREATE TABLE IF NOT EXISTS message_stars (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    message_id INTEGER NOT NULL,
+    conversation_id INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    UNIQUE (user_id, message_id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (message_id) REFERENCES messages(id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+);
+CREATE INDEX IF NOT EXISTS idx_message_stars_message ON message_stars(message_id);
+`
+
+// draftsMigration 会话草稿（每个用户每个会话一份）
+var draftsMigration = `
+CREATE TABLE IF NOT EXISTS drafts (
+    user_id INTEGER NOT NULL,
+    conversation_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    reply_to_id INTEGER,
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, conversation_id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+);
+`
+
+// messageForwardingMigration 转发消息的来源（最初的消息、发送者和发送时间）
+var messageForwardingMigration = `
+ALTER TABLE messages ADD COLUMN forwarded_message_id INTEGER;
+ALTER TABLE messages ADD COLUMN forwarded_sender_id INTEGER;
+ALTER TABLE messages ADD COLUMN forwarded_at INTEGER;
+`
+
+// pollsMigration 投票记录（多选时每个选项一行）和投票关闭记录
+var pollsMigration = `
+CREATE TABLE IF NOT EXISTS poll_votes (
+    message_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    option_index INTEGER NOT NULL,
+    voted_at INTEGER NOT NULL,
+    PRIMARY KEY (message_id, user_id, option_index),
+    FOREIGN KEY (message_id) REFERENCES messages(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS poll_closures (
+    message_id INTEGER PRIMARY KEY,
+    closed_by INTEGER NOT NULL,
+    closed_at INTEGER NOT NULL,
+    FOREIGN KEY (message_id) REFERENCES messages(id),
+    FOREIGN KEY (closed_by) REFERENCES users(id)
+);
+`
+
+// conversationSettingsMigration 成员对会话的个人设置（归档、免打扰、隐藏、置顶）
+var conversationSettingsMigration = `
+ALTER TABLE conversation_members ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE conversation_members ADD COLUMN muted_until INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE conversation_members ADD COLUMN hidden INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE conversation_members ADD COLUMN pinned_at INTEGER NOT NULL DEFAULT 0;
+`
+
+// clearHistoryMigration 成员清空聊天记录的水位（只对自己隐藏该ID及之前的消息）
+var clearHistoryMigration = `
+ALTER TABLE conversation_members ADD COLUMN cleared_message_id INTEGER NOT NULL DEFAULT 0;
+`
+
+// readCursorsMigration 单聊的已读状态改由成员的已读位置表示
+// 按已有的消息状态回填单聊成员的已读位置（取其已读的最新一条消息）
+var readCursorsMigration = `
+UPDATE conversation_members SET last_read_message_id = MAX(last_read_message_id, COALESCE((
+	SELECT MAX(id) FROM messages
+	WHERE messages.conversation_id = conversation_members.conversation_id
+		AND messages.receiver_id = conversation_members.user_id
+		AND messages.status = 'read'
+), 0));
+`
+
+// eventLogMigration 实时事件日志（每个用户只保留最近的一部分，供 SSE 断线重连后补发）
+var eventLogMigration = `
+CREATE TABLE IF NOT EXISTS event_log (
+    user_id INTEGER NOT NULL,
+    event_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, event_id)
+);
+`
+
+// memberJoinWatermarkMigration 成员加入时会话的最新消息ID（该ID及之前的消息对新成员不可见）
+// 按加入时间回填已有的群成员，单聊成员从会话创建起即可见全部消息
+var memberJoinWatermarkMigration = `
+ALTER TABLE conversation_members ADD COLUMN joined_message_id INTEGER NOT NULL DEFAULT 0;
+UPDATE conversation_members SET joined_message_id = COALESCE((
+	SELECT MAX(id) FROM messages
+	WHERE messages.conversation_id = conversation_members.conversation_id
+		AND messages.created_at < conversation_members.joined_at
+), 0)
+WHERE conversation_id IN (SELECT id FROM conversations WHERE type = 'group');
+`
+
+// migrate 执行尚未应用的迁移
+// 必须在开启外键约束之前调用，否则重建表时会触发外键检查
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at INTEGER NOT NULL
+		)
+	`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("get schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", m.version, err)
+		}
+
+		if err := m.up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d (%s): %w", m.version, m.name, err)
+		}
+
+		if _, err := tx.Exec(
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.version, m.name, time.Now().Unix(),
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", m.version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", m.version, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package dal
+
+import (
+	"fmt"
+	"zmessage/server/models"
+)
+
+// pinnedVisible 只保留仍然可见的置顶消息（已撤回或已过期的消息不再展示）
+const pinnedVisible = `EXISTS (
+	SELECT 1 FROM messages WHERE messages.id = message_pins.message_id AND ` + notRecalled + ` AND ` + notExpired + `
+)`
+
+type pinDAL struct {
+	db DB
+}
+
+func NewPinDAL(db DB) PinDAL {
+	return &pinDAL{db: db}
+}
+
+// Add 置顶消息（已置顶时返回 ErrDuplicate）
+func (d *pinDAL) Add(pin *models.MessagePin) error {
+	query := `
+		INSERT INTO message_pins (conversation_id, message_id, pinned_by, pinned_at)
+		VALUES (?, ?, ?, ?)
+	`
+	_, err := d.db.Exec(query, pin.ConversationID, pin.MessageID, pin.PinnedBy, pin.PinnedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("add pin: %w", err)
+	}
+	return nil
+}
+
+// Remove 取消置顶
+func (d *pinDAL) Remove(convID int64, messageID int64) error {
+	query := `DELETE FROM message_pins WHERE conversation_id = ? AND message_id = ?`
+	result, err := d.db.Exec(query, convID, messageID)
+	if err != nil {
+		return fmt.Errorf("remove pin: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByConversation 获取会话的置顶记录（最近置顶的在前）
+func (d *pinDAL) GetByConversation(convID int64) ([]*models.MessagePin, error) {
+	query := `
+		SELECT conversation_id, message_id, pinned_by, pinned_at
+		FROM message_pins
+		WHERE conversation_id = ? AND ` + pinnedVisible + `
+		ORDER BY pinned_at DESC, rowid DESC
+	`
+	rows, err := d.db.Query(query, convID)
+	if err != nil {
+		return nil, fmt.Errorf("get pins: %w", err)
+	}
+	defer rows.Close()
+
+	var pins []*models.MessagePin
+	for rows.Next() {
+		p := &models.MessagePin{}
+		if err := rows.Scan(&p.ConversationID, &p.MessageID, &p.PinnedBy, &p.PinnedAt); err != nil {
+			return nil, fmt.Errorf("scan pin: %w", err)
+		}
+		pins = append(pins, p)
+	}
+
+	return pins, rows.Err()
+}
+
+// Count 统计会话中可见的置顶消息数
+func (d *pinDAL) Count(convID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM message_pins WHERE conversation_id = ? AND ` + pinnedVisible
+	var count int
+	if err := d.db.QueryRow(query, convID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count pins: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,87 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"zmessage/server/models"
+)
+
+type pollDAL struct {
+	db DB
+}
+
+func NewPollDAL(db DB) PollDAL {
+	return &pollDAL{db: db}
+}
+
+// SetVotes 替换用户在投票中的选择（options 为空时撤回投票）
+func (d *pollDAL) SetVotes(messageID int64, userID int64, options []int, votedAt int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM poll_votes WHERE message_id = ? AND user_id = ?`, messageID, userID); err != nil {
+		return fmt.Errorf("clear poll votes: %w", err)
+	}
+	for _, option := range options {
+		query := `INSERT INTO poll_votes (message_id, user_id, option_index, voted_at) VALUES (?, ?, ?, ?)`
+		if _, err := tx.Exec(query, messageID, userID, option, votedAt); err != nil {
+			return fmt.Errorf("insert poll vote: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetVotes 获取投票的全部记录（按投票时间先后）
+func (d *pollDAL) GetVotes(messageID int64) ([]*models.PollVote, error) {
+	query := `
+		SELECT message_id, user_id, option_index, voted_at
+		FROM poll_votes
+		WHERE message_id = ?
+		ORDER BY voted_at ASC, user_id ASC, option_index ASC
+	`
+	rows, err := d.db.Query(query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("query poll votes: %w", err)
+	}
+	defer rows.Close()
+
+	var votes []*models.PollVote
+	for rows.Next() {
+		v := &models.PollVote{}
+		if err := rows.Scan(&v.MessageID, &v.UserID, &v.OptionIndex, &v.VotedAt); err != nil {
+			return nil, fmt.Errorf("scan poll vote: %w", err)
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+// Close 关闭投票（已关闭时返回 ErrDuplicate）
+func (d *pollDAL) Close(closure *models.PollClosure) error {
+	query := `INSERT INTO poll_closures (message_id, closed_by, closed_at) VALUES (?, ?, ?)`
+	if _, err := d.db.Exec(query, closure.MessageID, closure.ClosedBy, closure.ClosedAt); err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("close poll: %w", err)
+	}
+	return nil
+}
+
+// GetClosure 获取投票的关闭记录（未关闭时返回 ErrNotFound）
+func (d *pollDAL) GetClosure(messageID int64) (*models.PollClosure, error) {
+	query := `SELECT message_id, closed_by, closed_at FROM poll_closures WHERE message_id = ?`
+	c := &models.PollClosure{}
+	err := d.db.QueryRow(query, messageID).Scan(&c.MessageID, &c.ClosedBy, &c.ClosedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("get poll closure: %w", err)
+	}
+	return c, nil
+}
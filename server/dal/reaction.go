@@ -0,0 +1,85 @@
+package dal
+
+import (
+	"fmt"
+	"strings"
+	"zmessage/server/models"
+)
+
+type reactionDAL struct {
+	db DB
+}
+
+func NewReactionDAL(db DB) ReactionDAL {
+	return &reactionDAL{db: db}
+}
+
+func (d *reactionDAL) Add(reaction *models.Reaction) error {
+	query := `
+		INSERT INTO message_reactions (message_id, user_id, emoji, created_at)
+		VALUES (?, ?, ?, ?)
+	`
+	_, err := d.db.Exec(query, reaction.MessageID, reaction.UserID, reaction.Emoji, reaction.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("add reaction: %w", err)
+	}
+	return nil
+}
+
+func (d *reactionDAL) Remove(messageID int64, userID int64, emoji string) error {
+	query := `DELETE FROM message_reactions WHERE message_id = ? AND user_id = ? AND emoji = ?`
+	result, err := d.db.Exec(query, messageID, userID, emoji)
+	if err != nil {
+		return fmt.Errorf("remove reaction: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetByMessages 批量获取多条消息的表情回应（按添加顺序）
+func (d *reactionDAL) GetByMessages(messageIDs []int64) ([]*models.Reaction, error) {
+	if len(messageIDs) == 0 {
+		return []*models.Reaction{}, nil
+	}
+
+	placeholders := make([]string, len(messageIDs))
+	args := make([]interface{}, len(messageIDs))
+	for i, id := range messageIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	query := `
+		SELECT message_id, user_id, emoji, created_at
+		FROM message_reactions
+		WHERE message_id IN (` + strings.Join(placeholders, ",") + `)
+		ORDER BY created_at ASC, rowid ASC
+	`
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get reactions: %w", err)
+	}
+	defer rows.Close()
+
+	var reactions []*models.Reaction
+	for rows.Next() {
+		r := &models.Reaction{}
+		if err := rows.Scan(&r.MessageID, &r.UserID, &r.Emoji, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan reaction: %w", err)
+		}
+		reactions = append(reactions, r)
+	}
+
+	return reactions, nil
+}
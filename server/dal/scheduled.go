@@ -0,0 +1,196 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"zmessage/server/models"
+)
+
+// scheduledColumns 定时消息查询列
+const scheduledColumns = `
+	id, sender_id, conversation_id, type, content, COALESCE(reply_to_id, 0), send_at, status,
+	COALESCE(message_id, 0), error, created_at, updated_at
+`
+
+type scheduledMessageDAL struct {
+	db DB
+}
+
+func NewScheduledMessageDAL(db DB) ScheduledMessageDAL {
+	return &scheduledMessageDAL{db: db}
+}
+
+func (d *scheduledMessageDAL) Create(sm *models.ScheduledMessage) error {
+	query := `
+		INSERT INTO scheduled_messages (sender_id, conversation_id, type, content, reply_to_id, send_at, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := d.db.Exec(query,
+		sm.SenderID,
+		sm.ConversationID,
+		sm.Type,
+		sm.Content,
+		nullableID(sm.ReplyToID),
+		sm.SendAt,
+		sm.Status,
+		sm.CreatedAt,
+		sm.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create scheduled message: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("get last insert id: %w", err)
+	}
+
+	sm.ID = id
+	return nil
+}
+
+func (d *scheduledMessageDAL) GetByID(id int64) (*models.ScheduledMessage, error) {
+	query := `SELECT ` + scheduledColumns + ` FROM scheduled_messages WHERE id = ?`
+	sm, err := scanScheduled(d.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get scheduled message: %w", err)
+	}
+	return sm, nil
+}
+
+// GetBySender 获取用户的定时消息（status 为空时返回全部），按计划发送时间排序
+func (d *scheduledMessageDAL) GetBySender(senderID int64, status string) ([]*models.ScheduledMessage, error) {
+	query := `SELECT ` + scheduledColumns + ` FROM scheduled_messages WHERE sender_id = ?`
+	args := []interface{}{senderID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY send_at ASC, id ASC`
+
+	return d.query(query, args...)
+}
+
+// GetDue 获取已到期、尚未发送完成的定时消息（含上次启动时发送中断的消息）
+func (d *scheduledMessageDAL) GetDue(now int64, limit int) ([]*models.ScheduledMessage, error) {
+	query := `
+		SELECT ` + scheduledColumns + `
+		FROM scheduled_messages
+		WHERE status IN ('pending', 'sending') AND send_at <= ?
+		ORDER BY send_at ASC, id ASC
+		LIMIT ?
+	`
+	return d.query(query, now, limit)
+}
+
+// Update 修改待发送的定时消息
+func (d *scheduledMessageDAL) Update(sm *models.ScheduledMessage) error {
+	query := `
+		UPDATE scheduled_messages
+		SET type = ?, content = ?, send_at = ?, updated_at = ?
+		WHERE id = ? AND status = 'pending'
+	`
+	return d.exec("update scheduled message", query, sm.Type, sm.Content, sm.SendAt, sm.UpdatedAt, sm.ID)
+}
+
+// Claim 将到期消息标记为发送中（已是发送中的视为上次未完成，可再次认领）
+func (d *scheduledMessageDAL) Claim(id int64, now int64) error {
+	query := `
+		UPDATE scheduled_messages
+		SET status = 'sending', updated_at = ?
+		WHERE id = ? AND status IN ('pending', 'sending')
+	`
+	return d.exec("claim scheduled message", query, now, id)
+}
+
+// MarkSent 标记为已发送
+func (d *scheduledMessageDAL) MarkSent(id int64, messageID int64, now int64) error {
+	query := `
+		UPDATE scheduled_messages
+		SET status = 'sent', message_id = ?, updated_at = ?
+		WHERE id = ? AND status = 'sending'
+	`
+	return d.exec("mark scheduled message sent", query, messageID, now, id)
+}
+
+// MarkFailed 标记为发送失败
+func (d *scheduledMessageDAL) MarkFailed(id int64, reason string, now int64) error {
+	query := `
+		UPDATE scheduled_messages
+		SET status = 'failed', error = ?, updated_at = ?
+		WHERE id = ? AND status = 'sending'
+	`
+	return d.exec("mark scheduled message failed", query, reason, now, id)
+}
+
+// Cancel 取消待发送的定时消息
+func (d *scheduledMessageDAL) Cancel(id int64, now int64) error {
+	query := `
+		UPDATE scheduled_messages
+		SET status = 'canceled', updated_at = ?
+		WHERE id = ? AND status = 'pending'
+	`
+	return d.exec("cancel scheduled message", query, now, id)
+}
+
+// exec 执行条件更新，未命中任何行时返回 ErrNotFound
+func (d *scheduledMessageDAL) exec(op string, query string, args ...interface{}) error {
+	result, err := d.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (d *scheduledMessageDAL) query(query string, args ...interface{}) ([]*models.ScheduledMessage, error) {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query scheduled messages: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*models.ScheduledMessage
+	for rows.Next() {
+		sm, err := scanScheduled(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan scheduled message: %w", err)
+		}
+		list = append(list, sm)
+	}
+
+	return list, rows.Err()
+}
+
+// scanScheduled 扫描一行定时消息数据
+func scanScheduled(row rowScanner) (*models.ScheduledMessage, error) {
+	sm := &models.ScheduledMessage{}
+	err := row.Scan(
+		&sm.ID,
+		&sm.SenderID,
+		&sm.ConversationID,
+		&sm.Type,
+		&sm.Content,
+		&sm.ReplyToID,
+		&sm.SendAt,
+		&sm.Status,
+		&sm.MessageID,
+		&sm.Error,
+		&sm.CreatedAt,
+		&sm.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
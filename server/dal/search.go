@@ -0,0 +1,338 @@
+package dal
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+	"zmessage/server/models"
+)
+
+// 高亮标记：查询时使用不可见字符占位，转义内容后再替换为 HTML 标签
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// snippetRadius 非全文索引模式下高亮片段在命中位置两侧保留的字符数
+const snippetRadius = 20
+
+// minTrigramQuery trigram 分词器可匹配的最短关键词（字符数）
+const minTrigramQuery = 3
+
+// searchIndexSchema 全文索引（trigram 分词以支持中文子串匹配）
+// 索引自存可搜索文本而非引用 messages.content：文件、位置、投票等消息只索引其中可读的字段
+var searchIndexSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+    content,
+    tokenize = 'trigram'
+);
+`
+
+// searchText 返回消息可搜索文本的 SQL 表达式（alias 为消息表别名或触发器中的 new）
+// 文本消息为全文，文件为文件名，位置为地点名称，投票为问题和选项；其余类型（图片、语音、名片、系统通知）不可搜索
+func searchText(alias string) string {
+	c := alias + ".content"
+	return `(CASE ` + alias + `.type
+		WHEN 'text' THEN ` + c + `
+		WHEN 'file' THEN CASE WHEN json_valid(` + c + `) THEN json_extract(` + c + `, '$.name') END
+		WHEN 'location' THEN CASE WHEN json_valid(` + c + `) THEN json_extract(` + c + `, '$.label') END
+		WHEN 'poll' THEN CASE WHEN json_valid(` + c + `) THEN
+			COALESCE(json_extract(` + c + `, '$.question'), '') || ' ' ||
+			COALESCE((SELECT group_concat(value, ' ') FROM json_each(` + c + `, '$.options')), '') END
+	END)`
+}
+
+// indexMessage 将消息的可搜索文本写入全文索引（无可搜索文本时跳过）
+func indexMessage(alias string) string {
+	return `INSERT INTO messages_fts(rowid, content)
+		SELECT ` + alias + `.id, t FROM (SELECT ` + searchText(alias) + ` AS t) WHERE t IS NOT NULL AND t != '';`
+}
+
+// searchTriggers 保持全文索引与 messages 表同步的触发器
+var searchTriggers = `
+CREATE TRIGGER IF NOT EXISTS messages_fts_ai AFTER INSERT ON messages BEGIN
+    ` + indexMessage("new") + `
+END;
+CREATE TRIGGER IF NOT EXISTS messages_fts_ad AFTER DELETE ON messages BEGIN
+    DELETE FROM messages_fts WHERE rowid = old.id;
+END;
+CREATE TRIGGER IF NOT EXISTS messages_fts_au AFTER UPDATE OF content ON messages BEGIN
+    DELETE FROM messages_fts WHERE rowid = old.id;
+    ` + indexMessage("new") + `
+END;
+`
+
+// dropSearchTriggers 删除同步触发器（SQLite 未编译 FTS5 时，触发器会让所有写入失败）
+var dropSearchTriggers = `
+DROP TRIGGER IF EXISTS messages_fts_ai;
+DROP TRIGGER IF EXISTS messages_fts_ad;
+DROP TRIGGER IF EXISTS messages_fts_au;
+`
+
+// ensureSearchIndex 创建全文索引并回填已有消息，返回 FTS5 是否可用
+// 每次启动都会执行：触发器缺失（首次创建、或曾以不支持 FTS5 的版本运行过）时重建索引
+func ensureSearchIndex(db *sql.DB) (bool, error) {
+	if err := dropLegacySearchIndex(db); err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(searchIndexSchema); err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			if _, err := db.Exec(dropSearchTriggers); err != nil {
+				return false, fmt.Errorf("drop search triggers: %w", err)
+			}
+			return false, nil
+		}
+		return false, fmt.Errorf("create search index: %w", err)
+	}
+
+	var triggers int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'trigger' AND name IN ('messages_fts_ai', 'messages_fts_ad', 'messages_fts_au')
+	`).Scan(&triggers)
+	if err != nil {
+		return false, fmt.Errorf("check search triggers: %w", err)
+	}
+	if triggers == 3 {
+		return true, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(searchTriggers); err != nil {
+		return false, fmt.Errorf("create search triggers: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM messages_fts`); err != nil {
+		return false, fmt.Errorf("reset search index: %w", err)
+	}
+	backfill := `
+		INSERT INTO messages_fts(rowid, content)
+		SELECT id, t FROM (SELECT m.id, ` + searchText("m") + ` AS t FROM messages m)
+		WHERE t IS NOT NULL AND t != ''
+	`
+	if _, err := tx.Exec(backfill); err != nil {
+		return false, fmt.Errorf("backfill search index: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit search index: %w", err)
+	}
+	return true, nil
+}
+
+// dropLegacySearchIndex 删除旧版以 messages.content 为外部内容的索引（收录了所有类型的原始内容），随后按新结构重建
+func dropLegacySearchIndex(db *sql.DB) error {
+	var schema string
+	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'messages_fts'`).Scan(&schema)
+	if err == sql.ErrNoRows || (err == nil && !strings.Contains(schema, "content_rowid")) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check search index: %w", err)
+	}
+
+	if _, err := db.Exec(dropSearchTriggers); err != nil {
+		return fmt.Errorf("drop search triggers: %w", err)
+	}
+	if _, err := db.Exec(`DROP TABLE messages_fts`); err != nil && !strings.Contains(err.Error(), "no such module") {
+		return fmt.Errorf("drop legacy search index: %w", err)
+	}
+	return nil
+}
+
+type searchDAL struct {
+	db  DB
+	fts bool
+}
+
+// NewSearchDAL 创建搜索数据访问，fts 为 false 时退化为 LIKE 匹配
+func NewSearchDAL(db DB, fts bool) SearchDAL {
+	return &searchDAL{db: db, fts: fts}
+}
+
+// SearchMessages 搜索用户所在会话中的消息（按ID倒序）
+func (d *searchDAL) SearchMessages(q *models.MessageSearchQuery) ([]*models.MessageSearchHit, error) {
+	useFTS := d.fts && utf8.RuneCountInString(q.Query) >= minTrigramQuery
+
+	var query string
+	var args []interface{}
+	if useFTS {
+		query = `
+			SELECT ` + prefixColumns("m") + `,
+				snippet(messages_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 32)
+			FROM messages_fts
+			JOIN messages m ON m.id = messages_fts.rowid
+			WHERE messages_fts MATCH ?
+		`
+		args = append(args, ftsPhrase(q.Query))
+	} else {
+		query = `
+			SELECT ` + prefixColumns("m") + `, ` + searchText("m") + `
+			FROM messages m
+			WHERE ` + searchText("m") + ` LIKE ? ESCAPE '\'
+		`
+		args = append(args, "%"+escapeLike(q.Query)+"%")
+	}
+
+	// 只搜索用户所在会话中可见的消息
+	query += `
+		AND m.conversation_id IN (SELECT conversation_id FROM conversation_members WHERE user_id = ?)
+		AND m.recalled_at IS NULL
+		AND (m.expires_at IS NULL OR m.expires_at > CAST(strftime('%s', 'now') AS INTEGER))
+		AND NOT EXISTS (SELECT 1 FROM message_deletions md WHERE md.message_id = m.id AND md.user_id = ?)
+		AND m.id > COALESCE((
+			SELECT MAX(cleared_message_id, joined_message_id) FROM conversation_members cc
+			WHERE cc.conversation_id = m.conversation_id AND cc.user_id = ?
+		), 0)
+	`
+	args = append(args, q.UserID, q.UserID, q.UserID)
+
+	if q.ConversationID > 0 {
+		query += ` AND m.conversation_id = ?`
+		args = append(args, q.ConversationID)
+	}
+	if q.SenderID > 0 {
+		query += ` AND m.sender_id = ?`
+		args = append(args, q.SenderID)
+	}
+	if q.Type != "" {
+		query += ` AND m.type = ?`
+		args = append(args, q.Type)
+	}
+	if q.From > 0 {
+		query += ` AND m.created_at >= ?`
+		args = append(args, q.From)
+	}
+	if q.To > 0 {
+		query += ` AND m.created_at <= ?`
+		args = append(args, q.To)
+	}
+	if q.BeforeID > 0 {
+		query += ` AND m.id < ?`
+		args = append(args, q.BeforeID)
+	}
+
+	query += ` ORDER BY m.id DESC LIMIT ?`
+	args = append(args, q.Limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []*models.MessageSearchHit
+	for rows.Next() {
+		var snippet string
+		msg, err := scanMessage(rows, &snippet)
+		if err != nil {
+			return nil, fmt.Errorf("scan search hit: %w", err)
+		}
+		if !useFTS {
+			snippet = likeSnippet(snippet, q.Query)
+		}
+		hits = append(hits, &models.MessageSearchHit{
+			Message: msg,
+			Snippet: renderSnippet(snippet),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	msgs := make([]*models.Message, len(hits))
+	for i, hit := range hits {
+		msgs[i] = hit.Message
+	}
+	if err := applyReadCursors(d.db, msgs); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// prefixColumns 为消息查询列加上表别名（COALESCE 等函数只为其第一个参数加别名）
+func prefixColumns(alias string) string {
+	var cols []string
+	depth, start := 0, 0
+	for i, r := range messageColumns {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			cols = append(cols, strings.TrimSpace(messageColumns[start:i]))
+			start = i + 1
+		}
+	}
+	cols = append(cols, strings.TrimSpace(messageColumns[start:]))
+
+	for i, col := range cols {
+		if open := strings.Index(col, "("); open >= 0 {
+			cols[i] = col[:open+1] + alias + "." + col[open+1:]
+		} else {
+			cols[i] = alias + "." + col
+		}
+	}
+	return strings.Join(cols, ", ")
+}
+
+// ftsPhrase 将用户输入转换为 FTS5 短语查询，避免语法字符被解释
+func ftsPhrase(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
+
+// likeSnippet 在内容中定位关键词并截取带标记的片段（与 SQLite LIKE 一致，仅不区分 ASCII 大小写）
+func likeSnippet(content, keyword string) string {
+	idx := strings.Index(asciiLower(content), asciiLower(keyword))
+	if idx < 0 {
+		return content
+	}
+
+	before := []rune(content[:idx])
+	match := content[idx : idx+len(keyword)]
+	after := []rune(content[idx+len(keyword):])
+
+	prefix, suffix := "", ""
+	if len(before) > snippetRadius {
+		before = before[len(before)-snippetRadius:]
+		prefix = "…"
+	}
+	if len(after) > snippetRadius {
+		after = after[:snippetRadius]
+		suffix = "…"
+	}
+	return prefix + string(before) + snippetOpen + match + snippetClose + string(after) + suffix
+}
+
+// asciiLower 仅将 ASCII 大写字母转为小写，保持字节长度不变以便按偏移截取原文
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// renderSnippet 转义片段内容并将占位标记替换为 <mark> 标签
+func renderSnippet(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, snippetOpen, "<mark>")
+	return strings.ReplaceAll(s, snippetClose, "</mark>")
+}
@@ -0,0 +1,159 @@
+package dal
+
+import (
+	"fmt"
+	"strings"
+	"zmessage/server/models"
+)
+
+type starDAL struct {
+	db DB
+}
+
+func NewStarDAL(db DB) StarDAL {
+	return &starDAL{db: db}
+}
+
+// Add 收藏消息（已收藏时返回 ErrDuplicate）
+func (d *starDAL) Add(star *models.MessageStar) error {
+	query := `
+		INSERT INTO message_stars (user_id, message_id, conversation_id, created_at)
+		VALUES (?, ?, ?, ?)
+	`
+	result, err := d.db.Exec(query, star.UserID, star.MessageID, star.ConversationID, star.StarredAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicate
+		}
+		return fmt.Errorf("add star: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("get last insert id: %w", err)
+	}
+
+	star.ID = id
+	return nil
+}
+
+// Remove 取消收藏
+func (d *starDAL) Remove(userID int64, messageID int64) error {
+	query := `DELETE FROM message_stars WHERE user_id = ? AND message_id = ?`
+	result, err := d.db.Exec(query, userID, messageID)
+	if err != nil {
+		return fmt.Errorf("remove star: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RemoveByMessage 删除所有用户对该消息的收藏（消息被撤回时调用）
+func (d *starDAL) RemoveByMessage(messageID int64) error {
+	if _, err := d.db.Exec(`DELETE FROM message_stars WHERE message_id = ?`, messageID); err != nil {
+		return fmt.Errorf("remove stars by message: %w", err)
+	}
+	return nil
+}
+
+// GetByUser 获取用户的收藏（最近收藏的在前），只返回仍在所在会话中可见的消息
+func (d *starDAL) GetByUser(q *models.StarredMessageQuery) ([]*models.StarredMessage, error) {
+	query := `
+		SELECT ` + prefixColumns("m") + `, s.id, s.created_at
+		FROM message_stars s
+		JOIN messages m ON m.id = s.message_id
+		WHERE s.user_id = ?
+			AND s.conversation_id IN (SELECT conversation_id FROM conversation_members WHERE user_id = ?)
+			AND ` + notRecalled + ` AND ` + notExpired + `
+	`
+	args := []interface{}{q.UserID, q.UserID}
+
+	if q.ConversationID > 0 {
+		query += ` AND s.conversation_id = ?`
+		args = append(args, q.ConversationID)
+	}
+	if q.Type != "" {
+		query += ` AND m.type = ?`
+		args = append(args, q.Type)
+	}
+	if q.BeforeID > 0 {
+		query += ` AND s.id < ?`
+		args = append(args, q.BeforeID)
+	}
+
+	query += ` ORDER BY s.id DESC LIMIT ?`
+	args = append(args, q.Limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get starred messages: %w", err)
+	}
+	defer rows.Close()
+
+	var stars []*models.StarredMessage
+	for rows.Next() {
+		star := &models.StarredMessage{}
+		msg, err := scanMessage(rows, &star.ID, &star.StarredAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan starred message: %w", err)
+		}
+		star.Message = msg
+		stars = append(stars, star)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	msgs := make([]*models.Message, len(stars))
+	for i, star := range stars {
+		msgs[i] = star.Message
+	}
+	if err := applyReadCursors(d.db, msgs); err != nil {
+		return nil, err
+	}
+	return stars, nil
+}
+
+// GetStarredIDs 批量查询用户收藏了其中哪些消息
+func (d *starDAL) GetStarredIDs(userID int64, messageIDs []int64) (map[int64]bool, error) {
+	starred := make(map[int64]bool)
+	if len(messageIDs) == 0 {
+		return starred, nil
+	}
+
+	placeholders := make([]string, len(messageIDs))
+	args := []interface{}{userID}
+	for i, id := range messageIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+
+	query := `
+		SELECT message_id FROM message_stars
+		WHERE user_id = ? AND message_id IN (` + strings.Join(placeholders, ",") + `)
+	`
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get starred ids: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan starred id: %w", err)
+		}
+		starred[id] = true
+	}
+
+	return starred, rows.Err()
+}
@@ -0,0 +1,181 @@
+package hub
+
+import (
+	"log"
+	"sync"
+)
+
+// Event 实时事件
+type Event struct {
+	Type   string      // 事件类型（即 SSE 的 event 名，如 chat、message_edited）
+	Data   interface{} // 事件数据（SSE 以 JSON 下发，WebSocket 按数据类型转换为协议消息）
+	Origin string      // 发起事件的连接ID（可选，订阅者可据此跳过发起方）
+	Seq    int64       // 用户内单调递增的变更序号（由 Recorder 分配，未记录的瞬时事件为0）
+}
+
+// Recorder 事件记录器
+// 在投递前为用户的事件分配递增序号并持久化，供断线重连和增量同步使用
+type Recorder interface {
+	// Record 记录事件并返回序号，不需要记录的事件返回0
+	Record(userID int64, event *Event) (int64, error)
+}
+
+// Deliver 投递函数，返回是否投递成功
+// 在发布者的协程中同步调用（不持有事件中心的锁），不能阻塞；可能被多个发布者并发调用
+type Deliver func(userID int64, event *Event) bool
+
+// Hub 事件中心
+// 服务按用户发布领域事件，SSE、WebSocket 等传输层订阅后推送给各自的连接
+type Hub interface {
+	// Publish 向用户发布事件，返回投递成功的订阅数
+	Publish(userID int64, event *Event) int
+
+	// Subscribe 订阅用户的事件（userID 为 AllUsers 时订阅所有用户），topics 为空时订阅全部事件类型
+	// 返回取消订阅函数，返回后不会再开始新的投递（已在进行中的发布仍可能完成投递）
+	Subscribe(userID int64, topics []string, deliver Deliver) (unsubscribe func())
+}
+
+// AllUsers 订阅所有用户的事件
+const AllUsers int64 = 0
+
+// Option 事件中心配置项
+type Option func(*hub)
+
+// WithRecorder 设置事件记录器
+func WithRecorder(r Recorder) Option {
+	return func(h *hub) {
+		h.recorder = r
+	}
+}
+
+// New 创建事件中心
+func New(opts ...Option) Hub {
+	h := &hub{
+		subs:  make(map[int64]map[uint64]*subscription),
+		locks: make(map[int64]*userLock),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// subscription 订阅
+type subscription struct {
+	topics  map[string]bool // 为空时订阅全部事件类型
+	deliver Deliver
+}
+
+// userLock 用户的序号锁（引用计数归零时从表中移除）
+type userLock struct {
+	sync.Mutex
+	refs int
+}
+
+// hub 事件中心实现
+type hub struct {
+	mu     sync.RWMutex
+	nextID uint64
+	subs   map[int64]map[uint64]*subscription // userID -> 订阅（AllUsers 为全局订阅）
+
+	recorder Recorder
+	locksMu  sync.Mutex
+	locks    map[int64]*userLock // 序号按用户分配，只需串行化同一用户的记录
+}
+
+// Publish 向用户发布事件
+// 设置了记录器时先记录事件，再把带序号的副本投递给订阅者（同一事件可能发布给多个用户，序号按用户分配）
+// 投递时不持有任何锁；同一用户的并发发布可能不按序号顺序到达，客户端以序号为准
+func (h *hub) Publish(userID int64, event *Event) int {
+	if h.recorder != nil {
+		unlock := h.lockUser(userID)
+		seq, err := h.recorder.Record(userID, event)
+		unlock()
+		if err != nil {
+			log.Printf("[Hub] Record event %s for user %d failed: %v", event.Type, userID, err)
+		}
+		if seq > 0 {
+			recorded := *event
+			recorded.Seq = seq
+			event = &recorded
+		}
+	}
+
+	delivered := 0
+	for _, sub := range h.subscribers(userID, event.Type) {
+		if sub.deliver(userID, event) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// subscribers 取出订阅了用户该类型事件的订阅（用户订阅在前，全局订阅在后）
+func (h *hub) subscribers(userID int64, eventType string) []*subscription {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var subs []*subscription
+	for _, uid := range []int64{userID, AllUsers} {
+		for _, sub := range h.subs[uid] {
+			if len(sub.topics) > 0 && !sub.topics[eventType] {
+				continue
+			}
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
+
+// lockUser 获取用户的序号锁，返回解锁函数
+func (h *hub) lockUser(userID int64) func() {
+	h.locksMu.Lock()
+	l := h.locks[userID]
+	if l == nil {
+		l = &userLock{}
+		h.locks[userID] = l
+	}
+	l.refs++
+	h.locksMu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		h.locksMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(h.locks, userID)
+		}
+		h.locksMu.Unlock()
+	}
+}
+
+// Subscribe 订阅事件
+func (h *hub) Subscribe(userID int64, topics []string, deliver Deliver) func() {
+	sub := &subscription{deliver: deliver}
+	if len(topics) > 0 {
+		sub.topics = make(map[string]bool, len(topics))
+		for _, t := range topics {
+			sub.topics[t] = true
+		}
+	}
+
+	h.mu.Lock()
+	h.nextID++
+	id := h.nextID
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[uint64]*subscription)
+	}
+	h.subs[userID][id] = sub
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[userID], id)
+		if len(h.subs[userID]) == 0 {
+			delete(h.subs, userID)
+		}
+	}
+}
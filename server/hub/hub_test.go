@@ -0,0 +1,128 @@
+package hub
+
+import (
+	"testing"
+)
+
+func TestHub_PublishSubscribe(t *testing.T) {
+	h := New()
+
+	var got []string
+	record := func(name string) Deliver {
+		return func(userID int64, e *Event) bool {
+			got = append(got, name)
+			return true
+		}
+	}
+
+	unsubscribe := h.Subscribe(1, nil, record("user1"))
+	h.Subscribe(2, nil, record("user2"))
+	h.Subscribe(AllUsers, []string{"chat"}, record("chat"))
+
+	// 用户订阅收到自己的全部事件，全局订阅只收到指定类型
+	if n := h.Publish(1, &Event{Type: "chat"}); n != 2 {
+		t.Errorf("expected 2 deliveries, got %d", n)
+	}
+	if n := h.Publish(1, &Event{Type: "typing"}); n != 1 {
+		t.Errorf("expected 1 delivery, got %d", n)
+	}
+	if len(got) != 3 || got[2] != "user1" {
+		t.Errorf("unexpected deliveries: %v", got)
+	}
+
+	// 取消订阅后不再投递
+	unsubscribe()
+	unsubscribe()
+	got = nil
+	if n := h.Publish(1, &Event{Type: "typing"}); n != 0 || len(got) != 0 {
+		t.Errorf("expected no delivery after unsubscribe, got %d %v", n, got)
+	}
+
+	// 投递失败不计数
+	h.Subscribe(3, nil, func(int64, *Event) bool { return false })
+	if n := h.Publish(3, &Event{Type: "chat"}); n != 1 {
+		t.Errorf("expected only the global subscription to count, got %d", n)
+	}
+}
+
+// seqRecorder 按用户递增分配序号的记录器，不记录 typing 事件
+type seqRecorder struct {
+	seqs map[int64]int64
+}
+
+func (r *seqRecorder) Record(userID int64, event *Event) (int64, error) {
+	if event.Type == "typing" {
+		return 0, nil
+	}
+	r.seqs[userID]++
+	return r.seqs[userID], nil
+}
+
+func TestHub_Recorder(t *testing.T) {
+	h := New(WithRecorder(&seqRecorder{seqs: make(map[int64]int64)}))
+
+	var got []int64
+	h.Subscribe(AllUsers, nil, func(userID int64, e *Event) bool {
+		got = append(got, e.Seq)
+		return true
+	})
+
+	// 同一事件发布给多个用户时，各自分配序号且不修改原事件
+	event := &Event{Type: "chat"}
+	h.Publish(1, event)
+	h.Publish(2, event)
+	h.Publish(1, event)
+	h.Publish(1, &Event{Type: "typing"})
+	if event.Seq != 0 {
+		t.Errorf("expected published event to be left untouched, got seq %d", event.Seq)
+	}
+	want := []int64{1, 1, 2, 0}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d deliveries, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected seqs %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+// blockingRecorder 记录用户1的事件时阻塞，直到 release 关闭
+type blockingRecorder struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingRecorder) Record(userID int64, event *Event) (int64, error) {
+	if userID == 1 {
+		close(r.started)
+		<-r.release
+	}
+	return 1, nil
+}
+
+func TestHub_PublishNotSerialized(t *testing.T) {
+	r := &blockingRecorder{started: make(chan struct{}), release: make(chan struct{})}
+	h := New(WithRecorder(r))
+
+	// 投递时不持有锁，投递函数中可以再订阅
+	h.Subscribe(2, nil, func(int64, *Event) bool {
+		h.Subscribe(3, nil, func(int64, *Event) bool { return true })
+		return true
+	})
+
+	done := make(chan struct{})
+	go func() {
+		h.Publish(1, &Event{Type: "chat"})
+		close(done)
+	}()
+	<-r.started
+
+	// 用户1的记录阻塞时，其他用户的发布不受影响
+	if n := h.Publish(2, &Event{Type: "chat"}); n != 1 {
+		t.Errorf("expected 1 delivery, got %d", n)
+	}
+	close(r.release)
+	<-done
+}
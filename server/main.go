@@ -11,9 +11,16 @@ import (
 
 	"zmessage/server/api"
 	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/modules/changelog"
+	"zmessage/server/modules/disappear"
+	"zmessage/server/modules/group"
 	"zmessage/server/modules/media"
 	"zmessage/server/modules/message"
+	"zmessage/server/modules/poll"
+	"zmessage/server/modules/schedule"
 	"zmessage/server/modules/share"
+	"zmessage/server/modules/typing"
 	"zmessage/server/modules/user"
 	"zmessage/server/sse"
 	"zmessage/server/ws"
@@ -39,11 +46,21 @@ func main() {
 	}
 	defer dalMgr.Close()
 
+	// 实时事件中心：各服务发布事件，WebSocket 和 SSE 订阅后推送给客户端
+	// 事件先写入变更日志并分配序号，供 SSE 断线补发和增量同步使用
+	changeSvc := changelog.NewService(dalMgr)
+	eventHub := hub.New(hub.WithRecorder(changeSvc))
+
 	userSvc := user.NewService(dalMgr, "test-secret")
 	mediaSvc := media.NewService(dalMgr, dataDir+"/media")
-	msgSvc := message.NewService(dalMgr)
+	msgSvc := message.NewService(dalMgr, message.WithHub(eventHub))
 	shareSvc := share.NewService(dalMgr)
-	wsMgr := ws.NewManager(msgSvc, userSvc)
+	groupSvc := group.NewService(dalMgr, group.WithHub(eventHub))
+	typingSvc := typing.NewService(msgSvc, typing.WithHub(eventHub))
+	pollSvc := poll.NewService(dalMgr, msgSvc, poll.WithHub(eventHub))
+	schedSvc := schedule.NewService(dalMgr, msgSvc)
+	disappearSvc := disappear.NewService(dalMgr, media.NewLocalStorage(dataDir+"/media"), disappear.WithHub(eventHub))
+	wsMgr := ws.NewManager(msgSvc, userSvc, ws.WithTypingService(typingSvc), ws.WithHub(eventHub), ws.WithChangeLog(changeSvc))
 
 	r := gin.Default()
 
@@ -64,16 +81,25 @@ func main() {
 
 	api.RegisterAuthRoutes(r, userSvc)
 	api.RegisterUsersRoutes(r, userSvc)
-	api.RegisterConversationRoutes(r, msgSvc, userSvc, nil)
-	api.RegisterMessageRoutes(r, msgSvc, userSvc, nil)
+	api.RegisterConversationRoutes(r, msgSvc, userSvc)
+	api.RegisterMessageRoutes(r, msgSvc, userSvc)
+	api.RegisterSearchRoutes(r, msgSvc, userSvc)
+	api.RegisterStarredRoutes(r, msgSvc, userSvc)
+	api.RegisterTypingRoutes(r, typingSvc, userSvc)
+	api.RegisterPollRoutes(r, pollSvc, userSvc)
+	api.RegisterScheduledRoutes(r, schedSvc, userSvc)
 	api.RegisterMediaRoutes(r, mediaSvc, userSvc)
 	api.RegisterShareRoutes(r, shareSvc, userSvc)
+	api.RegisterGroupRoutes(r, groupSvc, userSvc)
+	api.RegisterSyncRoutes(r, changeSvc, userSvc)
 
 	// SSE 路由
-	sseHandler := sse.NewHandler(userSvc)
+	sseHandler := sse.NewHandler(userSvc, eventHub, changeSvc)
 	r.GET("/api/sse/subscribe", sseHandler.Subscribe)
 
+	// 客户端通过 Sec-WebSocket-Protocol 选择编码格式（zmessage.msgpack 或 zmessage.json），未指定时使用 MessagePack
 	upgrader := websocket.Upgrader{
+		Subprotocols: ws.Subprotocols,
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
@@ -104,6 +130,14 @@ func main() {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
+	// 定时消息调度（启动时补发停机期间到期的消息）
+	schedSvc.Start()
+	defer schedSvc.Stop()
+
+	// 阅后即焚清理（启动时清理停机期间过期的消息）
+	disappearSvc.Start()
+	defer disappearSvc.Stop()
+
 	go func() {
 		log.Printf("服务器启动，监听 %s", addr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -1,24 +1,93 @@
 package models
 
+// 会话类型
+const (
+	ConversationTypeDirect = "direct" // 单聊
+	ConversationTypeGroup  = "group"  // 群聊
+)
+
+// 群成员角色
+const (
+	MemberRoleOwner  = "owner"
+	MemberRoleAdmin  = "admin"
+	MemberRoleMember = "member"
+)
+
 // Conversation 会话模型
 type Conversation struct {
-	ID        int64 `json:"id"`
-	UserAID   int64 `json:"user_a_id"`
-	UserBID   int64 `json:"user_b_id"`
-	CreatedAt int64 `json:"created_at"`
-	UpdatedAt int64 `json:"updated_at"`
+	ID             int64  `json:"id"`
+	Type           string `json:"type"`      // direct, group
+	UserAID        int64  `json:"user_a_id"` // 仅单聊
+	UserBID        int64  `json:"user_b_id"` // 仅单聊
+	Title          string `json:"title,omitempty"`
+	AvatarID       *int64 `json:"avatar_id,omitempty"`
+	CreatedBy      int64  `json:"created_by,omitempty"`
+	DisappearAfter int64  `json:"disappear_after"` // 阅后即焚时长（秒，0为关闭）
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+// IsGroup 是否为群聊
+func (c *Conversation) IsGroup() bool {
+	return c.Type == ConversationTypeGroup
+}
+
+// ConversationMember 会话成员
+type ConversationMember struct {
+	ConversationID    int64  `json:"conversation_id"`
+	UserID            int64  `json:"user_id"`
+	Role              string `json:"role"` // owner, admin, member
+	JoinedAt          int64  `json:"joined_at"`
+	LastReadMessageID int64  `json:"last_read_message_id"`
+}
+
+// ConversationSettings 用户对会话的个人设置（只影响自己）
+type ConversationSettings struct {
+	Archived   bool  `json:"archived"`
+	MutedUntil int64 `json:"muted_until"` // 免打扰截止时间（Unix秒，0为未开启），免打扰期间不计入总未读数
+	Hidden     bool  `json:"hidden"`      // 从会话列表中隐藏，收到新消息后自动恢复
+	PinnedAt   int64 `json:"pinned_at"`   // 置顶时间（0为未置顶），置顶的会话排在列表最前
+
+	ClearedMessageID int64 `json:"cleared_message_id"` // 已清空到的消息ID（该ID及之前的消息对自己不可见）
+}
+
+// IsMuted 是否处于免打扰期间
+func (s *ConversationSettings) IsMuted(now int64) bool {
+	return s.MutedUntil > now
+}
+
+// ConversationQuery 会话列表查询条件（过滤条件为nil时不限制）
+type ConversationQuery struct {
+	UserID   int64
+	Archived *bool
+	Hidden   *bool
+	Muted    *bool
+	Pinned   *bool
+	Now      int64 // 判断免打扰的当前时间
+	Page     int
+	Limit    int
 }
 
 // ConversationWithInfo 带额外信息的会话
 type ConversationWithInfo struct {
-	ID           int64       `json:"id"`
-	UserAID      int64       `json:"user_a_id"`
-	UserBID      int64       `json:"user_b_id"`
-	Participant  *UserInfo   `json:"participant"`
-	LastMessage  *Message    `json:"last_message,omitempty"`
-	UnreadCount  int         `json:"unread_count"`
-	CreatedAt    int64       `json:"created_at"`
-	UpdatedAt    int64       `json:"updated_at"`
+	ID                    int64                 `json:"id"`
+	Type                  string                `json:"type"`
+	UserAID               int64                 `json:"user_a_id"`
+	UserBID               int64                 `json:"user_b_id"`
+	Participant           *UserInfo             `json:"participant"`
+	Title                 string                `json:"title,omitempty"`
+	Avatar                string                `json:"avatar,omitempty"`
+	Members               []*MemberInfo         `json:"members,omitempty"`
+	MemberCount           int                   `json:"member_count"`
+	LastMessage           *Message              `json:"last_message,omitempty"`
+	Pins                  []*PinnedMessage      `json:"pins,omitempty"`     // 置顶消息（最近置顶的在前）
+	Draft                 *Draft                `json:"draft,omitempty"`    // 当前用户的草稿
+	Settings              *ConversationSettings `json:"settings,omitempty"` // 当前用户的个人设置
+	UnreadCount           int                   `json:"unread_count"`
+	PeerLastReadMessageID int64                 `json:"peer_last_read_message_id,omitempty"` // 单聊对方的已读位置
+	DisappearAfter        int64                 `json:"disappear_after"`                     // 阅后即焚时长（秒，0为关闭）
+	CreatedAt             int64                 `json:"created_at"`
+	UpdatedAt             int64                 `json:"updated_at"`
 }
 
 // UserInfo 用户简要信息
@@ -30,3 +99,21 @@ type UserInfo struct {
 	Online   bool   `json:"online"`
 	LastSeen int64  `json:"last_seen"`
 }
+
+// MemberInfo 群成员信息
+type MemberInfo struct {
+	ID                int64  `json:"id"`
+	Username          string `json:"username"`
+	Nickname          string `json:"nickname"`
+	Avatar            string `json:"avatar,omitempty"`
+	Role              string `json:"role"`
+	JoinedAt          int64  `json:"joined_at"`
+	LastReadMessageID int64  `json:"last_read_message_id"` // 成员的已读位置
+}
+
+// ReadCursor 参与者在会话中的已读位置（该ID及之前的消息已读）
+type ReadCursor struct {
+	ConversationID    int64 `json:"conversation_id"`
+	UserID            int64 `json:"user_id"`
+	LastReadMessageID int64 `json:"last_read_message_id"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// EventLogEntry 推送给用户的实时事件（按用户递增编号，供断线重连后补发和增量同步）
+type EventLogEntry struct {
+	UserID    int64  `json:"user_id"`
+	ID        int64  `json:"id"`   // 用户内单调递增的事件ID（即 SSE 的 id 字段和增量同步的变更序号）
+	Type      string `json:"type"` // 事件类型（即 SSE 的 event 名）
+	Data      string `json:"data"` // JSON 编码的事件数据
+	CreatedAt int64  `json:"created_at"`
+}
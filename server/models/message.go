@@ -1,14 +1,163 @@
 package models
 
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// 消息状态（只能按 sent → delivered → read 前进）
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
+// 消息类型（内容格式见各类型的结构体）
+const (
+	MessageTypeText     = "text"     // 文本内容
+	MessageTypeImage    = "image"    // 媒体ID
+	MessageTypeVoice    = "voice"    // 媒体ID
+	MessageTypeFile     = "file"     // FileContent JSON
+	MessageTypeLocation = "location" // LocationContent JSON
+	MessageTypeContact  = "contact"  // ContactContent JSON
+)
+
+// MessageTypeSystem 系统通知消息（由服务端生成，客户端不可发送）
+const MessageTypeSystem = "system"
+
+// FileContent 文件消息内容
+type FileContent struct {
+	MediaID  int64  `json:"media_id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
+// LocationContent 位置消息内容
+type LocationContent struct {
+	Lat   float64 `json:"lat"`
+	Lng   float64 `json:"lng"`
+	Label string  `json:"label,omitempty"`
+}
+
+// ContactContent 名片消息内容（指向一个用户）
+type ContactContent struct {
+	UserID int64 `json:"user_id"`
+}
+
 // Message 消息模型
 type Message struct {
-	ID             int64  `json:"id"`
+	ID             int64              `json:"id"`
+	ConversationID int64              `json:"conversation_id"`
+	SenderID       int64              `json:"sender_id"`
+	ReceiverID     int64              `json:"receiver_id"`
+	Type           string             `json:"type"` // text, voice, image, file, location, contact, system
+	Content        string             `json:"content"`
+	Status         string             `json:"status"` // sent, delivered, read
+	CreatedAt      int64              `json:"created_at"`
+	SyncedAt       *int64             `json:"synced_at,omitempty"`
+	EditedAt       *int64             `json:"edited_at,omitempty"`      // 最近一次编辑时间
+	RecalledAt     *int64             `json:"recalled_at,omitempty"`    // 撤回时间（撤回后内容清空，仅保留墓碑）
+	ReplyToID      int64              `json:"reply_to_id,omitempty"`    // 引用的消息ID
+	ClientMsgID    string             `json:"client_msg_id,omitempty"`  // 客户端生成的消息ID（同一发送者唯一，用于重发去重）
+	ExpiresAt      *int64             `json:"expires_at,omitempty"`     // 阅后即焚的过期时间（到期后不可见并被清理）
+	ForwardedFrom  *ForwardInfo       `json:"forwarded_from,omitempty"` // 转发来源（非转发消息为空）
+	ReplyTo        *ReplyPreview      `json:"reply_to,omitempty"`       // 引用消息预览（查询时填充）
+	Reactions      []*ReactionSummary `json:"reactions,omitempty"`      // 表情回应汇总（查询时填充）
+	Starred        bool               `json:"starred,omitempty"`        // 当前用户是否已收藏（查询时填充）
+}
+
+// MediaID 获取消息引用的媒体ID（不引用媒体时为0）
+func (m *Message) MediaID() int64 {
+	switch m.Type {
+	case MessageTypeImage, MessageTypeVoice:
+		id, err := strconv.ParseInt(m.Content, 10, 64)
+		if err != nil {
+			return 0
+		}
+		return id
+	case MessageTypeFile:
+		var file FileContent
+		if err := json.Unmarshal([]byte(m.Content), &file); err != nil {
+			return 0
+		}
+		return file.MediaID
+	default:
+		return 0
+	}
+}
+
+// Reaction 表情回应
+type Reaction struct {
+	MessageID int64  `json:"message_id"`
+	UserID    int64  `json:"user_id"`
+	Emoji     string `json:"emoji"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Draft 用户在会话中未发送的草稿（每个用户每个会话一份，多设备同步）
+type Draft struct {
 	ConversationID int64  `json:"conversation_id"`
+	UserID         int64  `json:"user_id"`
+	Content        string `json:"content"` // 为空表示草稿已清除
+	ReplyToID      int64  `json:"reply_to_id,omitempty"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+// MessagePin 会话置顶消息记录
+type MessagePin struct {
+	ConversationID int64 `json:"conversation_id"`
+	MessageID      int64 `json:"message_id"`
+	PinnedBy       int64 `json:"pinned_by"`
+	PinnedAt       int64 `json:"pinned_at"`
+}
+
+// PinnedMessage 置顶消息（带完整消息内容）
+type PinnedMessage struct {
+	Message  *Message `json:"message"`
+	PinnedBy int64    `json:"pinned_by"`
+	PinnedAt int64    `json:"pinned_at"`
+}
+
+// ReactionSummary 单个表情的回应汇总
+type ReactionSummary struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+// ForwardInfo 转发来源（多次转发时保留最初的发送者和时间）
+type ForwardInfo struct {
+	MessageID      int64  `json:"message_id"` // 最初的消息ID（可能已被删除）
 	SenderID       int64  `json:"sender_id"`
-	ReceiverID     int64  `json:"receiver_id"`
-	Type           string `json:"type"` // text, voice, image
-	Content        string `json:"content"`
-	Status         string `json:"status"` // sent, delivered, read
+	SenderNickname string `json:"sender_nickname,omitempty"` // 查询时填充
 	CreatedAt      int64  `json:"created_at"`
-	SyncedAt       *int64 `json:"synced_at,omitempty"`
+}
+
+// ReplyPreview 被引用消息的简要预览
+type ReplyPreview struct {
+	MessageID      int64  `json:"message_id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderNickname string `json:"sender_nickname"`
+	Type           string `json:"type"`
+	Content        string `json:"content"` // 截断后的内容
+	Recalled       bool   `json:"recalled,omitempty"`
+}
+
+// MessageRevision 消息修订记录（保存编辑前的内容）
+type MessageRevision struct {
+	ID        int64  `json:"id"`
+	MessageID int64  `json:"message_id"`
+	Content   string `json:"content"`
+	EditedAt  int64  `json:"edited_at"` // 被替换的时间
+}
+
+// MessageReceipt 消息回执：接收者推进了同一会话中若干消息的状态，推送给发送者
+type MessageReceipt struct {
+	ConversationID int64   `json:"conversation_id"`
+	SenderID       int64   `json:"sender_id"` // 消息发送者（回执的推送对象）
+	UserID         int64   `json:"user_id"`   // 推进状态的接收者
+	Status         string  `json:"status"`
+	MessageIDs     []int64 `json:"message_ids"`
+	UpdatedAt      int64   `json:"updated_at"`
 }
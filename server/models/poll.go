@@ -0,0 +1,51 @@
+package models
+
+// MessageTypePoll 投票消息（内容为 PollContent JSON）
+const MessageTypePoll = "poll"
+
+// PollContent 投票消息内容
+type PollContent struct {
+	Question  string   `json:"question"`
+	Options   []string `json:"options"`
+	Multiple  bool     `json:"multiple,omitempty"`  // 是否允许多选
+	Anonymous bool     `json:"anonymous,omitempty"` // 匿名投票不公开投票者
+	Deadline  int64    `json:"deadline,omitempty"`  // 截止时间（Unix秒，0为不限）
+}
+
+// PollVote 投票记录（多选时每个选项一条）
+type PollVote struct {
+	MessageID   int64 `json:"message_id"`
+	UserID      int64 `json:"user_id"`
+	OptionIndex int   `json:"option_index"`
+	VotedAt     int64 `json:"voted_at"`
+}
+
+// PollClosure 投票关闭记录（关闭后结果冻结）
+type PollClosure struct {
+	MessageID int64 `json:"message_id"`
+	ClosedBy  int64 `json:"closed_by"`
+	ClosedAt  int64 `json:"closed_at"`
+}
+
+// PollResults 投票结果
+type PollResults struct {
+	MessageID      int64               `json:"message_id"`
+	ConversationID int64               `json:"conversation_id"`
+	Question       string              `json:"question"`
+	Multiple       bool                `json:"multiple"`
+	Anonymous      bool                `json:"anonymous"`
+	Deadline       int64               `json:"deadline,omitempty"`
+	Options        []*PollOptionResult `json:"options"`
+	TotalVoters    int                 `json:"total_voters"`
+	MyVotes        []int               `json:"my_votes"` // 当前用户选择的选项（推送时为空）
+	Closed         bool                `json:"closed"`   // 已关闭或已过截止时间
+	ClosedAt       int64               `json:"closed_at,omitempty"`
+}
+
+// PollOptionResult 单个选项的结果
+type PollOptionResult struct {
+	Index  int     `json:"index"`
+	Text   string  `json:"text"`
+	Count  int     `json:"count"`
+	Voters []int64 `json:"voters,omitempty"` // 匿名投票时为空
+}
@@ -0,0 +1,26 @@
+package models
+
+// 定时消息状态
+const (
+	ScheduledStatusPending  = "pending"  // 等待发送（可编辑、可取消）
+	ScheduledStatusSending  = "sending"  // 已到期，正在发送
+	ScheduledStatusSent     = "sent"     // 已发送
+	ScheduledStatusFailed   = "failed"   // 发送失败
+	ScheduledStatusCanceled = "canceled" // 已取消
+)
+
+// ScheduledMessage 定时消息
+type ScheduledMessage struct {
+	ID             int64  `json:"id"`
+	SenderID       int64  `json:"sender_id"`
+	ConversationID int64  `json:"conversation_id"`
+	Type           string `json:"type"`
+	Content        string `json:"content"`
+	ReplyToID      int64  `json:"reply_to_id,omitempty"`
+	SendAt         int64  `json:"send_at"` // 计划发送时间（Unix秒）
+	Status         string `json:"status"`
+	MessageID      int64  `json:"message_id,omitempty"` // 发送成功后的消息ID
+	Error          string `json:"error,omitempty"`      // 发送失败原因
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// MessageSearchQuery 消息搜索条件
+type MessageSearchQuery struct {
+	UserID         int64  // 搜索者（只返回其所在会话的消息）
+	Query          string // 关键词
+	ConversationID int64  // 限定会话（可选）
+	SenderID       int64  // 限定发送者（可选）
+	Type           string // 限定消息类型（可选）
+	From           int64  // 起始时间（含，Unix秒，可选）
+	To             int64  // 截止时间（含，Unix秒，可选）
+	BeforeID       int64  // 游标：只返回ID小于该值的消息
+	Limit          int
+}
+
+// MessageSearchHit 消息搜索结果
+type MessageSearchHit struct {
+	Message *Message `json:"message"`
+	Snippet string   `json:"snippet"` // 高亮片段，命中部分以 <mark></mark> 包裹（其余内容已转义）
+}
@@ -0,0 +1,26 @@
+package models
+
+// MessageStar 用户收藏的消息（仅自己可见）
+type MessageStar struct {
+	ID             int64 `json:"id"`
+	UserID         int64 `json:"user_id"`
+	MessageID      int64 `json:"message_id"`
+	ConversationID int64 `json:"conversation_id"`
+	StarredAt      int64 `json:"starred_at"`
+}
+
+// StarredMessageQuery 收藏列表查询条件
+type StarredMessageQuery struct {
+	UserID         int64  // 收藏者
+	ConversationID int64  // 限定会话（可选）
+	Type           string // 限定消息类型（可选）
+	BeforeID       int64  // 游标：只返回收藏ID小于该值的记录
+	Limit          int
+}
+
+// StarredMessage 收藏的消息（带完整消息内容）
+type StarredMessage struct {
+	ID        int64    `json:"id"` // 收藏ID（分页游标）
+	Message   *Message `json:"message"`
+	StarredAt int64    `json:"starred_at"`
+}
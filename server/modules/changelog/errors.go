@@ -0,0 +1,10 @@
+package changelog
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrInvalidCursor 游标无效
+	ErrInvalidCursor = fmt.Errorf("invalid cursor")
+)
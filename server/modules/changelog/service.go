@@ -0,0 +1,111 @@
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/typing"
+)
+
+// transientEvents 不记录、不补发的瞬时事件
+var transientEvents = map[string]bool{
+	typing.EventTyping: true,
+}
+
+// Option 变更日志服务配置项
+type Option func(*service)
+
+// WithRetention 设置每个用户保留的变更数
+func WithRetention(n int) Option {
+	return func(s *service) {
+		s.retention = n
+	}
+}
+
+// NewService 创建变更日志服务
+func NewService(dalMgr dal.Manager, opts ...Option) Service {
+	s := &service{
+		dal:       dalMgr,
+		retention: DefaultRetention,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// service 变更日志服务实现
+type service struct {
+	dal       dal.Manager
+	retention int
+}
+
+// Record 记录事件
+func (s *service) Record(userID int64, event *hub.Event) (int64, error) {
+	if transientEvents[event.Type] {
+		return 0, nil
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return 0, fmt.Errorf("encode event: %w", err)
+	}
+	entry := &models.EventLogEntry{UserID: userID, Type: event.Type, Data: string(data), CreatedAt: time.Now().Unix()}
+	if err := s.dal.EventLog().Append(entry, s.retention); err != nil {
+		return 0, err
+	}
+	return entry.ID, nil
+}
+
+// GetChanges 获取游标之后的变更
+func (s *service) GetChanges(userID int64, cursor int64, limit int) (*ChangeSet, error) {
+	if cursor < 0 {
+		return nil, ErrInvalidCursor
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	oldest, latest, err := s.dal.EventLog().Bounds(userID)
+	if err != nil {
+		return nil, err
+	}
+	// 游标超前（如服务端数据被重置）或之后的变更已被清理
+	if cursor > latest || (oldest > 0 && cursor < oldest-1) {
+		return &ChangeSet{Changes: []*Change{}, Cursor: latest, ResyncRequired: true}, nil
+	}
+
+	// 多取一条判断是否还有更多
+	entries, err := s.dal.EventLog().ListAfter(userID, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	set := &ChangeSet{Changes: make([]*Change, 0, len(entries)), Cursor: cursor}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		set.HasMore = true
+	}
+	for _, e := range entries {
+		set.Changes = append(set.Changes, &Change{
+			Seq:       e.ID,
+			Type:      e.Type,
+			Data:      json.RawMessage(e.Data),
+			CreatedAt: e.CreatedAt,
+		})
+		set.Cursor = e.ID
+	}
+	return set, nil
+}
+
+// LatestSeq 获取用户最新的变更序号
+func (s *service) LatestSeq(userID int64) (int64, error) {
+	_, latest, err := s.dal.EventLog().Bounds(userID)
+	return latest, err
+}
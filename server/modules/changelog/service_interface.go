@@ -0,0 +1,48 @@
+package changelog
+
+import (
+	"encoding/json"
+
+	"zmessage/server/hub"
+)
+
+const (
+	// DefaultRetention 每个用户保留的变更数，游标落后超过这么多变更后需要重新同步
+	DefaultRetention = 5000
+	// DefaultLimit 每次同步默认返回的变更数
+	DefaultLimit = 100
+	// MaxLimit 每次同步最多返回的变更数
+	MaxLimit = 500
+)
+
+// Change 一条变更（即推送给用户的一条实时事件）
+type Change struct {
+	Seq       int64           `json:"seq"`  // 用户内单调递增的序号（与 SSE 的事件ID相同）
+	Type      string          `json:"type"` // 事件类型，如 chat、message_edited、message_status
+	Data      json.RawMessage `json:"data"` // 事件数据，与实时推送的数据相同
+	CreatedAt int64           `json:"created_at"`
+}
+
+// ChangeSet 游标之后的增量变更
+type ChangeSet struct {
+	Changes []*Change `json:"changes"`
+	// Cursor 下次同步使用的游标（最后一条变更的序号，没有变更时为传入的游标）
+	Cursor  int64 `json:"cursor"`
+	HasMore bool  `json:"has_more"`
+	// ResyncRequired 游标之后的变更已被清理（或游标无效），客户端需重新拉取数据后从 Cursor 继续同步
+	ResyncRequired bool `json:"resync_required"`
+}
+
+// Service 变更日志服务接口
+// 作为事件中心的记录器，把推送给每个用户的事件（输入状态等瞬时事件除外）按用户编号持久化，
+// 设备重连后从上次的游标拉取增量，不会错过自己发出的消息、状态变化、编辑、删除等变更
+type Service interface {
+	// Record 记录事件并返回序号（实现 hub.Recorder，瞬时事件返回0）
+	Record(userID int64, event *hub.Event) (int64, error)
+
+	// GetChanges 获取游标之后的变更（按序号升序，最多 limit 条）
+	GetChanges(userID int64, cursor int64, limit int) (*ChangeSet, error)
+
+	// LatestSeq 获取用户最新的变更序号（没有变更时为0）
+	LatestSeq(userID int64) (int64, error)
+}
@@ -0,0 +1,188 @@
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/message"
+	"zmessage/server/modules/typing"
+)
+
+func setupTestService(t *testing.T, opts ...Option) (Service, hub.Hub, dal.Manager) {
+	t.Helper()
+
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+
+	svc := NewService(mgr, opts...)
+	return svc, hub.New(hub.WithRecorder(svc)), mgr
+}
+
+// changeTypes 按顺序拉取用户的全部变更（每页 limit 条），返回事件类型
+func changeTypes(t *testing.T, svc Service, userID int64, limit int) []string {
+	t.Helper()
+
+	var types []string
+	var cursor int64
+	for {
+		set, err := svc.GetChanges(userID, cursor, limit)
+		if err != nil {
+			t.Fatalf("get changes: %v", err)
+		}
+		if set.ResyncRequired || len(set.Changes) > limit {
+			t.Fatalf("unexpected change set: %+v", set)
+		}
+		for _, c := range set.Changes {
+			if c.Seq != cursor+1 {
+				t.Fatalf("expected seq %d, got %d", cursor+1, c.Seq)
+			}
+			cursor = c.Seq
+			types = append(types, c.Type)
+		}
+		if set.Cursor != cursor {
+			t.Fatalf("expected cursor %d, got %d", cursor, set.Cursor)
+		}
+		if !set.HasMore {
+			return types
+		}
+	}
+}
+
+func TestService_RecordsEveryMutation(t *testing.T) {
+	svc, h, mgr := setupTestService(t)
+
+	users := make([]*models.User, 2)
+	for i := range users {
+		users[i] = &models.User{Username: fmt.Sprintf("user%d", i), PasswordHash: "hash", Nickname: fmt.Sprintf("User %d", i), CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+		if err := mgr.User().Create(users[i]); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	alice, bob := users[0].ID, users[1].ID
+	msgSvc := message.NewService(mgr, message.WithHub(h))
+
+	msg, err := msgSvc.SendMessage(&message.SendMessageRequest{From: alice, To: bob, Type: "text", Content: "hi"})
+	if err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	if _, err := msgSvc.EditMessage(&message.EditMessageRequest{UserID: alice, MessageID: msg.ID, Content: "hello"}); err != nil {
+		t.Fatalf("edit message: %v", err)
+	}
+	if _, err := msgSvc.AddReaction(msg.ConversationID, msg.ID, bob, "👍"); err != nil {
+		t.Fatalf("add reaction: %v", err)
+	}
+	if _, err := msgSvc.MarkAsRead(msg.ConversationID, bob); err != nil {
+		t.Fatalf("mark as read: %v", err)
+	}
+	if _, err := msgSvc.RecallMessage(msg.ConversationID, msg.ID, alice); err != nil {
+		t.Fatalf("recall message: %v", err)
+	}
+	bye, err := msgSvc.SendMessage(&message.SendMessageRequest{From: alice, ConversationID: msg.ConversationID, Type: "text", Content: "bye"})
+	if err != nil {
+		t.Fatalf("send message: %v", err)
+	}
+	if _, err := msgSvc.DeleteMessageForMe(bye.ConversationID, bye.ID, alice); err != nil {
+		t.Fatalf("delete message: %v", err)
+	}
+
+	// 输入状态是瞬时事件，不记录
+	h.Publish(alice, &hub.Event{Type: typing.EventTyping, Data: &typing.Event{ConversationID: msg.ConversationID, UserID: bob, Typing: true}})
+
+	// 发送者的变更日志包含自己发出的消息、自己的编辑和删除，以及对方的回应和已读
+	got := changeTypes(t, svc, alice, 2)
+	want := map[string]int{
+		message.EventChat:            2,
+		message.EventMessageEdited:   1,
+		message.EventReactionUpdated: 1,
+		message.EventReadCursor:      1,
+		message.EventMessageStatus:   1,
+		message.EventMessageRecalled: 1,
+		message.EventMessageDeleted:  1,
+	}
+	counts := make(map[string]int)
+	for _, typ := range got {
+		counts[typ]++
+	}
+	if len(got) != 8 || got[0] != message.EventChat || got[1] != message.EventMessageEdited || got[7] != message.EventMessageDeleted {
+		t.Errorf("unexpected changes for sender: %v", got)
+	}
+	for typ, n := range want {
+		if counts[typ] != n {
+			t.Errorf("expected %d %s change(s) for sender, got %v", n, typ, got)
+		}
+	}
+
+	// 接收者的变更日志包含自己的回应和已读位置，不含发送者仅为自己删除的消息
+	got = changeTypes(t, svc, bob, 100)
+	if len(got) != 6 || got[0] != message.EventChat || got[2] != message.EventReactionUpdated || got[4] != message.EventMessageRecalled {
+		t.Errorf("unexpected changes for receiver: %v", got)
+	}
+
+	// 变更数据与实时推送的数据相同
+	set, _ := svc.GetChanges(bob, 0, 1)
+	var chat message.ChatEvent
+	if err := json.Unmarshal(set.Changes[0].Data, &chat); err != nil || chat.MessageID != msg.ID || chat.Content != "hi" {
+		t.Errorf("unexpected chat change: %s, %v", set.Changes[0].Data, err)
+	}
+	if !set.HasMore || set.Cursor != 1 {
+		t.Errorf("expected more changes after cursor 1, got %+v", set)
+	}
+
+	// 已是最新
+	latest, err := svc.LatestSeq(bob)
+	if err != nil || latest != 6 {
+		t.Errorf("expected latest seq 6, got %d, %v", latest, err)
+	}
+	set, _ = svc.GetChanges(bob, latest, 0)
+	if len(set.Changes) != 0 || set.HasMore || set.Cursor != latest || set.ResyncRequired {
+		t.Errorf("expected no changes at the latest cursor, got %+v", set)
+	}
+}
+
+func TestService_Retention(t *testing.T) {
+	svc, h, _ := setupTestService(t, WithRetention(3))
+
+	for i := 0; i < 5; i++ {
+		h.Publish(1, &hub.Event{Type: "chat", Data: map[string]int{"n": i + 1}})
+	}
+
+	// 游标之后的变更仍在保留范围内
+	set, err := svc.GetChanges(1, 2, 10)
+	if err != nil || len(set.Changes) != 3 || set.Changes[0].Seq != 3 || set.Cursor != 5 || set.HasMore || set.ResyncRequired {
+		t.Errorf("expected changes 3-5, got %+v, %v", set, err)
+	}
+
+	// 变更1、2已被清理，需要重新拉取数据后从最新序号继续
+	set, _ = svc.GetChanges(1, 1, 10)
+	if !set.ResyncRequired || len(set.Changes) != 0 || set.Cursor != 5 {
+		t.Errorf("expected resync for a trimmed cursor, got %+v", set)
+	}
+	set, _ = svc.GetChanges(1, 0, 10)
+	if !set.ResyncRequired {
+		t.Errorf("expected resync for a new device after trimming, got %+v", set)
+	}
+
+	// 游标超过服务端记录
+	set, _ = svc.GetChanges(1, 99, 10)
+	if !set.ResyncRequired || set.Cursor != 5 {
+		t.Errorf("expected resync for an unknown cursor, got %+v", set)
+	}
+
+	if _, err := svc.GetChanges(1, -1, 10); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor, got: %v", err)
+	}
+
+	// 没有变更的用户从0开始
+	set, _ = svc.GetChanges(2, 0, 10)
+	if set.ResyncRequired || len(set.Changes) != 0 || set.Cursor != 0 {
+		t.Errorf("expected empty change set, got %+v", set)
+	}
+}
@@ -0,0 +1,181 @@
+package disappear
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/media"
+	"zmessage/server/modules/message"
+)
+
+// DefaultInterval 默认清理间隔
+const DefaultInterval = time.Minute
+
+// SweepBatchSize 每批清理的消息数
+const SweepBatchSize = 100
+
+// Option 清理服务配置项
+type Option func(*service)
+
+// WithInterval 设置清理间隔
+func WithInterval(d time.Duration) Option {
+	return func(s *service) {
+		s.interval = d
+	}
+}
+
+// WithHub 设置实时事件中心
+func WithHub(h hub.Hub) Option {
+	return func(s *service) {
+		s.hub = h
+	}
+}
+
+// NewService 创建阅后即焚清理服务
+func NewService(dalMgr dal.Manager, storage media.Storage, opts ...Option) Service {
+	s := &service{
+		dal:      dalMgr,
+		storage:  storage,
+		hub:      hub.New(),
+		interval: DefaultInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// service 阅后即焚清理服务实现
+type service struct {
+	dal      dal.Manager
+	storage  media.Storage
+	hub      hub.Hub
+	interval time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Sweep 分批删除已过期的消息并通知会话成员；媒体消息在不再被引用后同时删除媒体记录和文件
+func (s *service) Sweep() (int, error) {
+	total := 0
+	for {
+		msgs, err := s.dal.Message().GetExpired(time.Now().Unix(), SweepBatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		for _, msg := range msgs {
+			if err := s.dal.Message().Delete(msg.ID); err != nil && !dal.IsNotFound(err) {
+				return total, fmt.Errorf("delete expired message %d: %w", msg.ID, err)
+			}
+			total++
+
+			if err := s.publishDeleted(msg); err != nil {
+				return total, err
+			}
+
+			if err := s.purgeMedia(msg); err != nil {
+				return total, err
+			}
+		}
+
+		if len(msgs) < SweepBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// publishDeleted 向会话全部成员推送消息删除事件（单聊双方同样登记为成员）
+func (s *service) publishDeleted(msg *models.Message) error {
+	members, err := s.dal.ConversationMember().GetByConversation(msg.ConversationID)
+	if err != nil {
+		return fmt.Errorf("get members of conversation %d: %w", msg.ConversationID, err)
+	}
+
+	data := &message.MessageDeletedEvent{MessageID: msg.ID, ConversationID: msg.ConversationID}
+	for _, m := range members {
+		s.hub.Publish(m.UserID, &hub.Event{Type: message.EventMessageDeleted, Data: data})
+	}
+	return nil
+}
+
+// purgeMedia 删除过期媒体消息引用的媒体（仍被其他消息或头像引用时保留）
+func (s *service) purgeMedia(msg *models.Message) error {
+	mediaID := msg.MediaID()
+	if mediaID == 0 {
+		return nil
+	}
+
+	referenced, err := s.dal.Media().IsReferenced(mediaID)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return nil
+	}
+
+	if err := s.dal.Media().Delete(mediaID); err != nil {
+		if dal.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("delete media %d: %w", mediaID, err)
+	}
+	if err := s.storage.Delete(mediaID); err != nil {
+		return fmt.Errorf("delete media files %d: %w", mediaID, err)
+	}
+	return nil
+}
+
+// Start 启动后台清理
+func (s *service) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run(s.stop, s.done)
+}
+
+// Stop 停止后台清理
+func (s *service) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// run 清理循环
+func (s *service) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(); err != nil {
+			log.Printf("清理过期消息失败: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
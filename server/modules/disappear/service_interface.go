@@ -0,0 +1,13 @@
+package disappear
+
+// Service 阅后即焚清理服务接口
+type Service interface {
+	// Sweep 清理已过期的消息及其媒体文件，返回本次删除的消息数
+	Sweep() (int, error)
+
+	// Start 启动后台清理（启动时立即清理停机期间过期的消息）
+	Start()
+
+	// Stop 停止后台清理
+	Stop()
+}
@@ -0,0 +1,114 @@
+package disappear
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/media"
+	"zmessage/server/modules/message"
+)
+
+func TestService_Sweep(t *testing.T) {
+	dataDir := t.TempDir()
+	mgr, err := dal.NewManager(dataDir)
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+	storage := media.NewLocalStorage(dataDir)
+	h := hub.New()
+	svc := NewService(mgr, storage, WithHub(h))
+	msgSvc := message.NewService(mgr)
+
+	var users []*models.User
+	for i := 0; i < 2; i++ {
+		u := &models.User{Username: fmt.Sprintf("user%d", i), PasswordHash: "hash", Nickname: fmt.Sprintf("User %d", i), CreatedAt: time.Now().Unix()}
+		if err := mgr.User().Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+	conv, _ := msgSvc.GetConversationWithUser(users[0].ID, users[1].ID)
+
+	var mu sync.Mutex
+	deleted := map[int64][]int64{}
+	h.Subscribe(hub.AllUsers, []string{message.EventMessageDeleted}, func(userID int64, e *hub.Event) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		deleted[userID] = append(deleted[userID], e.Data.(*message.MessageDeletedEvent).MessageID)
+		return true
+	})
+
+	// 上传的图片（原图和缩略图）
+	img := &models.Media{OwnerID: users[0].ID, Type: "image", MimeType: "image/png", CreatedAt: time.Now().Unix()}
+	if err := mgr.Media().Create(img); err != nil {
+		t.Fatalf("create media: %v", err)
+	}
+	original := storage.GetOriginalPath(img.ID) + ".png"
+	os.WriteFile(original, []byte("png"), 0644)
+	if _, err := storage.SaveThumbnail(img.ID, []byte("jpg")); err != nil {
+		t.Fatalf("save thumbnail: %v", err)
+	}
+
+	kept, _ := msgSvc.SendMessage(&message.SendMessageRequest{From: users[0].ID, To: users[1].ID, Type: "text", Content: "kept"})
+	msgSvc.SetDisappearingTimer(conv.ID, users[0].ID, 3600)
+	text, _ := msgSvc.SendMessage(&message.SendMessageRequest{From: users[0].ID, To: users[1].ID, Type: "text", Content: "secret"})
+	photo, _ := msgSvc.SendMessage(&message.SendMessageRequest{From: users[0].ID, To: users[1].ID, Type: "image", Content: fmt.Sprintf("%d", img.ID)})
+	pending, _ := msgSvc.SendMessage(&message.SendMessageRequest{From: users[0].ID, To: users[1].ID, Type: "text", Content: "not yet"})
+
+	// 尚未到期时不清理
+	if n, err := svc.Sweep(); err != nil || n != 0 {
+		t.Fatalf("expected nothing to sweep, got %d, %v", n, err)
+	}
+
+	past := time.Now().Unix() - 1
+	for _, id := range []int64{text.ID, photo.ID} {
+		mgr.DB().Exec(`UPDATE messages SET expires_at = ? WHERE id = ?`, past, id)
+	}
+
+	n, err := svc.Sweep()
+	if err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 messages swept, got %d", n)
+	}
+
+	for _, id := range []int64{text.ID, photo.ID} {
+		if _, err := mgr.Message().GetByID(id); !dal.IsNotFound(err) {
+			t.Errorf("expected message %d to be purged, got: %v", id, err)
+		}
+	}
+	for _, id := range []int64{kept.ID, pending.ID} {
+		if _, err := mgr.Message().GetByID(id); err != nil {
+			t.Errorf("expected message %d to remain, got: %v", id, err)
+		}
+	}
+
+	// 双方都收到删除事件
+	mu.Lock()
+	for _, u := range users {
+		ids := deleted[u.ID]
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if len(ids) != 2 || ids[0] != text.ID || ids[1] != photo.ID {
+			t.Errorf("expected user %d to receive deleted events for %d and %d, got %v", u.ID, text.ID, photo.ID, ids)
+		}
+	}
+	mu.Unlock()
+
+	if _, err := mgr.Media().GetByID(img.ID); !dal.IsNotFound(err) {
+		t.Errorf("expected media row to be deleted, got: %v", err)
+	}
+	for _, path := range []string{original, storage.GetThumbnailPath(img.ID)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted", filepath.Base(path))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package group
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrGroupNotFound 群聊不存在
+	ErrGroupNotFound = fmt.Errorf("group not found")
+
+	// ErrNotMember 不是群成员
+	ErrNotMember = fmt.Errorf("not a group member")
+
+	// ErrAlreadyMember 已经是群成员
+	ErrAlreadyMember = fmt.Errorf("already a group member")
+
+	// ErrPermissionDenied 权限不足
+	ErrPermissionDenied = fmt.Errorf("permission denied")
+
+	// ErrUserNotFound 用户不存在
+	ErrUserNotFound = fmt.Errorf("user not found")
+
+	// ErrInvalidTitle 无效的群名称
+	ErrInvalidTitle = fmt.Errorf("invalid group title")
+
+	// ErrInvalidAvatar 无效的群头像（须为已上传的图片）
+	ErrInvalidAvatar = fmt.Errorf("invalid group avatar")
+
+	// ErrInvalidRole 无效的成员角色
+	ErrInvalidRole = fmt.Errorf("invalid member role")
+
+	// ErrTooManyMembers 群成员数超出限制
+	ErrTooManyMembers = fmt.Errorf("too many group members")
+
+	// ErrCannotRemoveOwner 不能移除群主
+	ErrCannotRemoveOwner = fmt.Errorf("cannot remove group owner")
+)
+
+// 群聊限制
+const (
+	MaxGroupMembers = 200 // 群成员上限
+	MaxTitleLength  = 50  // 群名称最大长度（字符）
+)
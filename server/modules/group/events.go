@@ -0,0 +1,54 @@
+package group
+
+import (
+	"zmessage/server/models"
+)
+
+// 群聊服务发布的实时事件类型（即 SSE 的 event 名）
+const (
+	EventGroupCreated       = "group_created"
+	EventGroupUpdated       = "group_updated"
+	EventGroupMembersAdded  = "group_members_added"
+	EventGroupMemberRemoved = "group_member_removed"
+	EventGroupRoleChanged   = "group_role_changed"
+)
+
+// GroupCreatedEvent 群聊创建事件（推送给创建者和初始成员）
+type GroupCreatedEvent struct {
+	ConversationID int64  `json:"conversation_id"`
+	Title          string `json:"title"`
+	AvatarID       *int64 `json:"avatar_id"`
+	CreatedBy      int64  `json:"created_by"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+// GroupUpdatedEvent 群资料更新事件
+type GroupUpdatedEvent struct {
+	ConversationID int64  `json:"conversation_id"`
+	Title          string `json:"title"`
+	AvatarID       *int64 `json:"avatar_id"`
+	UpdatedBy      int64  `json:"updated_by"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+// MembersAddedEvent 成员加入事件（推送给包括新成员在内的全部成员）
+type MembersAddedEvent struct {
+	ConversationID int64                `json:"conversation_id"`
+	Members        []*models.MemberInfo `json:"members"`
+	AddedBy        int64                `json:"added_by"`
+}
+
+// MemberRemovedEvent 成员移除或退出事件（推送给包括被移除者在内的全部成员）
+type MemberRemovedEvent struct {
+	ConversationID int64 `json:"conversation_id"`
+	UserID         int64 `json:"user_id"`
+	RemovedBy      int64 `json:"removed_by"` // 主动退出时为本人
+}
+
+// RoleChangedEvent 成员角色变更事件
+type RoleChangedEvent struct {
+	ConversationID int64  `json:"conversation_id"`
+	UserID         int64  `json:"user_id"`
+	Role           string `json:"role"`
+	ChangedBy      int64  `json:"changed_by"`
+}
@@ -0,0 +1,459 @@
+package group
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+)
+
+// Option 群聊服务配置项
+type Option func(*service)
+
+// WithHub 设置实时事件中心
+func WithHub(h hub.Hub) Option {
+	return func(s *service) {
+		s.hub = h
+	}
+}
+
+// NewService 创建群聊服务
+func NewService(dalMgr dal.Manager, opts ...Option) Service {
+	s := &service{
+		dal: dalMgr,
+		hub: hub.New(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// service 群聊服务实现
+type service struct {
+	dal dal.Manager
+	hub hub.Hub
+}
+
+// broadcastToUsers 通过事件中心将群事件推送给指定用户（内部方法）
+func (s *service) broadcastToUsers(userIDs []int64, eventType string, data interface{}) {
+	for _, uid := range userIDs {
+		s.hub.Publish(uid, &hub.Event{Type: eventType, Data: data})
+	}
+}
+
+// CreateGroup 创建群聊
+func (s *service) CreateGroup(creatorID int64, req *CreateGroupRequest) (*models.Conversation, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" || utf8.RuneCountInString(title) > MaxTitleLength {
+		return nil, ErrInvalidTitle
+	}
+
+	// 去重并校验成员
+	memberIDs := uniqueIDs(req.MemberIDs, creatorID)
+	if len(memberIDs)+1 > MaxGroupMembers {
+		return nil, ErrTooManyMembers
+	}
+	if err := s.checkUsersExist(append([]int64{creatorID}, memberIDs...)); err != nil {
+		return nil, err
+	}
+	if err := s.checkAvatar(req.AvatarID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	conv := &models.Conversation{
+		Title:     title,
+		AvatarID:  req.AvatarID,
+		CreatedBy: creatorID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.dal.Conversation().CreateGroup(conv, memberIDs); err != nil {
+		return nil, fmt.Errorf("create group: %w", err)
+	}
+
+	// 创建者的其他设备也需要同步新群
+	s.broadcastToUsers(append([]int64{creatorID}, memberIDs...), EventGroupCreated, &GroupCreatedEvent{
+		ConversationID: conv.ID,
+		Title:          conv.Title,
+		AvatarID:       conv.AvatarID,
+		CreatedBy:      creatorID,
+		CreatedAt:      now,
+	})
+
+	return conv, nil
+}
+
+// UpdateGroup 更新群资料
+func (s *service) UpdateGroup(groupID int64, operatorID int64, req *UpdateGroupRequest) (*models.Conversation, error) {
+	conv, operator, err := s.getGroupMember(groupID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(operator.Role) {
+		return nil, ErrPermissionDenied
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" || utf8.RuneCountInString(title) > MaxTitleLength {
+			return nil, ErrInvalidTitle
+		}
+		conv.Title = title
+	}
+	if req.AvatarID != nil {
+		if err := s.checkAvatar(req.AvatarID); err != nil {
+			return nil, err
+		}
+		conv.AvatarID = req.AvatarID
+	}
+	conv.UpdatedAt = time.Now().Unix()
+
+	if err := s.dal.Conversation().Update(conv); err != nil {
+		return nil, fmt.Errorf("update group: %w", err)
+	}
+
+	s.broadcastToGroup(groupID, EventGroupUpdated, &GroupUpdatedEvent{
+		ConversationID: conv.ID,
+		Title:          conv.Title,
+		AvatarID:       conv.AvatarID,
+		UpdatedBy:      operatorID,
+		UpdatedAt:      conv.UpdatedAt,
+	})
+
+	return conv, nil
+}
+
+// GetMembers 获取群成员列表
+func (s *service) GetMembers(groupID int64, userID int64) ([]*models.MemberInfo, error) {
+	if _, _, err := s.getGroupMember(groupID, userID); err != nil {
+		return nil, err
+	}
+
+	members, err := s.dal.ConversationMember().GetByConversation(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("get members: %w", err)
+	}
+
+	result := make([]*models.MemberInfo, 0, len(members))
+	for _, m := range members {
+		info, err := s.toMemberInfo(m)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// AddMembers 添加群成员
+func (s *service) AddMembers(groupID int64, operatorID int64, userIDs []int64) ([]*models.MemberInfo, error) {
+	_, operator, err := s.getGroupMember(groupID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(operator.Role) {
+		return nil, ErrPermissionDenied
+	}
+
+	ids := uniqueIDs(userIDs, operatorID)
+	if err := s.checkUsersExist(ids); err != nil {
+		return nil, err
+	}
+
+	count, err := s.dal.ConversationMember().Count(groupID)
+	if err != nil {
+		return nil, fmt.Errorf("count members: %w", err)
+	}
+	if count+len(ids) > MaxGroupMembers {
+		return nil, ErrTooManyMembers
+	}
+
+	now := time.Now().Unix()
+	added := make([]*models.MemberInfo, 0, len(ids))
+	for _, uid := range ids {
+		member := &models.ConversationMember{
+			ConversationID: groupID,
+			UserID:         uid,
+			Role:           models.MemberRoleMember,
+			JoinedAt:       now,
+		}
+		if err := s.dal.ConversationMember().Add(member); err != nil {
+			if dal.IsDuplicate(err) {
+				continue // 已在群内，跳过
+			}
+			return nil, fmt.Errorf("add member: %w", err)
+		}
+
+		info, err := s.toMemberInfo(member)
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, info)
+	}
+
+	if len(added) > 0 {
+		s.broadcastToGroup(groupID, EventGroupMembersAdded, &MembersAddedEvent{
+			ConversationID: groupID,
+			Members:        added,
+			AddedBy:        operatorID,
+		})
+	}
+
+	return added, nil
+}
+
+// RemoveMember 移除群成员
+func (s *service) RemoveMember(groupID int64, operatorID int64, userID int64) error {
+	if operatorID == userID {
+		return s.Leave(groupID, userID)
+	}
+
+	_, operator, err := s.getGroupMember(groupID, operatorID)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.dal.ConversationMember().Get(groupID, userID)
+	if err != nil {
+		if dal.IsNotFound(err) {
+			return ErrNotMember
+		}
+		return fmt.Errorf("get member: %w", err)
+	}
+
+	switch {
+	case target.Role == models.MemberRoleOwner:
+		return ErrCannotRemoveOwner
+	case operator.Role == models.MemberRoleOwner:
+		// 群主可以移除任何成员
+	case operator.Role == models.MemberRoleAdmin && target.Role == models.MemberRoleMember:
+		// 管理员只能移除普通成员
+	default:
+		return ErrPermissionDenied
+	}
+
+	if err := s.dal.ConversationMember().Remove(groupID, userID); err != nil {
+		return fmt.Errorf("remove member: %w", err)
+	}
+
+	s.publishRemoved(groupID, userID, operatorID)
+	return nil
+}
+
+// Leave 退出群聊
+func (s *service) Leave(groupID int64, userID int64) error {
+	_, member, err := s.getGroupMember(groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	// 群主退出时，将群主转让给最早加入的管理员，没有管理员则转让给最早加入的成员
+	if member.Role == models.MemberRoleOwner {
+		members, err := s.dal.ConversationMember().GetByConversation(groupID)
+		if err != nil {
+			return fmt.Errorf("get members: %w", err)
+		}
+
+		var successor *models.ConversationMember
+		for _, m := range members {
+			if m.UserID == userID {
+				continue
+			}
+			if m.Role == models.MemberRoleAdmin {
+				successor = m
+				break
+			}
+			if successor == nil {
+				successor = m
+			}
+		}
+
+		if successor != nil {
+			if err := s.dal.ConversationMember().RemoveOwner(groupID, userID, successor.UserID); err != nil {
+				return fmt.Errorf("transfer owner: %w", err)
+			}
+
+			s.publishRemoved(groupID, userID, userID)
+			s.broadcastToGroup(groupID, EventGroupRoleChanged, &RoleChangedEvent{
+				ConversationID: groupID,
+				UserID:         successor.UserID,
+				Role:           models.MemberRoleOwner,
+				ChangedBy:      userID,
+			})
+			return nil
+		}
+	}
+
+	if err := s.dal.ConversationMember().Remove(groupID, userID); err != nil {
+		return fmt.Errorf("remove member: %w", err)
+	}
+
+	s.publishRemoved(groupID, userID, userID)
+	return nil
+}
+
+// SetRole 设置成员角色
+func (s *service) SetRole(groupID int64, operatorID int64, userID int64, role string) error {
+	if role != models.MemberRoleOwner && role != models.MemberRoleAdmin && role != models.MemberRoleMember {
+		return ErrInvalidRole
+	}
+
+	_, operator, err := s.getGroupMember(groupID, operatorID)
+	if err != nil {
+		return err
+	}
+	if operator.Role != models.MemberRoleOwner {
+		return ErrPermissionDenied
+	}
+	if operatorID == userID {
+		return ErrInvalidRole
+	}
+
+	if _, err := s.dal.ConversationMember().Get(groupID, userID); err != nil {
+		if dal.IsNotFound(err) {
+			return ErrNotMember
+		}
+		return fmt.Errorf("get member: %w", err)
+	}
+
+	if err := s.dal.ConversationMember().UpdateRole(groupID, userID, role); err != nil {
+		return fmt.Errorf("update role: %w", err)
+	}
+
+	// 转让群主后原群主降为管理员
+	if role == models.MemberRoleOwner {
+		if err := s.dal.ConversationMember().UpdateRole(groupID, operatorID, models.MemberRoleAdmin); err != nil {
+			return fmt.Errorf("demote owner: %w", err)
+		}
+	}
+
+	s.broadcastToGroup(groupID, EventGroupRoleChanged, &RoleChangedEvent{
+		ConversationID: groupID,
+		UserID:         userID,
+		Role:           role,
+		ChangedBy:      operatorID,
+	})
+
+	return nil
+}
+
+// getGroupMember 获取群聊及指定成员（内部方法）
+func (s *service) getGroupMember(groupID int64, userID int64) (*models.Conversation, *models.ConversationMember, error) {
+	conv, err := s.dal.Conversation().GetByID(groupID)
+	if err != nil || !conv.IsGroup() {
+		return nil, nil, ErrGroupNotFound
+	}
+
+	member, err := s.dal.ConversationMember().Get(groupID, userID)
+	if err != nil {
+		if dal.IsNotFound(err) {
+			return nil, nil, ErrNotMember
+		}
+		return nil, nil, fmt.Errorf("get member: %w", err)
+	}
+
+	return conv, member, nil
+}
+
+// checkUsersExist 校验用户均存在（内部方法）
+func (s *service) checkUsersExist(userIDs []int64) error {
+	for _, uid := range userIDs {
+		if _, err := s.dal.User().GetByID(uid); err != nil {
+			if dal.IsNotFound(err) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("get user: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkAvatar 校验群头像指向已上传的图片，未设置头像时不校验（内部方法）
+func (s *service) checkAvatar(avatarID *int64) error {
+	if avatarID == nil {
+		return nil
+	}
+	media, err := s.dal.Media().GetByID(*avatarID)
+	if err != nil {
+		if dal.IsNotFound(err) {
+			return ErrInvalidAvatar
+		}
+		return fmt.Errorf("get avatar: %w", err)
+	}
+	if media.Type != "image" {
+		return ErrInvalidAvatar
+	}
+	return nil
+}
+
+// broadcastToGroup 推送事件给全部群成员（内部方法）
+func (s *service) broadcastToGroup(groupID int64, eventType string, data interface{}) {
+	members, err := s.dal.ConversationMember().GetByConversation(groupID)
+	if err != nil {
+		return
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	s.broadcastToUsers(ids, eventType, data)
+}
+
+// publishRemoved 成员移除后通知剩余成员和被移除者（内部方法）
+func (s *service) publishRemoved(groupID int64, userID int64, removedBy int64) {
+	event := &MemberRemovedEvent{
+		ConversationID: groupID,
+		UserID:         userID,
+		RemovedBy:      removedBy,
+	}
+	s.broadcastToGroup(groupID, EventGroupMemberRemoved, event)
+	s.broadcastToUsers([]int64{userID}, EventGroupMemberRemoved, event)
+}
+
+// toMemberInfo 转换群成员信息（内部方法）
+func (s *service) toMemberInfo(m *models.ConversationMember) (*models.MemberInfo, error) {
+	u, err := s.dal.User().GetByID(m.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get member user: %w", err)
+	}
+
+	var avatar string
+	if u.AvatarID != nil {
+		avatar = fmt.Sprintf("%d", *u.AvatarID)
+	}
+
+	return &models.MemberInfo{
+		ID:       u.ID,
+		Username: u.Username,
+		Nickname: u.Nickname,
+		Avatar:   avatar,
+		Role:     m.Role,
+		JoinedAt: m.JoinedAt,
+	}, nil
+}
+
+// canManage 是否拥有群管理权限（内部方法）
+func canManage(role string) bool {
+	return role == models.MemberRoleOwner || role == models.MemberRoleAdmin
+}
+
+// uniqueIDs 去重并排除指定ID（内部方法）
+func uniqueIDs(ids []int64, exclude int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if id == exclude || id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
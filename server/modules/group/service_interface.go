@@ -0,0 +1,42 @@
+package group
+
+import (
+	"zmessage/server/models"
+)
+
+// CreateGroupRequest 创建群聊请求
+type CreateGroupRequest struct {
+	Title     string  `json:"title"`
+	AvatarID  *int64  `json:"avatar_id"`
+	MemberIDs []int64 `json:"member_ids"` // 初始成员（不含创建者）
+}
+
+// UpdateGroupRequest 更新群资料请求
+type UpdateGroupRequest struct {
+	Title    *string `json:"title"`
+	AvatarID *int64  `json:"avatar_id"`
+}
+
+// Service 群聊服务接口
+type Service interface {
+	// CreateGroup 创建群聊，创建者成为群主
+	CreateGroup(creatorID int64, req *CreateGroupRequest) (*models.Conversation, error)
+
+	// UpdateGroup 更新群名称和头像（群主或管理员）
+	UpdateGroup(groupID int64, operatorID int64, req *UpdateGroupRequest) (*models.Conversation, error)
+
+	// GetMembers 获取群成员列表（仅群成员可见）
+	GetMembers(groupID int64, userID int64) ([]*models.MemberInfo, error)
+
+	// AddMembers 添加群成员（群主或管理员），返回新加入的成员
+	AddMembers(groupID int64, operatorID int64, userIDs []int64) ([]*models.MemberInfo, error)
+
+	// RemoveMember 移除群成员（群主可移除任何人，管理员只能移除普通成员）
+	RemoveMember(groupID int64, operatorID int64, userID int64) error
+
+	// Leave 退出群聊，群主退出时自动转让群主
+	Leave(groupID int64, userID int64) error
+
+	// SetRole 设置成员角色（仅群主），设为 owner 即转让群主
+	SetRole(groupID int64, operatorID int64, userID int64, role string) error
+}
@@ -0,0 +1,286 @@
+package group
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+)
+
+func setupTestService(t *testing.T, userCount int) (Service, []*models.User) {
+	t.Helper()
+
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	users := make([]*models.User, 0, userCount)
+	for i := 0; i < userCount; i++ {
+		u := &models.User{
+			Username:     fmt.Sprintf("user%d", i),
+			PasswordHash: "hash",
+			Nickname:     fmt.Sprintf("User %d", i),
+			CreatedAt:    time.Now().Unix(),
+			LastSeen:     time.Now().Unix(),
+		}
+		if err := mgr.User().Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	return NewService(mgr), users
+}
+
+func TestService_CreateGroup(t *testing.T) {
+	svc, users := setupTestService(t, 3)
+
+	conv, err := svc.CreateGroup(users[0].ID, &CreateGroupRequest{
+		Title:     "Team",
+		MemberIDs: []int64{users[1].ID, users[2].ID, users[1].ID},
+	})
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	if !conv.IsGroup() {
+		t.Errorf("expected group conversation, got type %s", conv.Type)
+	}
+
+	members, err := svc.GetMembers(conv.ID, users[1].ID)
+	if err != nil {
+		t.Fatalf("get members failed: %v", err)
+	}
+	if len(members) != 3 {
+		t.Errorf("expected 3 members, got %d", len(members))
+	}
+
+	// 空标题
+	if _, err := svc.CreateGroup(users[0].ID, &CreateGroupRequest{Title: "  "}); err != ErrInvalidTitle {
+		t.Errorf("expected ErrInvalidTitle, got: %v", err)
+	}
+
+	// 不存在的成员
+	_, err = svc.CreateGroup(users[0].ID, &CreateGroupRequest{Title: "Bad", MemberIDs: []int64{99999}})
+	if err != ErrUserNotFound {
+		t.Errorf("expected ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestService_CreateGroupAvatarAndNotify(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+	creator := &models.User{Username: "alice", PasswordHash: "hash", Nickname: "Alice", CreatedAt: time.Now().Unix()}
+	member := &models.User{Username: "bob", PasswordHash: "hash", Nickname: "Bob", CreatedAt: time.Now().Unix()}
+	mgr.User().Create(creator)
+	mgr.User().Create(member)
+
+	h := hub.New()
+	notified := make(map[int64]bool)
+	h.Subscribe(hub.AllUsers, []string{EventGroupCreated}, func(userID int64, e *hub.Event) bool {
+		notified[userID] = true
+		return true
+	})
+	svc := NewService(mgr, WithHub(h))
+
+	image := &models.Media{OwnerID: creator.ID, Type: "image", OriginalPath: "/a.jpg", CreatedAt: time.Now().Unix()}
+	voice := &models.Media{OwnerID: creator.ID, Type: "voice", OriginalPath: "/a.ogg", CreatedAt: time.Now().Unix()}
+	mgr.Media().Create(image)
+	mgr.Media().Create(voice)
+
+	// 头像须为已上传的图片
+	for _, id := range []int64{voice.ID, 99999} {
+		if _, err := svc.CreateGroup(creator.ID, &CreateGroupRequest{Title: "Team", AvatarID: &id}); err != ErrInvalidAvatar {
+			t.Errorf("expected ErrInvalidAvatar for media %d, got: %v", id, err)
+		}
+	}
+
+	conv, err := svc.CreateGroup(creator.ID, &CreateGroupRequest{Title: "Team", AvatarID: &image.ID, MemberIDs: []int64{member.ID}})
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	if conv.AvatarID == nil || *conv.AvatarID != image.ID {
+		t.Errorf("expected avatar %d, got %v", image.ID, conv.AvatarID)
+	}
+
+	// 创建者的其他设备也收到新群通知
+	if !notified[creator.ID] || !notified[member.ID] {
+		t.Errorf("expected creator and member to be notified, got %v", notified)
+	}
+
+	if _, err := svc.UpdateGroup(conv.ID, creator.ID, &UpdateGroupRequest{AvatarID: &voice.ID}); err != ErrInvalidAvatar {
+		t.Errorf("expected ErrInvalidAvatar on update, got: %v", err)
+	}
+}
+
+func TestService_Members(t *testing.T) {
+	svc, users := setupTestService(t, 4)
+	owner, admin, member, outsider := users[0], users[1], users[2], users[3]
+
+	conv, err := svc.CreateGroup(owner.ID, &CreateGroupRequest{
+		Title:     "Team",
+		MemberIDs: []int64{admin.ID, member.ID},
+	})
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+
+	if err := svc.SetRole(conv.ID, owner.ID, admin.ID, models.MemberRoleAdmin); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+
+	// 普通成员无权添加成员
+	if _, err := svc.AddMembers(conv.ID, member.ID, []int64{outsider.ID}); err != ErrPermissionDenied {
+		t.Errorf("expected ErrPermissionDenied, got: %v", err)
+	}
+
+	// 管理员添加成员
+	added, err := svc.AddMembers(conv.ID, admin.ID, []int64{outsider.ID})
+	if err != nil {
+		t.Fatalf("add members failed: %v", err)
+	}
+	if len(added) != 1 || added[0].ID != outsider.ID {
+		t.Errorf("unexpected added members: %+v", added)
+	}
+
+	// 管理员不能移除群主
+	if err := svc.RemoveMember(conv.ID, admin.ID, owner.ID); err != ErrCannotRemoveOwner {
+		t.Errorf("expected ErrCannotRemoveOwner, got: %v", err)
+	}
+
+	// 管理员移除普通成员
+	if err := svc.RemoveMember(conv.ID, admin.ID, outsider.ID); err != nil {
+		t.Fatalf("remove member failed: %v", err)
+	}
+	if _, err := svc.GetMembers(conv.ID, outsider.ID); err != ErrNotMember {
+		t.Errorf("expected ErrNotMember, got: %v", err)
+	}
+
+	// 非群主不能修改角色
+	if err := svc.SetRole(conv.ID, admin.ID, member.ID, models.MemberRoleAdmin); err != ErrPermissionDenied {
+		t.Errorf("expected ErrPermissionDenied, got: %v", err)
+	}
+}
+
+func TestService_Leave(t *testing.T) {
+	svc, users := setupTestService(t, 3)
+	owner, admin, member := users[0], users[1], users[2]
+
+	conv, err := svc.CreateGroup(owner.ID, &CreateGroupRequest{
+		Title:     "Team",
+		MemberIDs: []int64{member.ID, admin.ID},
+	})
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	if err := svc.SetRole(conv.ID, owner.ID, admin.ID, models.MemberRoleAdmin); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+
+	// 群主退出后管理员成为群主
+	if err := svc.Leave(conv.ID, owner.ID); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+
+	members, err := svc.GetMembers(conv.ID, admin.ID)
+	if err != nil {
+		t.Fatalf("get members failed: %v", err)
+	}
+	if len(members) != 2 {
+		t.Errorf("expected 2 members, got %d", len(members))
+	}
+	for _, m := range members {
+		if m.ID == admin.ID && m.Role != models.MemberRoleOwner {
+			t.Errorf("expected admin to become owner, got %s", m.Role)
+		}
+	}
+
+	// 修改群资料
+	title := "Renamed"
+	updated, err := svc.UpdateGroup(conv.ID, admin.ID, &UpdateGroupRequest{Title: &title})
+	if err != nil {
+		t.Fatalf("update group failed: %v", err)
+	}
+	if updated.Title != title {
+		t.Errorf("expected title %s, got %s", title, updated.Title)
+	}
+	if _, err := svc.UpdateGroup(conv.ID, member.ID, &UpdateGroupRequest{Title: &title}); err != ErrPermissionDenied {
+		t.Errorf("expected ErrPermissionDenied, got: %v", err)
+	}
+}
+
+func TestService_LeaveEvents(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+	var users []*models.User
+	for _, name := range []string{"owner", "admin", "member"} {
+		u := &models.User{Username: name, PasswordHash: "hash", Nickname: name, CreatedAt: time.Now().Unix()}
+		if err := mgr.User().Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+	owner, admin, member := users[0], users[1], users[2]
+
+	h := hub.New()
+	svc := NewService(mgr, WithHub(h))
+	conv, err := svc.CreateGroup(owner.ID, &CreateGroupRequest{Title: "Team", MemberIDs: []int64{admin.ID, member.ID}})
+	if err != nil {
+		t.Fatalf("create group failed: %v", err)
+	}
+	if err := svc.SetRole(conv.ID, owner.ID, admin.ID, models.MemberRoleAdmin); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+
+	removed := make(map[int64]bool)
+	owners := make(map[int64]int64)
+	h.Subscribe(hub.AllUsers, []string{EventGroupMemberRemoved, EventGroupRoleChanged}, func(userID int64, e *hub.Event) bool {
+		switch data := e.Data.(type) {
+		case *MemberRemovedEvent:
+			// 事件在移除提交后才发布
+			if _, err := mgr.ConversationMember().Get(conv.ID, data.UserID); !dal.IsNotFound(err) {
+				t.Errorf("expected member %d to be removed before the event, got: %v", data.UserID, err)
+			}
+			removed[userID] = true
+		case *RoleChangedEvent:
+			if data.Role == models.MemberRoleOwner {
+				owners[userID] = data.UserID
+			}
+		}
+		return true
+	})
+
+	// 群主退出：被移除者和剩余成员都收到移除事件，剩余成员得知新群主
+	if err := svc.Leave(conv.ID, owner.ID); err != nil {
+		t.Fatalf("leave failed: %v", err)
+	}
+	for _, u := range users {
+		if !removed[u.ID] {
+			t.Errorf("expected user %d to receive the removal", u.ID)
+		}
+	}
+	for _, u := range []*models.User{admin, member} {
+		if owners[u.ID] != admin.ID {
+			t.Errorf("expected user %d to learn admin became owner, got %d", u.ID, owners[u.ID])
+		}
+	}
+	if _, ok := owners[owner.ID]; ok {
+		t.Error("expected former owner not to receive the role change")
+	}
+
+	// 移除失败时不发布事件
+	removed = make(map[int64]bool)
+	if err := svc.RemoveMember(conv.ID, admin.ID, owner.ID); err != ErrNotMember {
+		t.Errorf("expected ErrNotMember, got: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("expected no removal events, got %v", removed)
+	}
+}
@@ -40,6 +40,7 @@ var (
 const (
 	MaxImageSize = 5 * 1024 * 1024  // 5MB
 	MaxVoiceSize = 10 * 1024 * 1024 // 10MB
+	MaxFileSize  = 20 * 1024 * 1024 // 20MB，通用文件不限扩展名
 )
 
 // 缩略图配置
@@ -69,4 +70,8 @@ var MimeTypes = map[string]string{
 	".m4a":  "audio/mp4",
 	".ogg":  "audio/ogg",
 	".wav":  "audio/wav",
+	".webm": "audio/webm",
 }
+
+// FileMimeType 通用文件存储和下发时使用的 MIME 类型（不信任客户端声明的类型）
+const FileMimeType = "application/octet-stream"
@@ -60,13 +60,18 @@ func (s *service) Upload(req *UploadRequest) (*models.Media, error) {
 
 	// 验证类型
 	ext := strings.ToLower(filepath.Ext(req.Header.Filename))
-	mediaType, err := s.ValidateType(req.Header.Filename, req.Header.Header.Get("Content-Type"))
-	if err != nil {
-		return nil, err
-	}
+	// 通用文件不限制扩展名
+	mediaType := req.Type
+	if mediaType != "file" {
+		var err error
+		mediaType, err = s.ValidateType(req.Header.Filename, req.Header.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, err
+		}
 
-	if req.Type != "" && req.Type != mediaType {
-		return nil, ErrInvalidMediaType
+		if req.Type != "" && req.Type != mediaType {
+			return nil, ErrInvalidMediaType
+		}
 	}
 
 	// 读取文件内容
@@ -82,15 +87,18 @@ func (s *service) Upload(req *UploadRequest) (*models.Media, error) {
 		return nil, err
 	}
 
-	// 创建媒体记录
+	// 创建媒体记录（MIME 类型按扩展名推断，不使用客户端声明的类型）
 	now := time.Now().Unix()
 	media := &models.Media{
-		OwnerID:  req.OwnerID,
+		OwnerID:   req.OwnerID,
 		Type:      mediaType,
 		Size:      size,
-		MimeType:  req.Header.Header.Get("Content-Type"),
+		MimeType:  FileMimeType,
 		CreatedAt: now,
 	}
+	if mimeType, ok := MimeTypes[ext]; ok && mediaType != "file" {
+		media.MimeType = mimeType
+	}
 
 	// 保存原始文件
 	contentReader := bytes.NewReader(content)
@@ -254,6 +262,24 @@ func (s *service) ValidateType(filename string, mimeType string) (string, error)
 	return "", ErrInvalidType
 }
 
+// ContentType 返回下发媒体文件时使用的 Content-Type
+// 只有图片和语音使用按扩展名推断的类型，其余一律按二进制文件下载，避免上传的 HTML/SVG 在本站点执行
+func ContentType(m *models.Media) string {
+	switch m.Type {
+	case "image", "voice":
+		for _, mimeType := range MimeTypes {
+			if m.MimeType == mimeType {
+				return mimeType
+			}
+		}
+		if m.Type == "image" {
+			return "image/jpeg"
+		}
+		return "audio/webm"
+	}
+	return FileMimeType
+}
+
 // ValidateSize 验证文件大小
 func (s *service) ValidateSize(size int64, mediaType string) error {
 	switch mediaType {
@@ -265,6 +291,10 @@ func (s *service) ValidateSize(size int64, mediaType string) error {
 		if size > MaxVoiceSize {
 			return ErrInvalidSize
 		}
+	case "file":
+		if size > MaxFileSize {
+			return ErrInvalidSize
+		}
 	default:
 		return ErrInvalidMediaType
 	}
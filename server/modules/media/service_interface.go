@@ -8,10 +8,10 @@ import (
 
 // UploadRequest 上传请求
 type UploadRequest struct {
-	File     multipart.File
-	Header   *multipart.FileHeader
-	Type      string // "image" or "voice"
-	OwnerID   int64
+	File    multipart.File
+	Header  *multipart.FileHeader
+	Type    string // "image", "voice" or "file"（通用文件）
+	OwnerID int64
 }
 
 // MediaWithURL 带URL的媒体信息
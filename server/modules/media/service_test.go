@@ -136,6 +136,16 @@ func TestService_ValidateSize(t *testing.T) {
 	if err != ErrInvalidSize {
 		t.Errorf("11MB voice should be invalid, got: %v", err)
 	}
+
+	err = svc.ValidateSize(15*1024*1024, "file") // 15MB - 应该通过
+	if err != nil {
+		t.Errorf("15MB file should be valid, got: %v", err)
+	}
+
+	err = svc.ValidateSize(21*1024*1024, "file") // 21MB - 应该失败
+	if err != ErrInvalidSize {
+		t.Errorf("21MB file should be invalid, got: %v", err)
+	}
 }
 
 func TestService_Delete(t *testing.T) {
@@ -188,3 +198,21 @@ func TestService_GetWithInvalidID(t *testing.T) {
 		t.Errorf("expected ErrNotFound, got: %v", err)
 	}
 }
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		media *models.Media
+		want  string
+	}{
+		{&models.Media{Type: "image", MimeType: "image/png"}, "image/png"},
+		{&models.Media{Type: "image", MimeType: "image/svg+xml"}, "image/jpeg"},
+		{&models.Media{Type: "voice", MimeType: "text/html"}, "audio/webm"},
+		{&models.Media{Type: "file", MimeType: "text/html"}, FileMimeType},
+	}
+
+	for _, tt := range tests {
+		if got := ContentType(tt.media); got != tt.want {
+			t.Errorf("ContentType(%s, %s) = %q, want %q", tt.media.Type, tt.media.MimeType, got, tt.want)
+		}
+	}
+}
@@ -25,4 +25,40 @@ var (
 
 	// ErrAccessDenied 无权访问
 	ErrAccessDenied = fmt.Errorf("access denied")
+
+	// ErrMessageNotEditable 消息不可编辑（非文本消息）
+	ErrMessageNotEditable = fmt.Errorf("message not editable")
+
+	// ErrRecallWindowExpired 超过撤回时限
+	ErrRecallWindowExpired = fmt.Errorf("recall window expired")
+
+	// ErrInvalidReply 引用的消息不存在或不属于当前会话
+	ErrInvalidReply = fmt.Errorf("invalid reply target")
+
+	// ErrInvalidReaction 无效的表情回应
+	ErrInvalidReaction = fmt.Errorf("invalid reaction")
+
+	// ErrInvalidClientMsgID 无效的客户端消息ID
+	ErrInvalidClientMsgID = fmt.Errorf("invalid client message id")
+
+	// ErrInvalidStatus 无效的消息状态
+	ErrInvalidStatus = fmt.Errorf("invalid message status")
+
+	// ErrInvalidSearchQuery 无效的搜索条件
+	ErrInvalidSearchQuery = fmt.Errorf("invalid search query")
+
+	// ErrInvalidDisappearTimer 无效的阅后即焚时长
+	ErrInvalidDisappearTimer = fmt.Errorf("invalid disappearing timer")
+
+	// ErrPinLimitReached 置顶消息（或置顶会话）数已达上限
+	ErrPinLimitReached = fmt.Errorf("pin limit reached")
+
+	// ErrInvalidForward 无效的转发（消息为空、超过上限或包含不可转发的消息）
+	ErrInvalidForward = fmt.Errorf("invalid forward")
+
+	// ErrInvalidDraft 无效的草稿
+	ErrInvalidDraft = fmt.Errorf("invalid draft")
+
+	// ErrInvalidSettings 无效的会话设置
+	ErrInvalidSettings = fmt.Errorf("invalid conversation settings")
 )
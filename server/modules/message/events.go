@@ -0,0 +1,103 @@
+package message
+
+import (
+	"zmessage/server/models"
+)
+
+// 消息服务发布的实时事件类型（即 SSE 的 event 名）
+const (
+	EventChat                 = "chat"
+	EventMessageEdited        = "message_edited"
+	EventMessageRecalled      = "message_recalled"
+	EventMessageDeleted       = "message_deleted"
+	EventReactionUpdated      = "reaction_updated"
+	EventPinsUpdated          = "pins_updated"
+	EventMessageStatus        = "message_status"        // 数据为 *models.MessageReceipt
+	EventReadCursor           = "read_cursor"           // 数据为 *models.ReadCursor
+	EventDraftUpdated         = "draft_updated"         // 数据为 *models.Draft
+	EventConversationSettings = "conversation_settings" // 数据为 *SettingsEvent
+	EventHistoryCleared       = "history_cleared"       // 数据为 *HistoryClearedEvent
+)
+
+// ChatEvent 新消息事件
+type ChatEvent struct {
+	MessageID      int64                `json:"message_id"`
+	ConversationID int64                `json:"conversation_id"`
+	SenderID       int64                `json:"sender_id"`
+	ReceiverID     int64                `json:"receiver_id"`
+	Type           string               `json:"type"`
+	Content        string               `json:"content"`
+	CreatedAt      int64                `json:"created_at"`
+	ReplyTo        *models.ReplyPreview `json:"reply_to"`
+	ExpiresAt      *int64               `json:"expires_at"`
+	ForwardedFrom  *models.ForwardInfo  `json:"forwarded_from"`
+}
+
+// newChatEvent 构建新消息事件
+func newChatEvent(msg *models.Message) *ChatEvent {
+	return &ChatEvent{
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		SenderID:       msg.SenderID,
+		ReceiverID:     msg.ReceiverID,
+		Type:           msg.Type,
+		Content:        msg.Content,
+		CreatedAt:      msg.CreatedAt,
+		ReplyTo:        msg.ReplyTo,
+		ExpiresAt:      msg.ExpiresAt,
+		ForwardedFrom:  msg.ForwardedFrom,
+	}
+}
+
+// MessageEditedEvent 消息编辑事件
+type MessageEditedEvent struct {
+	MessageID      int64  `json:"message_id"`
+	ConversationID int64  `json:"conversation_id"`
+	SenderID       int64  `json:"sender_id"`
+	Content        string `json:"content"`
+	EditedAt       int64  `json:"edited_at"`
+}
+
+// MessageRecalledEvent 消息撤回事件
+type MessageRecalledEvent struct {
+	MessageID      int64 `json:"message_id"`
+	ConversationID int64 `json:"conversation_id"`
+	SenderID       int64 `json:"sender_id"`
+	RecalledAt     int64 `json:"recalled_at"`
+}
+
+// MessageDeletedEvent 消息删除事件（仅自己，同步到自己的其他连接）
+type MessageDeletedEvent struct {
+	MessageID      int64 `json:"message_id"`
+	ConversationID int64 `json:"conversation_id"`
+}
+
+// ReactionEvent 表情回应事件
+type ReactionEvent struct {
+	MessageID      int64  `json:"message_id"`
+	ConversationID int64  `json:"conversation_id"`
+	UserID         int64  `json:"user_id"`
+	Emoji          string `json:"emoji"`
+	Action         string `json:"action"` // add/remove
+}
+
+// PinEvent 置顶消息变更事件
+type PinEvent struct {
+	ConversationID int64  `json:"conversation_id"`
+	MessageID      int64  `json:"message_id"`
+	UserID         int64  `json:"user_id"`
+	Action         string `json:"action"` // pin/unpin
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+// SettingsEvent 会话个人设置变更事件（同步到自己的其他连接）
+type SettingsEvent struct {
+	ConversationID int64                        `json:"conversation_id"`
+	Settings       *models.ConversationSettings `json:"settings"`
+}
+
+// HistoryClearedEvent 清空聊天记录事件（同步到自己的其他连接）
+type HistoryClearedEvent struct {
+	ConversationID   int64 `json:"conversation_id"`
+	ClearedMessageID int64 `json:"cleared_message_id"`
+}
@@ -1,78 +1,194 @@
 package message
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 	"zmessage/server/dal"
+	"zmessage/server/hub"
 	"zmessage/server/models"
-	"zmessage/server/sse"
 )
 
-// 广播消息给指定用户的所有 SSE 连接
-func broadcastMessage(userID int64, msgType string, data interface{}) {
-	sse.Broadcast(userID, msgType, data)
+// DefaultRecallWindow 默认撤回时限
+const DefaultRecallWindow = 2 * time.Minute
+
+// ReplyPreviewLength 引用预览的最大字符数
+const ReplyPreviewLength = 100
+
+// MaxReactionLength 表情回应的最大字符数（兼容组合表情）
+const MaxReactionLength = 16
+
+// MaxPinnedMessages 每个会话最多置顶的消息数
+const MaxPinnedMessages = 10
+
+// MaxPinnedConversations 每个用户最多置顶的会话数
+const MaxPinnedConversations = 5
+
+// MaxClientMsgIDLength 客户端消息ID的最大长度
+const MaxClientMsgIDLength = 64
+
+// MaxForwardMessages 单次最多转发的消息数
+const MaxForwardMessages = 50
+
+// MaxDraftLength 草稿内容的最大字节数
+const MaxDraftLength = 10000
+
+// MaxSearchQueryLength 搜索关键词的最大字符数
+const MaxSearchQueryLength = 100
+
+// DisappearTimers 可选的阅后即焚时长（秒）及其显示文本
+var DisappearTimers = map[int64]string{
+	3600:   "1 小时",
+	86400:  "1 天",
+	604800: "7 天",
+}
+
+// Option 消息服务配置项
+type Option func(*service)
+
+// WithRecallWindow 设置撤回时限（发送后超过该时长不可撤回）
+func WithRecallWindow(d time.Duration) Option {
+	return func(s *service) {
+		s.recallWindow = d
+	}
+}
+
+// WithHub 设置实时事件中心（未设置时事件不会推送给任何连接）
+func WithHub(h hub.Hub) Option {
+	return func(s *service) {
+		s.hub = h
+	}
 }
 
 // NewService 创建消息服务
-func NewService(dalMgr dal.Manager) Service {
-	return &service{
-		dal: dalMgr,
+func NewService(dalMgr dal.Manager, opts ...Option) Service {
+	s := &service{
+		dal:          dalMgr,
+		recallWindow: DefaultRecallWindow,
+		hub:          hub.New(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // service 消息服务实现
 type service struct {
-	dal dal.Manager
+	dal          dal.Manager
+	recallWindow time.Duration
+	hub          hub.Hub
+
+	mu sync.Mutex
+}
+
+// publish 通过事件中心向用户发布事件，返回投递成功的连接数（内部方法）
+func (s *service) publish(userID int64, eventType string, data interface{}) int {
+	return s.hub.Publish(userID, &hub.Event{Type: eventType, Data: data})
 }
 
 // SendMessage 发送消息
 func (s *service) SendMessage(req *SendMessageRequest) (*models.Message, error) {
 	// 验证发送者和接收者
-	if req.From == req.To {
+	if req.ConversationID == 0 && req.From == req.To {
 		return nil, ErrSendToSelf
 	}
 
-	// 验证消息类型
-	if !isValidMessageType(req.Type) {
-		return nil, ErrInvalidMessageType
+	// 验证消息类型和内容格式
+	if err := ValidateContent(req.Type, req.Content); err != nil {
+		return nil, err
 	}
 
-	// 验证消息内容
-	if req.Content == "" {
-		return nil, ErrInvalidMessageContent
+	if len(req.ClientMsgID) > MaxClientMsgIDLength {
+		return nil, ErrInvalidClientMsgID
 	}
 
 	// 验证用户存在
 	sender, err := s.dal.User().GetByID(req.From)
 	if err != nil || sender == nil {
-		return nil, fmt.Errorf("get sender: %w", err)
+		return nil, ErrUserNotFound
 	}
 
-	receiver, err := s.dal.User().GetByID(req.To)
-	if err != nil || receiver == nil {
-		return nil, fmt.Errorf("get receiver: %w", err)
+	// 名片须指向存在的用户
+	if req.Type == models.MessageTypeContact {
+		if err := s.checkContact(req.Content); err != nil {
+			return nil, err
+		}
 	}
 
-	// 获取或创建会话
-	conv, err := s.getOrCreateConversation(req.From, req.To)
-	if err != nil {
-		return nil, err
+	// 确定目标会话和接收者
+	var conv *models.Conversation
+	var receiverID int64
+	if req.ConversationID > 0 {
+		conv, err = s.checkParticipant(req.ConversationID, req.From)
+		if err != nil {
+			return nil, err
+		}
+		if !conv.IsGroup() {
+			receiverID = otherParticipant(conv, req.From)
+		}
+	} else {
+		receiver, err := s.dal.User().GetByID(req.To)
+		if err != nil || receiver == nil {
+			return nil, ErrUserNotFound
+		}
+
+		// 获取或创建会话
+		conv, err = s.getOrCreateConversation(req.From, req.To)
+		if err != nil {
+			return nil, err
+		}
+		receiverID = req.To
+	}
+
+	// 重发的消息直接返回已存储的结果（须在确认仍可向目标会话发送之后）
+	if req.ClientMsgID != "" {
+		if existing, err := s.findByClientMsgID(conv.ID, req.From, req.ClientMsgID); err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	// 验证引用的消息属于同一会话
+	var replyTo *models.ReplyPreview
+	if req.ReplyToID > 0 {
+		quoted, err := s.getMessage(conv.ID, req.ReplyToID)
+		if err != nil {
+			return nil, ErrInvalidReply
+		}
+		replyTo = s.buildReplyPreview(quoted)
 	}
 
 	// 创建消息
 	now := time.Now().Unix()
 	msg := &models.Message{
 		ConversationID: conv.ID,
-		SenderID:      req.From,
-		ReceiverID:    req.To,
-		Type:          req.Type,
-		Content:       req.Content,
-		Status:        "sent",
-		CreatedAt:     now,
-		SyncedAt:      &now,
+		SenderID:       req.From,
+		ReceiverID:     receiverID,
+		Type:           req.Type,
+		Content:        req.Content,
+		Status:         "sent",
+		CreatedAt:      now,
+		SyncedAt:       &now,
+		ReplyToID:      req.ReplyToID,
+		ReplyTo:        replyTo,
+		ClientMsgID:    req.ClientMsgID,
+		ForwardedFrom:  req.ForwardedFrom,
+	}
+
+	// 开启了阅后即焚的会话，消息在设定时长后过期
+	if conv.DisappearAfter > 0 {
+		expiresAt := now + conv.DisappearAfter
+		msg.ExpiresAt = &expiresAt
 	}
 
 	if err := s.dal.Message().Create(msg); err != nil {
+		// 并发重发：另一个请求已先写入
+		if dal.IsDuplicate(err) && req.ClientMsgID != "" {
+			return s.findByClientMsgID(conv.ID, req.From, req.ClientMsgID)
+		}
 		return nil, fmt.Errorf("create message: %w", err)
 	}
 
@@ -81,121 +197,168 @@ func (s *service) SendMessage(req *SendMessageRequest) (*models.Message, error)
 		return nil, fmt.Errorf("update conversation time: %w", err)
 	}
 
-	// 推送给接收者（通过 SSE）
-	broadcastMessage(req.To, "chat", map[string]interface{}{
-		"message_id":     msg.ID,
-		"conversation_id": conv.ID,
-		"sender_id":      req.From,
-		"receiver_id":    req.To,
-		"type":           req.Type,
-		"content":        req.Content,
-		"created_at":     msg.CreatedAt,
-	})
+	// 新消息让已隐藏的会话重新出现，并取消未开启免打扰的成员的归档
+	if err := s.dal.ConversationMember().RestoreOnNewMessage(conv.ID, now); err != nil {
+		return nil, err
+	}
 
-	return msg, nil
-}
+	// 发送成功后清除发送者在该会话的草稿
+	if !req.KeepDraft {
+		if err := s.clearDraft(req.From, conv.ID, now); err != nil {
+			return nil, err
+		}
+	}
 
-// GetConversation 获取会话详情
-func (s *service) GetConversation(id int64, userID int64) (*models.ConversationWithInfo, error) {
-	conv, err := s.dal.Conversation().GetByID(id)
+	// 推送给其他参与者，并同步到发送者的其他设备（通过事件中心）
+	recipients, err := s.GetParticipantIDs(conv.ID)
 	if err != nil {
-		return nil, ErrConversationNotFound
+		return nil, err
 	}
+	event := newChatEvent(msg)
+	for _, uid := range recipients {
+		if uid == req.From {
+			s.hub.Publish(uid, &hub.Event{Type: EventChat, Data: event, Origin: req.Origin})
+			continue
+		}
+		delivered := s.publish(uid, EventChat, event)
 
-	// 验证用户是否是会话参与者
-	if conv.UserAID != userID && conv.UserBID != userID {
-		return nil, ErrAccessDenied
+		// 已推送到接收者的连接，标记为已投递
+		if delivered > 0 && uid == receiverID {
+			if _, err := s.MarkDelivered(uid, []int64{msg.ID}); err != nil {
+				return nil, err
+			}
+			msg.Status = models.MessageStatusDelivered
+		}
 	}
 
-	// 获取对方用户信息
-	var otherUserID int64
-	if conv.UserAID == userID {
-		otherUserID = conv.UserBID
-	} else {
-		otherUserID = conv.UserAID
+	return msg, nil
+}
+
+// ForwardMessages 转发消息
+func (s *service) ForwardMessages(req *ForwardMessagesRequest) ([]*models.Message, error) {
+	if len(req.MessageIDs) == 0 || len(req.MessageIDs) > MaxForwardMessages {
+		return nil, ErrInvalidForward
 	}
 
-	otherUser, err := s.dal.User().GetByID(otherUserID)
-	if err != nil {
-		return nil, fmt.Errorf("get other user: %w", err)
+	// 须能读取来源会话并向目标会话发送
+	if _, err := s.checkParticipant(req.SourceConversationID, req.UserID); err != nil {
+		return nil, err
+	}
+	if _, err := s.checkParticipant(req.TargetConversationID, req.UserID); err != nil {
+		return nil, err
 	}
 
-	// 获取未读消息数
-	unreadCount, err := s.dal.Message().CountUnread(id, userID)
-	if err != nil {
-		return nil, fmt.Errorf("count unread: %w", err)
+	// 先校验全部消息，避免只转发了一部分
+	originals := make([]*models.Message, len(req.MessageIDs))
+	for i, id := range req.MessageIDs {
+		original, err := s.getMessage(req.SourceConversationID, id)
+		if err != nil {
+			return nil, err
+		}
+		if original.Type == models.MessageTypeSystem {
+			return nil, ErrInvalidForward
+		}
+		originals[i] = original
 	}
 
-	// 构建返回信息
-	convInfo := &models.ConversationWithInfo{
-		ID:           conv.ID,
-		UserAID:      conv.UserAID,
-		UserBID:      conv.UserBID,
-		Participant:   toUserInfo(otherUser),
-		UnreadCount:   unreadCount,
-		CreatedAt:     conv.CreatedAt,
-		UpdatedAt:     conv.UpdatedAt,
+	forwarded := make([]*models.Message, 0, len(originals))
+	for _, original := range originals {
+		// 多次转发时保留最初的来源
+		from := original.ForwardedFrom
+		if from == nil {
+			from = &models.ForwardInfo{
+				MessageID: original.ID,
+				SenderID:  original.SenderID,
+				CreatedAt: original.CreatedAt,
+			}
+		}
+
+		// 图片和语音的内容是媒体ID，直接复用已有的媒体记录
+		msg, err := s.SendMessage(&SendMessageRequest{
+			From:           req.UserID,
+			ConversationID: req.TargetConversationID,
+			Type:           original.Type,
+			Content:        original.Content,
+			KeepDraft:      true,
+			ForwardedFrom:  from,
+		})
+		if err != nil {
+			return nil, err
+		}
+		forwarded = append(forwarded, msg)
 	}
+	s.attachForwardSenders(forwarded)
 
-	return convInfo, nil
+	return forwarded, nil
 }
 
-// GetConversationWithUser 获取或创建与指定用户的会话
-func (s *service) GetConversationWithUser(userID, otherUserID int64) (*models.ConversationWithInfo, error) {
-	// 获取或创建会话
-	conv, err := s.getOrCreateConversation(userID, otherUserID)
-	if err != nil {
-		return nil, err
+// checkContact 验证名片指向的用户存在（内部方法）
+func (s *service) checkContact(content string) error {
+	var contact models.ContactContent
+	if err := json.Unmarshal([]byte(content), &contact); err != nil {
+		return ErrInvalidMessageContent
 	}
-
-	// 获取对方用户信息
-	var participantID int64
-	if conv.UserAID == userID {
-		participantID = conv.UserBID
-	} else {
-		participantID = conv.UserAID
+	if _, err := s.dal.User().GetByID(contact.UserID); err != nil {
+		return ErrInvalidMessageContent
 	}
+	return nil
+}
 
-	participant, err := s.dal.User().GetByID(participantID)
+// findByClientMsgID 按客户端消息ID查找同一会话中已发送的消息，不存在时返回nil
+// 客户端消息ID已用于其他会话时返回 ErrInvalidClientMsgID，避免把别处的消息当作发送结果
+func (s *service) findByClientMsgID(conversationID int64, senderID int64, clientMsgID string) (*models.Message, error) {
+	msg, err := s.dal.Message().GetByClientMsgID(senderID, clientMsgID)
 	if err != nil {
-		return nil, fmt.Errorf("get participant: %w", err)
+		if dal.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get message by client msg id: %w", err)
 	}
-
-	// 获取未读消息数
-	unreadCount, err := s.dal.Message().CountUnread(conv.ID, userID)
-	if err != nil {
-		return nil, fmt.Errorf("count unread: %w", err)
+	if msg.ConversationID != conversationID {
+		return nil, ErrInvalidClientMsgID
 	}
+	s.attachReplyPreviews([]*models.Message{msg})
+	return msg, nil
+}
 
-	// 获取最后一条消息
-	messages, err := s.dal.Message().GetByConversation(conv.ID, 0, 1)
+// GetConversation 获取会话详情
+func (s *service) GetConversation(id int64, userID int64) (*models.ConversationWithInfo, error) {
+	// 验证用户是否是会话参与者
+	conv, err := s.checkParticipant(id, userID)
 	if err != nil {
-		return nil, fmt.Errorf("get last message: %w", err)
+		return nil, err
 	}
 
-	var lastMessage *models.Message
-	if len(messages) > 0 {
-		lastMessage = messages[0]
-	}
+	return s.buildConversationInfo(conv, userID)
+}
 
-	// 构建返回信息
-	convInfo := &models.ConversationWithInfo{
-		ID:           conv.ID,
-		UserAID:      conv.UserAID,
-		UserBID:      conv.UserBID,
-		Participant:   toUserInfo(participant),
-		LastMessage:   lastMessage,
-		UnreadCount:   unreadCount,
-		CreatedAt:     conv.CreatedAt,
-		UpdatedAt:     conv.UpdatedAt,
+// GetConversationWithUser 获取或创建与指定用户的会话
+func (s *service) GetConversationWithUser(userID, otherUserID int64) (*models.ConversationWithInfo, error) {
+	// 获取或创建会话
+	conv, err := s.getOrCreateConversation(userID, otherUserID)
+	if err != nil {
+		return nil, err
 	}
 
-	return convInfo, nil
+	return s.buildConversationInfo(conv, userID)
 }
 
-// GetConversations 获取用户的所有会话
+// GetConversations 获取用户的会话（不含已归档和已隐藏的会话）
 func (s *service) GetConversations(userID int64, page, limit int) ([]*models.ConversationWithInfo, int, error) {
+	visible := false
+	return s.ListConversations(&ConversationListRequest{
+		UserID:   userID,
+		Archived: &visible,
+		Hidden:   &visible,
+		Page:     page,
+		Limit:    limit,
+	})
+}
+
+// ListConversations 按个人设置过滤用户的会话
+func (s *service) ListConversations(req *ConversationListRequest) ([]*models.ConversationWithInfo, int, error) {
 	// 默认值
+	page, limit := req.Page, req.Limit
 	if page < 1 {
 		page = 1
 	}
@@ -203,14 +366,23 @@ func (s *service) GetConversations(userID int64, page, limit int) ([]*models.Con
 		limit = 20
 	}
 
-	convs, total, err := s.dal.Conversation().GetByUser(userID, page, limit)
+	convs, total, err := s.dal.Conversation().ListByUser(&models.ConversationQuery{
+		UserID:   req.UserID,
+		Archived: req.Archived,
+		Hidden:   req.Hidden,
+		Muted:    req.Muted,
+		Pinned:   req.Pinned,
+		Now:      time.Now().Unix(),
+		Page:     page,
+		Limit:    limit,
+	})
 	if err != nil {
 		return nil, 0, err
 	}
 
 	result := make([]*models.ConversationWithInfo, 0, len(convs))
 	for _, conv := range convs {
-		convInfo, err := s.buildConversationInfo(conv, userID)
+		convInfo, err := s.buildConversationInfo(conv, req.UserID)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -220,15 +392,63 @@ func (s *service) GetConversations(userID int64, page, limit int) ([]*models.Con
 	return result, total, nil
 }
 
+// UpdateConversationSettings 更新用户对会话的个人设置
+func (s *service) UpdateConversationSettings(req *UpdateConversationSettingsRequest) (*models.ConversationSettings, error) {
+	if req.MutedUntil != nil && *req.MutedUntil < 0 {
+		return nil, ErrInvalidSettings
+	}
+	if _, err := s.checkParticipant(req.ConversationID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings, err := s.dal.ConversationMember().GetSettings(req.ConversationID, req.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get conversation settings: %w", err)
+	}
+
+	now := time.Now().Unix()
+	if req.Archived != nil {
+		settings.Archived = *req.Archived
+	}
+	if req.MutedUntil != nil {
+		settings.MutedUntil = *req.MutedUntil
+	}
+	if req.Hidden != nil {
+		settings.Hidden = *req.Hidden
+	}
+	if req.Pinned != nil {
+		switch {
+		case *req.Pinned && settings.PinnedAt == 0:
+			count, err := s.dal.ConversationMember().CountPinned(req.UserID)
+			if err != nil {
+				return nil, err
+			}
+			if count >= MaxPinnedConversations {
+				return nil, ErrPinLimitReached
+			}
+			settings.PinnedAt = now
+		case !*req.Pinned:
+			settings.PinnedAt = 0
+		}
+	}
+
+	if err := s.dal.ConversationMember().UpdateSettings(req.ConversationID, req.UserID, settings); err != nil {
+		return nil, fmt.Errorf("update conversation settings: %w", err)
+	}
+
+	// 同步到用户的其他设备
+	s.publish(req.UserID, EventConversationSettings, &SettingsEvent{ConversationID: req.ConversationID, Settings: settings})
+	return settings, nil
+}
+
 // GetMessages 获取消息历史
 func (s *service) GetMessages(conversationID int64, userID int64, beforeID int64, limit int) ([]*models.Message, bool, error) {
 	// 验证用户是否是会话参与者
-	conv, err := s.dal.Conversation().GetByID(conversationID)
-	if err != nil {
-		return nil, false, ErrConversationNotFound
-	}
-	if conv.UserAID != userID && conv.UserBID != userID {
-		return nil, false, ErrAccessDenied
+	if _, err := s.checkParticipant(conversationID, userID); err != nil {
+		return nil, false, err
 	}
 
 	// 默认值
@@ -239,34 +459,152 @@ func (s *service) GetMessages(conversationID int64, userID int64, beforeID int64
 		limit = 100
 	}
 
-	messages, err := s.dal.Message().GetByConversation(conversationID, beforeID, limit)
+	messages, err := s.dal.Message().GetVisibleByConversation(conversationID, userID, beforeID, limit)
 	if err != nil {
 		return nil, false, err
 	}
+	s.attachReplyPreviews(messages)
+	s.attachForwardSenders(messages)
+	if err := s.attachReactions(messages, userID); err != nil {
+		return nil, false, err
+	}
+	if err := s.attachStars(messages, userID); err != nil {
+		return nil, false, err
+	}
 
 	// 检查是否还有更多消息
 	hasMore := len(messages) == limit
 	return messages, hasMore, nil
 }
 
-// MarkAsRead 标记会话消息为已读
-func (s *service) MarkAsRead(conversationID int64, userID int64) error {
+// MarkAsRead 将用户的已读位置推进到会话最新消息，返回推送给发送者的回执（无变化时为nil）
+func (s *service) MarkAsRead(conversationID int64, userID int64) (*models.MessageReceipt, error) {
 	// 验证用户是否是会话参与者
-	conv, err := s.dal.Conversation().GetByID(conversationID)
+	conv, err := s.checkParticipant(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.dal.Message().GetByConversation(conversationID, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("get last message: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return s.advanceReadCursor(conv, userID, messages[0].ID)
+}
+
+// UpdateMessageStatus 接收者回执单条消息（仅接收者可推进，状态不会回退）
+// 已读回执推进接收者的已读位置，该消息及之前的消息都视为已读
+func (s *service) UpdateMessageStatus(messageID int64, userID int64, status string) (*models.MessageReceipt, error) {
+	if status != models.MessageStatusDelivered && status != models.MessageStatusRead {
+		return nil, ErrInvalidStatus
+	}
+
+	msg, err := s.getMessage(0, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ReceiverID != userID {
+		return nil, ErrAccessDenied
+	}
+
+	if status == models.MessageStatusRead {
+		conv, err := s.dal.Conversation().GetByID(msg.ConversationID)
+		if err != nil {
+			return nil, ErrConversationNotFound
+		}
+		return s.advanceReadCursor(conv, userID, msg.ID)
+	}
+
+	changed, err := s.dal.Message().AdvanceStatus(userID, []int64{messageID}, status)
+	if err != nil {
+		return nil, fmt.Errorf("update message status: %w", err)
+	}
+	return firstReceipt(s.notifyReceipts(userID, changed, status)), nil
+}
+
+// advanceReadCursor 推进用户的已读位置并通知其他参与者（内部方法）
+// 单聊同时为新读到的消息生成已读回执
+func (s *service) advanceReadCursor(conv *models.Conversation, userID int64, messageID int64) (*models.MessageReceipt, error) {
+	prev, err := s.dal.ConversationMember().UpdateLastRead(conv.ID, userID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("update last read: %w", err)
+	}
+	if messageID <= prev {
+		return nil, nil
+	}
+
+	// 其他参与者据此绘制"已读到这里"的标记，自己的其他设备据此同步已读位置
+	recipients, err := s.GetParticipantIDs(conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	cursor := &models.ReadCursor{ConversationID: conv.ID, UserID: userID, LastReadMessageID: messageID}
+	for _, uid := range recipients {
+		s.publish(uid, EventReadCursor, cursor)
+	}
+
+	// 群聊消息没有单一接收者，只推进已读位置
+	if conv.IsGroup() {
+		return nil, nil
+	}
+	changed, err := s.dal.Message().GetReadRange(conv.ID, userID, prev, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("mark as read: %w", err)
+	}
+	return firstReceipt(s.notifyReceipts(userID, changed, models.MessageStatusRead)), nil
+}
+
+// MarkDelivered 消息已推送到接收者的连接，自动标记为已投递
+// 非该用户接收的消息会被忽略，返回按会话汇总的回执
+func (s *service) MarkDelivered(userID int64, messageIDs []int64) ([]*models.MessageReceipt, error) {
+	changed, err := s.dal.Message().AdvanceStatus(userID, messageIDs, models.MessageStatusDelivered)
 	if err != nil {
-		return ErrConversationNotFound
+		return nil, fmt.Errorf("mark delivered: %w", err)
+	}
+	return s.notifyReceipts(userID, changed, models.MessageStatusDelivered), nil
+}
+
+// notifyReceipts 将状态发生变化的消息按会话和发送者汇总为回执，并通过事件中心推送给发送者
+func (s *service) notifyReceipts(userID int64, changed []*models.Message, status string) []*models.MessageReceipt {
+	if len(changed) == 0 {
+		return nil
 	}
-	if conv.UserAID != userID && conv.UserBID != userID {
-		return ErrAccessDenied
+
+	now := time.Now().Unix()
+	var receipts []*models.MessageReceipt
+	index := make(map[[2]int64]*models.MessageReceipt)
+	for _, msg := range changed {
+		key := [2]int64{msg.ConversationID, msg.SenderID}
+		r, ok := index[key]
+		if !ok {
+			r = &models.MessageReceipt{
+				ConversationID: msg.ConversationID,
+				SenderID:       msg.SenderID,
+				UserID:         userID,
+				Status:         status,
+				UpdatedAt:      now,
+			}
+			index[key] = r
+			receipts = append(receipts, r)
+		}
+		r.MessageIDs = append(r.MessageIDs, msg.ID)
 	}
 
-	// 更新消息状态
-	return s.dal.Message().UpdateMessagesStatus(conversationID, userID, "read")
+	for _, r := range receipts {
+		s.publish(r.SenderID, EventMessageStatus, r)
+	}
+	return receipts
 }
 
-// UpdateMessageStatus 更新消息状态
-func (s *service) UpdateMessageStatus(messageID int64, status string) error {
-	return s.dal.Message().UpdateStatus(messageID, status)
+// firstReceipt 返回第一条回执（单聊回执只涉及一个发送者）
+func firstReceipt(receipts []*models.MessageReceipt) *models.MessageReceipt {
+	if len(receipts) == 0 {
+		return nil
+	}
+	return receipts[0]
 }
 
 // GetOfflineMessages 获取离线消息
@@ -279,109 +617,1002 @@ func (s *service) GetOfflineMessages(userID int64, lastMessageID int64, limit in
 		limit = 100
 	}
 
-	return s.dal.Message().GetOfflineMessages(userID, lastMessageID, limit)
+	messages, err := s.dal.Message().GetOfflineMessages(userID, lastMessageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.attachReplyPreviews(messages)
+	s.attachForwardSenders(messages)
+	return messages, nil
 }
 
 // GetUnreadCount 获取未读消息数
 func (s *service) GetUnreadCount(conversationID int64, userID int64) (int, error) {
 	// 验证用户是否是会话参与者
-	conv, err := s.dal.Conversation().GetByID(conversationID)
-	if err != nil {
-		return 0, ErrConversationNotFound
-	}
-	if conv.UserAID != userID && conv.UserBID != userID {
-		return 0, ErrAccessDenied
+	if _, err := s.checkParticipant(conversationID, userID); err != nil {
+		return 0, err
 	}
 
 	return s.dal.Message().CountUnread(conversationID, userID)
 }
 
-// getOrCreateConversation 获取或创建会话（内部方法）
-func (s *service) getOrCreateConversation(from, to int64) (*models.Conversation, error) {
-	// 确保 userA < userB（用于排序）
-	userA, userB := from, to
-	if userA > userB {
-		userA, userB = userB, userA
+// ClearHistory 推进用户在会话中的清空水位，之前的消息对其不再可见，也不计入未读
+func (s *service) ClearHistory(conversationID int64, userID int64, upToMessageID int64) (int64, error) {
+	if upToMessageID < 0 {
+		return 0, ErrMessageNotFound
 	}
-
-	// 检查是否已存在
-	conv, err := s.dal.Conversation().GetByUsers(userA, userB)
-	if err == nil {
-		return conv, nil
+	if _, err := s.checkParticipant(conversationID, userID); err != nil {
+		return 0, err
 	}
 
-	// 创建新会话
-	now := time.Now().Unix()
-	conv = &models.Conversation{
-		UserAID:   userA,
-		UserBID:   userB,
-		CreatedAt: now,
-		UpdatedAt: now,
+	if upToMessageID > 0 {
+		if _, err := s.getMessage(conversationID, upToMessageID); err != nil {
+			return 0, err
+		}
+	} else {
+		messages, err := s.dal.Message().GetByConversation(conversationID, 0, 1)
+		if err != nil {
+			return 0, fmt.Errorf("get last message: %w", err)
+		}
+		if len(messages) == 0 {
+			return 0, nil
+		}
+		upToMessageID = messages[0].ID
 	}
 
-	if err := s.dal.Conversation().Create(conv); err != nil {
-		return nil, fmt.Errorf("create conversation: %w", err)
+	if err := s.dal.ConversationMember().ClearHistory(conversationID, userID, upToMessageID); err != nil {
+		return 0, fmt.Errorf("clear history: %w", err)
+	}
+	settings, err := s.dal.ConversationMember().GetSettings(conversationID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("get conversation settings: %w", err)
 	}
 
-	return conv, nil
+	// 同步到用户的其他设备
+	s.publish(userID, EventHistoryCleared, &HistoryClearedEvent{ConversationID: conversationID, ClearedMessageID: settings.ClearedMessageID})
+	return settings.ClearedMessageID, nil
 }
 
-// buildConversationInfo 构建会话信息（内部方法）
-func (s *service) buildConversationInfo(conv *models.Conversation, userID int64) (*models.ConversationWithInfo, error) {
-	// 获取对方用户ID
-	var otherUserID int64
-	if conv.UserAID == userID {
-		otherUserID = conv.UserBID
-	} else {
-		otherUserID = conv.UserAID
+// GetTotalUnreadCount 获取用户所有会话的未读消息总数（免打扰中的会话不计入）
+func (s *service) GetTotalUnreadCount(userID int64) (int, error) {
+	return s.dal.Message().CountTotalUnread(userID)
+}
+
+// EditMessage 编辑消息
+func (s *service) EditMessage(req *EditMessageRequest) (*models.Message, error) {
+	if req.Content == "" {
+		return nil, ErrInvalidMessageContent
 	}
 
-	// 获取对方用户信息
-	otherUser, err := s.dal.User().GetByID(otherUserID)
+	msg, err := s.getMessage(req.ConversationID, req.MessageID)
 	if err != nil {
-		return nil, fmt.Errorf("get participant: %w", err)
+		return nil, err
 	}
 
-	// 获取未读消息数
-	unreadCount, err := s.dal.Message().CountUnread(conv.ID, userID)
-	if err != nil {
-		return nil, fmt.Errorf("count unread: %w", err)
+	// 只有发送者本人（且仍在会话中）可以编辑
+	if msg.SenderID != req.UserID {
+		return nil, ErrAccessDenied
+	}
+	if _, err := s.checkParticipant(msg.ConversationID, req.UserID); err != nil {
+		return nil, err
 	}
 
-	// 获取最后一条消息
-	messages, err := s.dal.Message().GetByConversation(conv.ID, 0, 1)
-	if err != nil {
-		return nil, fmt.Errorf("get last message: %w", err)
+	// 语音、图片消息的内容是媒体ID，不允许编辑
+	if msg.Type != "text" {
+		return nil, ErrMessageNotEditable
 	}
 
-	var lastMessage *models.Message
-	if len(messages) > 0 {
-		lastMessage = messages[0]
+	if msg.Content == req.Content {
+		return msg, nil
 	}
 
-	return &models.ConversationWithInfo{
-		ID:           conv.ID,
-		UserAID:      conv.UserAID,
-		UserBID:      conv.UserBID,
-		Participant:   toUserInfo(otherUser),
-		LastMessage:   lastMessage,
-		UnreadCount:   unreadCount,
-		CreatedAt:     conv.CreatedAt,
-		UpdatedAt:     conv.UpdatedAt,
-	}, nil
-}
+	now := time.Now().Unix()
+	if err := s.dal.Message().Edit(msg.ID, req.Content, now); err != nil {
+		return nil, fmt.Errorf("edit message: %w", err)
+	}
+	msg.Content = req.Content
+	msg.EditedAt = &now
 
-// toUserInfo 转换用户信息为简要信息（内部方法）
-func toUserInfo(user *models.User) *models.UserInfo {
-	return &models.UserInfo{
-		ID:       user.ID,
-		Username: user.Username,
+	// 推送给全部参与者，操作者的其他设备也据此同步（通过事件中心）
+	recipients, err := s.GetParticipantIDs(msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range recipients {
+		s.publish(uid, EventMessageEdited, &MessageEditedEvent{
+			MessageID:      msg.ID,
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			Content:        msg.Content,
+			EditedAt:       now,
+		})
+	}
+
+	return msg, nil
+}
+
+// GetMessageRevisions 获取消息的修订记录
+func (s *service) GetMessageRevisions(conversationID int64, messageID int64, userID int64) ([]*models.MessageRevision, error) {
+	msg, err := s.getMessage(conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证用户是否是会话参与者
+	if _, err := s.checkParticipant(msg.ConversationID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.dal.Message().GetRevisions(messageID)
+}
+
+// RecallMessage 撤回消息（对所有人）
+func (s *service) RecallMessage(conversationID int64, messageID int64, userID int64) (*models.Message, error) {
+	msg, err := s.getMessage(conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只有发送者本人可以撤回，且须在撤回时限内
+	if msg.SenderID != userID {
+		return nil, ErrAccessDenied
+	}
+	if _, err := s.checkParticipant(msg.ConversationID, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.Sub(time.Unix(msg.CreatedAt, 0)) > s.recallWindow {
+		return nil, ErrRecallWindowExpired
+	}
+
+	recalledAt := now.Unix()
+	if err := s.dal.Message().Recall(msg.ID, recalledAt); err != nil {
+		if dal.IsNotFound(err) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, fmt.Errorf("recall message: %w", err)
+	}
+	msg.Content = ""
+	msg.RecalledAt = &recalledAt
+
+	// 撤回的消息不再保留在任何人的收藏中
+	if err := s.dal.Star().RemoveByMessage(msg.ID); err != nil {
+		return nil, err
+	}
+
+	// 推送给全部参与者，操作者的其他设备也据此同步（通过事件中心）
+	recipients, err := s.GetParticipantIDs(msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range recipients {
+		s.publish(uid, EventMessageRecalled, &MessageRecalledEvent{
+			MessageID:      msg.ID,
+			ConversationID: msg.ConversationID,
+			SenderID:       msg.SenderID,
+			RecalledAt:     recalledAt,
+		})
+	}
+
+	return msg, nil
+}
+
+// DeleteMessageForMe 仅为自己删除消息
+func (s *service) DeleteMessageForMe(conversationID int64, messageID int64, userID int64) (*models.Message, error) {
+	msg, err := s.getMessage(conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证用户是否是会话参与者
+	if _, err := s.checkParticipant(msg.ConversationID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.dal.Message().DeleteForUser(msg.ID, userID, time.Now().Unix()); err != nil {
+		return nil, fmt.Errorf("delete message: %w", err)
+	}
+	if err := s.dal.Star().Remove(userID, msg.ID); err != nil && !dal.IsNotFound(err) {
+		return nil, err
+	}
+
+	// 同步给自己的其他设备
+	s.publish(userID, EventMessageDeleted, &MessageDeletedEvent{MessageID: msg.ID, ConversationID: msg.ConversationID})
+
+	return msg, nil
+}
+
+// AddReaction 添加表情回应（重复添加不报错）
+func (s *service) AddReaction(conversationID int64, messageID int64, userID int64, emoji string) (*models.Message, error) {
+	return s.react(conversationID, messageID, userID, emoji, true)
+}
+
+// RemoveReaction 移除表情回应（不存在时不报错）
+func (s *service) RemoveReaction(conversationID int64, messageID int64, userID int64, emoji string) (*models.Message, error) {
+	return s.react(conversationID, messageID, userID, emoji, false)
+}
+
+// react 添加或移除表情回应，不更新会话时间也不计入未读（内部方法）
+func (s *service) react(conversationID int64, messageID int64, userID int64, emoji string, add bool) (*models.Message, error) {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || utf8.RuneCountInString(emoji) > MaxReactionLength || strings.ContainsAny(emoji, " \t\n") {
+		return nil, ErrInvalidReaction
+	}
+
+	msg, err := s.getMessage(conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证用户是否是会话参与者
+	if _, err := s.checkParticipant(msg.ConversationID, userID); err != nil {
+		return nil, err
+	}
+
+	changed := true
+	if add {
+		err = s.dal.Reaction().Add(&models.Reaction{
+			MessageID: msg.ID,
+			UserID:    userID,
+			Emoji:     emoji,
+			CreatedAt: time.Now().Unix(),
+		})
+		if dal.IsDuplicate(err) {
+			changed, err = false, nil
+		}
+	} else {
+		err = s.dal.Reaction().Remove(msg.ID, userID, emoji)
+		if dal.IsNotFound(err) {
+			changed, err = false, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update reaction: %w", err)
+	}
+
+	if err := s.attachReactions([]*models.Message{msg}, userID); err != nil {
+		return nil, err
+	}
+
+	// 推送给全部参与者，操作者的其他设备也据此同步（通过事件中心）
+	if changed {
+		action := "add"
+		if !add {
+			action = "remove"
+		}
+		recipients, err := s.GetParticipantIDs(msg.ConversationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range recipients {
+			s.publish(uid, EventReactionUpdated, &ReactionEvent{
+				MessageID:      msg.ID,
+				ConversationID: msg.ConversationID,
+				UserID:         userID,
+				Emoji:          emoji,
+				Action:         action,
+			})
+		}
+	}
+
+	return msg, nil
+}
+
+// PinMessage 置顶消息（已置顶时不报错）
+func (s *service) PinMessage(conversationID int64, messageID int64, userID int64) ([]*models.PinnedMessage, error) {
+	return s.pin(conversationID, messageID, userID, true)
+}
+
+// UnpinMessage 取消置顶（未置顶时不报错）
+func (s *service) UnpinMessage(conversationID int64, messageID int64, userID int64) ([]*models.PinnedMessage, error) {
+	return s.pin(conversationID, messageID, userID, false)
+}
+
+// GetPinnedMessages 获取会话的置顶消息
+func (s *service) GetPinnedMessages(conversationID int64, userID int64) ([]*models.PinnedMessage, error) {
+	if _, err := s.checkParticipant(conversationID, userID); err != nil {
+		return nil, err
+	}
+	return s.getPinnedMessages(conversationID, userID)
+}
+
+// pin 置顶或取消置顶，返回最新的置顶列表（内部方法）
+func (s *service) pin(conversationID int64, messageID int64, userID int64, add bool) ([]*models.PinnedMessage, error) {
+	if _, err := s.checkParticipant(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	changed := true
+	now := time.Now().Unix()
+	if add {
+		msg, err := s.getMessage(conversationID, messageID)
+		if err != nil {
+			return nil, err
+		}
+		err = s.addPin(&models.MessagePin{
+			ConversationID: conversationID,
+			MessageID:      msg.ID,
+			PinnedBy:       userID,
+			PinnedAt:       now,
+		})
+		if dal.IsDuplicate(err) {
+			changed, err = false, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := s.dal.Pin().Remove(conversationID, messageID)
+		if dal.IsNotFound(err) {
+			changed, err = false, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 推送给全部参与者，操作者的其他设备也据此同步（通过事件中心）
+	if changed {
+		action := "pin"
+		if !add {
+			action = "unpin"
+		}
+		recipients, err := s.GetParticipantIDs(conversationID)
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range recipients {
+			s.publish(uid, EventPinsUpdated, &PinEvent{
+				ConversationID: conversationID,
+				MessageID:      messageID,
+				UserID:         userID,
+				Action:         action,
+				UpdatedAt:      now,
+			})
+		}
+	}
+
+	return s.getPinnedMessages(conversationID, userID)
+}
+
+// addPin 在置顶数量上限内添加置顶（内部方法）
+// 计数与写入在服务锁内完成，避免并发置顶超过上限
+func (s *service) addPin(pin *models.MessagePin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count, err := s.dal.Pin().Count(pin.ConversationID)
+	if err != nil {
+		return err
+	}
+	if count >= MaxPinnedMessages {
+		return ErrPinLimitReached
+	}
+	return s.dal.Pin().Add(pin)
+}
+
+// getPinnedMessages 获取置顶消息及其完整内容（内部方法）
+// 置顶列表由会话共享，但只返回对当前用户可见的消息（不含已删除、已清空的消息）
+func (s *service) getPinnedMessages(conversationID int64, userID int64) ([]*models.PinnedMessage, error) {
+	pins, err := s.dal.Pin().GetByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get pins: %w", err)
+	}
+
+	result := make([]*models.PinnedMessage, 0, len(pins))
+	messages := make([]*models.Message, 0, len(pins))
+	for _, p := range pins {
+		msg, err := s.dal.Message().GetVisibleByID(p.MessageID, userID)
+		if err != nil {
+			if dal.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("get pinned message: %w", err)
+		}
+		messages = append(messages, msg)
+		result = append(result, &models.PinnedMessage{
+			Message:  msg,
+			PinnedBy: p.PinnedBy,
+			PinnedAt: p.PinnedAt,
+		})
+	}
+
+	s.attachReplyPreviews(messages)
+	s.attachForwardSenders(messages)
+	if err := s.attachReactions(messages, userID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveDraft 保存草稿（内容为空时清除），并同步到用户的其他连接
+func (s *service) SaveDraft(req *SaveDraftRequest) (*models.Draft, error) {
+	if len(req.Content) > MaxDraftLength {
+		return nil, ErrInvalidDraft
+	}
+	if _, err := s.checkParticipant(req.ConversationID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	draft := &models.Draft{
+		ConversationID: req.ConversationID,
+		UserID:         req.UserID,
+		UpdatedAt:      now,
+	}
+
+	if strings.TrimSpace(req.Content) == "" {
+		if err := s.dal.Draft().Delete(req.UserID, req.ConversationID); err != nil {
+			if dal.IsNotFound(err) {
+				return draft, nil
+			}
+			return nil, err
+		}
+		s.emitDraft(draft, req.Origin)
+		return draft, nil
+	}
+
+	if req.ReplyToID > 0 {
+		if _, err := s.getMessage(req.ConversationID, req.ReplyToID); err != nil {
+			return nil, ErrInvalidReply
+		}
+	}
+
+	draft.Content = req.Content
+	draft.ReplyToID = req.ReplyToID
+	if err := s.dal.Draft().Save(draft); err != nil {
+		return nil, err
+	}
+	s.emitDraft(draft, req.Origin)
+	return draft, nil
+}
+
+// clearDraft 清除草稿，存在时通知用户的其他连接（内部方法）
+func (s *service) clearDraft(userID int64, conversationID int64, now int64) error {
+	if err := s.dal.Draft().Delete(userID, conversationID); err != nil {
+		if dal.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("clear draft: %w", err)
+	}
+	s.emitDraft(&models.Draft{ConversationID: conversationID, UserID: userID, UpdatedAt: now}, "")
+	return nil
+}
+
+// emitDraft 将草稿变更发布给用户自己的连接（内部方法）
+func (s *service) emitDraft(draft *models.Draft, origin string) {
+	s.hub.Publish(draft.UserID, &hub.Event{Type: EventDraftUpdated, Data: draft, Origin: origin})
+}
+
+// StarMessage 收藏消息（仅自己可见，重复收藏不报错）
+func (s *service) StarMessage(conversationID int64, messageID int64, userID int64) error {
+	msg, err := s.getMessage(conversationID, messageID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.checkParticipant(msg.ConversationID, userID); err != nil {
+		return err
+	}
+
+	err = s.dal.Star().Add(&models.MessageStar{
+		UserID:         userID,
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		StarredAt:      time.Now().Unix(),
+	})
+	if err != nil && !dal.IsDuplicate(err) {
+		return fmt.Errorf("star message: %w", err)
+	}
+	return nil
+}
+
+// UnstarMessage 取消收藏（未收藏时不报错）
+func (s *service) UnstarMessage(conversationID int64, messageID int64, userID int64) error {
+	if _, err := s.checkParticipant(conversationID, userID); err != nil {
+		return err
+	}
+
+	if err := s.dal.Star().Remove(userID, messageID); err != nil && !dal.IsNotFound(err) {
+		return fmt.Errorf("unstar message: %w", err)
+	}
+	return nil
+}
+
+// GetStarredMessages 获取用户的收藏列表，返回收藏记录及是否还有更多
+func (s *service) GetStarredMessages(req *StarredMessagesRequest) ([]*models.StarredMessage, bool, error) {
+	if req.ConversationID > 0 {
+		if _, err := s.checkParticipant(req.ConversationID, req.UserID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// 默认值
+	limit := req.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	// 多取一条用于判断是否还有更多
+	stars, err := s.dal.Star().GetByUser(&models.StarredMessageQuery{
+		UserID:         req.UserID,
+		ConversationID: req.ConversationID,
+		Type:           req.Type,
+		BeforeID:       req.BeforeID,
+		Limit:          limit + 1,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(stars) > limit
+	if hasMore {
+		stars = stars[:limit]
+	}
+
+	messages := make([]*models.Message, len(stars))
+	for i, star := range stars {
+		star.Message.Starred = true
+		messages[i] = star.Message
+	}
+	s.attachReplyPreviews(messages)
+	s.attachForwardSenders(messages)
+	if err := s.attachReactions(messages, req.UserID); err != nil {
+		return nil, false, err
+	}
+	return stars, hasMore, nil
+}
+
+// SearchMessages 搜索消息
+// 指定会话时与 GetMessages 一样校验参与者身份；未指定时只在用户所在的会话中搜索
+func (s *service) SearchMessages(req *SearchMessagesRequest) ([]*models.MessageSearchHit, bool, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" || utf8.RuneCountInString(query) > MaxSearchQueryLength {
+		return nil, false, ErrInvalidSearchQuery
+	}
+	if req.From > 0 && req.To > 0 && req.From > req.To {
+		return nil, false, ErrInvalidSearchQuery
+	}
+
+	if req.ConversationID > 0 {
+		if _, err := s.checkParticipant(req.ConversationID, req.UserID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// 默认值
+	limit := req.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	// 多取一条用于判断是否还有更多
+	hits, err := s.dal.Search().SearchMessages(&models.MessageSearchQuery{
+		UserID:         req.UserID,
+		Query:          query,
+		ConversationID: req.ConversationID,
+		SenderID:       req.SenderID,
+		Type:           req.Type,
+		From:           req.From,
+		To:             req.To,
+		BeforeID:       req.BeforeID,
+		Limit:          limit + 1,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("search messages: %w", err)
+	}
+
+	hasMore := len(hits) > limit
+	if hasMore {
+		hits = hits[:limit]
+	}
+	return hits, hasMore, nil
+}
+
+// SetDisappearingTimer 设置会话的阅后即焚时长（0为关闭），并在会话中发布系统通知
+// 只影响之后发送的消息；时长未变化时返回nil通知
+func (s *service) SetDisappearingTimer(conversationID int64, userID int64, seconds int64) (*models.Message, error) {
+	if _, ok := DisappearTimers[seconds]; !ok && seconds != 0 {
+		return nil, ErrInvalidDisappearTimer
+	}
+
+	conv, err := s.checkParticipant(conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.DisappearAfter == seconds {
+		return nil, nil
+	}
+
+	now := time.Now().Unix()
+	if err := s.dal.Conversation().SetDisappearAfter(conv.ID, seconds, now); err != nil {
+		return nil, fmt.Errorf("set disappearing timer: %w", err)
+	}
+
+	actor, err := s.dal.User().GetByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	content := fmt.Sprintf("%s 关闭了阅后即焚", actor.Nickname)
+	if seconds > 0 {
+		content = fmt.Sprintf("%s 开启了阅后即焚，新消息将在 %s 后消失", actor.Nickname, DisappearTimers[seconds])
+	}
+	return s.postSystemNotice(conv, userID, content, now)
+}
+
+// postSystemNotice 在会话中发布系统通知并推送给全部参与者（通知本身不会过期）（内部方法）
+func (s *service) postSystemNotice(conv *models.Conversation, actorID int64, content string, now int64) (*models.Message, error) {
+	var receiverID int64
+	if !conv.IsGroup() {
+		receiverID = otherParticipant(conv, actorID)
+	}
+
+	msg := &models.Message{
+		ConversationID: conv.ID,
+		SenderID:       actorID,
+		ReceiverID:     receiverID,
+		Type:           models.MessageTypeSystem,
+		Content:        content,
+		Status:         models.MessageStatusSent,
+		CreatedAt:      now,
+		SyncedAt:       &now,
+	}
+	if err := s.dal.Message().Create(msg); err != nil {
+		return nil, fmt.Errorf("create system notice: %w", err)
+	}
+
+	recipients, err := s.GetParticipantIDs(conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, uid := range recipients {
+		s.publish(uid, EventChat, newChatEvent(msg))
+	}
+
+	return msg, nil
+}
+
+// GetParticipantIDs 获取会话全部参与者ID
+func (s *service) GetParticipantIDs(conversationID int64) ([]int64, error) {
+	conv, err := s.dal.Conversation().GetByID(conversationID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+
+	if !conv.IsGroup() {
+		return []int64{conv.UserAID, conv.UserBID}, nil
+	}
+
+	members, err := s.dal.ConversationMember().GetByConversation(conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("get members: %w", err)
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	return ids, nil
+}
+
+// getMessage 获取未撤回且未过期的消息，conversationID 非0时校验所属会话（内部方法）
+func (s *service) getMessage(conversationID int64, messageID int64) (*models.Message, error) {
+	msg, err := s.dal.Message().GetByID(messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if conversationID != 0 && msg.ConversationID != conversationID {
+		return nil, ErrMessageNotFound
+	}
+	if msg.RecalledAt != nil {
+		return nil, ErrMessageNotFound
+	}
+	if msg.ExpiresAt != nil && *msg.ExpiresAt <= time.Now().Unix() {
+		return nil, ErrMessageNotFound
+	}
+	return msg, nil
+}
+
+// checkParticipant 验证用户是否是会话参与者（内部方法）
+func (s *service) checkParticipant(conversationID int64, userID int64) (*models.Conversation, error) {
+	conv, err := s.dal.Conversation().GetByID(conversationID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+
+	if !conv.IsGroup() {
+		if conv.UserAID != userID && conv.UserBID != userID {
+			return nil, ErrAccessDenied
+		}
+		return conv, nil
+	}
+
+	if _, err := s.dal.ConversationMember().Get(conversationID, userID); err != nil {
+		if dal.IsNotFound(err) {
+			return nil, ErrAccessDenied
+		}
+		return nil, fmt.Errorf("get member: %w", err)
+	}
+	return conv, nil
+}
+
+// getOrCreateConversation 获取或创建会话（内部方法）
+func (s *service) getOrCreateConversation(from, to int64) (*models.Conversation, error) {
+	// 确保 userA < userB（用于排序）
+	userA, userB := from, to
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+
+	// 检查是否已存在
+	conv, err := s.dal.Conversation().GetByUsers(userA, userB)
+	if err == nil {
+		return conv, nil
+	}
+
+	// 创建新会话
+	now := time.Now().Unix()
+	conv = &models.Conversation{
+		UserAID:   userA,
+		UserBID:   userB,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.dal.Conversation().Create(conv); err != nil {
+		return nil, fmt.Errorf("create conversation: %w", err)
+	}
+
+	return conv, nil
+}
+
+// buildConversationInfo 构建会话信息（内部方法）
+func (s *service) buildConversationInfo(conv *models.Conversation, userID int64) (*models.ConversationWithInfo, error) {
+	convInfo := &models.ConversationWithInfo{
+		ID:             conv.ID,
+		Type:           conv.Type,
+		UserAID:        conv.UserAID,
+		UserBID:        conv.UserBID,
+		Title:          conv.Title,
+		DisappearAfter: conv.DisappearAfter,
+		Avatar:         avatarToString(conv.AvatarID),
+		CreatedAt:      conv.CreatedAt,
+		UpdatedAt:      conv.UpdatedAt,
+	}
+
+	if conv.IsGroup() {
+		// 获取群成员信息
+		members, err := s.dal.ConversationMember().GetByConversation(conv.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get members: %w", err)
+		}
+		for _, m := range members {
+			u, err := s.dal.User().GetByID(m.UserID)
+			if err != nil {
+				return nil, fmt.Errorf("get member: %w", err)
+			}
+			convInfo.Members = append(convInfo.Members, toMemberInfo(u, m))
+		}
+		convInfo.MemberCount = len(members)
+	} else {
+		// 获取对方用户信息
+		otherUser, err := s.dal.User().GetByID(otherParticipant(conv, userID))
+		if err != nil {
+			return nil, fmt.Errorf("get participant: %w", err)
+		}
+		convInfo.Participant = toUserInfo(otherUser)
+		convInfo.MemberCount = 2
+
+		// 对方的已读位置
+		peer, err := s.dal.ConversationMember().Get(conv.ID, otherUser.ID)
+		if err != nil && !dal.IsNotFound(err) {
+			return nil, fmt.Errorf("get participant read cursor: %w", err)
+		}
+		if peer != nil {
+			convInfo.PeerLastReadMessageID = peer.LastReadMessageID
+		}
+	}
+
+	// 获取未读消息数
+	unreadCount, err := s.dal.Message().CountUnread(conv.ID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("count unread: %w", err)
+	}
+	convInfo.UnreadCount = unreadCount
+
+	// 获取最后一条可见消息
+	messages, err := s.dal.Message().GetVisibleByConversation(conv.ID, userID, 0, 1)
+	if err != nil {
+		return nil, fmt.Errorf("get last message: %w", err)
+	}
+	if len(messages) > 0 {
+		s.attachReplyPreviews(messages)
+		convInfo.LastMessage = messages[0]
+	}
+
+	// 获取当前用户的草稿
+	draft, err := s.dal.Draft().Get(userID, conv.ID)
+	if err != nil && !dal.IsNotFound(err) {
+		return nil, fmt.Errorf("get draft: %w", err)
+	}
+	convInfo.Draft = draft
+
+	// 置顶消息随会话返回，客户端无需额外请求即可展示
+	pins, err := s.getPinnedMessages(conv.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	convInfo.Pins = pins
+
+	settings, err := s.dal.ConversationMember().GetSettings(conv.ID, userID)
+	if err != nil && !dal.IsNotFound(err) {
+		return nil, fmt.Errorf("get conversation settings: %w", err)
+	}
+	convInfo.Settings = settings
+
+	return convInfo, nil
+}
+
+// attachReplyPreviews 为引用了其他消息的消息填充引用预览（内部方法）
+func (s *service) attachReplyPreviews(messages []*models.Message) {
+	previews := make(map[int64]*models.ReplyPreview)
+	for _, msg := range messages {
+		if msg.ReplyToID == 0 {
+			continue
+		}
+		preview, ok := previews[msg.ReplyToID]
+		if !ok {
+			if quoted, err := s.dal.Message().GetByID(msg.ReplyToID); err == nil {
+				preview = s.buildReplyPreview(quoted)
+			}
+			previews[msg.ReplyToID] = preview
+		}
+		msg.ReplyTo = preview
+	}
+}
+
+// attachForwardSenders 为转发的消息填充原发送者昵称（内部方法）
+func (s *service) attachForwardSenders(messages []*models.Message) {
+	nicknames := make(map[int64]string)
+	for _, msg := range messages {
+		if msg.ForwardedFrom == nil {
+			continue
+		}
+		nickname, ok := nicknames[msg.ForwardedFrom.SenderID]
+		if !ok {
+			if sender, err := s.dal.User().GetByID(msg.ForwardedFrom.SenderID); err == nil {
+				nickname = sender.Nickname
+			}
+			nicknames[msg.ForwardedFrom.SenderID] = nickname
+		}
+		msg.ForwardedFrom.SenderNickname = nickname
+	}
+}
+
+// attachReactions 为消息填充表情回应汇总（内部方法）
+func (s *service) attachReactions(messages []*models.Message, userID int64) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	reactions, err := s.dal.Reaction().GetByMessages(ids)
+	if err != nil {
+		return fmt.Errorf("get reactions: %w", err)
+	}
+
+	// 按消息聚合，表情按首次出现的顺序排列
+	summaries := make(map[int64][]*models.ReactionSummary)
+	for _, r := range reactions {
+		var summary *models.ReactionSummary
+		for _, sum := range summaries[r.MessageID] {
+			if sum.Emoji == r.Emoji {
+				summary = sum
+				break
+			}
+		}
+		if summary == nil {
+			summary = &models.ReactionSummary{Emoji: r.Emoji}
+			summaries[r.MessageID] = append(summaries[r.MessageID], summary)
+		}
+		summary.Count++
+		if r.UserID == userID {
+			summary.ReactedByMe = true
+		}
+	}
+
+	for _, msg := range messages {
+		msg.Reactions = summaries[msg.ID]
+	}
+	return nil
+}
+
+// attachStars 标记当前用户已收藏的消息（内部方法）
+func (s *service) attachStars(messages []*models.Message, userID int64) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+	starred, err := s.dal.Star().GetStarredIDs(userID, ids)
+	if err != nil {
+		return fmt.Errorf("get stars: %w", err)
+	}
+
+	for _, msg := range messages {
+		msg.Starred = starred[msg.ID]
+	}
+	return nil
+}
+
+// buildReplyPreview 构建引用预览，撤回的消息不再暴露内容（内部方法）
+func (s *service) buildReplyPreview(quoted *models.Message) *models.ReplyPreview {
+	preview := &models.ReplyPreview{
+		MessageID: quoted.ID,
+		SenderID:  quoted.SenderID,
+		Type:      quoted.Type,
+		Content:   truncateRunes(quoted.Content, ReplyPreviewLength),
+		Recalled:  quoted.RecalledAt != nil,
+	}
+	if sender, err := s.dal.User().GetByID(quoted.SenderID); err == nil {
+		preview.SenderNickname = sender.Nickname
+	}
+	return preview
+}
+
+// truncateRunes 按字符截断字符串（内部方法）
+func truncateRunes(str string, n int) string {
+	runes := []rune(str)
+	if len(runes) <= n {
+		return str
+	}
+	return string(runes[:n]) + "…"
+}
+
+// otherParticipant 获取单聊中对方的用户ID（内部方法）
+func otherParticipant(conv *models.Conversation, userID int64) int64 {
+	if conv.UserAID == userID {
+		return conv.UserBID
+	}
+	return conv.UserAID
+}
+
+// toUserInfo 转换用户信息为简要信息（内部方法）
+func toUserInfo(user *models.User) *models.UserInfo {
+	return &models.UserInfo{
+		ID:       user.ID,
+		Username: user.Username,
 		Nickname: user.Nickname,
 		Avatar:   avatarToString(user.AvatarID),
 		LastSeen: user.LastSeen,
 	}
 }
 
+// toMemberInfo 转换群成员信息（内部方法）
+func toMemberInfo(user *models.User, member *models.ConversationMember) *models.MemberInfo {
+	return &models.MemberInfo{
+		ID:                user.ID,
+		Username:          user.Username,
+		Nickname:          user.Nickname,
+		Avatar:            avatarToString(user.AvatarID),
+		Role:              member.Role,
+		JoinedAt:          member.JoinedAt,
+		LastReadMessageID: member.LastReadMessageID,
+	}
+}
+
 // avatarToString 转换头像ID为字符串（内部方法）
 func avatarToString(avatarID *int64) string {
 	if avatarID == nil {
@@ -389,13 +1620,3 @@ func avatarToString(avatarID *int64) string {
 	}
 	return fmt.Sprintf("%d", *avatarID)
 }
-
-// isValidMessageType 验证消息类型（内部方法）
-func isValidMessageType(msgType string) bool {
-	switch msgType {
-	case "text", "voice", "image":
-		return true
-	default:
-		return false
-	}
-}
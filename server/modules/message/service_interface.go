@@ -6,16 +6,85 @@ import (
 
 // SendMessageRequest 发送消息请求
 type SendMessageRequest struct {
-	From    int64  `json:"from"`
-	To      int64  `json:"to"`
-	Type    string `json:"type"`    // text, voice, image
-	Content string `json:"content"` // 文本内容或媒体ID
+	From           int64  `json:"from"`
+	To             int64  `json:"to"`              // 单聊接收者（指定 ConversationID 时可省略）
+	ConversationID int64  `json:"conversation_id"` // 目标会话（群聊必填）
+	Type           string `json:"type"`            // text, voice, image
+	Content        string `json:"content"`         // 文本内容或媒体ID
+	ReplyToID      int64  `json:"reply_to_id"`     // 引用的消息ID（可选，须属于同一会话）
+	ClientMsgID    string `json:"client_msg_id"`   // 客户端消息ID（可选，同一发送者重复提交时返回已存储的消息）
+	KeepDraft      bool   `json:"-"`               // 不清除发送者的草稿（定时消息等非交互发送）
+	Origin         string `json:"-"`               // 发起发送的连接ID（同步到发送者其他设备时跳过该连接）
+
+	ForwardedFrom *models.ForwardInfo `json:"-"` // 转发来源（由 ForwardMessages 设置）
+}
+
+// ForwardMessagesRequest 转发消息请求
+type ForwardMessagesRequest struct {
+	UserID               int64   `json:"user_id"`                // 转发者（须同时是来源会话和目标会话的参与者）
+	SourceConversationID int64   `json:"source_conversation_id"` // 来源会话
+	MessageIDs           []int64 `json:"message_ids"`            // 按给定顺序转发
+	TargetConversationID int64   `json:"target_conversation_id"` // 目标会话
+}
+
+// EditMessageRequest 编辑消息请求
+type EditMessageRequest struct {
+	UserID         int64  `json:"user_id"`         // 编辑者（必须是发送者）
+	ConversationID int64  `json:"conversation_id"` // 所属会话（为0时不校验）
+	MessageID      int64  `json:"message_id"`
+	Content        string `json:"content"`
+}
+
+// SearchMessagesRequest 搜索消息请求
+type SearchMessagesRequest struct {
+	UserID         int64  `json:"user_id"`         // 搜索者（只返回其所在会话的消息）
+	Query          string `json:"query"`           // 关键词
+	ConversationID int64  `json:"conversation_id"` // 限定会话（可选）
+	SenderID       int64  `json:"sender_id"`       // 限定发送者（可选）
+	Type           string `json:"type"`            // 限定消息类型（可选）
+	From           int64  `json:"from"`            // 起始时间（Unix秒，可选）
+	To             int64  `json:"to"`              // 截止时间（Unix秒，可选）
+	BeforeID       int64  `json:"before_id"`       // 游标：上一页最后一条结果的消息ID
+	Limit          int    `json:"limit"`
+}
+
+// SaveDraftRequest 保存草稿请求
+type SaveDraftRequest struct {
+	UserID         int64  `json:"user_id"`
+	ConversationID int64  `json:"conversation_id"`
+	Content        string `json:"content"`     // 为空时清除草稿
+	ReplyToID      int64  `json:"reply_to_id"` // 草稿引用的消息（可选）
+	Origin         string `json:"-"`           // 发起变更的连接ID（事件不回推给该连接）
 }
 
-// ConversationListRequest 获取会话列表请求
+// StarredMessagesRequest 获取收藏列表请求
+type StarredMessagesRequest struct {
+	UserID         int64  `json:"user_id"`
+	ConversationID int64  `json:"conversation_id"` // 限定会话（可选）
+	Type           string `json:"type"`            // 限定消息类型（可选）
+	BeforeID       int64  `json:"before_id"`       // 游标：上一页最后一条收藏的ID
+	Limit          int    `json:"limit"`
+}
+
+// ConversationListRequest 获取会话列表请求（过滤条件为nil时不限制）
 type ConversationListRequest struct {
-	Page  int `json:"page"`
-	Limit int `json:"limit"`
+	UserID   int64 `json:"user_id"`
+	Archived *bool `json:"archived"`
+	Hidden   *bool `json:"hidden"`
+	Muted    *bool `json:"muted"` // 当前是否处于免打扰期间
+	Pinned   *bool `json:"pinned"`
+	Page     int   `json:"page"`
+	Limit    int   `json:"limit"`
+}
+
+// UpdateConversationSettingsRequest 更新会话个人设置请求（字段为nil时保持不变）
+type UpdateConversationSettingsRequest struct {
+	UserID         int64  `json:"user_id"`
+	ConversationID int64  `json:"conversation_id"`
+	Archived       *bool  `json:"archived"`
+	MutedUntil     *int64 `json:"muted_until"` // 免打扰截止时间（Unix秒），0为取消免打扰
+	Hidden         *bool  `json:"hidden"`
+	Pinned         *bool  `json:"pinned"`
 }
 
 // MessageListRequest 获取消息历史请求
@@ -27,30 +96,96 @@ type MessageListRequest struct {
 
 // Service 消息服务接口
 type Service interface {
-	// SendMessage 发送消息
+	// SendMessage 发送消息（携带已存在的 ClientMsgID 时直接返回原消息，不重复写入）
 	SendMessage(req *SendMessageRequest) (*models.Message, error)
 
+	// ForwardMessages 将来源会话中的消息复制到目标会话（媒体消息复用原媒体文件），返回新消息
+	ForwardMessages(req *ForwardMessagesRequest) ([]*models.Message, error)
+
 	// GetConversation 获取会话详情
 	GetConversation(id int64, userID int64) (*models.ConversationWithInfo, error)
 
 	// GetConversationWithUser 获取与指定用户的会话（如不存在则创建）
 	GetConversationWithUser(userID, otherUserID int64) (*models.ConversationWithInfo, error)
 
-	// GetConversations 获取会话列表
+	// GetConversations 获取会话列表（不含已归档和已隐藏的会话）
 	GetConversations(userID int64, page, limit int) ([]*models.ConversationWithInfo, int, error)
 
+	// ListConversations 按个人设置过滤会话列表（置顶的会话在前）
+	ListConversations(req *ConversationListRequest) ([]*models.ConversationWithInfo, int, error)
+
+	// UpdateConversationSettings 更新用户对会话的个人设置（归档、免打扰、隐藏、置顶），变更同步到用户的其他连接
+	UpdateConversationSettings(req *UpdateConversationSettingsRequest) (*models.ConversationSettings, error)
+
 	// GetMessages 获取消息历史
 	GetMessages(conversationID int64, userID int64, beforeID int64, limit int) ([]*models.Message, bool, error)
 
-	// MarkAsRead 标记会话消息为已读
-	MarkAsRead(conversationID int64, userID int64) error
+	// MarkAsRead 标记会话消息为已读，返回推送给发送者的回执（无变化时为nil）
+	MarkAsRead(conversationID int64, userID int64) (*models.MessageReceipt, error)
 
-	// UpdateMessageStatus 更新消息状态
-	UpdateMessageStatus(messageID int64, status string) error
+	// UpdateMessageStatus 接收者回执单条消息（仅接收者可推进 sent → delivered → read，不会回退）
+	UpdateMessageStatus(messageID int64, userID int64, status string) (*models.MessageReceipt, error)
+
+	// MarkDelivered 消息推送到接收者后自动标记为已投递，返回按会话汇总的回执
+	MarkDelivered(userID int64, messageIDs []int64) ([]*models.MessageReceipt, error)
 
 	// GetOfflineMessages 获取离线消息
 	GetOfflineMessages(userID int64, lastMessageID int64, limit int) ([]*models.Message, error)
 
+	// ClearHistory 为自己清空会话的聊天记录（upToMessageID 为0时清空到最新消息），不影响其他参与者和分享链接，返回清空水位
+	ClearHistory(conversationID int64, userID int64, upToMessageID int64) (int64, error)
+
 	// GetUnreadCount 获取未读消息数
 	GetUnreadCount(conversationID int64, userID int64) (int, error)
+
+	// GetTotalUnreadCount 获取用户所有会话的未读消息总数（不含免打扰中的会话）
+	GetTotalUnreadCount(userID int64) (int, error)
+
+	// EditMessage 编辑消息（仅发送者可编辑文本消息）
+	EditMessage(req *EditMessageRequest) (*models.Message, error)
+
+	// GetMessageRevisions 获取消息的修订记录
+	GetMessageRevisions(conversationID int64, messageID int64, userID int64) ([]*models.MessageRevision, error)
+
+	// RecallMessage 撤回消息（对所有人，仅发送者可在时限内撤回）
+	RecallMessage(conversationID int64, messageID int64, userID int64) (*models.Message, error)
+
+	// DeleteMessageForMe 仅为自己删除消息
+	DeleteMessageForMe(conversationID int64, messageID int64, userID int64) (*models.Message, error)
+
+	// AddReaction 添加表情回应
+	AddReaction(conversationID int64, messageID int64, userID int64, emoji string) (*models.Message, error)
+
+	// RemoveReaction 移除表情回应
+	RemoveReaction(conversationID int64, messageID int64, userID int64, emoji string) (*models.Message, error)
+
+	// PinMessage 置顶消息（每个会话最多 MaxPinnedMessages 条），返回最新的置顶列表
+	PinMessage(conversationID int64, messageID int64, userID int64) ([]*models.PinnedMessage, error)
+
+	// UnpinMessage 取消置顶，返回最新的置顶列表
+	UnpinMessage(conversationID int64, messageID int64, userID int64) ([]*models.PinnedMessage, error)
+
+	// GetPinnedMessages 获取会话的置顶消息（最近置顶的在前）
+	GetPinnedMessages(conversationID int64, userID int64) ([]*models.PinnedMessage, error)
+
+	// SaveDraft 保存草稿（内容为空时清除），变更同步到用户的其他 SSE/WS 连接
+	SaveDraft(req *SaveDraftRequest) (*models.Draft, error)
+
+	// StarMessage 收藏消息（仅自己可见）
+	StarMessage(conversationID int64, messageID int64, userID int64) error
+
+	// UnstarMessage 取消收藏
+	UnstarMessage(conversationID int64, messageID int64, userID int64) error
+
+	// GetStarredMessages 获取用户在所有会话中的收藏（最近收藏的在前），返回是否还有更多
+	GetStarredMessages(req *StarredMessagesRequest) ([]*models.StarredMessage, bool, error)
+
+	// SearchMessages 搜索消息，返回命中结果及是否还有更多
+	SearchMessages(req *SearchMessagesRequest) ([]*models.MessageSearchHit, bool, error)
+
+	// SetDisappearingTimer 设置会话的阅后即焚时长（秒，0为关闭），返回发布到会话中的系统通知（未变化时为nil）
+	SetDisappearingTimer(conversationID int64, userID int64, seconds int64) (*models.Message, error)
+
+	// GetParticipantIDs 获取会话全部参与者ID（单聊为双方，群聊为全部成员）
+	GetParticipantIDs(conversationID int64) ([]int64, error)
 }
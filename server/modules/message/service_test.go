@@ -1,10 +1,13 @@
 package message
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"zmessage/server/dal"
+	"zmessage/server/hub"
 	"zmessage/server/models"
 )
 
@@ -55,15 +58,23 @@ func TestService_SendMessage(t *testing.T) {
 		t.Fatalf("create dal manager: %v", err)
 	}
 
-	svc := NewService(mgr)
+	h := hub.New()
+	svc := NewService(mgr, WithHub(h))
 	user1, user2 := setupTestUsers(t, mgr)
 
+	published := make(map[int64]*hub.Event)
+	h.Subscribe(hub.AllUsers, []string{EventChat}, func(userID int64, e *hub.Event) bool {
+		published[userID] = e
+		return false
+	})
+
 	// 测试发送文本消息
 	req := &SendMessageRequest{
 		From:    user1.ID,
 		To:      user2.ID,
 		Type:    "text",
 		Content: "Hello Bob!",
+		Origin:  "conn-1",
 	}
 
 	msg, err := svc.SendMessage(req)
@@ -99,6 +110,14 @@ func TestService_SendMessage(t *testing.T) {
 		t.Error("conversation ID not set")
 	}
 
+	// 推送给接收者，并同步到发送者的其他设备（跳过发起发送的连接）
+	if e := published[user2.ID]; e == nil || e.Origin != "" {
+		t.Errorf("expected chat event for receiver, got %+v", e)
+	}
+	if e := published[user1.ID]; e == nil || e.Origin != "conn-1" || e.Data.(*ChatEvent).MessageID != msg.ID {
+		t.Errorf("expected chat event for sender's other devices, got %+v", e)
+	}
+
 	// 测试不能给自己发消息
 	req.To = user1.ID
 	_, err = svc.SendMessage(req)
@@ -312,9 +331,18 @@ func TestService_MarkAsRead(t *testing.T) {
 	}
 
 	// 标记为已读
-	if err := svc.MarkAsRead(conv.ID, user2.ID); err != nil {
+	receipt, err := svc.MarkAsRead(conv.ID, user2.ID)
+	if err != nil {
 		t.Fatalf("mark as read failed: %v", err)
 	}
+	if receipt == nil || receipt.SenderID != user1.ID || receipt.Status != "read" || len(receipt.MessageIDs) != 1 {
+		t.Errorf("unexpected receipt: %+v", receipt)
+	}
+
+	// 再次标记没有新的回执
+	if receipt, _ := svc.MarkAsRead(conv.ID, user2.ID); receipt != nil {
+		t.Errorf("expected no receipt when nothing changed, got %+v", receipt)
+	}
 
 	// 检查未读数
 	unread, _ = svc.GetUnreadCount(conv.ID, user2.ID)
@@ -382,10 +410,22 @@ func TestService_UpdateMessageStatus(t *testing.T) {
 	}
 	msg, _ := svc.SendMessage(req)
 
+	// 发送者不能推进自己消息的状态
+	if _, err := svc.UpdateMessageStatus(msg.ID, user1.ID, "delivered"); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+	if _, err := svc.UpdateMessageStatus(msg.ID, user2.ID, "sent"); err != ErrInvalidStatus {
+		t.Errorf("expected ErrInvalidStatus, got: %v", err)
+	}
+
 	// 更新为已投递
-	if err := svc.UpdateMessageStatus(msg.ID, "delivered"); err != nil {
+	receipt, err := svc.UpdateMessageStatus(msg.ID, user2.ID, "delivered")
+	if err != nil {
 		t.Fatalf("update status to delivered failed: %v", err)
 	}
+	if receipt == nil || receipt.SenderID != user1.ID || receipt.UserID != user2.ID || receipt.MessageIDs[0] != msg.ID {
+		t.Errorf("unexpected receipt: %+v", receipt)
+	}
 
 	// 验证状态
 	updatedMsg, err := mgr.Message().GetByID(msg.ID)
@@ -398,9 +438,29 @@ func TestService_UpdateMessageStatus(t *testing.T) {
 	}
 
 	// 更新为已读
-	if err := svc.UpdateMessageStatus(msg.ID, "read"); err != nil {
+	if _, err := svc.UpdateMessageStatus(msg.ID, user2.ID, "read"); err != nil {
 		t.Fatalf("update status to read failed: %v", err)
 	}
+
+	// 状态不能回退
+	receipt, err = svc.UpdateMessageStatus(msg.ID, user2.ID, "delivered")
+	if err != nil || receipt != nil {
+		t.Errorf("expected no-op when moving backwards, got %+v (%v)", receipt, err)
+	}
+	updatedMsg, _ = mgr.Message().GetByID(msg.ID)
+	if updatedMsg.Status != "read" {
+		t.Errorf("expected status to stay 'read', got '%s'", updatedMsg.Status)
+	}
+
+	// 批量投递只处理发给自己的消息
+	reply, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "text", Content: "Hey"})
+	receipts, err := svc.MarkDelivered(user1.ID, []int64{msg.ID, reply.ID})
+	if err != nil {
+		t.Fatalf("mark delivered failed: %v", err)
+	}
+	if len(receipts) != 1 || receipts[0].SenderID != user2.ID || len(receipts[0].MessageIDs) != 1 || receipts[0].MessageIDs[0] != reply.ID {
+		t.Errorf("unexpected receipts: %+v", receipts)
+	}
 }
 
 func TestService_UnreadCount(t *testing.T) {
@@ -443,3 +503,1313 @@ func TestService_UnreadCount(t *testing.T) {
 		t.Errorf("expected sender unread count 0, got %d", count)
 	}
 }
+
+func TestService_GroupMessage(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	user3 := &models.User{
+		Username:     "carol",
+		PasswordHash: "hash3",
+		Nickname:     "Carol",
+		CreatedAt:    time.Now().Unix(),
+		LastSeen:     time.Now().Unix(),
+	}
+	if err := mgr.User().Create(user3); err != nil {
+		t.Fatalf("create user3: %v", err)
+	}
+
+	group := &models.Conversation{
+		Title:     "Friends",
+		CreatedBy: user1.ID,
+		CreatedAt: time.Now().Unix(),
+		UpdatedAt: time.Now().Unix(),
+	}
+	if err := mgr.Conversation().CreateGroup(group, []int64{user2.ID}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	// 群成员发送消息
+	msg, err := svc.SendMessage(&SendMessageRequest{
+		From:           user1.ID,
+		ConversationID: group.ID,
+		Type:           "text",
+		Content:        "Hello group!",
+	})
+	if err != nil {
+		t.Fatalf("send group message failed: %v", err)
+	}
+	if msg.ConversationID != group.ID || msg.ReceiverID != 0 {
+		t.Errorf("unexpected group message: %+v", msg)
+	}
+
+	// 非成员不能发送
+	_, err = svc.SendMessage(&SendMessageRequest{
+		From:           user3.ID,
+		ConversationID: group.ID,
+		Type:           "text",
+		Content:        "Let me in",
+	})
+	if err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	// 每个成员独立计算未读数
+	count, err := svc.GetUnreadCount(group.ID, user2.ID)
+	if err != nil {
+		t.Fatalf("get unread count failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected unread count 1, got %d", count)
+	}
+	count, _ = svc.GetUnreadCount(group.ID, user1.ID)
+	if count != 0 {
+		t.Errorf("expected sender unread count 0, got %d", count)
+	}
+
+	// 群消息进入成员的离线消息
+	offline, err := svc.GetOfflineMessages(user2.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("get offline messages failed: %v", err)
+	}
+	if len(offline) != 1 {
+		t.Errorf("expected 1 offline message, got %d", len(offline))
+	}
+
+	// 标记已读后未读数归零
+	if _, err := svc.MarkAsRead(group.ID, user2.ID); err != nil {
+		t.Fatalf("mark as read failed: %v", err)
+	}
+	count, _ = svc.GetUnreadCount(group.ID, user2.ID)
+	if count != 0 {
+		t.Errorf("expected unread count 0 after read, got %d", count)
+	}
+
+	// 群会话信息包含成员
+	info, err := svc.GetConversation(group.ID, user2.ID)
+	if err != nil {
+		t.Fatalf("get group conversation failed: %v", err)
+	}
+	if info.MemberCount != 2 || info.Participant != nil {
+		t.Errorf("unexpected group info: %+v", info)
+	}
+
+	ids, err := svc.GetParticipantIDs(group.ID)
+	if err != nil {
+		t.Fatalf("get participant ids failed: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("expected 2 participants, got %d", len(ids))
+	}
+
+	// 新成员看不到加入前的消息
+	if err := mgr.ConversationMember().Add(&models.ConversationMember{
+		ConversationID: group.ID,
+		UserID:         user3.ID,
+		Role:           models.MemberRoleMember,
+		JoinedAt:       time.Now().Unix(),
+	}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	after, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, ConversationID: group.ID, Type: "text", Content: "Welcome!"})
+	offline, _ = svc.GetOfflineMessages(user3.ID, 0, 10)
+	if len(offline) != 1 || offline[0].ID != after.ID {
+		t.Errorf("expected only messages after joining in offline messages, got %d", len(offline))
+	}
+	history, _, _ := svc.GetMessages(group.ID, user3.ID, 0, 10)
+	if len(history) != 1 || history[0].ID != after.ID {
+		t.Errorf("expected only messages after joining in history, got %d", len(history))
+	}
+	if count, _ = svc.GetUnreadCount(group.ID, user3.ID); count != 1 {
+		t.Errorf("expected new member unread count 1, got %d", count)
+	}
+}
+
+func TestService_EditMessage(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	msg, err := svc.SendMessage(&SendMessageRequest{
+		From:    user1.ID,
+		To:      user2.ID,
+		Type:    "text",
+		Content: "Helo",
+	})
+	if err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+
+	// 发送者编辑消息
+	edited, err := svc.EditMessage(&EditMessageRequest{
+		UserID:         user1.ID,
+		ConversationID: msg.ConversationID,
+		MessageID:      msg.ID,
+		Content:        "Hello",
+	})
+	if err != nil {
+		t.Fatalf("edit message failed: %v", err)
+	}
+	if edited.Content != "Hello" || edited.EditedAt == nil {
+		t.Errorf("unexpected edited message: %+v", edited)
+	}
+
+	// 接收者不能编辑
+	_, err = svc.EditMessage(&EditMessageRequest{
+		UserID:    user2.ID,
+		MessageID: msg.ID,
+		Content:   "Hacked",
+	})
+	if err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	// 会话不匹配
+	_, err = svc.EditMessage(&EditMessageRequest{
+		UserID:         user1.ID,
+		ConversationID: msg.ConversationID + 1,
+		MessageID:      msg.ID,
+		Content:        "Hello!",
+	})
+	if err != ErrMessageNotFound {
+		t.Errorf("expected ErrMessageNotFound, got: %v", err)
+	}
+
+	// 历史记录中的内容已更新
+	messages, _, err := svc.GetMessages(msg.ConversationID, user2.ID, 0, 10)
+	if err != nil {
+		t.Fatalf("get messages failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Content != "Hello" || messages[0].EditedAt == nil {
+		t.Errorf("edit not visible in history: %+v", messages)
+	}
+
+	// 修订记录保留原内容
+	revisions, err := svc.GetMessageRevisions(msg.ConversationID, msg.ID, user2.ID)
+	if err != nil {
+		t.Fatalf("get revisions failed: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Content != "Helo" {
+		t.Errorf("unexpected revisions: %+v", revisions)
+	}
+
+	// 非文本消息不可编辑
+	voice, _ := svc.SendMessage(&SendMessageRequest{
+		From:    user1.ID,
+		To:      user2.ID,
+		Type:    "voice",
+		Content: "1",
+	})
+	_, err = svc.EditMessage(&EditMessageRequest{
+		UserID:    user1.ID,
+		MessageID: voice.ID,
+		Content:   "2",
+	})
+	if err != ErrMessageNotEditable {
+		t.Errorf("expected ErrMessageNotEditable, got: %v", err)
+	}
+}
+
+func TestService_RecallMessage(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr, WithRecallWindow(time.Minute))
+	user1, user2 := setupTestUsers(t, mgr)
+
+	first, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "first"})
+	msg, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "oops"})
+	if err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+
+	// 接收者不能撤回
+	if _, err := svc.RecallMessage(msg.ConversationID, msg.ID, user2.ID); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	recalled, err := svc.RecallMessage(msg.ConversationID, msg.ID, user1.ID)
+	if err != nil {
+		t.Fatalf("recall message failed: %v", err)
+	}
+	if recalled.RecalledAt == nil || recalled.Content != "" {
+		t.Errorf("expected tombstone, got %+v", recalled)
+	}
+
+	// 撤回后不出现在历史、离线消息和最后一条消息中
+	messages, _, _ := svc.GetMessages(msg.ConversationID, user2.ID, 0, 10)
+	if len(messages) != 1 || messages[0].ID != first.ID {
+		t.Errorf("expected only first message in history, got %+v", messages)
+	}
+	offline, _ := svc.GetOfflineMessages(user2.ID, 0, 10)
+	if len(offline) != 1 {
+		t.Errorf("expected 1 offline message, got %d", len(offline))
+	}
+	conv, _ := svc.GetConversation(msg.ConversationID, user2.ID)
+	if conv.LastMessage == nil || conv.LastMessage.ID != first.ID {
+		t.Errorf("expected last message to be first, got %+v", conv.LastMessage)
+	}
+	if conv.UnreadCount != 1 {
+		t.Errorf("expected unread count 1, got %d", conv.UnreadCount)
+	}
+
+	// 已撤回的消息不能再次撤回或编辑
+	if _, err := svc.RecallMessage(msg.ConversationID, msg.ID, user1.ID); err != ErrMessageNotFound {
+		t.Errorf("expected ErrMessageNotFound, got: %v", err)
+	}
+
+	// 超过撤回时限
+	old := &models.Message{
+		ConversationID: msg.ConversationID,
+		SenderID:       user1.ID,
+		ReceiverID:     user2.ID,
+		Type:           "text",
+		Content:        "old",
+		Status:         "sent",
+		CreatedAt:      time.Now().Add(-2 * time.Minute).Unix(),
+	}
+	if err := mgr.Message().Create(old); err != nil {
+		t.Fatalf("create old message: %v", err)
+	}
+	if _, err := svc.RecallMessage(old.ConversationID, old.ID, user1.ID); err != ErrRecallWindowExpired {
+		t.Errorf("expected ErrRecallWindowExpired, got: %v", err)
+	}
+}
+
+func TestService_DeleteMessageForMe(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	msg, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "Hi"})
+	if err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+
+	if _, err := svc.DeleteMessageForMe(msg.ConversationID, msg.ID, user2.ID); err != nil {
+		t.Fatalf("delete message failed: %v", err)
+	}
+
+	// 删除者看不到，另一方仍可见
+	messages, _, _ := svc.GetMessages(msg.ConversationID, user2.ID, 0, 10)
+	if len(messages) != 0 {
+		t.Errorf("expected message hidden for user2, got %d", len(messages))
+	}
+	messages, _, _ = svc.GetMessages(msg.ConversationID, user1.ID, 0, 10)
+	if len(messages) != 1 {
+		t.Errorf("expected message visible for user1, got %d", len(messages))
+	}
+
+	offline, _ := svc.GetOfflineMessages(user2.ID, 0, 10)
+	if len(offline) != 0 {
+		t.Errorf("expected no offline messages for user2, got %d", len(offline))
+	}
+}
+
+func TestService_ReplyMessage(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	long := strings.Repeat("长", ReplyPreviewLength+20)
+	quoted, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: long})
+	if err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+
+	reply, err := svc.SendMessage(&SendMessageRequest{
+		From:      user2.ID,
+		To:        user1.ID,
+		Type:      "text",
+		Content:   "agreed",
+		ReplyToID: quoted.ID,
+	})
+	if err != nil {
+		t.Fatalf("send reply failed: %v", err)
+	}
+	if reply.ReplyTo == nil || reply.ReplyTo.MessageID != quoted.ID {
+		t.Fatalf("expected reply preview, got %+v", reply.ReplyTo)
+	}
+	if reply.ReplyTo.SenderNickname != "Alice" {
+		t.Errorf("expected sender nickname Alice, got %s", reply.ReplyTo.SenderNickname)
+	}
+	if n := len([]rune(reply.ReplyTo.Content)); n != ReplyPreviewLength+1 {
+		t.Errorf("expected truncated preview, got %d runes", n)
+	}
+
+	// 历史与离线消息都带有预览
+	messages, _, _ := svc.GetMessages(reply.ConversationID, user1.ID, 0, 10)
+	if len(messages) != 2 || messages[0].ReplyTo == nil || messages[0].ReplyToID != quoted.ID {
+		t.Errorf("expected reply preview in history, got %+v", messages)
+	}
+	offline, _ := svc.GetOfflineMessages(user1.ID, 0, 10)
+	if len(offline) != 1 || offline[0].ReplyTo == nil {
+		t.Errorf("expected reply preview in offline messages, got %+v", offline)
+	}
+
+	// 引用其他会话的消息
+	user3 := &models.User{Username: "carol", PasswordHash: "hash3", Nickname: "Carol", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user3)
+	_, err = svc.SendMessage(&SendMessageRequest{
+		From:      user3.ID,
+		To:        user1.ID,
+		Type:      "text",
+		Content:   "sneaky",
+		ReplyToID: quoted.ID,
+	})
+	if err != ErrInvalidReply {
+		t.Errorf("expected ErrInvalidReply, got: %v", err)
+	}
+}
+
+func TestService_Reactions(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	msg, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "Lunch?"})
+	if err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+	svc.MarkAsRead(msg.ConversationID, user2.ID)
+
+	before, _ := mgr.Conversation().GetByID(msg.ConversationID)
+	mgr.Conversation().UpdateTime(msg.ConversationID, before.UpdatedAt-100)
+
+	if _, err := svc.AddReaction(msg.ConversationID, msg.ID, user2.ID, "👍"); err != nil {
+		t.Fatalf("add reaction failed: %v", err)
+	}
+	// 重复添加不报错
+	if _, err := svc.AddReaction(msg.ConversationID, msg.ID, user2.ID, "👍"); err != nil {
+		t.Fatalf("add duplicate reaction failed: %v", err)
+	}
+	reacted, err := svc.AddReaction(msg.ConversationID, msg.ID, user1.ID, "👍")
+	if err != nil {
+		t.Fatalf("add reaction failed: %v", err)
+	}
+	if len(reacted.Reactions) != 1 || reacted.Reactions[0].Count != 2 || !reacted.Reactions[0].ReactedByMe {
+		t.Errorf("unexpected reactions: %+v", reacted.Reactions)
+	}
+
+	if _, err := svc.AddReaction(msg.ConversationID, msg.ID, user2.ID, ""); err != ErrInvalidReaction {
+		t.Errorf("expected ErrInvalidReaction, got: %v", err)
+	}
+
+	// 历史消息带有汇总与"我已回应"
+	if _, err := svc.RemoveReaction(msg.ConversationID, msg.ID, user1.ID, "👍"); err != nil {
+		t.Fatalf("remove reaction failed: %v", err)
+	}
+	messages, _, _ := svc.GetMessages(msg.ConversationID, user1.ID, 0, 10)
+	if len(messages) != 1 || len(messages[0].Reactions) != 1 {
+		t.Fatalf("expected reactions in history, got %+v", messages)
+	}
+	if messages[0].Reactions[0].Count != 1 || messages[0].Reactions[0].ReactedByMe {
+		t.Errorf("unexpected summary for user1: %+v", messages[0].Reactions[0])
+	}
+
+	// 回应不更新会话时间，也不计入未读
+	after, _ := mgr.Conversation().GetByID(msg.ConversationID)
+	if after.UpdatedAt != before.UpdatedAt-100 {
+		t.Errorf("reaction should not bump updated_at")
+	}
+	count, _ := svc.GetUnreadCount(msg.ConversationID, user1.ID)
+	if count != 0 {
+		t.Errorf("expected unread 0 for sender, got %d", count)
+	}
+	count, _ = svc.GetUnreadCount(msg.ConversationID, user2.ID)
+	if count != 0 {
+		t.Errorf("expected unread 0 for receiver, got %d", count)
+	}
+}
+
+func TestService_SearchMessages(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+	outsider := &models.User{Username: "carol", PasswordHash: "hash3", Nickname: "Carol", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(outsider)
+
+	var convID int64
+	for i := 0; i < 3; i++ {
+		msg, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: fmt.Sprintf("meeting at %d pm", i+1)})
+		if err != nil {
+			t.Fatalf("send message failed: %v", err)
+		}
+		convID = msg.ConversationID
+	}
+
+	// 游标分页
+	hits, hasMore, err := svc.SearchMessages(&SearchMessagesRequest{UserID: user2.ID, Query: "meeting", Limit: 2})
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(hits) != 2 || !hasMore {
+		t.Fatalf("expected 2 hits with more, got %d (hasMore=%v)", len(hits), hasMore)
+	}
+	if !strings.Contains(hits[0].Snippet, "<mark>meeting</mark>") {
+		t.Errorf("expected highlighted snippet, got %s", hits[0].Snippet)
+	}
+	hits, hasMore, _ = svc.SearchMessages(&SearchMessagesRequest{UserID: user2.ID, Query: "meeting", Limit: 2, BeforeID: hits[1].Message.ID})
+	if len(hits) != 1 || hasMore {
+		t.Errorf("expected last page with 1 hit, got %d (hasMore=%v)", len(hits), hasMore)
+	}
+
+	// 非参与者
+	if _, _, err := svc.SearchMessages(&SearchMessagesRequest{UserID: outsider.ID, Query: "meeting", ConversationID: convID}); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+	hits, _, _ = svc.SearchMessages(&SearchMessagesRequest{UserID: outsider.ID, Query: "meeting"})
+	if len(hits) != 0 {
+		t.Errorf("expected no hits for outsider, got %d", len(hits))
+	}
+
+	if _, _, err := svc.SearchMessages(&SearchMessagesRequest{UserID: user1.ID, Query: "  "}); err != ErrInvalidSearchQuery {
+		t.Errorf("expected ErrInvalidSearchQuery, got: %v", err)
+	}
+}
+
+func TestService_SendMessageIdempotent(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	req := &SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "Hi", ClientMsgID: "c-42"}
+	first, err := svc.SendMessage(req)
+	if err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+	retry, err := svc.SendMessage(req)
+	if err != nil {
+		t.Fatalf("resend message failed: %v", err)
+	}
+	if retry.ID != first.ID || retry.CreatedAt != first.CreatedAt || retry.ClientMsgID != "c-42" {
+		t.Errorf("expected resend to return stored message %d, got %+v", first.ID, retry)
+	}
+
+	messages, _, _ := svc.GetMessages(first.ConversationID, user1.ID, 0, 10)
+	if len(messages) != 1 {
+		t.Errorf("expected 1 stored message, got %d", len(messages))
+	}
+
+	// 客户端ID已用于其他会话时不返回原消息
+	user3 := &models.User{Username: "carol", PasswordHash: "hash3", Nickname: "Carol", CreatedAt: time.Now().Unix(), LastSeen: time.Now().Unix()}
+	mgr.User().Create(user3)
+	if _, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user3.ID, Type: "text", Content: "Hi", ClientMsgID: "c-42"}); err != ErrInvalidClientMsgID {
+		t.Errorf("expected ErrInvalidClientMsgID for reused client id, got: %v", err)
+	}
+
+	// 不带客户端ID的消息不去重
+	svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "Hi"})
+	svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "Hi"})
+	messages, _, _ = svc.GetMessages(first.ConversationID, user1.ID, 0, 10)
+	if len(messages) != 3 {
+		t.Errorf("expected 3 stored messages, got %d", len(messages))
+	}
+
+	_, err = svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "Hi", ClientMsgID: strings.Repeat("x", MaxClientMsgIDLength+1)})
+	if err != ErrInvalidClientMsgID {
+		t.Errorf("expected ErrInvalidClientMsgID, got: %v", err)
+	}
+}
+
+func TestService_DisappearingMessages(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	conv, _ := svc.GetConversationWithUser(user1.ID, user2.ID)
+	before, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "kept"})
+	if before.ExpiresAt != nil {
+		t.Errorf("expected no expiry before the timer is set")
+	}
+
+	if _, err := svc.SetDisappearingTimer(conv.ID, user1.ID, 42); err != ErrInvalidDisappearTimer {
+		t.Errorf("expected ErrInvalidDisappearTimer, got: %v", err)
+	}
+
+	notice, err := svc.SetDisappearingTimer(conv.ID, user1.ID, 3600)
+	if err != nil {
+		t.Fatalf("set disappearing timer failed: %v", err)
+	}
+	if notice == nil || notice.Type != models.MessageTypeSystem || notice.ReceiverID != user2.ID || notice.ExpiresAt != nil {
+		t.Errorf("expected a non-expiring system notice for user2, got %+v", notice)
+	}
+	info, _ := svc.GetConversation(conv.ID, user2.ID)
+	if info.DisappearAfter != 3600 {
+		t.Errorf("expected disappear_after 3600, got %d", info.DisappearAfter)
+	}
+
+	// 未变化时不重复发布通知
+	if again, err := svc.SetDisappearingTimer(conv.ID, user2.ID, 3600); err != nil || again != nil {
+		t.Errorf("expected no notice for unchanged timer, got %+v, %v", again, err)
+	}
+
+	msg, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "secret"})
+	if msg.ExpiresAt == nil || *msg.ExpiresAt != msg.CreatedAt+3600 {
+		t.Errorf("expected message to expire after 1h, got %v", msg.ExpiresAt)
+	}
+
+	// 模拟到期（清理前已不可见）
+	if _, err := mgr.DB().Exec(`UPDATE messages SET expires_at = ? WHERE id = ?`, time.Now().Unix()-1, msg.ID); err != nil {
+		t.Fatalf("expire message: %v", err)
+	}
+
+	messages, _, _ := svc.GetMessages(conv.ID, user2.ID, 0, 10)
+	if len(messages) != 2 || messages[0].ID != notice.ID || messages[1].ID != before.ID {
+		t.Errorf("expected expired message to be hidden, got %d messages", len(messages))
+	}
+	offline, _ := svc.GetOfflineMessages(user2.ID, 0, 10)
+	for _, m := range offline {
+		if m.ID == msg.ID {
+			t.Errorf("expected expired message to be excluded from offline messages")
+		}
+	}
+	if _, err := svc.AddReaction(conv.ID, msg.ID, user2.ID, "👍"); err != ErrMessageNotFound {
+		t.Errorf("expected ErrMessageNotFound for expired message, got: %v", err)
+	}
+
+	// 关闭
+	notice, _ = svc.SetDisappearingTimer(conv.ID, user2.ID, 0)
+	if notice == nil || !strings.Contains(notice.Content, "Bob") {
+		t.Errorf("expected notice from Bob, got %+v", notice)
+	}
+	msg, _ = svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "normal"})
+	if msg.ExpiresAt != nil {
+		t.Errorf("expected no expiry after the timer is turned off")
+	}
+}
+
+func TestService_PinMessages(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	var msgs []*models.Message
+	for i := 0; i <= MaxPinnedMessages; i++ {
+		msg, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: fmt.Sprintf("msg %d", i)})
+		msgs = append(msgs, msg)
+	}
+	convID := msgs[0].ConversationID
+
+	pins, err := svc.PinMessage(convID, msgs[0].ID, user2.ID)
+	if err != nil {
+		t.Fatalf("pin failed: %v", err)
+	}
+	if len(pins) != 1 || pins[0].Message.Content != "msg 0" || pins[0].PinnedBy != user2.ID {
+		t.Errorf("expected pinned message with full content, got %+v", pins)
+	}
+
+	// 重复置顶不报错
+	if pins, err = svc.PinMessage(convID, msgs[0].ID, user1.ID); err != nil || len(pins) != 1 {
+		t.Errorf("expected repeated pin to be a no-op, got %d pins, %v", len(pins), err)
+	}
+
+	// 会话详情中包含置顶消息
+	info, _ := svc.GetConversation(convID, user1.ID)
+	if len(info.Pins) != 1 || info.Pins[0].Message.ID != msgs[0].ID {
+		t.Errorf("expected pins in conversation info, got %+v", info.Pins)
+	}
+
+	// 达到上限
+	for i := 1; i < MaxPinnedMessages; i++ {
+		if _, err := svc.PinMessage(convID, msgs[i].ID, user1.ID); err != nil {
+			t.Fatalf("pin %d failed: %v", i, err)
+		}
+	}
+	if _, err := svc.PinMessage(convID, msgs[MaxPinnedMessages].ID, user1.ID); err != ErrPinLimitReached {
+		t.Errorf("expected ErrPinLimitReached, got: %v", err)
+	}
+
+	// 撤回的消息不再显示为置顶，也不占用名额
+	if _, err := svc.RecallMessage(convID, msgs[1].ID, user1.ID); err != nil {
+		t.Fatalf("recall failed: %v", err)
+	}
+	pins, _ = svc.GetPinnedMessages(convID, user2.ID)
+	if len(pins) != MaxPinnedMessages-1 {
+		t.Errorf("expected %d pins after recall, got %d", MaxPinnedMessages-1, len(pins))
+	}
+	if pins[0].Message.ID != msgs[MaxPinnedMessages-1].ID {
+		t.Errorf("expected most recently pinned first, got message %d", pins[0].Message.ID)
+	}
+	if _, err := svc.PinMessage(convID, msgs[MaxPinnedMessages].ID, user1.ID); err != nil {
+		t.Errorf("expected pin to succeed after recall freed a slot, got: %v", err)
+	}
+
+	pins, err = svc.UnpinMessage(convID, msgs[0].ID, user1.ID)
+	if err != nil {
+		t.Fatalf("unpin failed: %v", err)
+	}
+	for _, p := range pins {
+		if p.Message.ID == msgs[0].ID {
+			t.Errorf("expected message to be unpinned")
+		}
+	}
+	if _, err := svc.UnpinMessage(convID, msgs[0].ID, user1.ID); err != nil {
+		t.Errorf("expected repeated unpin to be a no-op, got: %v", err)
+	}
+
+	// 仅自己删除的消息只对删除者隐藏
+	if _, err := svc.DeleteMessageForMe(convID, msgs[2].ID, user2.ID); err != nil {
+		t.Fatalf("delete for me failed: %v", err)
+	}
+	user1Pins, _ := svc.GetPinnedMessages(convID, user1.ID)
+	user2Pins, _ := svc.GetPinnedMessages(convID, user2.ID)
+	if len(user2Pins) != len(user1Pins)-1 {
+		t.Errorf("expected deleted message to be hidden from user2 pins, got %d vs %d", len(user2Pins), len(user1Pins))
+	}
+	for _, p := range user2Pins {
+		if p.Message.ID == msgs[2].ID {
+			t.Errorf("expected deleted message not to be returned")
+		}
+	}
+
+	// 非参与者
+	other := &models.User{Username: "carol", PasswordHash: "hash", Nickname: "Carol", CreatedAt: time.Now().Unix()}
+	mgr.User().Create(other)
+	if _, err := svc.GetPinnedMessages(convID, other.ID); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+}
+
+func TestService_StarMessages(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+	user3 := &models.User{Username: "carol", PasswordHash: "hash", Nickname: "Carol", CreatedAt: time.Now().Unix()}
+	mgr.User().Create(user3)
+
+	text, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "remember this"})
+	image, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "image", Content: "1"})
+	recalled, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "text", Content: "oops"})
+	deleted, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "text", Content: "bye"})
+	other, _ := svc.SendMessage(&SendMessageRequest{From: user3.ID, To: user1.ID, Type: "text", Content: "from carol"})
+
+	for _, m := range []*models.Message{text, image, recalled, deleted, other} {
+		if err := svc.StarMessage(m.ConversationID, m.ID, user1.ID); err != nil {
+			t.Fatalf("star message %d failed: %v", m.ID, err)
+		}
+	}
+	// 重复收藏不报错
+	if err := svc.StarMessage(text.ConversationID, text.ID, user1.ID); err != nil {
+		t.Errorf("expected repeated star to be a no-op, got: %v", err)
+	}
+	// 非参与者不能收藏
+	if err := svc.StarMessage(text.ConversationID, text.ID, user3.ID); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	// 撤回或删除源消息后收藏随之移除
+	svc.RecallMessage(recalled.ConversationID, recalled.ID, user2.ID)
+	svc.DeleteMessageForMe(deleted.ConversationID, deleted.ID, user1.ID)
+
+	stars, hasMore, err := svc.GetStarredMessages(&StarredMessagesRequest{UserID: user1.ID})
+	if err != nil {
+		t.Fatalf("get starred failed: %v", err)
+	}
+	if len(stars) != 3 || hasMore {
+		t.Fatalf("expected 3 stars, got %d (has_more=%v)", len(stars), hasMore)
+	}
+	if stars[0].Message.ID != other.ID || stars[2].Message.ID != text.ID || stars[2].Message.Content != "remember this" {
+		t.Errorf("expected most recent star first with full message")
+	}
+
+	// 收藏是私有的
+	if stars, _, _ := svc.GetStarredMessages(&StarredMessagesRequest{UserID: user2.ID}); len(stars) != 0 {
+		t.Errorf("expected no stars for user2, got %d", len(stars))
+	}
+
+	// 按会话和类型过滤
+	stars, _, _ = svc.GetStarredMessages(&StarredMessagesRequest{UserID: user1.ID, ConversationID: text.ConversationID})
+	if len(stars) != 2 {
+		t.Errorf("expected 2 stars in conversation, got %d", len(stars))
+	}
+	stars, _, _ = svc.GetStarredMessages(&StarredMessagesRequest{UserID: user1.ID, Type: "image"})
+	if len(stars) != 1 || stars[0].Message.ID != image.ID {
+		t.Errorf("expected only the image star, got %d", len(stars))
+	}
+
+	// 游标分页
+	page1, hasMore, _ := svc.GetStarredMessages(&StarredMessagesRequest{UserID: user1.ID, Limit: 2})
+	if len(page1) != 2 || !hasMore {
+		t.Fatalf("expected 2 stars with more, got %d (has_more=%v)", len(page1), hasMore)
+	}
+	page2, hasMore, _ := svc.GetStarredMessages(&StarredMessagesRequest{UserID: user1.ID, Limit: 2, BeforeID: page1[1].ID})
+	if len(page2) != 1 || hasMore || page2[0].Message.ID != text.ID {
+		t.Errorf("expected last star on page 2, got %d (has_more=%v)", len(page2), hasMore)
+	}
+
+	// 消息列表中标记已收藏
+	messages, _, _ := svc.GetMessages(text.ConversationID, user1.ID, 0, 10)
+	for _, m := range messages {
+		if m.Starred != (m.ID == text.ID || m.ID == image.ID) {
+			t.Errorf("unexpected starred flag %v on message %d", m.Starred, m.ID)
+		}
+	}
+
+	if err := svc.UnstarMessage(text.ConversationID, text.ID, user1.ID); err != nil {
+		t.Fatalf("unstar failed: %v", err)
+	}
+	stars, _, _ = svc.GetStarredMessages(&StarredMessagesRequest{UserID: user1.ID})
+	if len(stars) != 2 {
+		t.Errorf("expected 2 stars after unstar, got %d", len(stars))
+	}
+}
+
+func TestService_Drafts(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	h := hub.New()
+	svc := NewService(mgr, WithHub(h))
+	user1, user2 := setupTestUsers(t, mgr)
+	conv, _ := svc.GetConversationWithUser(user1.ID, user2.ID)
+
+	type change struct {
+		draft  *models.Draft
+		origin string
+	}
+	var changes []change
+	h.Subscribe(user1.ID, []string{EventDraftUpdated}, func(_ int64, e *hub.Event) bool {
+		changes = append(changes, change{e.Data.(*models.Draft), e.Origin})
+		return true
+	})
+
+	draft, err := svc.SaveDraft(&SaveDraftRequest{UserID: user1.ID, ConversationID: conv.ID, Content: "half-written", Origin: "conn-1"})
+	if err != nil {
+		t.Fatalf("save draft failed: %v", err)
+	}
+	if draft.Content != "half-written" {
+		t.Errorf("unexpected draft: %+v", draft)
+	}
+	if len(changes) != 1 || changes[0].origin != "conn-1" || changes[0].draft.Content != "half-written" {
+		t.Errorf("expected draft change with origin, got %+v", changes)
+	}
+
+	// 草稿只属于自己
+	info, _ := svc.GetConversation(conv.ID, user1.ID)
+	if info.Draft == nil || info.Draft.Content != "half-written" {
+		t.Errorf("expected draft in conversation info, got %+v", info.Draft)
+	}
+	info, _ = svc.GetConversation(conv.ID, user2.ID)
+	if info.Draft != nil {
+		t.Errorf("expected no draft for user2, got %+v", info.Draft)
+	}
+
+	// 覆盖
+	svc.SaveDraft(&SaveDraftRequest{UserID: user1.ID, ConversationID: conv.ID, Content: "almost done"})
+	info, _ = svc.GetConversation(conv.ID, user1.ID)
+	if info.Draft.Content != "almost done" {
+		t.Errorf("expected draft to be replaced, got %q", info.Draft.Content)
+	}
+
+	// 定时消息等非交互发送不清除草稿
+	svc.SendMessage(&SendMessageRequest{From: user1.ID, ConversationID: conv.ID, Type: "text", Content: "scheduled", KeepDraft: true})
+	info, _ = svc.GetConversation(conv.ID, user1.ID)
+	if info.Draft == nil {
+		t.Errorf("expected draft to be kept")
+	}
+
+	// 发送成功后自动清除
+	changes = nil
+	if _, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, ConversationID: conv.ID, Type: "text", Content: "almost done!"}); err != nil {
+		t.Fatalf("send message failed: %v", err)
+	}
+	info, _ = svc.GetConversation(conv.ID, user1.ID)
+	if info.Draft != nil {
+		t.Errorf("expected draft to be cleared after send, got %+v", info.Draft)
+	}
+	if len(changes) != 1 || changes[0].draft.Content != "" {
+		t.Errorf("expected a cleared draft change, got %+v", changes)
+	}
+
+	// 没有草稿时清除不产生变更
+	changes = nil
+	svc.SaveDraft(&SaveDraftRequest{UserID: user1.ID, ConversationID: conv.ID})
+	if len(changes) != 0 {
+		t.Errorf("expected no change when clearing a missing draft, got %d", len(changes))
+	}
+
+	if _, err := svc.SaveDraft(&SaveDraftRequest{UserID: user1.ID, ConversationID: conv.ID, Content: "re", ReplyToID: 9999}); err != ErrInvalidReply {
+		t.Errorf("expected ErrInvalidReply, got: %v", err)
+	}
+	if _, err := svc.SaveDraft(&SaveDraftRequest{UserID: user1.ID, ConversationID: conv.ID, Content: strings.Repeat("x", MaxDraftLength+1)}); err != ErrInvalidDraft {
+		t.Errorf("expected ErrInvalidDraft, got: %v", err)
+	}
+	other := &models.User{Username: "carol", PasswordHash: "hash", Nickname: "Carol", CreatedAt: time.Now().Unix()}
+	mgr.User().Create(other)
+	if _, err := svc.SaveDraft(&SaveDraftRequest{UserID: other.ID, ConversationID: conv.ID, Content: "hi"}); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+}
+
+func TestService_ForwardMessages(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+	user3 := &models.User{Username: "carol", PasswordHash: "hash", Nickname: "Carol", CreatedAt: time.Now().Unix()}
+	mgr.User().Create(user3)
+
+	text, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "text", Content: "hello"})
+	image, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "image", Content: "42"})
+	target, _ := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user3.ID, Type: "text", Content: "hi carol"})
+
+	forwarded, err := svc.ForwardMessages(&ForwardMessagesRequest{
+		UserID:               user1.ID,
+		SourceConversationID: text.ConversationID,
+		MessageIDs:           []int64{text.ID, image.ID},
+		TargetConversationID: target.ConversationID,
+	})
+	if err != nil {
+		t.Fatalf("forward failed: %v", err)
+	}
+	if len(forwarded) != 2 {
+		t.Fatalf("expected 2 forwarded messages, got %d", len(forwarded))
+	}
+	copied := forwarded[1]
+	if copied.ConversationID != target.ConversationID || copied.SenderID != user1.ID || copied.ReceiverID != user3.ID {
+		t.Errorf("expected copy sent by forwarder into target conversation")
+	}
+	// 媒体消息复用原媒体ID
+	if copied.Type != "image" || copied.Content != "42" {
+		t.Errorf("expected image copy to reuse media id, got %s/%s", copied.Type, copied.Content)
+	}
+	fwd := copied.ForwardedFrom
+	if fwd == nil || fwd.MessageID != image.ID || fwd.SenderID != user2.ID || fwd.CreatedAt != image.CreatedAt || fwd.SenderNickname != "Bob" {
+		t.Errorf("expected attribution to original sender, got %+v", fwd)
+	}
+
+	// 接收者查询历史时可见转发来源
+	messages, _, _ := svc.GetMessages(target.ConversationID, user3.ID, 0, 50)
+	if len(messages) != 3 || messages[0].ForwardedFrom == nil || messages[0].ForwardedFrom.SenderID != user2.ID {
+		t.Errorf("expected forwarded_from in history")
+	}
+
+	// 再次转发保留最初的来源
+	again, err := svc.ForwardMessages(&ForwardMessagesRequest{
+		UserID:               user3.ID,
+		SourceConversationID: target.ConversationID,
+		MessageIDs:           []int64{forwarded[0].ID},
+		TargetConversationID: target.ConversationID,
+	})
+	if err != nil {
+		t.Fatalf("re-forward failed: %v", err)
+	}
+	if again[0].ForwardedFrom.MessageID != text.ID || again[0].ForwardedFrom.SenderID != user2.ID {
+		t.Errorf("expected original attribution to be kept, got %+v", again[0].ForwardedFrom)
+	}
+
+	// 不能读取来源会话或不能写入目标会话时拒绝
+	if _, err := svc.ForwardMessages(&ForwardMessagesRequest{
+		UserID: user3.ID, SourceConversationID: text.ConversationID, MessageIDs: []int64{text.ID}, TargetConversationID: target.ConversationID,
+	}); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied for unreadable source, got: %v", err)
+	}
+	if _, err := svc.ForwardMessages(&ForwardMessagesRequest{
+		UserID: user2.ID, SourceConversationID: text.ConversationID, MessageIDs: []int64{text.ID}, TargetConversationID: target.ConversationID,
+	}); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied for unwritable target, got: %v", err)
+	}
+
+	// 消息须属于来源会话
+	if _, err := svc.ForwardMessages(&ForwardMessagesRequest{
+		UserID: user1.ID, SourceConversationID: text.ConversationID, MessageIDs: []int64{target.ID}, TargetConversationID: target.ConversationID,
+	}); err != ErrMessageNotFound {
+		t.Errorf("expected ErrMessageNotFound, got: %v", err)
+	}
+	if _, err := svc.ForwardMessages(&ForwardMessagesRequest{
+		UserID: user1.ID, SourceConversationID: text.ConversationID, TargetConversationID: target.ConversationID,
+	}); err != ErrInvalidForward {
+		t.Errorf("expected ErrInvalidForward for empty list, got: %v", err)
+	}
+}
+
+func TestService_ContentTypes(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	contact := fmt.Sprintf(`{"user_id": %d}`, user2.ID)
+	valid := []struct {
+		msgType string
+		content string
+	}{
+		{"text", "hello"},
+		{"image", "12"},
+		{"voice", "13"},
+		{"file", `{"media_id": 14, "name": "report.pdf", "size": 2048, "mime_type": "application/pdf"}`},
+		{"location", `{"lat": 31.2304, "lng": 121.4737, "label": "上海"}`},
+		{"location", `{"lat": -33.86, "lng": 151.2}`},
+		{"contact", contact},
+		{"poll", `{"question": "Lunch?", "options": ["noodles", "pizza"], "multiple": true}`},
+	}
+	for _, tt := range valid {
+		msg, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: tt.msgType, Content: tt.content})
+		if err != nil {
+			t.Errorf("send %s %s failed: %v", tt.msgType, tt.content, err)
+			continue
+		}
+		if msg.Content != tt.content {
+			t.Errorf("expected content stored as-is, got %s", msg.Content)
+		}
+	}
+
+	invalid := []struct {
+		msgType string
+		content string
+		want    error
+	}{
+		{"system", "notice", ErrInvalidMessageType},
+		{"sticker", "1", ErrInvalidMessageType},
+		{"text", "   ", ErrInvalidMessageContent},
+		{"image", "not-an-id", ErrInvalidMessageContent},
+		{"voice", "-1", ErrInvalidMessageContent},
+		{"file", `{"name": "report.pdf"}`, ErrInvalidMessageContent},
+		{"file", `{"media_id": "14", "name": "report.pdf"}`, ErrInvalidMessageContent},
+		{"file", `{"media_id": 14, "name": ""}`, ErrInvalidMessageContent},
+		{"location", `{"lat": 91, "lng": 0}`, ErrInvalidMessageContent},
+		{"location", `{"lat": 10, "lng": 200}`, ErrInvalidMessageContent},
+		{"location", `{"lat": 10}`, ErrInvalidMessageContent},
+		{"location", `{"lat": 10, "lng": 20, "extra": true}`, ErrInvalidMessageContent},
+		{"location", `[1, 2]`, ErrInvalidMessageContent},
+		{"contact", `{"user_id": 1.5}`, ErrInvalidMessageContent},
+		{"contact", `{"user_id": 99999}`, ErrInvalidMessageContent},
+		{"poll", `{"question": "Lunch?", "options": ["noodles"]}`, ErrInvalidMessageContent},
+		{"poll", `{"question": "Lunch?", "options": ["pizza", "pizza"]}`, ErrInvalidMessageContent},
+		{"poll", `{"question": "", "options": ["a", "b"]}`, ErrInvalidMessageContent},
+		{"poll", `{"question": "Lunch?", "options": ["a", "b"], "deadline": 1}`, ErrInvalidMessageContent},
+	}
+	for _, tt := range invalid {
+		_, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: tt.msgType, Content: tt.content})
+		if err != tt.want {
+			t.Errorf("send %s %s: expected %v, got %v", tt.msgType, tt.content, tt.want, err)
+		}
+	}
+
+	// 注册自定义类型
+	RegisterContentType("sticker", JSONSchema{{Name: "sticker_id", Kind: KindString, Required: true}})
+	defer func() {
+		typesMu.Lock()
+		delete(contentTypes, "sticker")
+		typesMu.Unlock()
+	}()
+	if _, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "sticker", Content: `{"sticker_id": "cat"}`}); err != nil {
+		t.Errorf("expected registered type to be accepted, got: %v", err)
+	}
+
+	// 文件消息引用的媒体ID
+	file := &models.Message{Type: "file", Content: `{"media_id": 14, "name": "report.pdf"}`}
+	if file.MediaID() != 14 {
+		t.Errorf("expected file media id 14, got %d", file.MediaID())
+	}
+	if referenced, err := mgr.Media().IsReferenced(14); err != nil || !referenced {
+		t.Errorf("expected media referenced by file message, got %v, %v", referenced, err)
+	}
+}
+
+func TestService_ConversationSettings(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+	user3 := &models.User{Username: "carol", PasswordHash: "hash3", Nickname: "Carol", CreatedAt: time.Now().Unix()}
+	if err := mgr.User().Create(user3); err != nil {
+		t.Fatalf("create user3: %v", err)
+	}
+
+	send := func(from, to int64) *models.Message {
+		t.Helper()
+		msg, err := svc.SendMessage(&SendMessageRequest{From: from, To: to, Type: "text", Content: "hi"})
+		if err != nil {
+			t.Fatalf("send message: %v", err)
+		}
+		return msg
+	}
+	conv12 := send(user2.ID, user1.ID).ConversationID
+	conv13 := send(user3.ID, user1.ID).ConversationID
+	yes := true
+
+	// 归档后默认列表不再包含，archived=true 时只返回归档的会话
+	if _, err := svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user1.ID, ConversationID: conv12, Archived: &yes}); err != nil {
+		t.Fatalf("archive conversation: %v", err)
+	}
+	convs, total, _ := svc.GetConversations(user1.ID, 1, 10)
+	if total != 1 || convs[0].ID != conv13 {
+		t.Fatalf("expected only conversation %d, got total %d", conv13, total)
+	}
+	archived, _, _ := svc.ListConversations(&ConversationListRequest{UserID: user1.ID, Archived: &yes})
+	if len(archived) != 1 || archived[0].ID != conv12 || !archived[0].Settings.Archived {
+		t.Fatalf("expected archived conversation %d, got %+v", conv12, archived)
+	}
+	// 归档只影响自己
+	if _, total, _ := svc.GetConversations(user2.ID, 1, 10); total != 1 {
+		t.Errorf("expected archive to be per-user, user2 sees %d conversations", total)
+	}
+
+	// 新消息自动取消归档
+	send(user2.ID, user1.ID)
+	if _, total, _ := svc.GetConversations(user1.ID, 1, 10); total != 2 {
+		t.Errorf("expected new message to unarchive, got %d conversations", total)
+	}
+
+	// 免打扰的会话收到新消息仍保持归档，且不计入总未读数
+	mutedUntil := time.Now().Add(time.Hour).Unix()
+	if _, err := svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user1.ID, ConversationID: conv12, Archived: &yes, MutedUntil: &mutedUntil}); err != nil {
+		t.Fatalf("mute conversation: %v", err)
+	}
+	send(user2.ID, user1.ID)
+	if _, total, _ := svc.GetConversations(user1.ID, 1, 10); total != 1 {
+		t.Errorf("expected muted conversation to stay archived, got %d conversations", total)
+	}
+	unread, err := svc.GetTotalUnreadCount(user1.ID)
+	if err != nil {
+		t.Fatalf("get total unread: %v", err)
+	}
+	if unread != 1 {
+		t.Errorf("expected muted conversation excluded from total unread (1), got %d", unread)
+	}
+	muted, _, _ := svc.ListConversations(&ConversationListRequest{UserID: user1.ID, Muted: &yes})
+	if len(muted) != 1 || muted[0].ID != conv12 {
+		t.Errorf("expected muted filter to return conversation %d", conv12)
+	}
+
+	// 隐藏的会话收到新消息后重新出现
+	if _, err := svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user1.ID, ConversationID: conv13, Hidden: &yes}); err != nil {
+		t.Fatalf("hide conversation: %v", err)
+	}
+	if _, total, _ := svc.GetConversations(user1.ID, 1, 10); total != 0 {
+		t.Errorf("expected hidden conversation excluded, got %d conversations", total)
+	}
+	send(user3.ID, user1.ID)
+	if _, total, _ := svc.GetConversations(user1.ID, 1, 10); total != 1 {
+		t.Errorf("expected hidden conversation restored by new message, got %d", total)
+	}
+
+	// 置顶的会话排在最前
+	no := false
+	svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user1.ID, ConversationID: conv12, Archived: &no, MutedUntil: new(int64)})
+	settings, err := svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user1.ID, ConversationID: conv12, Pinned: &yes})
+	if err != nil {
+		t.Fatalf("pin conversation: %v", err)
+	}
+	if settings.PinnedAt == 0 || settings.MutedUntil != 0 {
+		t.Errorf("unexpected settings after pin: %+v", settings)
+	}
+	convs, _, _ = svc.GetConversations(user1.ID, 1, 10)
+	if len(convs) != 2 || convs[0].ID != conv12 {
+		t.Fatalf("expected pinned conversation %d first", conv12)
+	}
+
+	// 非参与者不能修改设置，负的免打扰时间无效
+	if _, err := svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user3.ID, ConversationID: conv12, Pinned: &yes}); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got %v", err)
+	}
+	negative := int64(-1)
+	if _, err := svc.UpdateConversationSettings(&UpdateConversationSettingsRequest{UserID: user1.ID, ConversationID: conv12, MutedUntil: &negative}); err != ErrInvalidSettings {
+		t.Errorf("expected ErrInvalidSettings, got %v", err)
+	}
+}
+
+func TestService_ClearHistory(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	var convID int64
+	for i := 0; i < 2; i++ {
+		msg, err := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "text", Content: "old"})
+		if err != nil {
+			t.Fatalf("send message: %v", err)
+		}
+		convID = msg.ConversationID
+	}
+
+	cleared, err := svc.ClearHistory(convID, user1.ID, 0)
+	if err != nil {
+		t.Fatalf("clear history: %v", err)
+	}
+	if cleared == 0 {
+		t.Fatal("expected non-zero watermark")
+	}
+
+	// 清空的一方看不到旧消息，也不计入未读
+	if msgs, _, _ := svc.GetMessages(convID, user1.ID, 0, 50); len(msgs) != 0 {
+		t.Errorf("expected no messages after clear, got %d", len(msgs))
+	}
+	if offline, _ := svc.GetOfflineMessages(user1.ID, 0, 50); len(offline) != 0 {
+		t.Errorf("expected no offline messages after clear, got %d", len(offline))
+	}
+	if unread, _ := svc.GetUnreadCount(convID, user1.ID); unread != 0 {
+		t.Errorf("expected unread 0 after clear, got %d", unread)
+	}
+	if total, _ := svc.GetTotalUnreadCount(user1.ID); total != 0 {
+		t.Errorf("expected total unread 0 after clear, got %d", total)
+	}
+	conv, err := svc.GetConversation(convID, user1.ID)
+	if err != nil {
+		t.Fatalf("get conversation: %v", err)
+	}
+	if conv.LastMessage != nil {
+		t.Errorf("expected no last message after clear, got %d", conv.LastMessage.ID)
+	}
+	if conv.Settings.ClearedMessageID != cleared {
+		t.Errorf("expected watermark %d in settings, got %d", cleared, conv.Settings.ClearedMessageID)
+	}
+
+	// 对方的记录不受影响
+	if msgs, _, _ := svc.GetMessages(convID, user2.ID, 0, 50); len(msgs) != 2 {
+		t.Errorf("expected other participant to keep 2 messages, got %d", len(msgs))
+	}
+	if msgs, _ := mgr.Message().GetByConversation(convID, 0, 50); len(msgs) != 2 {
+		t.Errorf("expected shared history to keep 2 messages, got %d", len(msgs))
+	}
+
+	// 清空后的新消息正常可见
+	newMsg, _ := svc.SendMessage(&SendMessageRequest{From: user2.ID, To: user1.ID, Type: "text", Content: "new"})
+	msgs, _, _ := svc.GetMessages(convID, user1.ID, 0, 50)
+	if len(msgs) != 1 || msgs[0].ID != newMsg.ID {
+		t.Errorf("expected only the new message after clear, got %d", len(msgs))
+	}
+	if unread, _ := svc.GetUnreadCount(convID, user1.ID); unread != 1 {
+		t.Errorf("expected unread 1, got %d", unread)
+	}
+
+	// 水位不会回退
+	if got, _ := svc.ClearHistory(convID, user1.ID, cleared-1); got != cleared {
+		t.Errorf("expected watermark to stay at %d, got %d", cleared, got)
+	}
+}
+
+func TestService_ReadCursors(t *testing.T) {
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	svc := NewService(mgr)
+	user1, user2 := setupTestUsers(t, mgr)
+
+	var msgs []*models.Message
+	for i := 0; i < 3; i++ {
+		msg, err := svc.SendMessage(&SendMessageRequest{From: user1.ID, To: user2.ID, Type: "text", Content: "hi"})
+		if err != nil {
+			t.Fatalf("send message: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	convID := msgs[0].ConversationID
+
+	// 回执单条消息为已读时，之前的消息一并视为已读
+	receipt, err := svc.UpdateMessageStatus(msgs[1].ID, user2.ID, "read")
+	if err != nil {
+		t.Fatalf("mark message read: %v", err)
+	}
+	if receipt == nil || len(receipt.MessageIDs) != 2 || receipt.MessageIDs[1] != msgs[1].ID {
+		t.Errorf("expected receipt for the first two messages, got %+v", receipt)
+	}
+	if unread, _ := svc.GetUnreadCount(convID, user2.ID); unread != 1 {
+		t.Errorf("expected unread 1 from cursor, got %d", unread)
+	}
+
+	// 已读由已读位置表示，不改写消息状态
+	var raw string
+	mgr.DB().QueryRow(`SELECT status FROM messages WHERE id = ?`, msgs[0].ID).Scan(&raw)
+	if raw != "sent" {
+		t.Errorf("expected stored status to stay 'sent', got %q", raw)
+	}
+	history, _, _ := svc.GetMessages(convID, user1.ID, 0, 50)
+	for _, m := range history {
+		want := "read"
+		if m.ID == msgs[2].ID {
+			want = "sent"
+		}
+		if m.Status != want {
+			t.Errorf("message %d: expected status %s, got %s", m.ID, want, m.Status)
+		}
+	}
+
+	// 发送者能看到对方的已读位置
+	conv, err := svc.GetConversation(convID, user1.ID)
+	if err != nil {
+		t.Fatalf("get conversation: %v", err)
+	}
+	if conv.PeerLastReadMessageID != msgs[1].ID {
+		t.Errorf("expected peer read cursor %d, got %d", msgs[1].ID, conv.PeerLastReadMessageID)
+	}
+
+	// 已读位置之前的消息不会再收到投递回执
+	if receipts, _ := svc.MarkDelivered(user2.ID, []int64{msgs[0].ID, msgs[2].ID}); len(receipts) != 1 || len(receipts[0].MessageIDs) != 1 || receipts[0].MessageIDs[0] != msgs[2].ID {
+		t.Errorf("expected delivered receipt only for the unread message, got %+v", receipts)
+	}
+
+	// 全部已读后回执只包含新读到的消息
+	receipt, _ = svc.MarkAsRead(convID, user2.ID)
+	if receipt == nil || len(receipt.MessageIDs) != 1 || receipt.MessageIDs[0] != msgs[2].ID {
+		t.Errorf("expected receipt for the last message, got %+v", receipt)
+	}
+	if unread, _ := svc.GetUnreadCount(convID, user2.ID); unread != 0 {
+		t.Errorf("expected unread 0, got %d", unread)
+	}
+	if receipt, _ := svc.UpdateMessageStatus(msgs[0].ID, user2.ID, "read"); receipt != nil {
+		t.Errorf("expected cursor not to move backwards, got %+v", receipt)
+	}
+}
@@ -0,0 +1,219 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"zmessage/server/models"
+)
+
+// ContentSchema 消息内容校验规则
+type ContentSchema interface {
+	// Validate 校验内容是否符合该类型的格式
+	Validate(content string) error
+}
+
+// 已注册的消息类型（系统通知不在其中，客户端不可发送）
+var (
+	typesMu      sync.RWMutex
+	contentTypes = map[string]ContentSchema{
+		models.MessageTypeText:  TextSchema{},
+		models.MessageTypeImage: MediaIDSchema{},
+		models.MessageTypeVoice: MediaIDSchema{},
+		models.MessageTypeFile: JSONSchema{
+			{Name: "media_id", Kind: KindInteger, Required: true, Min: 1},
+			{Name: "name", Kind: KindString, Required: true, MaxLength: 255},
+			{Name: "size", Kind: KindInteger, Min: 0},
+			{Name: "mime_type", Kind: KindString, MaxLength: 127},
+		},
+		models.MessageTypeLocation: JSONSchema{
+			{Name: "lat", Kind: KindNumber, Required: true, Min: -90, Max: 90},
+			{Name: "lng", Kind: KindNumber, Required: true, Min: -180, Max: 180},
+			{Name: "label", Kind: KindString, MaxLength: 200},
+		},
+		models.MessageTypeContact: JSONSchema{
+			{Name: "user_id", Kind: KindInteger, Required: true, Min: 1},
+		},
+		models.MessageTypePoll: PollSchema{},
+	}
+)
+
+// RegisterContentType 注册（或替换）消息类型及其内容校验规则
+func RegisterContentType(msgType string, schema ContentSchema) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	contentTypes[msgType] = schema
+}
+
+// ValidateContent 按消息类型校验内容
+// 未注册的类型返回 ErrInvalidMessageType，内容不符合格式返回 ErrInvalidMessageContent
+func ValidateContent(msgType string, content string) error {
+	typesMu.RLock()
+	schema, ok := contentTypes[msgType]
+	typesMu.RUnlock()
+	if !ok {
+		return ErrInvalidMessageType
+	}
+	if err := schema.Validate(content); err != nil {
+		return ErrInvalidMessageContent
+	}
+	return nil
+}
+
+// TextSchema 文本内容（不能为空白）
+type TextSchema struct{}
+
+// Validate 校验文本内容
+func (TextSchema) Validate(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrInvalidMessageContent
+	}
+	return nil
+}
+
+// MediaIDSchema 媒体ID（正整数）
+type MediaIDSchema struct{}
+
+// Validate 校验媒体ID
+func (MediaIDSchema) Validate(content string) error {
+	id, err := strconv.ParseInt(content, 10, 64)
+	if err != nil || id <= 0 {
+		return ErrInvalidMessageContent
+	}
+	return nil
+}
+
+// 投票限制
+const (
+	MinPollOptions        = 2
+	MaxPollOptions        = 10
+	MaxPollQuestionLength = 300
+	MaxPollOptionLength   = 100
+)
+
+// PollSchema 投票内容（选项不能重复，截止时间须在未来）
+type PollSchema struct{}
+
+// Validate 校验投票内容
+func (PollSchema) Validate(content string) error {
+	dec := json.NewDecoder(bytes.NewReader([]byte(content)))
+	dec.DisallowUnknownFields()
+	var poll models.PollContent
+	if err := dec.Decode(&poll); err != nil || dec.More() {
+		return ErrInvalidMessageContent
+	}
+
+	question := strings.TrimSpace(poll.Question)
+	if question == "" || utf8.RuneCountInString(question) > MaxPollQuestionLength {
+		return ErrInvalidMessageContent
+	}
+	if len(poll.Options) < MinPollOptions || len(poll.Options) > MaxPollOptions {
+		return ErrInvalidMessageContent
+	}
+	seen := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > MaxPollOptionLength || seen[option] {
+			return ErrInvalidMessageContent
+		}
+		seen[option] = true
+	}
+	if poll.Deadline < 0 || (poll.Deadline > 0 && poll.Deadline <= time.Now().Unix()) {
+		return ErrInvalidMessageContent
+	}
+	return nil
+}
+
+// FieldKind JSON字段类型
+type FieldKind int
+
+const (
+	KindString  FieldKind = iota // 字符串
+	KindNumber                   // 数字
+	KindInteger                  // 整数
+)
+
+// Field JSON字段规则
+type Field struct {
+	Name      string
+	Kind      FieldKind
+	Required  bool
+	Min, Max  float64 // 数字范围（Min < Max 时校验上下限，否则只校验下限）
+	MaxLength int     // 字符串最大字符数（0为不限制）
+}
+
+// JSONSchema JSON对象内容，只允许列出的字段
+type JSONSchema []Field
+
+// Validate 校验JSON内容
+func (schema JSONSchema) Validate(content string) error {
+	dec := json.NewDecoder(bytes.NewReader([]byte(content)))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil || obj == nil || dec.More() {
+		return ErrInvalidMessageContent
+	}
+
+	known := make(map[string]bool, len(schema))
+	for _, f := range schema {
+		known[f.Name] = true
+		value, ok := obj[f.Name]
+		if !ok || value == nil {
+			if f.Required {
+				return ErrInvalidMessageContent
+			}
+			continue
+		}
+		if !f.check(value) {
+			return ErrInvalidMessageContent
+		}
+	}
+	for name := range obj {
+		if !known[name] {
+			return ErrInvalidMessageContent
+		}
+	}
+	return nil
+}
+
+// check 校验单个字段的值
+func (f Field) check(value interface{}) bool {
+	switch f.Kind {
+	case KindString:
+		str, ok := value.(string)
+		if !ok || (f.Required && strings.TrimSpace(str) == "") {
+			return false
+		}
+		return f.MaxLength == 0 || utf8.RuneCountInString(str) <= f.MaxLength
+	case KindNumber, KindInteger:
+		num, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		var n float64
+		if f.Kind == KindInteger {
+			i, err := num.Int64()
+			if err != nil {
+				return false
+			}
+			n = float64(i)
+		} else {
+			v, err := num.Float64()
+			if err != nil {
+				return false
+			}
+			n = v
+		}
+		if f.Min < f.Max {
+			return n >= f.Min && n <= f.Max
+		}
+		return n >= f.Min
+	default:
+		return false
+	}
+}
@@ -0,0 +1,19 @@
+package poll
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrPollNotFound 投票不存在（或已撤回、已过期）
+	ErrPollNotFound = fmt.Errorf("poll not found")
+
+	// ErrAccessDenied 不是会话参与者，或关闭不是自己发起的投票
+	ErrAccessDenied = fmt.Errorf("access denied")
+
+	// ErrPollClosed 投票已关闭或已过截止时间
+	ErrPollClosed = fmt.Errorf("poll closed")
+
+	// ErrInvalidVote 无效的选项（越界、重复或单选投票选择了多项）
+	ErrInvalidVote = fmt.Errorf("invalid vote")
+)
@@ -0,0 +1,271 @@
+package poll
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/message"
+)
+
+// Option 投票服务配置项
+type Option func(*service)
+
+// WithHub 设置实时事件中心
+func WithHub(h hub.Hub) Option {
+	return func(s *service) {
+		s.hub = h
+	}
+}
+
+// NewService 创建投票服务
+func NewService(dalMgr dal.Manager, msgSvc message.Service, opts ...Option) Service {
+	s := &service{
+		dal:    dalMgr,
+		msgSvc: msgSvc,
+		hub:    hub.New(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// service 投票服务实现
+type service struct {
+	dal    dal.Manager
+	msgSvc message.Service
+	hub    hub.Hub
+
+	mu sync.Mutex // 串行化投票和关闭，避免关闭后仍写入投票
+}
+
+// poll 已加载的投票
+type poll struct {
+	msg          *models.Message
+	content      *models.PollContent
+	participants []int64
+}
+
+// Vote 投票
+func (s *service) Vote(req *VoteRequest) (*models.PollResults, error) {
+	p, err := s.vote(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.publish(p, req.UserID)
+}
+
+// vote 校验投票状态和选项后写入
+func (s *service) vote(req *VoteRequest) (*poll, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.load(req.ConversationID, req.MessageID, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	closure, err := s.getClosure(p.msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if closure != nil || isPastDeadline(p.content, now) {
+		return nil, ErrPollClosed
+	}
+	if err := validateOptions(p.content, req.Options); err != nil {
+		return nil, err
+	}
+	if err := s.dal.Poll().SetVotes(p.msg.ID, req.UserID, req.Options, now); err != nil {
+		return nil, fmt.Errorf("set votes: %w", err)
+	}
+	return p, nil
+}
+
+// Close 关闭投票
+func (s *service) Close(conversationID int64, messageID int64, userID int64) (*models.PollResults, error) {
+	p, changed, err := s.close(conversationID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	// 已关闭，结果不变
+	if !changed {
+		return s.results(p, userID)
+	}
+	return s.publish(p, userID)
+}
+
+// close 记录关闭，返回是否为本次关闭
+func (s *service) close(conversationID int64, messageID int64, userID int64) (*poll, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, err := s.load(conversationID, messageID, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if p.msg.SenderID != userID {
+		return nil, false, ErrAccessDenied
+	}
+	err = s.dal.Poll().Close(&models.PollClosure{
+		MessageID: p.msg.ID,
+		ClosedBy:  userID,
+		ClosedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		if dal.IsDuplicate(err) {
+			return p, false, nil
+		}
+		return nil, false, fmt.Errorf("close poll: %w", err)
+	}
+	return p, true, nil
+}
+
+// GetResults 获取投票结果
+func (s *service) GetResults(conversationID int64, messageID int64, userID int64) (*models.PollResults, error) {
+	p, err := s.load(conversationID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.results(p, userID)
+}
+
+// load 加载用户可见的投票消息并校验参与者身份
+// 已撤回、已过期、被用户删除、清空历史或入群前的投票均视为不存在
+func (s *service) load(conversationID int64, messageID int64, userID int64) (*poll, error) {
+	msg, err := s.dal.Message().GetVisibleByID(messageID, userID)
+	if err != nil {
+		if dal.IsNotFound(err) {
+			return nil, ErrPollNotFound
+		}
+		return nil, fmt.Errorf("get message: %w", err)
+	}
+	if msg.Type != models.MessageTypePoll || (conversationID != 0 && msg.ConversationID != conversationID) {
+		return nil, ErrPollNotFound
+	}
+
+	participants, err := s.msgSvc.GetParticipantIDs(msg.ConversationID)
+	if err != nil {
+		return nil, ErrPollNotFound
+	}
+	isMember := false
+	for _, uid := range participants {
+		if uid == userID {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		return nil, ErrAccessDenied
+	}
+
+	var content models.PollContent
+	if err := json.Unmarshal([]byte(msg.Content), &content); err != nil {
+		return nil, fmt.Errorf("decode poll: %w", err)
+	}
+	return &poll{msg: msg, content: &content, participants: participants}, nil
+}
+
+// getClosure 获取关闭记录，未关闭时返回nil
+func (s *service) getClosure(messageID int64) (*models.PollClosure, error) {
+	closure, err := s.dal.Poll().GetClosure(messageID)
+	if err != nil {
+		if dal.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return closure, nil
+}
+
+// results 统计投票结果，userID 非0时填充其选择
+func (s *service) results(p *poll, userID int64) (*models.PollResults, error) {
+	votes, err := s.dal.Poll().GetVotes(p.msg.ID)
+	if err != nil {
+		return nil, err
+	}
+	closure, err := s.getClosure(p.msg.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &models.PollResults{
+		MessageID:      p.msg.ID,
+		ConversationID: p.msg.ConversationID,
+		Question:       p.content.Question,
+		Multiple:       p.content.Multiple,
+		Anonymous:      p.content.Anonymous,
+		Deadline:       p.content.Deadline,
+		Options:        make([]*models.PollOptionResult, len(p.content.Options)),
+	}
+	for i, text := range p.content.Options {
+		r.Options[i] = &models.PollOptionResult{Index: i, Text: text}
+	}
+	if userID != 0 {
+		r.MyVotes = []int{}
+	}
+
+	voters := make(map[int64]bool)
+	for _, v := range votes {
+		if v.OptionIndex < 0 || v.OptionIndex >= len(r.Options) {
+			continue
+		}
+		option := r.Options[v.OptionIndex]
+		option.Count++
+		if !p.content.Anonymous {
+			option.Voters = append(option.Voters, v.UserID)
+		}
+		voters[v.UserID] = true
+		if v.UserID == userID {
+			r.MyVotes = append(r.MyVotes, v.OptionIndex)
+		}
+	}
+	r.TotalVoters = len(voters)
+
+	if closure != nil {
+		r.Closed = true
+		r.ClosedAt = closure.ClosedAt
+	} else if isPastDeadline(p.content, time.Now().Unix()) {
+		r.Closed = true
+		r.ClosedAt = p.content.Deadline
+	}
+	return r, nil
+}
+
+// publish 将最新结果推送给全部参与者，并返回包含操作者选择的结果
+func (s *service) publish(p *poll, userID int64) (*models.PollResults, error) {
+	shared, err := s.results(p, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, uid := range p.participants {
+		s.hub.Publish(uid, &hub.Event{Type: EventPollUpdated, Data: shared})
+	}
+
+	return s.results(p, userID)
+}
+
+// isPastDeadline 是否已过截止时间
+func isPastDeadline(content *models.PollContent, now int64) bool {
+	return content.Deadline > 0 && now >= content.Deadline
+}
+
+// validateOptions 校验选项下标
+func validateOptions(content *models.PollContent, options []int) error {
+	if len(options) > 1 && !content.Multiple {
+		return ErrInvalidVote
+	}
+	seen := make(map[int]bool, len(options))
+	for _, option := range options {
+		if option < 0 || option >= len(content.Options) || seen[option] {
+			return ErrInvalidVote
+		}
+		seen[option] = true
+	}
+	return nil
+}
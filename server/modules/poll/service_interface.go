@@ -0,0 +1,29 @@
+package poll
+
+import (
+	"zmessage/server/models"
+)
+
+// VoteRequest 投票请求
+type VoteRequest struct {
+	UserID         int64 `json:"user_id"`
+	ConversationID int64 `json:"conversation_id"` // 所属会话（为0时不校验）
+	MessageID      int64 `json:"message_id"`      // 投票消息ID
+	Options        []int `json:"options"`         // 选项下标，为空时撤回投票
+}
+
+// EventPollUpdated 投票结果变化的实时事件类型，数据为 *models.PollResults（不含个人选择）
+const EventPollUpdated = "poll_updated"
+
+// Service 投票服务接口
+// 投票本身是一条 poll 类型的消息，由消息服务发送；本服务负责投票、关闭和统计结果
+type Service interface {
+	// Vote 投票（再次投票覆盖之前的选择），返回最新结果
+	Vote(req *VoteRequest) (*models.PollResults, error)
+
+	// Close 关闭投票（仅发起者），之后结果冻结；重复关闭不报错
+	Close(conversationID int64, messageID int64, userID int64) (*models.PollResults, error)
+
+	// GetResults 获取投票结果
+	GetResults(conversationID int64, messageID int64, userID int64) (*models.PollResults, error)
+}
@@ -0,0 +1,254 @@
+package poll
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/message"
+)
+
+// published 发布到事件中心的投票结果
+type published struct {
+	userID  int64
+	results *models.PollResults
+}
+
+// subscribe 订阅投票结果事件
+func subscribe(h hub.Hub) chan published {
+	events := make(chan published, 10)
+	h.Subscribe(hub.AllUsers, []string{EventPollUpdated}, func(userID int64, e *hub.Event) bool {
+		select {
+		case events <- published{userID, e.Data.(*models.PollResults)}:
+			return true
+		default:
+			return false
+		}
+	})
+	return events
+}
+
+func setupTestService(t *testing.T) (Service, hub.Hub, message.Service, dal.Manager, int64, []*models.User) {
+	t.Helper()
+
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	users := make([]*models.User, 0, 4)
+	for i := 0; i < 4; i++ {
+		u := &models.User{
+			Username:     fmt.Sprintf("user%d", i),
+			PasswordHash: "hash",
+			Nickname:     fmt.Sprintf("User %d", i),
+			CreatedAt:    time.Now().Unix(),
+			LastSeen:     time.Now().Unix(),
+		}
+		if err := mgr.User().Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	// 前三个用户组成群聊
+	conv := &models.Conversation{Type: models.ConversationTypeGroup, Title: "lunch", CreatedBy: users[0].ID, CreatedAt: time.Now().Unix(), UpdatedAt: time.Now().Unix()}
+	if err := mgr.Conversation().CreateGroup(conv, []int64{users[0].ID, users[1].ID, users[2].ID}); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	h := hub.New()
+	msgSvc := message.NewService(mgr)
+	return NewService(mgr, msgSvc, WithHub(h)), h, msgSvc, mgr, conv.ID, users
+}
+
+// sendPoll 发送投票消息
+func sendPoll(t *testing.T, msgSvc message.Service, convID int64, from int64, content string) *models.Message {
+	t.Helper()
+	msg, err := msgSvc.SendMessage(&message.SendMessageRequest{From: from, ConversationID: convID, Type: models.MessageTypePoll, Content: content})
+	if err != nil {
+		t.Fatalf("send poll failed: %v", err)
+	}
+	return msg
+}
+
+func TestService_Vote(t *testing.T) {
+	svc, h, msgSvc, _, convID, users := setupTestService(t)
+
+	events := subscribe(h)
+
+	single := sendPoll(t, msgSvc, convID, users[0].ID, `{"question": "Lunch?", "options": ["noodles", "pizza", "salad"]}`)
+
+	results, err := svc.Vote(&VoteRequest{UserID: users[1].ID, ConversationID: convID, MessageID: single.ID, Options: []int{1}})
+	if err != nil {
+		t.Fatalf("vote failed: %v", err)
+	}
+	if results.TotalVoters != 1 || results.Options[1].Count != 1 || len(results.MyVotes) != 1 || results.MyVotes[0] != 1 {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if len(results.Options[1].Voters) != 1 || results.Options[1].Voters[0] != users[1].ID {
+		t.Errorf("expected voter to be visible in a public poll")
+	}
+
+	// 推送给全部参与者，不含个人选择
+	recipients := make(map[int64]bool)
+	for i := 0; i < 3; i++ {
+		p := <-events
+		recipients[p.userID] = true
+		if p.results.MyVotes != nil || p.results.Options[1].Count != 1 {
+			t.Errorf("unexpected event: %+v", p.results)
+		}
+	}
+	if len(recipients) != 3 || len(events) != 0 {
+		t.Errorf("expected results pushed to 3 participants, got %v", recipients)
+	}
+
+	// 再次投票覆盖之前的选择
+	svc.Vote(&VoteRequest{UserID: users[1].ID, MessageID: single.ID, Options: []int{2}})
+	results, _ = svc.GetResults(convID, single.ID, users[2].ID)
+	if results.Options[1].Count != 0 || results.Options[2].Count != 1 || len(results.MyVotes) != 0 {
+		t.Errorf("expected vote to be replaced, got %+v", results)
+	}
+
+	// 单选不能选多项，选项须在范围内
+	if _, err := svc.Vote(&VoteRequest{UserID: users[2].ID, MessageID: single.ID, Options: []int{0, 1}}); err != ErrInvalidVote {
+		t.Errorf("expected ErrInvalidVote for multiple choices, got: %v", err)
+	}
+	if _, err := svc.Vote(&VoteRequest{UserID: users[2].ID, MessageID: single.ID, Options: []int{3}}); err != ErrInvalidVote {
+		t.Errorf("expected ErrInvalidVote for out-of-range option, got: %v", err)
+	}
+
+	// 非参与者不能投票或查看
+	if _, err := svc.Vote(&VoteRequest{UserID: users[3].ID, MessageID: single.ID, Options: []int{0}}); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+	if _, err := svc.GetResults(convID, single.ID, users[3].ID); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	// 普通消息不是投票
+	text, _ := msgSvc.SendMessage(&message.SendMessageRequest{From: users[0].ID, ConversationID: convID, Type: "text", Content: "hi"})
+	if _, err := svc.GetResults(convID, text.ID, users[0].ID); err != ErrPollNotFound {
+		t.Errorf("expected ErrPollNotFound, got: %v", err)
+	}
+
+	// 多选匿名投票
+	multi := sendPoll(t, msgSvc, convID, users[0].ID, `{"question": "Which days?", "options": ["Mon", "Tue", "Wed"], "multiple": true, "anonymous": true}`)
+	results, err = svc.Vote(&VoteRequest{UserID: users[1].ID, MessageID: multi.ID, Options: []int{0, 2}})
+	if err != nil {
+		t.Fatalf("multiple vote failed: %v", err)
+	}
+	svc.Vote(&VoteRequest{UserID: users[2].ID, MessageID: multi.ID, Options: []int{2}})
+	results, _ = svc.GetResults(convID, multi.ID, users[1].ID)
+	if results.TotalVoters != 2 || results.Options[0].Count != 1 || results.Options[2].Count != 2 || len(results.MyVotes) != 2 {
+		t.Errorf("unexpected multiple results: %+v", results)
+	}
+	for _, o := range results.Options {
+		if len(o.Voters) != 0 {
+			t.Errorf("expected anonymous poll to hide voters")
+		}
+	}
+
+	// 空选项撤回投票
+	results, _ = svc.Vote(&VoteRequest{UserID: users[1].ID, MessageID: multi.ID})
+	if results.TotalVoters != 1 || len(results.MyVotes) != 0 {
+		t.Errorf("expected vote retracted, got %+v", results)
+	}
+}
+
+func TestService_Visibility(t *testing.T) {
+	svc, _, msgSvc, mgr, convID, users := setupTestService(t)
+
+	p := sendPoll(t, msgSvc, convID, users[0].ID, `{"question": "Lunch?", "options": ["noodles", "pizza"]}`)
+	svc.Vote(&VoteRequest{UserID: users[0].ID, MessageID: p.ID, Options: []int{0}})
+
+	// 投票发出后才入群的成员看不到该投票
+	late := &models.ConversationMember{ConversationID: convID, UserID: users[3].ID, Role: models.MemberRoleMember, JoinedAt: time.Now().Unix()}
+	if err := mgr.ConversationMember().Add(late); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+
+	// 为自己删除或清空历史后同样不可见
+	if _, err := msgSvc.DeleteMessageForMe(convID, p.ID, users[1].ID); err != nil {
+		t.Fatalf("delete for me failed: %v", err)
+	}
+	if _, err := msgSvc.ClearHistory(convID, users[2].ID, 0); err != nil {
+		t.Fatalf("clear history failed: %v", err)
+	}
+
+	for _, u := range users[1:] {
+		if _, err := svc.GetResults(convID, p.ID, u.ID); err != ErrPollNotFound {
+			t.Errorf("expected ErrPollNotFound for user %d, got: %v", u.ID, err)
+		}
+		if _, err := svc.Vote(&VoteRequest{UserID: u.ID, MessageID: p.ID, Options: []int{1}}); err != ErrPollNotFound {
+			t.Errorf("expected vote by user %d to be rejected, got: %v", u.ID, err)
+		}
+	}
+	if results, err := svc.GetResults(convID, p.ID, users[0].ID); err != nil || results.TotalVoters != 1 {
+		t.Errorf("expected sender to still see the poll, got %+v, %v", results, err)
+	}
+}
+
+func TestService_Close(t *testing.T) {
+	svc, h, msgSvc, mgr, convID, users := setupTestService(t)
+
+	p := sendPoll(t, msgSvc, convID, users[0].ID, `{"question": "Ship it?", "options": ["yes", "no"]}`)
+	svc.Vote(&VoteRequest{UserID: users[1].ID, MessageID: p.ID, Options: []int{0}})
+
+	// 只有发起者可以关闭
+	if _, err := svc.Close(convID, p.ID, users[1].ID); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	events := subscribe(h)
+
+	results, err := svc.Close(convID, p.ID, users[0].ID)
+	if err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if !results.Closed || results.ClosedAt == 0 || results.Options[0].Count != 1 {
+		t.Errorf("unexpected closed results: %+v", results)
+	}
+	for i := 0; i < 3; i++ {
+		if p := <-events; !p.results.Closed {
+			t.Errorf("expected close to be pushed")
+		}
+	}
+
+	// 关闭后结果冻结
+	if _, err := svc.Vote(&VoteRequest{UserID: users[2].ID, MessageID: p.ID, Options: []int{1}}); err != ErrPollClosed {
+		t.Errorf("expected ErrPollClosed, got: %v", err)
+	}
+	if _, err := svc.Vote(&VoteRequest{UserID: users[1].ID, MessageID: p.ID}); err != ErrPollClosed {
+		t.Errorf("expected ErrPollClosed for retraction, got: %v", err)
+	}
+
+	// 重复关闭不报错，也不重复推送
+	again, err := svc.Close(convID, p.ID, users[0].ID)
+	if err != nil || again.ClosedAt != results.ClosedAt {
+		t.Errorf("expected repeated close to be a no-op, got %+v, %v", again, err)
+	}
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event: %+v", e)
+	default:
+	}
+
+	// 截止时间已过的投票视为已关闭
+	deadline := sendPoll(t, msgSvc, convID, users[0].ID, fmt.Sprintf(`{"question": "Soon?", "options": ["a", "b"], "deadline": %d}`, time.Now().Unix()+3600))
+	if _, err := svc.Vote(&VoteRequest{UserID: users[1].ID, MessageID: deadline.ID, Options: []int{1}}); err != nil {
+		t.Fatalf("vote before deadline failed: %v", err)
+	}
+	past := time.Now().Unix() - 1
+	mgr.DB().Exec(`UPDATE messages SET content = ? WHERE id = ?`, fmt.Sprintf(`{"question": "Soon?", "options": ["a", "b"], "deadline": %d}`, past), deadline.ID)
+	if _, err := svc.Vote(&VoteRequest{UserID: users[2].ID, MessageID: deadline.ID, Options: []int{0}}); err != ErrPollClosed {
+		t.Errorf("expected ErrPollClosed after deadline, got: %v", err)
+	}
+	results, _ = svc.GetResults(convID, deadline.ID, users[1].ID)
+	if !results.Closed || results.ClosedAt != past || results.Options[1].Count != 1 {
+		t.Errorf("expected results frozen at deadline, got %+v", results)
+	}
+}
@@ -0,0 +1,19 @@
+package schedule
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrScheduledNotFound 定时消息不存在
+	ErrScheduledNotFound = fmt.Errorf("scheduled message not found")
+
+	// ErrNotPending 定时消息已发送或已取消，不能再修改
+	ErrNotPending = fmt.Errorf("scheduled message is not pending")
+
+	// ErrInvalidSendTime 无效的发送时间
+	ErrInvalidSendTime = fmt.Errorf("invalid send time")
+
+	// ErrInvalidContent 无效的消息内容
+	ErrInvalidContent = fmt.Errorf("invalid message content")
+)
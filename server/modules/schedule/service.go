@@ -0,0 +1,300 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/models"
+	"zmessage/server/modules/message"
+)
+
+// DefaultInterval 默认调度间隔
+const DefaultInterval = time.Second
+
+// DispatchBatchSize 每次调度最多处理的消息数
+const DispatchBatchSize = 100
+
+// MaxScheduleAhead 最多可提前预约的时长
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+// Option 定时消息服务配置项
+type Option func(*service)
+
+// WithInterval 设置调度间隔
+func WithInterval(d time.Duration) Option {
+	return func(s *service) {
+		s.interval = d
+	}
+}
+
+// NewService 创建定时消息服务
+func NewService(dalMgr dal.Manager, msgSvc message.Service, opts ...Option) Service {
+	s := &service{
+		dal:      dalMgr,
+		msgSvc:   msgSvc,
+		interval: DefaultInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// service 定时消息服务实现
+type service struct {
+	dal      dal.Manager
+	msgSvc   message.Service
+	interval time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Schedule 创建定时消息
+func (s *service) Schedule(userID int64, req *ScheduleRequest) (*models.ScheduledMessage, error) {
+	// 与发送消息一样校验内容，避免到期发送时才失败
+	if err := message.ValidateContent(req.Type, req.Content); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := validateSendAt(req.SendAt, now); err != nil {
+		return nil, err
+	}
+
+	// 与发送消息一样校验参与者身份和引用的消息
+	if _, err := s.msgSvc.GetConversation(req.ConversationID, userID); err != nil {
+		return nil, err
+	}
+	if req.ReplyToID > 0 {
+		quoted, err := s.dal.Message().GetVisibleByID(req.ReplyToID, userID)
+		if err != nil || quoted.ConversationID != req.ConversationID {
+			return nil, message.ErrInvalidReply
+		}
+	}
+
+	sm := &models.ScheduledMessage{
+		SenderID:       userID,
+		ConversationID: req.ConversationID,
+		Type:           req.Type,
+		Content:        req.Content,
+		ReplyToID:      req.ReplyToID,
+		SendAt:         req.SendAt,
+		Status:         models.ScheduledStatusPending,
+		CreatedAt:      now.Unix(),
+		UpdatedAt:      now.Unix(),
+	}
+	if err := s.dal.ScheduledMessage().Create(sm); err != nil {
+		return nil, fmt.Errorf("create scheduled message: %w", err)
+	}
+	return sm, nil
+}
+
+// List 获取用户的定时消息
+func (s *service) List(userID int64, status string) ([]*models.ScheduledMessage, error) {
+	list, err := s.dal.ScheduledMessage().GetBySender(userID, status)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = []*models.ScheduledMessage{}
+	}
+	return list, nil
+}
+
+// Update 修改待发送的定时消息
+func (s *service) Update(id int64, userID int64, req *UpdateRequest) (*models.ScheduledMessage, error) {
+	sm, err := s.getOwned(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if sm.Status != models.ScheduledStatusPending {
+		return nil, ErrNotPending
+	}
+
+	now := time.Now()
+	if req.Type != nil {
+		if *req.Type == "" {
+			return nil, ErrInvalidContent
+		}
+		sm.Type = *req.Type
+	}
+	if req.Content != nil {
+		if strings.TrimSpace(*req.Content) == "" {
+			return nil, ErrInvalidContent
+		}
+		sm.Content = *req.Content
+	}
+	if err := message.ValidateContent(sm.Type, sm.Content); err != nil {
+		return nil, err
+	}
+	if req.SendAt != nil {
+		if err := validateSendAt(*req.SendAt, now); err != nil {
+			return nil, err
+		}
+		sm.SendAt = *req.SendAt
+	}
+	sm.UpdatedAt = now.Unix()
+
+	if err := s.dal.ScheduledMessage().Update(sm); err != nil {
+		// 调度器已认领
+		if dal.IsNotFound(err) {
+			return nil, ErrNotPending
+		}
+		return nil, err
+	}
+	return sm, nil
+}
+
+// Cancel 取消待发送的定时消息
+func (s *service) Cancel(id int64, userID int64) error {
+	sm, err := s.getOwned(id, userID)
+	if err != nil {
+		return err
+	}
+	if sm.Status != models.ScheduledStatusPending {
+		return ErrNotPending
+	}
+
+	if err := s.dal.ScheduledMessage().Cancel(id, time.Now().Unix()); err != nil {
+		if dal.IsNotFound(err) {
+			return ErrNotPending
+		}
+		return err
+	}
+	return nil
+}
+
+// DispatchDue 发送已到期的定时消息
+// 认领后才发送，发送时以定时消息ID作为客户端消息ID，中断后重新发送不会产生重复消息
+func (s *service) DispatchDue() (int, error) {
+	now := time.Now().Unix()
+	due, err := s.dal.ScheduledMessage().GetDue(now, DispatchBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("get due scheduled messages: %w", err)
+	}
+
+	count := 0
+	for _, sm := range due {
+		if err := s.dal.ScheduledMessage().Claim(sm.ID, now); err != nil {
+			// 已被取消
+			if dal.IsNotFound(err) {
+				continue
+			}
+			return count, err
+		}
+
+		msg, err := s.msgSvc.SendMessage(&message.SendMessageRequest{
+			From:           sm.SenderID,
+			ConversationID: sm.ConversationID,
+			Type:           sm.Type,
+			Content:        sm.Content,
+			ReplyToID:      sm.ReplyToID,
+			ClientMsgID:    fmt.Sprintf("scheduled-%d", sm.ID),
+			KeepDraft:      true, // 到期发送与用户当前的输入无关
+		})
+		if err != nil {
+			if !isPermanent(err) {
+				// 保持发送中状态，下次调度重试
+				return count, fmt.Errorf("send scheduled message %d: %w", sm.ID, err)
+			}
+			if err := s.dal.ScheduledMessage().MarkFailed(sm.ID, err.Error(), time.Now().Unix()); err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
+
+		if err := s.dal.ScheduledMessage().MarkSent(sm.ID, msg.ID, time.Now().Unix()); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Start 启动后台调度
+func (s *service) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run(s.stop, s.done)
+}
+
+// Stop 停止后台调度
+func (s *service) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// run 调度循环
+func (s *service) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		// 首次进入时立即执行，补发停机期间到期的消息
+		if _, err := s.DispatchDue(); err != nil {
+			log.Printf("定时消息发送失败: %v", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getOwned 获取属于用户的定时消息
+func (s *service) getOwned(id int64, userID int64) (*models.ScheduledMessage, error) {
+	sm, err := s.dal.ScheduledMessage().GetByID(id)
+	if err != nil {
+		if dal.IsNotFound(err) {
+			return nil, ErrScheduledNotFound
+		}
+		return nil, err
+	}
+	if sm.SenderID != userID {
+		return nil, ErrScheduledNotFound
+	}
+	return sm, nil
+}
+
+// validateSendAt 校验计划发送时间
+func validateSendAt(sendAt int64, now time.Time) error {
+	if sendAt <= now.Unix() || sendAt > now.Add(MaxScheduleAhead).Unix() {
+		return ErrInvalidSendTime
+	}
+	return nil
+}
+
+// isPermanent 判断发送错误是否无法通过重试恢复（如已不是会话成员、内容不合法）
+func isPermanent(err error) bool {
+	switch err {
+	case message.ErrInvalidMessageType, message.ErrInvalidMessageContent, message.ErrConversationNotFound,
+		message.ErrUserNotFound, message.ErrAccessDenied, message.ErrInvalidReply, message.ErrInvalidClientMsgID:
+		return true
+	}
+	return false
+}
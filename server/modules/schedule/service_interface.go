@@ -0,0 +1,45 @@
+package schedule
+
+import (
+	"zmessage/server/models"
+)
+
+// ScheduleRequest 创建定时消息请求
+type ScheduleRequest struct {
+	ConversationID int64  `json:"conversation_id"`
+	Type           string `json:"type"`
+	Content        string `json:"content"`
+	ReplyToID      int64  `json:"reply_to_id"`
+	SendAt         int64  `json:"send_at"` // 计划发送时间（Unix秒，必须晚于当前时间）
+}
+
+// UpdateRequest 修改定时消息请求（未提供的字段保持不变）
+type UpdateRequest struct {
+	Type    *string `json:"type"`
+	Content *string `json:"content"`
+	SendAt  *int64  `json:"send_at"`
+}
+
+// Service 定时消息服务接口
+type Service interface {
+	// Schedule 创建定时消息（须是会话参与者）
+	Schedule(userID int64, req *ScheduleRequest) (*models.ScheduledMessage, error)
+
+	// List 获取用户的定时消息（status 为空时返回全部）
+	List(userID int64, status string) ([]*models.ScheduledMessage, error)
+
+	// Update 修改待发送的定时消息
+	Update(id int64, userID int64, req *UpdateRequest) (*models.ScheduledMessage, error)
+
+	// Cancel 取消待发送的定时消息
+	Cancel(id int64, userID int64) error
+
+	// DispatchDue 发送已到期的定时消息，返回本次处理的数量
+	DispatchDue() (int, error)
+
+	// Start 启动后台调度（启动时立即补发停机期间到期的消息）
+	Start()
+
+	// Stop 停止后台调度
+	Stop()
+}
@@ -0,0 +1,214 @@
+package schedule
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/models"
+	"zmessage/server/modules/message"
+)
+
+func setupTestService(t *testing.T) (Service, dal.Manager, message.Service, int64, []*models.User) {
+	t.Helper()
+
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	users := make([]*models.User, 0, 3)
+	for i := 0; i < 3; i++ {
+		u := &models.User{
+			Username:     fmt.Sprintf("user%d", i),
+			PasswordHash: "hash",
+			Nickname:     fmt.Sprintf("User %d", i),
+			CreatedAt:    time.Now().Unix(),
+			LastSeen:     time.Now().Unix(),
+		}
+		if err := mgr.User().Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	msgSvc := message.NewService(mgr)
+	conv, err := msgSvc.GetConversationWithUser(users[0].ID, users[1].ID)
+	if err != nil {
+		t.Fatalf("create conversation: %v", err)
+	}
+
+	return NewService(mgr, msgSvc, WithInterval(10*time.Millisecond)), mgr, msgSvc, conv.ID, users
+}
+
+// makeDue 将定时消息的发送时间改到过去
+func makeDue(t *testing.T, mgr dal.Manager, id int64) {
+	t.Helper()
+	if _, err := mgr.DB().Exec(`UPDATE scheduled_messages SET send_at = ? WHERE id = ?`, time.Now().Unix()-60, id); err != nil {
+		t.Fatalf("make due: %v", err)
+	}
+}
+
+func TestService_ScheduleUpdateCancel(t *testing.T) {
+	svc, _, _, convID, users := setupTestService(t)
+	sendAt := time.Now().Add(time.Hour).Unix()
+
+	sm, err := svc.Schedule(users[0].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "Good morning", SendAt: sendAt})
+	if err != nil {
+		t.Fatalf("schedule failed: %v", err)
+	}
+	if sm.Status != models.ScheduledStatusPending {
+		t.Errorf("expected pending, got %s", sm.Status)
+	}
+
+	// 过去的时间
+	_, err = svc.Schedule(users[0].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "Hi", SendAt: time.Now().Unix() - 1})
+	if err != ErrInvalidSendTime {
+		t.Errorf("expected ErrInvalidSendTime, got: %v", err)
+	}
+
+	// 非参与者
+	_, err = svc.Schedule(users[2].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "Hi", SendAt: sendAt})
+	if err != message.ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+
+	// 内容和引用与发送消息一样校验
+	for _, tt := range []struct {
+		req  *ScheduleRequest
+		want error
+	}{
+		{&ScheduleRequest{ConversationID: convID, Type: "unknown", Content: "Hi", SendAt: sendAt}, message.ErrInvalidMessageType},
+		{&ScheduleRequest{ConversationID: convID, Type: "file", Content: "{bad", SendAt: sendAt}, message.ErrInvalidMessageContent},
+		{&ScheduleRequest{ConversationID: convID, Type: "image", Content: "-1", SendAt: sendAt}, message.ErrInvalidMessageContent},
+		{&ScheduleRequest{ConversationID: convID, Type: "text", Content: "Hi", ReplyToID: 99999, SendAt: sendAt}, message.ErrInvalidReply},
+	} {
+		if _, err := svc.Schedule(users[0].ID, tt.req); err != tt.want {
+			t.Errorf("expected %v for %+v, got: %v", tt.want, tt.req, err)
+		}
+	}
+	badType := "unknown"
+	if _, err := svc.Update(sm.ID, users[0].ID, &UpdateRequest{Type: &badType}); err != message.ErrInvalidMessageType {
+		t.Errorf("expected ErrInvalidMessageType on update, got: %v", err)
+	}
+
+	// 修改内容和时间
+	content := "Good morning!"
+	later := sendAt + 60
+	updated, err := svc.Update(sm.ID, users[0].ID, &UpdateRequest{Content: &content, SendAt: &later})
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if updated.Content != content || updated.SendAt != later {
+		t.Errorf("unexpected updated message: %+v", updated)
+	}
+
+	// 其他用户看不到
+	if _, err := svc.Update(sm.ID, users[1].ID, &UpdateRequest{Content: &content}); err != ErrScheduledNotFound {
+		t.Errorf("expected ErrScheduledNotFound, got: %v", err)
+	}
+
+	list, _ := svc.List(users[0].ID, models.ScheduledStatusPending)
+	if len(list) != 1 {
+		t.Errorf("expected 1 pending message, got %d", len(list))
+	}
+
+	if err := svc.Cancel(sm.ID, users[0].ID); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if err := svc.Cancel(sm.ID, users[0].ID); err != ErrNotPending {
+		t.Errorf("expected ErrNotPending, got: %v", err)
+	}
+	list, _ = svc.List(users[0].ID, models.ScheduledStatusPending)
+	if len(list) != 0 {
+		t.Errorf("expected no pending messages, got %d", len(list))
+	}
+}
+
+func TestService_DispatchDue(t *testing.T) {
+	svc, mgr, msgSvc, convID, users := setupTestService(t)
+	sendAt := time.Now().Add(time.Hour).Unix()
+
+	due, _ := svc.Schedule(users[0].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "due", SendAt: sendAt})
+	future, _ := svc.Schedule(users[0].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "future", SendAt: sendAt})
+	// 发送时失败的定时消息（如旧版本接受的未知类型）
+	invalid := &models.ScheduledMessage{SenderID: users[0].ID, ConversationID: convID, Type: "unknown", Content: "bad", SendAt: sendAt, Status: models.ScheduledStatusPending}
+	if err := mgr.ScheduledMessage().Create(invalid); err != nil {
+		t.Fatalf("create scheduled message: %v", err)
+	}
+	makeDue(t, mgr, due.ID)
+	makeDue(t, mgr, invalid.ID)
+
+	count, err := svc.DispatchDue()
+	if err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 dispatched, got %d", count)
+	}
+
+	sent, _ := mgr.ScheduledMessage().GetByID(due.ID)
+	if sent.Status != models.ScheduledStatusSent || sent.MessageID == 0 {
+		t.Errorf("expected sent with message id, got %+v", sent)
+	}
+	failed, _ := mgr.ScheduledMessage().GetByID(invalid.ID)
+	if failed.Status != models.ScheduledStatusFailed || failed.Error == "" {
+		t.Errorf("expected failed with reason, got %+v", failed)
+	}
+	pending, _ := mgr.ScheduledMessage().GetByID(future.ID)
+	if pending.Status != models.ScheduledStatusPending {
+		t.Errorf("expected future message still pending, got %s", pending.Status)
+	}
+
+	// 已发送的不能再修改
+	content := "edited"
+	if _, err := svc.Update(due.ID, users[0].ID, &UpdateRequest{Content: &content}); err != ErrNotPending {
+		t.Errorf("expected ErrNotPending, got: %v", err)
+	}
+
+	messages, _, _ := msgSvc.GetMessages(convID, users[1].ID, 0, 10)
+	if len(messages) != 1 || messages[0].Content != "due" || messages[0].SenderID != users[0].ID {
+		t.Errorf("expected the due message to be delivered, got %+v", messages)
+	}
+}
+
+func TestService_CatchUpAfterRestart(t *testing.T) {
+	svc, mgr, msgSvc, convID, users := setupTestService(t)
+	sendAt := time.Now().Add(time.Hour).Unix()
+
+	// 上次运行时已认领并发送，但未来得及标记为已发送
+	interrupted, _ := svc.Schedule(users[0].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "interrupted", SendAt: sendAt})
+	makeDue(t, mgr, interrupted.ID)
+	mgr.ScheduledMessage().Claim(interrupted.ID, time.Now().Unix())
+	msgSvc.SendMessage(&message.SendMessageRequest{From: users[0].ID, ConversationID: convID, Type: "text", Content: "interrupted", ClientMsgID: fmt.Sprintf("scheduled-%d", interrupted.ID)})
+
+	// 停机期间到期
+	missed, _ := svc.Schedule(users[0].ID, &ScheduleRequest{ConversationID: convID, Type: "text", Content: "missed", SendAt: sendAt})
+	makeDue(t, mgr, missed.ID)
+
+	svc.Start()
+	defer svc.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		sm, _ := mgr.ScheduledMessage().GetByID(missed.ID)
+		if sm.Status == models.ScheduledStatusSent {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected missed message to be sent after start, got %s", sm.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sm, _ := mgr.ScheduledMessage().GetByID(interrupted.ID)
+	if sm.Status != models.ScheduledStatusSent {
+		t.Errorf("expected interrupted message to be completed, got %s", sm.Status)
+	}
+
+	messages, _, _ := msgSvc.GetMessages(convID, users[1].ID, 0, 10)
+	if len(messages) != 2 {
+		t.Errorf("expected 2 messages without duplicates, got %d", len(messages))
+	}
+}
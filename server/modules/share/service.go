@@ -50,8 +50,8 @@ func (s *service) CreateShare(req *models.ShareRequest, creatorID int64) (*model
 		return nil, fmt.Errorf("conversation not found: %w", err)
 	}
 
-	// 检查用户是否是会话参与者
-	if conv.UserAID != creatorID && conv.UserBID != creatorID {
+	// 检查用户是否是会话参与者（单聊和群聊均登记在成员表中）
+	if _, err := s.dalMgr.ConversationMember().Get(conv.ID, creatorID); err != nil {
 		return nil, fmt.Errorf("access denied: not a participant")
 	}
 
@@ -208,18 +208,24 @@ func (s *service) ListShares(userID int64, page, limit int) ([]*models.ShareList
 			continue
 		}
 
-		// 确定对方用户
-		var otherUserID int64
-		if conv.UserAID == userID {
-			otherUserID = conv.UserBID
+		// 确定参与方：单聊为对方用户，群聊为群本身
+		var participant models.ParticipantInfo
+		if conv.IsGroup() {
+			participant = models.ParticipantInfo{ID: conv.ID, Nickname: conv.Title}
 		} else {
-			otherUserID = conv.UserAID
-		}
-
-		// 获取对方用户信息
-		otherUser, err := s.dalMgr.User().GetByID(otherUserID)
-		if err != nil {
-			continue
+			var otherUserID int64
+			if conv.UserAID == userID {
+				otherUserID = conv.UserBID
+			} else {
+				otherUserID = conv.UserAID
+			}
+
+			// 获取对方用户信息
+			otherUser, err := s.dalMgr.User().GetByID(otherUserID)
+			if err != nil {
+				continue
+			}
+			participant = models.ParticipantInfo{ID: otherUser.ID, Nickname: otherUser.Nickname}
 		}
 
 		// 检查是否过期
@@ -230,10 +236,7 @@ func (s *service) ListShares(userID int64, page, limit int) ([]*models.ShareList
 			ShareToken:     sc.ShareToken,
 			ShareURL:       fmt.Sprintf("/shared/%s", sc.ShareToken),
 			ConversationID: sc.ConversationID,
-			Participant: models.ParticipantInfo{
-				ID:       otherUser.ID,
-				Nickname: otherUser.Nickname,
-			},
+			Participant:    participant,
 			MessageCount: sc.MessageCount,
 			ExpireAt:     sc.ExpireAt,
 			CreatedAt:    sc.CreatedAt,
@@ -272,19 +275,18 @@ func (s *service) buildShareDetail(sc *models.SharedConversation) (*models.Share
 	}
 
 	// 获取参与者信息
-	userA, err := s.dalMgr.User().GetByID(conv.UserAID)
+	members, err := s.dalMgr.ConversationMember().GetByConversation(conv.ID)
 	if err != nil {
-		return nil, fmt.Errorf("get user A: %w", err)
+		return nil, fmt.Errorf("get members: %w", err)
 	}
 
-	userB, err := s.dalMgr.User().GetByID(conv.UserBID)
-	if err != nil {
-		return nil, fmt.Errorf("get user B: %w", err)
-	}
-
-	participants := []models.ParticipantInfo{
-		{ID: userA.ID, Nickname: userA.Nickname},
-		{ID: userB.ID, Nickname: userB.Nickname},
+	participants := make([]models.ParticipantInfo, 0, len(members))
+	for _, m := range members {
+		u, err := s.dalMgr.User().GetByID(m.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("get participant: %w", err)
+		}
+		participants = append(participants, models.ParticipantInfo{ID: u.ID, Nickname: u.Nickname})
 	}
 
 	// 获取分享者昵称
@@ -0,0 +1,13 @@
+package typing
+
+import (
+	"fmt"
+)
+
+var (
+	// ErrConversationNotFound 会话不存在
+	ErrConversationNotFound = fmt.Errorf("conversation not found")
+
+	// ErrAccessDenied 不是会话参与者
+	ErrAccessDenied = fmt.Errorf("access denied")
+)
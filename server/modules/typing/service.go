@@ -0,0 +1,175 @@
+package typing
+
+import (
+	"sync"
+	"time"
+
+	"zmessage/server/hub"
+	"zmessage/server/modules/message"
+)
+
+// DefaultTTL 输入状态的默认有效期，客户端应在此时间内重复发送“开始输入”以保持状态
+const DefaultTTL = 6 * time.Second
+
+// Option 输入状态服务配置项
+type Option func(*service)
+
+// WithTTL 设置输入状态有效期
+func WithTTL(ttl time.Duration) Option {
+	return func(s *service) {
+		s.ttl = ttl
+	}
+}
+
+// WithHub 设置实时事件中心
+func WithHub(h hub.Hub) Option {
+	return func(s *service) {
+		s.hub = h
+	}
+}
+
+// NewService 创建输入状态服务
+func NewService(msgSvc message.Service, opts ...Option) Service {
+	s := &service{
+		msgSvc: msgSvc,
+		ttl:    DefaultTTL,
+		hub:    hub.New(),
+		typing: make(map[key]*entry),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// key 会话内的输入者
+type key struct {
+	conversationID int64
+	userID         int64
+}
+
+// entry 正在输入的记录
+type entry struct {
+	timer      *time.Timer // 过期计时器
+	recipients []int64
+}
+
+// service 输入状态服务实现
+type service struct {
+	msgSvc message.Service
+	ttl    time.Duration
+	hub    hub.Hub
+	mu     sync.Mutex
+	typing map[key]*entry
+}
+
+// Start 开始输入
+func (s *service) Start(conversationID int64, userID int64) error {
+	recipients, err := s.recipients(conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	k := key{conversationID, userID}
+	s.mu.Lock()
+	prev, typing := s.typing[k]
+	if typing && prev.timer.Reset(s.ttl) {
+		s.mu.Unlock()
+		return nil
+	}
+	// 计时器已触发时 expire 正在等待锁，替换记录使其失效（接收方尚未收到停止输入，无需再次推送）
+	e := &entry{recipients: recipients}
+	e.timer = time.AfterFunc(s.ttl, func() {
+		s.expire(k, e)
+	})
+	s.typing[k] = e
+	s.mu.Unlock()
+
+	if !typing {
+		s.emit(&Event{ConversationID: conversationID, UserID: userID, Typing: true}, recipients)
+	}
+	return nil
+}
+
+// Stop 停止输入
+func (s *service) Stop(conversationID int64, userID int64) error {
+	recipients, err := s.recipients(conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	k := key{conversationID, userID}
+	s.mu.Lock()
+	e, ok := s.typing[k]
+	if ok {
+		e.timer.Stop()
+		delete(s.typing, k)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		s.emit(&Event{ConversationID: conversationID, UserID: userID, Typing: false}, recipients)
+	}
+	return nil
+}
+
+// GetTyping 获取会话中正在输入的用户
+func (s *service) GetTyping(conversationID int64, userID int64) ([]int64, error) {
+	if _, err := s.recipients(conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var users []int64
+	for k := range s.typing {
+		if k.conversationID == conversationID && k.userID != userID {
+			users = append(users, k.userID)
+		}
+	}
+	return users, nil
+}
+
+// expire 有效期内未刷新，自动停止输入
+func (s *service) expire(k key, e *entry) {
+	s.mu.Lock()
+	// 记录已被 Stop 移除或替换
+	if s.typing[k] != e {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.typing, k)
+	s.mu.Unlock()
+
+	s.emit(&Event{ConversationID: k.conversationID, UserID: k.userID, Typing: false}, e.recipients)
+}
+
+// recipients 校验参与者身份并返回其他参与者
+func (s *service) recipients(conversationID int64, userID int64) ([]int64, error) {
+	participants, err := s.msgSvc.GetParticipantIDs(conversationID)
+	if err != nil {
+		return nil, ErrConversationNotFound
+	}
+
+	isMember := false
+	others := make([]int64, 0, len(participants))
+	for _, uid := range participants {
+		if uid == userID {
+			isMember = true
+			continue
+		}
+		others = append(others, uid)
+	}
+	if !isMember {
+		return nil, ErrAccessDenied
+	}
+	return others, nil
+}
+
+// emit 通过事件中心推送给其他参与者（在锁外调用）
+func (s *service) emit(event *Event, recipients []int64) {
+	for _, uid := range recipients {
+		s.hub.Publish(uid, &hub.Event{Type: EventTyping, Data: event})
+	}
+}
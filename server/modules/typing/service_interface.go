@@ -0,0 +1,24 @@
+package typing
+
+// EventTyping 输入状态变化的实时事件类型，数据为 *Event
+const EventTyping = "typing"
+
+// Event 输入状态变化事件
+type Event struct {
+	ConversationID int64 `json:"conversation_id"`
+	UserID         int64 `json:"user_id"`
+	Typing         bool  `json:"typing"`
+}
+
+// Service 输入状态服务接口
+// 状态只保存在内存中，超过有效期未刷新自动视为停止输入
+type Service interface {
+	// Start 开始输入（重复调用刷新有效期，不重复推送）
+	Start(conversationID int64, userID int64) error
+
+	// Stop 停止输入
+	Stop(conversationID int64, userID int64) error
+
+	// GetTyping 获取会话中正在输入的用户（不含自己）
+	GetTyping(conversationID int64, userID int64) ([]int64, error)
+}
@@ -0,0 +1,154 @@
+package typing
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/message"
+)
+
+// published 发布到事件中心的输入状态事件
+type published struct {
+	userID int64
+	event  *Event
+}
+
+func setupTestService(t *testing.T, ttl time.Duration) (Service, chan published, int64, []*models.User) {
+	t.Helper()
+
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+
+	users := make([]*models.User, 0, 3)
+	for i := 0; i < 3; i++ {
+		u := &models.User{
+			Username:     fmt.Sprintf("user%d", i),
+			PasswordHash: "hash",
+			Nickname:     fmt.Sprintf("User %d", i),
+			CreatedAt:    time.Now().Unix(),
+			LastSeen:     time.Now().Unix(),
+		}
+		if err := mgr.User().Create(u); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, u)
+	}
+
+	msgSvc := message.NewService(mgr)
+	conv, err := msgSvc.GetConversationWithUser(users[0].ID, users[1].ID)
+	if err != nil {
+		t.Fatalf("create conversation: %v", err)
+	}
+
+	h := hub.New()
+	events := make(chan published, 10)
+	h.Subscribe(hub.AllUsers, []string{EventTyping}, func(userID int64, e *hub.Event) bool {
+		events <- published{userID, e.Data.(*Event)}
+		return true
+	})
+
+	return NewService(msgSvc, WithTTL(ttl), WithHub(h)), events, conv.ID, users
+}
+
+func TestService_StartStop(t *testing.T) {
+	svc, events, convID, users := setupTestService(t, time.Minute)
+
+	if err := svc.Start(convID, users[0].ID); err != nil {
+		t.Fatalf("start typing failed: %v", err)
+	}
+	p := <-events
+	if !p.event.Typing || p.event.UserID != users[0].ID || p.userID != users[1].ID {
+		t.Errorf("unexpected start event: %+v to %d", p.event, p.userID)
+	}
+
+	// 刷新不重复推送
+	svc.Start(convID, users[0].ID)
+	if len(events) != 0 {
+		t.Errorf("expected no event on refresh, got %d", len(events))
+	}
+
+	typingUsers, _ := svc.GetTyping(convID, users[1].ID)
+	if len(typingUsers) != 1 || typingUsers[0] != users[0].ID {
+		t.Errorf("expected user0 typing, got %v", typingUsers)
+	}
+
+	if err := svc.Stop(convID, users[0].ID); err != nil {
+		t.Fatalf("stop typing failed: %v", err)
+	}
+	if p := <-events; p.event.Typing {
+		t.Errorf("expected stop event, got %+v", p.event)
+	}
+
+	// 未在输入时停止不推送
+	svc.Stop(convID, users[0].ID)
+	if len(events) != 0 {
+		t.Errorf("expected no event when not typing, got %d", len(events))
+	}
+
+	// 非参与者
+	if err := svc.Start(convID, users[2].ID); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+	if err := svc.Start(99999, users[0].ID); err != ErrConversationNotFound {
+		t.Errorf("expected ErrConversationNotFound, got: %v", err)
+	}
+}
+
+func TestService_Expire(t *testing.T) {
+	svc, events, convID, users := setupTestService(t, 20*time.Millisecond)
+
+	if err := svc.Start(convID, users[1].ID); err != nil {
+		t.Fatalf("start typing failed: %v", err)
+	}
+	<-events
+
+	select {
+	case p := <-events:
+		if p.event.Typing || p.event.UserID != users[1].ID || p.userID != users[0].ID {
+			t.Errorf("unexpected expire event: %+v to %d", p.event, p.userID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected typing to expire")
+	}
+
+	typingUsers, _ := svc.GetTyping(convID, users[0].ID)
+	if len(typingUsers) != 0 {
+		t.Errorf("expected nobody typing after expiry, got %v", typingUsers)
+	}
+}
+
+func TestService_RefreshAfterTimerFired(t *testing.T) {
+	svc, events, convID, users := setupTestService(t, time.Minute)
+
+	if err := svc.Start(convID, users[0].ID); err != nil {
+		t.Fatalf("start typing failed: %v", err)
+	}
+	<-events
+
+	// 模拟计时器已触发、expire 正在等待锁时用户刷新输入状态
+	s := svc.(*service)
+	k := key{convID, users[0].ID}
+	s.mu.Lock()
+	fired := s.typing[k]
+	fired.timer.Stop()
+	s.mu.Unlock()
+
+	if err := svc.Start(convID, users[0].ID); err != nil {
+		t.Fatalf("refresh typing failed: %v", err)
+	}
+	s.expire(k, fired)
+
+	if len(events) != 0 {
+		t.Errorf("expected no events after refresh, got %+v", (<-events).event)
+	}
+	typingUsers, _ := svc.GetTyping(convID, users[1].ID)
+	if len(typingUsers) != 1 || typingUsers[0] != users[0].ID {
+		t.Errorf("expected user to still be typing, got %v", typingUsers)
+	}
+}
@@ -5,29 +5,63 @@ type MessageType int16
 
 // 客户端 → 服务端
 const (
-	MsgAuth     MessageType = 1   // 认证
-	MsgChat     MessageType = 2   // 聊天消息
-	MsgAck      MessageType = 3   // 确认
-	MsgSyncReq  MessageType = 4   // 同步请求
-	MsgPresence MessageType = 5   // 在线状态
-	MsgPing     MessageType = 6   // 心跳
+	MsgAuth      MessageType = 1  // 认证
+	MsgChat      MessageType = 2  // 聊天消息
+	MsgAck       MessageType = 3  // 确认
+	MsgSyncReq   MessageType = 4  // 同步请求
+	MsgPresence  MessageType = 5  // 在线状态
+	MsgPing      MessageType = 6  // 心跳
+	MsgEdit      MessageType = 7  // 编辑消息
+	MsgRecall    MessageType = 8  // 撤回消息（对所有人）
+	MsgDelete    MessageType = 9  // 删除消息（仅自己）
+	MsgReaction  MessageType = 10 // 表情回应
+	MsgTyping    MessageType = 11 // 输入状态（开始/停止输入）
+	MsgDraft     MessageType = 12 // 保存草稿（内容为空时清除）
+	MsgSyncReqV2 MessageType = 13 // 增量同步请求（按变更序号拉取全部类型的变更）
 )
 
 // 服务端 → 客户端
 const (
-	MsgAuthRsp     MessageType = 101 // 认证响应
-	MsgChatPush    MessageType = 102 // 消息推送
-	MsgSyncRsp     MessageType = 103 // 同步响应
-	MsgPresencePush MessageType = 104 // 在线状态推送
-	MsgPong        MessageType = 105 // 心跳响应
-	MsgError       MessageType = 106 // 错误通知
+	MsgAuthRsp            MessageType = 101 // 认证响应
+	MsgChatPush           MessageType = 102 // 消息推送
+	MsgSyncRsp            MessageType = 103 // 同步响应
+	MsgPresencePush       MessageType = 104 // 在线状态推送
+	MsgPong               MessageType = 105 // 心跳响应
+	MsgError              MessageType = 106 // 错误通知
+	MsgEditPush           MessageType = 107 // 消息编辑推送
+	MsgRecallPush         MessageType = 108 // 消息撤回推送
+	MsgDeletePush         MessageType = 109 // 消息删除推送（同步到自己的其他设备）
+	MsgReactionPush       MessageType = 110 // 表情回应推送
+	MsgChatRsp            MessageType = 111 // 发送结果（Seq 与请求一致）
+	MsgStatusPush         MessageType = 112 // 消息状态回执推送（推送给发送者的所有连接）
+	MsgTypingPush         MessageType = 113 // 输入状态推送
+	MsgPinPush            MessageType = 114 // 置顶消息变更推送
+	MsgDraftPush          MessageType = 115 // 草稿同步推送（推送给自己的其他连接）
+	MsgPollPush           MessageType = 116 // 投票结果推送（推送给会话全部参与者）
+	MsgSyncRspV2          MessageType = 117 // 增量同步响应（Seq 与请求一致）
+	MsgEditRsp            MessageType = 118 // 编辑结果（Seq 与请求一致，负载同 EditPushPayload）
+	MsgRecallRsp          MessageType = 119 // 撤回结果（Seq 与请求一致，负载同 RecallPushPayload）
+	MsgDeleteRsp          MessageType = 120 // 删除结果（Seq 与请求一致，负载同 DeletePushPayload）
+	MsgReactionRsp        MessageType = 121 // 表情回应结果（Seq 与请求一致，负载同 ReactionPushPayload）
+	MsgGroupCreatedPush   MessageType = 122 // 群聊创建推送（推送给创建者和初始成员）
+	MsgGroupUpdatedPush   MessageType = 123 // 群资料更新推送
+	MsgGroupMembersPush   MessageType = 124 // 群成员加入推送（包括新成员）
+	MsgGroupRemovedPush   MessageType = 125 // 群成员移除/退出推送（包括被移除者）
+	MsgGroupRolePush      MessageType = 126 // 群成员角色变更推送
+	MsgReadCursorPush     MessageType = 127 // 已读位置推送（推送给会话全部参与者）
+	MsgSettingsPush       MessageType = 128 // 会话个人设置推送（推送给自己的所有连接）
+	MsgHistoryClearedPush MessageType = 129 // 清空聊天记录推送（推送给自己的所有连接）
+	MsgEventPush          MessageType = 130 // 通用事件推送（没有专用协议消息的事件，数据与 SSE 相同）
 )
 
 // WSMessage WebSocket消息
 type WSMessage struct {
 	Type    MessageType `msgpack:"type"`
 	Seq     int64       `msgpack:"seq"`
-	Payload []byte      `msgpack:"payload"`
+	Payload []byte      `msgpack:"payload"` // 按连接的编码格式编码的负载
+
+	// Body 待发送的负载，发送时按每个连接协商的编码格式（MessagePack/JSON）编码，设置后忽略 Payload
+	Body interface{} `msgpack:"-"`
 }
 
 // MessageStatus 消息状态
@@ -41,64 +75,334 @@ const (
 
 // AuthPayload 认证请求负载
 type AuthPayload struct {
-	Token string `msgpack:"token"`
+	Token string `msgpack:"token" json:"token"`
 }
 
 // AuthResponsePayload 认证响应负载
 type AuthResponsePayload struct {
-	Success bool   `msgpack:"success"`
-	UserID  int64  `msgpack:"user_id,omitempty"`
-	Error   string `msgpack:"error,omitempty"`
+	Success bool   `msgpack:"success" json:"success"`
+	UserID  int64  `msgpack:"user_id,omitempty" json:"user_id,omitempty"`
+	Error   string `msgpack:"error,omitempty" json:"error,omitempty"`
 }
 
 // ChatPayload 聊天消息负载
 type ChatPayload struct {
-	To      int64  `msgpack:"to"`        // 接收者ID
-	Type    string `msgpack:"type"`      // text/voice/image
-	Content string `msgpack:"content"`   // 消息内容或媒体ID
+	To             int64  `msgpack:"to" json:"to"`                                               // 接收者ID（单聊）
+	ConversationID int64  `msgpack:"conversation_id,omitempty" json:"conversation_id,omitempty"` // 会话ID（群聊必填）
+	Type           string `msgpack:"type" json:"type"`                                           // text/voice/image/file/location/contact
+	Content        string `msgpack:"content" json:"content"`                                     // 文本、媒体ID或结构化内容（JSON）
+	ReplyToID      int64  `msgpack:"reply_to_id,omitempty" json:"reply_to_id,omitempty"`         // 引用的消息ID
+	ClientMsgID    string `msgpack:"client_msg_id,omitempty" json:"client_msg_id,omitempty"`     // 客户端消息ID（重发时携带相同值以去重）
+}
+
+// ChatRspPayload 发送结果负载
+type ChatRspPayload struct {
+	MessageID      int64  `msgpack:"message_id" json:"message_id"`
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	ClientMsgID    string `msgpack:"client_msg_id,omitempty" json:"client_msg_id,omitempty"`
+	CreatedAt      int64  `msgpack:"created_at" json:"created_at"`
 }
 
 // ChatPushPayload 消息推送负载
 type ChatPushPayload struct {
-	MessageID   int64  `msgpack:"message_id"`
-	From        int64  `msgpack:"from"`
-	To          int64  `msgpack:"to"`
-	Type        string `msgpack:"type"`
-	Content     string `msgpack:"content"`
-	CreatedAt   int64  `msgpack:"created_at"`
+	Seq            int64         `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	MessageID      int64         `msgpack:"message_id" json:"message_id"`
+	ConversationID int64         `msgpack:"conversation_id" json:"conversation_id"`
+	From           int64         `msgpack:"from" json:"from"`
+	To             int64         `msgpack:"to" json:"to"` // 群聊消息为0
+	Type           string        `msgpack:"type" json:"type"`
+	Content        string        `msgpack:"content" json:"content"`
+	CreatedAt      int64         `msgpack:"created_at" json:"created_at"`
+	EditedAt       int64         `msgpack:"edited_at,omitempty" json:"edited_at,omitempty"`           // 最近编辑时间（未编辑为0）
+	ReplyTo        *ReplyPreview `msgpack:"reply_to,omitempty" json:"reply_to,omitempty"`             // 引用消息预览
+	ExpiresAt      int64         `msgpack:"expires_at,omitempty" json:"expires_at,omitempty"`         // 阅后即焚过期时间（未开启为0）
+	ForwardedFrom  *ForwardInfo  `msgpack:"forwarded_from,omitempty" json:"forwarded_from,omitempty"` // 转发来源
+}
+
+// ForwardInfo 转发来源（最初的发送者和发送时间）
+type ForwardInfo struct {
+	MessageID      int64  `msgpack:"message_id" json:"message_id"`
+	From           int64  `msgpack:"from" json:"from"`
+	SenderNickname string `msgpack:"sender_nickname,omitempty" json:"sender_nickname,omitempty"`
+	CreatedAt      int64  `msgpack:"created_at" json:"created_at"`
+}
+
+// ReplyPreview 引用消息预览
+type ReplyPreview struct {
+	MessageID      int64  `msgpack:"message_id" json:"message_id"`
+	From           int64  `msgpack:"from" json:"from"`
+	SenderNickname string `msgpack:"sender_nickname" json:"sender_nickname"`
+	Type           string `msgpack:"type" json:"type"`
+	Content        string `msgpack:"content" json:"content"` // 截断后的内容
+	Recalled       bool   `msgpack:"recalled,omitempty" json:"recalled,omitempty"`
+}
+
+// EditPayload 编辑消息负载
+type EditPayload struct {
+	MessageID int64  `msgpack:"message_id" json:"message_id"`
+	Content   string `msgpack:"content" json:"content"` // 新内容
+}
+
+// EditPushPayload 消息编辑推送负载
+type EditPushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	MessageID      int64  `msgpack:"message_id" json:"message_id"`
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	From           int64  `msgpack:"from" json:"from"`
+	Content        string `msgpack:"content" json:"content"`
+	EditedAt       int64  `msgpack:"edited_at" json:"edited_at"`
+}
+
+// RecallPayload 撤回消息负载
+type RecallPayload struct {
+	MessageID int64 `msgpack:"message_id" json:"message_id"`
+}
+
+// RecallPushPayload 消息撤回推送负载
+type RecallPushPayload struct {
+	Seq            int64 `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	MessageID      int64 `msgpack:"message_id" json:"message_id"`
+	ConversationID int64 `msgpack:"conversation_id" json:"conversation_id"`
+	From           int64 `msgpack:"from" json:"from"`
+	RecalledAt     int64 `msgpack:"recalled_at" json:"recalled_at"`
+}
+
+// DeletePayload 删除消息负载
+type DeletePayload struct {
+	MessageID int64 `msgpack:"message_id" json:"message_id"`
+}
+
+// DeletePushPayload 消息删除推送负载
+type DeletePushPayload struct {
+	Seq            int64 `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	MessageID      int64 `msgpack:"message_id" json:"message_id"`
+	ConversationID int64 `msgpack:"conversation_id" json:"conversation_id"`
+}
+
+// ReactionPayload 表情回应负载
+type ReactionPayload struct {
+	MessageID int64  `msgpack:"message_id" json:"message_id"`
+	Emoji     string `msgpack:"emoji" json:"emoji"`
+	Remove    bool   `msgpack:"remove,omitempty" json:"remove,omitempty"` // true 表示取消回应
+}
+
+// ReactionPushPayload 表情回应推送负载
+type ReactionPushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	MessageID      int64  `msgpack:"message_id" json:"message_id"`
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	UserID         int64  `msgpack:"user_id" json:"user_id"`
+	Emoji          string `msgpack:"emoji" json:"emoji"`
+	Action         string `msgpack:"action" json:"action"` // add/remove
+}
+
+// DraftPayload 保存草稿负载
+type DraftPayload struct {
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	Content        string `msgpack:"content" json:"content"`                             // 为空时清除草稿
+	ReplyToID      int64  `msgpack:"reply_to_id,omitempty" json:"reply_to_id,omitempty"` // 草稿引用的消息
+}
+
+// DraftPushPayload 草稿同步推送负载
+type DraftPushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	Content        string `msgpack:"content" json:"content"` // 为空表示草稿已清除
+	ReplyToID      int64  `msgpack:"reply_to_id,omitempty" json:"reply_to_id,omitempty"`
+	UpdatedAt      int64  `msgpack:"updated_at" json:"updated_at"`
+}
+
+// PinPushPayload 置顶消息变更推送负载
+type PinPushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	MessageID      int64  `msgpack:"message_id" json:"message_id"`
+	UserID         int64  `msgpack:"user_id" json:"user_id"`
+	Action         string `msgpack:"action" json:"action"` // pin/unpin
+	UpdatedAt      int64  `msgpack:"updated_at" json:"updated_at"`
+}
+
+// PollPushPayload 投票结果推送负载
+type PollPushPayload struct {
+	Seq            int64              `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64              `msgpack:"conversation_id" json:"conversation_id"`
+	MessageID      int64              `msgpack:"message_id" json:"message_id"`
+	Options        []PollOptionResult `msgpack:"options" json:"options"`
+	TotalVoters    int                `msgpack:"total_voters" json:"total_voters"`
+	Closed         bool               `msgpack:"closed" json:"closed"`
+	ClosedAt       int64              `msgpack:"closed_at,omitempty" json:"closed_at,omitempty"`
+}
+
+// PollOptionResult 投票选项结果
+type PollOptionResult struct {
+	Index  int     `msgpack:"index" json:"index"`
+	Count  int     `msgpack:"count" json:"count"`
+	Voters []int64 `msgpack:"voters,omitempty" json:"voters,omitempty"` // 匿名投票时为空
+}
+
+// GroupCreatedPushPayload 群聊创建推送负载
+type GroupCreatedPushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	Title          string `msgpack:"title" json:"title"`
+	AvatarID       int64  `msgpack:"avatar_id,omitempty" json:"avatar_id,omitempty"` // 未设置头像为0
+	CreatedBy      int64  `msgpack:"created_by" json:"created_by"`
+	CreatedAt      int64  `msgpack:"created_at" json:"created_at"`
+}
+
+// GroupUpdatedPushPayload 群资料更新推送负载
+type GroupUpdatedPushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	Title          string `msgpack:"title" json:"title"`
+	AvatarID       int64  `msgpack:"avatar_id,omitempty" json:"avatar_id,omitempty"`
+	UpdatedBy      int64  `msgpack:"updated_by" json:"updated_by"`
+	UpdatedAt      int64  `msgpack:"updated_at" json:"updated_at"`
+}
+
+// GroupMember 群成员信息
+type GroupMember struct {
+	UserID   int64  `msgpack:"user_id" json:"user_id"`
+	Username string `msgpack:"username" json:"username"`
+	Nickname string `msgpack:"nickname" json:"nickname"`
+	Avatar   string `msgpack:"avatar,omitempty" json:"avatar,omitempty"`
+	Role     string `msgpack:"role" json:"role"` // owner/admin/member
+	JoinedAt int64  `msgpack:"joined_at" json:"joined_at"`
+}
+
+// GroupMembersPushPayload 群成员加入推送负载
+type GroupMembersPushPayload struct {
+	Seq            int64         `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64         `msgpack:"conversation_id" json:"conversation_id"`
+	Members        []GroupMember `msgpack:"members" json:"members"`
+	AddedBy        int64         `msgpack:"added_by" json:"added_by"`
+}
+
+// GroupRemovedPushPayload 群成员移除/退出推送负载
+type GroupRemovedPushPayload struct {
+	Seq            int64 `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64 `msgpack:"conversation_id" json:"conversation_id"`
+	UserID         int64 `msgpack:"user_id" json:"user_id"`
+	RemovedBy      int64 `msgpack:"removed_by" json:"removed_by"` // 主动退出时为本人
+}
+
+// GroupRolePushPayload 群成员角色变更推送负载
+type GroupRolePushPayload struct {
+	Seq            int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64  `msgpack:"conversation_id" json:"conversation_id"`
+	UserID         int64  `msgpack:"user_id" json:"user_id"`
+	Role           string `msgpack:"role" json:"role"`
+	ChangedBy      int64  `msgpack:"changed_by" json:"changed_by"`
+}
+
+// ReadCursorPushPayload 已读位置推送负载
+type ReadCursorPushPayload struct {
+	Seq               int64 `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID    int64 `msgpack:"conversation_id" json:"conversation_id"`
+	UserID            int64 `msgpack:"user_id" json:"user_id"`
+	LastReadMessageID int64 `msgpack:"last_read_message_id" json:"last_read_message_id"`
+}
+
+// SettingsPushPayload 会话个人设置推送负载
+type SettingsPushPayload struct {
+	Seq            int64 `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64 `msgpack:"conversation_id" json:"conversation_id"`
+	Archived       bool  `msgpack:"archived" json:"archived"`
+	MutedUntil     int64 `msgpack:"muted_until" json:"muted_until"`
+	Hidden         bool  `msgpack:"hidden" json:"hidden"`
+	PinnedAt       int64 `msgpack:"pinned_at" json:"pinned_at"`
+}
+
+// HistoryClearedPushPayload 清空聊天记录推送负载
+type HistoryClearedPushPayload struct {
+	Seq              int64 `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID   int64 `msgpack:"conversation_id" json:"conversation_id"`
+	ClearedMessageID int64 `msgpack:"cleared_message_id" json:"cleared_message_id"` // 该ID及之前的消息已清空
+}
+
+// EventPushPayload 通用事件推送负载
+type EventPushPayload struct {
+	Seq   int64  `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（瞬时事件为0）
+	Event string `msgpack:"event" json:"event"`                 // 事件类型（即 SSE 的 event 名）
+	Data  string `msgpack:"data" json:"data"`                   // JSON 编码的事件数据
 }
 
 // AckPayload 确认负载
 type AckPayload struct {
-	MessageID int64  `msgpack:"message_id"` // 消息ID
-	Status    string `msgpack:"status"`     // delivered/read
+	MessageID int64  `msgpack:"message_id" json:"message_id"` // 消息ID
+	Status    string `msgpack:"status" json:"status"`         // delivered/read
+}
+
+// StatusPushPayload 消息状态回执推送负载
+type StatusPushPayload struct {
+	Seq            int64   `msgpack:"seq,omitempty" json:"seq,omitempty"` // 变更序号（用于推进增量同步游标）
+	ConversationID int64   `msgpack:"conversation_id" json:"conversation_id"`
+	MessageIDs     []int64 `msgpack:"message_ids" json:"message_ids"`
+	UserID         int64   `msgpack:"user_id" json:"user_id"` // 推进状态的接收者
+	Status         string  `msgpack:"status" json:"status"`   // delivered/read
+	UpdatedAt      int64   `msgpack:"updated_at" json:"updated_at"`
+}
+
+// TypingPayload 输入状态负载
+type TypingPayload struct {
+	ConversationID int64 `msgpack:"conversation_id" json:"conversation_id"`
+	Typing         bool  `msgpack:"typing" json:"typing"` // true 开始输入（需定期重复发送），false 停止输入
+}
+
+// TypingPushPayload 输入状态推送负载
+type TypingPushPayload struct {
+	ConversationID int64 `msgpack:"conversation_id" json:"conversation_id"`
+	UserID         int64 `msgpack:"user_id" json:"user_id"`
+	Typing         bool  `msgpack:"typing" json:"typing"`
 }
 
 // SyncRequestPayload 同步请求负载
 type SyncRequestPayload struct {
-	LastMessageID int64 `msgpack:"last_message_id"` // 最后消息ID
-	LastSyncTime  int64 `msgpack:"last_sync_time"`  // 最后同步时间
+	LastMessageID int64 `msgpack:"last_message_id" json:"last_message_id"` // 最后消息ID
+	LastSyncTime  int64 `msgpack:"last_sync_time" json:"last_sync_time"`   // 最后同步时间
 }
 
 // SyncResponsePayload 同步响应负载
 type SyncResponsePayload struct {
-	Messages []ChatPushPayload `msgpack:"messages"` // 离线消息列表
-	HasMore  bool                 `msgpack:"has_more"` // 是否还有更多
+	Messages []ChatPushPayload `msgpack:"messages" json:"messages"` // 离线消息列表
+	HasMore  bool              `msgpack:"has_more" json:"has_more"` // 是否还有更多
+}
+
+// SyncV2RequestPayload 增量同步请求负载
+type SyncV2RequestPayload struct {
+	Cursor int64 `msgpack:"cursor" json:"cursor"` // 上次同步返回的游标（首次为0，也可用 SSE 的事件ID）
+	Limit  int   `msgpack:"limit" json:"limit"`   // 每页数量（0 使用默认值）
+}
+
+// SyncChange 一条变更
+type SyncChange struct {
+	Seq       int64  `msgpack:"seq" json:"seq"`   // 用户内单调递增的变更序号
+	Type      string `msgpack:"type" json:"type"` // 事件类型，如 chat、message_edited、message_status
+	Data      string `msgpack:"data" json:"data"` // JSON 编码的事件数据（与 SSE 推送的数据相同）
+	CreatedAt int64  `msgpack:"created_at" json:"created_at"`
+}
+
+// SyncV2ResponsePayload 增量同步响应负载
+type SyncV2ResponsePayload struct {
+	Changes        []SyncChange `msgpack:"changes" json:"changes"`                 // 按序号升序的变更
+	Cursor         int64        `msgpack:"cursor" json:"cursor"`                   // 下次同步使用的游标
+	HasMore        bool         `msgpack:"has_more" json:"has_more"`               // 是否还有更多（用 Cursor 继续拉取）
+	ResyncRequired bool         `msgpack:"resync_required" json:"resync_required"` // 游标之后的变更已被清理，需重新拉取数据后从 Cursor 继续
 }
 
 // PresencePayload 在线状态负载
 type PresencePayload struct {
-	Status string `msgpack:"status"` // online/offline/away
+	Status string `msgpack:"status" json:"status"` // online/offline/away
 }
 
 // PresencePushPayload 在线状态推送负载
 type PresencePushPayload struct {
-	UserID int64  `msgpack:"user_id"`
-	Status string `msgpack:"status"`
+	UserID int64  `msgpack:"user_id" json:"user_id"`
+	Status string `msgpack:"status" json:"status"`
 }
 
 // ErrorPayload 错误通知负载
 type ErrorPayload struct {
-	Code    string `msgpack:"code"`    // 错误码
-	Message string `msgpack:"message"` // 错误信息
+	Code    string `msgpack:"code" json:"code"`       // 错误码
+	Message string `msgpack:"message" json:"message"` // 错误信息
 }
@@ -4,69 +4,173 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"zmessage/server/hub"
+	"zmessage/server/modules/changelog"
 	"zmessage/server/modules/user"
 )
 
+const (
+	// DefaultReplayLimit 重连时最多补发的事件数，断线超过这么多事件后需要重新同步
+	DefaultReplayLimit = 1000
+	// BufferSize 每个连接的发送缓冲，写满时断开连接，由客户端重连后补发
+	BufferSize = 100
+	// EventResyncRequired 断线期间丢失的事件已无法补发，客户端需重新拉取数据
+	EventResyncRequired = "resync_required"
+)
+
+// Option SSE 处理器配置项
+type Option func(*Handler)
+
+// WithReplayLimit 设置重连时最多补发的事件数
+func WithReplayLimit(limit int) Option {
+	return func(h *Handler) {
+		h.replayLimit = limit
+	}
+}
+
 // Handler SSE 处理器
+// 作为事件中心的订阅者，把事件推送给用户的所有 SSE 连接，事件ID即变更日志分配的序号
 type Handler struct {
-	userSvc user.Service
+	userSvc     user.Service
+	changes     changelog.Service
+	replayLimit int
+
+	mu      sync.Mutex                 // 保证补发与实时推送之间不丢不重
+	clients map[int64]map[*client]bool // userID -> 连接（多个标签页/设备）
 }
 
 // NewHandler 创建 SSE 处理器
-func NewHandler(userSvc user.Service) *Handler {
-	return &Handler{userSvc: userSvc}
+// 事件中心需设置变更日志作为记录器（hub.WithRecorder），否则事件不带ID，也无法补发
+func NewHandler(userSvc user.Service, h hub.Hub, changes changelog.Service, opts ...Option) *Handler {
+	handler := &Handler{
+		userSvc:     userSvc,
+		changes:     changes,
+		replayLimit: DefaultReplayLimit,
+		clients:     make(map[int64]map[*client]bool),
+	}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	h.Subscribe(hub.AllUsers, nil, handler.deliver)
+	return handler
 }
 
 // PushMessage 推送消息的通道类型
 type PushMessage struct {
-	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	ID   int64           `json:"id,omitempty"` // 事件ID（瞬时事件为0，不带 id 字段）
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
 }
 
-// clients 存储所有 SSE 客户端连接
-// 每个用户可以有多个连接（多个标签页/设备）
-var (
-	clients  = make(map[int64]map[chan *PushMessage]bool) // userID -> channels
-	clientsMu sync.RWMutex
-)
+// client 一个 SSE 连接
+type client struct {
+	ch       chan *PushMessage
+	overflow chan struct{} // 发送缓冲写满时关闭
+	once     sync.Once
+	lastID   int64 // 登记时已补发（或已包含在最新ID内）的事件ID，之后只推送更新的事件
+}
 
-// Broadcast 广播消息给指定用户的所有连接
-func Broadcast(userID int64, msgType string, data interface{}) {
-	pushMsg := &PushMessage{
-		Type: msgType,
-		Data: data,
+// push 非阻塞地写入发送缓冲，写满时标记连接溢出
+func (c *client) push(msg *PushMessage) bool {
+	if msg.ID > 0 && msg.ID <= c.lastID {
+		return true
+	}
+	select {
+	case c.ch <- msg:
+		return true
+	default:
+		c.once.Do(func() { close(c.overflow) })
+		return false
 	}
+}
 
-	fmt.Printf("[SSE Broadcast] Broadcasting to user %d, type: %s, data: %+v\n", userID, msgType, data)
+// deliver 推送事件给用户的所有连接，返回是否至少推送到一个连接
+func (h *Handler) deliver(userID int64, event *hub.Event) bool {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		fmt.Printf("[SSE] Encode event %s for user %d failed: %v\n", event.Type, userID, err)
+		return false
+	}
+	msg := &PushMessage{ID: event.Seq, Type: event.Type, Data: data}
 
-	clientsMu.RLock()
-	userChans, ok := clients[userID]
-	clientsMu.RUnlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	if !ok {
-		fmt.Printf("[SSE Broadcast] User %d has no active connections\n", userID)
-		return
+	delivered := false
+	for c := range h.clients[userID] {
+		if c.push(msg) {
+			delivered = true
+		}
 	}
+	return delivered
+}
 
-	sentCount := 0
-	for ch := range userChans {
-		select {
-		case ch <- pushMsg:
-			sentCount++
-			fmt.Printf("[SSE Broadcast] Sent to one channel (total: %d)\n", sentCount)
-		default:
-			// 如果通道满了，忽略
-			fmt.Printf("[SSE Broadcast] Channel full, message dropped\n")
+// register 登记连接，并在同一把锁内取出需要补发的事件
+// 事件先写入变更日志再投递，登记前已写入日志但尚未投递的事件会在补发和实时推送中各出现一次，
+// 由 client.lastID 过滤重复
+// resync 为 true 表示断线期间的事件已被清理（或超过补发上限），无法补发
+func (h *Handler) register(userID int64, c *client, lastEventID int64, resume bool) (replay []*changelog.Change, latest int64, resync bool, connCount int, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if resume {
+		set, err := h.changes.GetChanges(userID, lastEventID, h.replayLimit)
+		if err != nil {
+			return nil, 0, false, 0, err
+		}
+		if set.ResyncRequired || set.HasMore {
+			resync = true
+			latest, err = h.changes.LatestSeq(userID)
+			if err != nil {
+				return nil, 0, false, 0, err
+			}
+		} else {
+			replay, latest = set.Changes, set.Cursor
 		}
+	} else {
+		latest, err = h.changes.LatestSeq(userID)
+		if err != nil {
+			return nil, 0, false, 0, err
+		}
+	}
+	c.lastID = latest
+
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*client]bool)
+	}
+	h.clients[userID][c] = true
+	return replay, latest, resync, len(h.clients[userID]), nil
+}
+
+// unregister 移除连接，返回该用户剩余的连接数
+func (h *Handler) unregister(userID int64, c *client) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients[userID], c)
+	remaining := len(h.clients[userID])
+	if remaining == 0 {
+		delete(h.clients, userID)
 	}
-	fmt.Printf("[SSE Broadcast] Completed broadcasting to user %d, sent to %d channels\n", userID, sentCount)
+	return remaining
+}
+
+// writeEvent 写出一条 SSE 事件（id 为0时不带 id 字段）
+func writeEvent(w gin.ResponseWriter, id int64, eventType string, data []byte) {
+	if id > 0 {
+		fmt.Fprintf(w, "id: %d\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\n", eventType)
+	fmt.Fprintf(w, "data: %s\n\n", data)
 }
 
 // Subscribe SSE 订阅端点
+// 重连时浏览器通过 Last-Event-ID 头（或 last_event_id 参数）告知最后收到的事件，服务端补发之后的事件
 func (h *Handler) Subscribe(c *gin.Context) {
 	// 从 URL 参数获取 token
 	token := c.Query("token")
@@ -82,6 +186,21 @@ func (h *Handler) Subscribe(c *gin.Context) {
 		return
 	}
 
+	// 断线重连的位置
+	lastEventIDStr := c.GetHeader("Last-Event-ID")
+	if lastEventIDStr == "" {
+		lastEventIDStr = c.Query("last_event_id")
+	}
+	var lastEventID int64
+	resume := lastEventIDStr != ""
+	if resume {
+		lastEventID, err = strconv.ParseInt(lastEventIDStr, 10, 64)
+		if err != nil || lastEventID < 0 {
+			c.JSON(400, gin.H{"error": "invalid last event id"})
+			return
+		}
+	}
+
 	// 设置 SSE 头
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -96,45 +215,38 @@ func (h *Handler) Subscribe(c *gin.Context) {
 		return
 	}
 
-	// 创建消息通道
-	msgChan := make(chan *PushMessage, 100)
-
-	// 注册连接
-	clientsMu.Lock()
-	if clients[userID] == nil {
-		clients[userID] = make(map[chan *PushMessage]bool)
+	// 注册连接并取出需要补发的事件
+	cl := &client{ch: make(chan *PushMessage, BufferSize), overflow: make(chan struct{})}
+	replay, latest, resync, connCount, err := h.register(userID, cl, lastEventID, resume)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "load events failed"})
+		return
 	}
-	clients[userID][msgChan] = true
-	connCount := len(clients[userID])
-	clientsMu.Unlock()
-
-	// 发送连接成功事件
-	fmt.Fprintf(c.Writer, "event: connected\n")
-	fmt.Fprintf(c.Writer, "data: {\"user_id\":%d,\"conn_count\":%d}\n\n", userID, connCount)
-	flusher.Flush()
-
-	// 监听连接断开
-	notify := c.Request.Context().Done()
 
 	// 保持连接并推送消息
 	defer func() {
-		close(msgChan)
-
-		clientsMu.Lock()
-		if clients[userID] != nil {
-			delete(clients[userID], msgChan)
-			// 如果该用户没有连接了，清理map
-			if len(clients[userID]) == 0 {
-				delete(clients, userID)
-			}
-		}
-		clientsMu.Unlock()
-
-		fmt.Printf("[SSE] User %d disconnected (remaining: %d)\n", userID, len(clients[userID]))
+		remaining := h.unregister(userID, cl)
+		fmt.Printf("[SSE] User %d disconnected (remaining: %d)\n", userID, remaining)
 	}()
 
+	// 发送连接成功事件（携带当前最新的事件ID，之后重连从这里补发）
+	writeEvent(c.Writer, latest, "connected", []byte(fmt.Sprintf("{\"user_id\":%d,\"conn_count\":%d,\"last_event_id\":%d}", userID, connCount, latest)))
+
+	// 补发断线期间的事件，或通知客户端重新同步
+	if resync {
+		writeEvent(c.Writer, latest, EventResyncRequired, []byte(fmt.Sprintf("{\"last_event_id\":%d,\"latest_event_id\":%d}", lastEventID, latest)))
+		fmt.Printf("[SSE] User %d resumed from %d, resync required (latest: %d)\n", userID, lastEventID, latest)
+	}
+	for _, e := range replay {
+		writeEvent(c.Writer, e.Seq, e.Type, e.Data)
+	}
+	flusher.Flush()
+
 	clientIP := c.ClientIP()
-	fmt.Printf("[SSE] User %d subscribed from %s (total connections: %d)\n", userID, clientIP, connCount)
+	fmt.Printf("[SSE] User %d subscribed from %s (total connections: %d, replayed: %d)\n", userID, clientIP, connCount, len(replay))
+
+	// 监听连接断开
+	notify := c.Request.Context().Done()
 
 	// 发送心跳（每 15 秒）
 	ticker := time.NewTicker(15 * time.Second)
@@ -147,18 +259,14 @@ func (h *Handler) Subscribe(c *gin.Context) {
 			fmt.Fprintf(c.Writer, "event: heartbeat\n")
 			fmt.Fprintf(c.Writer, "data: {\"timestamp\":%d,\"interval\":15}\n\n", time.Now().Unix())
 			flusher.Flush()
-			fmt.Printf("[SSE] Heartbeat sent to user %d\n", userID)
-		case msg, ok := <-msgChan:
-			if !ok {
-				return
-			}
+		case msg := <-cl.ch:
 			// 发送消息
-			jsonData, _ := json.Marshal(msg.Data)
-			fmt.Printf("[SSE] Sending to user %d: event=%s, data=%s\n", userID, msg.Type, string(jsonData))
-			fmt.Fprintf(c.Writer, "event: %s\n", msg.Type)
-			fmt.Fprintf(c.Writer, "data: %s\n\n", jsonData)
+			writeEvent(c.Writer, msg.ID, msg.Type, msg.Data)
 			flusher.Flush()
-			fmt.Printf("[SSE] Message sent to user %d successfully\n", userID)
+		case <-cl.overflow:
+			// 客户端跟不上推送速度，断开后由浏览器带 Last-Event-ID 重连补发
+			fmt.Printf("[SSE] User %d send buffer full, closing connection\n", userID)
+			return
 		case <-notify:
 			return
 		}
@@ -0,0 +1,149 @@
+package sse
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zmessage/server/dal"
+	"zmessage/server/hub"
+	"zmessage/server/modules/changelog"
+	"zmessage/server/modules/typing"
+	"zmessage/server/modules/user"
+)
+
+func setupTestHandler(t *testing.T) (*Handler, hub.Hub, string) {
+	t.Helper()
+
+	mgr, err := dal.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("create dal manager: %v", err)
+	}
+	t.Cleanup(func() { mgr.Close() })
+
+	userSvc := user.NewService(mgr, "test-secret")
+	token, err := userSvc.GenerateToken(1)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	changes := changelog.NewService(mgr, changelog.WithRetention(3))
+	h := hub.New(hub.WithRecorder(changes))
+	return NewHandler(userSvc, h, changes), h, token
+}
+
+// subscribe 订阅直到 ctx 结束，返回收到的事件流
+func subscribe(ctx context.Context, handler *Handler, token string, lastEventID string) string {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/sse/subscribe?token="+token, nil).WithContext(ctx)
+	if lastEventID != "" {
+		c.Request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	handler.Subscribe(c)
+	return w.Body.String()
+}
+
+// subscribeBriefly 订阅一小段时间，用于检查连接建立时补发的事件
+func subscribeBriefly(handler *Handler, token string, lastEventID string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	return subscribe(ctx, handler, token, lastEventID)
+}
+
+func TestHandler_Replay(t *testing.T) {
+	handler, h, token := setupTestHandler(t)
+
+	// 离线期间的事件写入变更日志，只保留最近3条
+	for i := 0; i < 5; i++ {
+		if n := h.Publish(1, &hub.Event{Type: "chat", Data: map[string]int{"n": i + 1}}); n != 0 {
+			t.Errorf("expected no delivery without connections, got %d", n)
+		}
+	}
+
+	// 新连接不补发，只告知最新的事件ID
+	body := subscribeBriefly(handler, token, "")
+	if !strings.Contains(body, "id: 5\nevent: connected\n") || strings.Contains(body, "event: chat") {
+		t.Errorf("unexpected fresh stream: %q", body)
+	}
+
+	// 从事件3之后补发
+	body = subscribeBriefly(handler, token, "3")
+	if !strings.Contains(body, "id: 4\nevent: chat\ndata: {\"n\":4}\n\n") || !strings.Contains(body, "id: 5\nevent: chat\ndata: {\"n\":5}\n\n") {
+		t.Errorf("expected events 4 and 5 to be replayed: %q", body)
+	}
+	if strings.Contains(body, "{\"n\":3}") || strings.Contains(body, EventResyncRequired) {
+		t.Errorf("unexpected replay: %q", body)
+	}
+
+	// 断线期间的事件超过补发上限，需要重新同步
+	limited := NewHandler(handler.userSvc, h, handler.changes, WithReplayLimit(1))
+	body = subscribeBriefly(limited, token, "3")
+	if !strings.Contains(body, "event: "+EventResyncRequired) || strings.Contains(body, "event: chat") {
+		t.Errorf("expected resync beyond the replay limit: %q", body)
+	}
+
+	// 已是最新，不补发
+	body = subscribeBriefly(handler, token, "5")
+	if strings.Contains(body, "event: chat") || strings.Contains(body, EventResyncRequired) {
+		t.Errorf("expected nothing to replay: %q", body)
+	}
+
+	// 事件1、2已被清理，需要重新同步
+	body = subscribeBriefly(handler, token, "1")
+	if !strings.Contains(body, "event: "+EventResyncRequired) || strings.Contains(body, "event: chat") {
+		t.Errorf("expected resync for a gap: %q", body)
+	}
+
+	// 事件ID超过服务端记录（日志已重置），需要重新同步
+	body = subscribeBriefly(handler, token, "99")
+	if !strings.Contains(body, "event: "+EventResyncRequired) {
+		t.Errorf("expected resync for an unknown id: %q", body)
+	}
+}
+
+func TestHandler_LiveEvents(t *testing.T) {
+	handler, h, token := setupTestHandler(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan string)
+	go func() {
+		done <- subscribe(ctx, handler, token, "")
+	}()
+
+	// 等待连接登记
+	for i := 0; i < 100; i++ {
+		handler.mu.Lock()
+		n := len(handler.clients[1])
+		handler.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := h.Publish(1, &hub.Event{Type: "chat", Data: map[string]string{"content": "hi"}}); n != 1 {
+		t.Errorf("expected delivery to the connection, got %d", n)
+	}
+	h.Publish(1, &hub.Event{Type: typing.EventTyping, Data: &typing.Event{ConversationID: 1, UserID: 2, Typing: true}})
+	h.Publish(2, &hub.Event{Type: "chat", Data: map[string]string{"content": "not yours"}})
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	body := <-done
+
+	if !strings.Contains(body, "id: 1\nevent: chat\ndata: {\"content\":\"hi\"}\n\n") {
+		t.Errorf("expected chat event with id 1: %q", body)
+	}
+	// 输入状态是瞬时事件，不带事件ID
+	if !strings.Contains(body, "\nevent: typing\n") || strings.Contains(body, "id: 2\n") {
+		t.Errorf("expected typing event without id: %q", body)
+	}
+	if strings.Contains(body, "not yours") {
+		t.Errorf("received another user's event: %q", body)
+	}
+}
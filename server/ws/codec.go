@@ -2,12 +2,143 @@ package ws
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 
+	"github.com/gorilla/websocket"
 	"github.com/vmihailenco/msgpack/v5"
 	"zmessage/server/pkg/protocol"
 )
 
+const (
+	// SubprotocolMsgpack MessagePack 二进制帧（客户端未请求子协议时的默认格式）
+	SubprotocolMsgpack = "zmessage.msgpack"
+	// SubprotocolJSON JSON 文本帧（便于浏览器调试、机器人和脚本接入）
+	SubprotocolJSON = "zmessage.json"
+)
+
+// Subprotocols 服务端支持的子协议（按优先级），用于 websocket.Upgrader 的 Subprotocols
+var Subprotocols = []string{SubprotocolMsgpack, SubprotocolJSON}
+
+// Codec WebSocket消息编解码器
+// 每个连接在握手时通过 Sec-WebSocket-Protocol 协商编码格式，收发消息都经过连接的编解码器
+type Codec interface {
+	// Subprotocol 对应的子协议名
+	Subprotocol() string
+	// FrameType 发送使用的帧类型（websocket.BinaryMessage 或 websocket.TextMessage）
+	FrameType() int
+	// Encode 编码完整的WebSocket消息（设置了 Body 时按本格式编码负载）
+	Encode(msg *protocol.WSMessage) ([]byte, error)
+	// Decode 解码WebSocket消息（Payload 保持本格式的编码，由 DecodePayload 解码）
+	Decode(data []byte) (*protocol.WSMessage, error)
+	// EncodePayload 编码负载数据
+	EncodePayload(payload interface{}) ([]byte, error)
+	// DecodePayload 解码负载数据
+	DecodePayload(data []byte, dest interface{}) error
+}
+
+// CodecFor 按握手协商的子协议选择编解码器，未协商（或不支持）时使用 MessagePack
+func CodecFor(subprotocol string) Codec {
+	if subprotocol == SubprotocolJSON {
+		return NewJSONCodec()
+	}
+	return NewMsgpackCodec()
+}
+
+// encodeBody 获取消息的负载：设置了 Body 时用编解码器编码，否则使用已编码的 Payload
+func encodeBody(c Codec, msg *protocol.WSMessage) ([]byte, error) {
+	if msg.Body == nil {
+		return msg.Payload, nil
+	}
+	return c.EncodePayload(msg.Body)
+}
+
+// msgpackCodec MessagePack 编解码器
+type msgpackCodec struct{}
+
+// NewMsgpackCodec 创建 MessagePack 编解码器
+func NewMsgpackCodec() Codec {
+	return msgpackCodec{}
+}
+
+func (msgpackCodec) Subprotocol() string { return SubprotocolMsgpack }
+
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (c msgpackCodec) Encode(msg *protocol.WSMessage) ([]byte, error) {
+	payload, err := encodeBody(c, msg)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncoder().Encode(&protocol.WSMessage{Type: msg.Type, Seq: msg.Seq, Payload: payload})
+}
+
+func (msgpackCodec) Decode(data []byte) (*protocol.WSMessage, error) {
+	return NewDecoder().Decode(data)
+}
+
+func (msgpackCodec) EncodePayload(payload interface{}) ([]byte, error) {
+	return NewEncoder().EncodePayload(payload)
+}
+
+func (msgpackCodec) DecodePayload(data []byte, dest interface{}) error {
+	return NewDecoder().DecodePayload(data, dest)
+}
+
+// jsonMessage JSON 格式的WebSocket消息（负载是嵌套的 JSON 对象，而不是编码后的字节）
+type jsonMessage struct {
+	Type    protocol.MessageType `json:"type"`
+	Seq     int64                `json:"seq"`
+	Payload json.RawMessage      `json:"payload,omitempty"`
+}
+
+// jsonCodec JSON 编解码器
+type jsonCodec struct{}
+
+// NewJSONCodec 创建 JSON 编解码器
+func NewJSONCodec() Codec {
+	return jsonCodec{}
+}
+
+func (jsonCodec) Subprotocol() string { return SubprotocolJSON }
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (c jsonCodec) Encode(msg *protocol.WSMessage) ([]byte, error) {
+	payload, err := encodeBody(c, msg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(&jsonMessage{Type: msg.Type, Seq: msg.Seq, Payload: payload})
+	if err != nil {
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+	return data, nil
+}
+
+func (jsonCodec) Decode(data []byte) (*protocol.WSMessage, error) {
+	var msg jsonMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+	return &protocol.WSMessage{Type: msg.Type, Seq: msg.Seq, Payload: msg.Payload}, nil
+}
+
+func (jsonCodec) EncodePayload(payload interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode payload: %w", err)
+	}
+	return data, nil
+}
+
+func (jsonCodec) DecodePayload(data []byte, dest interface{}) error {
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("decode payload: %w", err)
+	}
+	return nil
+}
+
 // Encoder MessagePack编码器
 type Encoder struct {
 	encoder *msgpack.Encoder
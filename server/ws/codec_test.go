@@ -1,8 +1,10 @@
 package ws
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/gorilla/websocket"
 	"zmessage/server/pkg/protocol"
 )
 
@@ -99,6 +101,15 @@ func TestEncoder_EncodePayload(t *testing.T) {
 				CreatedAt:  1234567890,
 			},
 		},
+		{
+			name: "encode chat response payload",
+			payload: &protocol.ChatRspPayload{
+				MessageID:      123,
+				ConversationID: 7,
+				ClientMsgID:    "c-9f2e",
+				CreatedAt:      1234567890,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -120,6 +131,8 @@ func TestEncoder_EncodePayload(t *testing.T) {
 				dest = &protocol.ErrorPayload{}
 			case *protocol.ChatPushPayload:
 				dest = &protocol.ChatPushPayload{}
+			case *protocol.ChatRspPayload:
+				dest = &protocol.ChatRspPayload{}
 			}
 
 			err = dec.DecodePayload(data, dest)
@@ -187,3 +200,120 @@ func TestDecoder_DecodePayload(t *testing.T) {
 		t.Errorf("Token = %v, want %v", decoded.Token, original.Token)
 	}
 }
+
+func TestCodecFor(t *testing.T) {
+	tests := []struct {
+		subprotocol string
+		want        string
+		frameType   int
+	}{
+		{SubprotocolJSON, SubprotocolJSON, websocket.TextMessage},
+		{SubprotocolMsgpack, SubprotocolMsgpack, websocket.BinaryMessage},
+		{"", SubprotocolMsgpack, websocket.BinaryMessage}, // 未协商时兼容旧客户端
+	}
+
+	for _, tt := range tests {
+		codec := CodecFor(tt.subprotocol)
+		if codec.Subprotocol() != tt.want || codec.FrameType() != tt.frameType {
+			t.Errorf("CodecFor(%q) = %s/%d, want %s/%d", tt.subprotocol, codec.Subprotocol(), codec.FrameType(), tt.want, tt.frameType)
+		}
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	push := &protocol.ChatPushPayload{
+		MessageID:      42,
+		ConversationID: 7,
+		From:           1,
+		To:             2,
+		Type:           "text",
+		Content:        "你好",
+		CreatedAt:      1700000000,
+		ReplyTo:        &protocol.ReplyPreview{MessageID: 41, From: 2, SenderNickname: "Bob", Type: "text", Content: "hi"},
+	}
+
+	for _, codec := range []Codec{NewMsgpackCodec(), NewJSONCodec()} {
+		t.Run(codec.Subprotocol(), func(t *testing.T) {
+			// 负载按本格式编码
+			data, err := codec.Encode(&protocol.WSMessage{Type: protocol.MsgChatPush, Seq: 3, Body: push})
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+
+			decoded, err := codec.Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if decoded.Type != protocol.MsgChatPush || decoded.Seq != 3 {
+				t.Errorf("unexpected header: type %d, seq %d", decoded.Type, decoded.Seq)
+			}
+
+			var got protocol.ChatPushPayload
+			if err := codec.DecodePayload(decoded.Payload, &got); err != nil {
+				t.Fatalf("DecodePayload failed: %v", err)
+			}
+			if got.MessageID != push.MessageID || got.Content != push.Content || got.ReplyTo == nil || got.ReplyTo.SenderNickname != "Bob" {
+				t.Errorf("payload mismatch: %+v", got)
+			}
+
+			// 没有 Body 时使用已编码的 Payload
+			payload, err := codec.EncodePayload(&protocol.AuthPayload{Token: "abc"})
+			if err != nil {
+				t.Fatalf("EncodePayload failed: %v", err)
+			}
+			data, err = codec.Encode(&protocol.WSMessage{Type: protocol.MsgAuth, Seq: 1, Payload: payload})
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			decoded, _ = codec.Decode(data)
+			var auth protocol.AuthPayload
+			if err := codec.DecodePayload(decoded.Payload, &auth); err != nil || auth.Token != "abc" {
+				t.Errorf("expected token abc, got %q, %v", auth.Token, err)
+			}
+
+			// 没有负载的消息
+			data, err = codec.Encode(&protocol.WSMessage{Type: protocol.MsgPong})
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if decoded, err = codec.Decode(data); err != nil || decoded.Type != protocol.MsgPong || len(decoded.Payload) != 0 {
+				t.Errorf("unexpected pong: %+v, %v", decoded, err)
+			}
+		})
+	}
+}
+
+func TestJSONCodec_Format(t *testing.T) {
+	codec := NewJSONCodec()
+
+	// 负载是嵌套的 JSON 对象，字段名与 MessagePack 相同
+	data, err := codec.Encode(&protocol.WSMessage{Type: protocol.MsgChatPush, Seq: 5, Body: &protocol.ChatPushPayload{MessageID: 9, ConversationID: 3, Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	s := string(data)
+	if !strings.HasPrefix(s, `{"type":102,"seq":5,"payload":{`) || !strings.Contains(s, `"message_id":9`) || !strings.Contains(s, `"conversation_id":3`) {
+		t.Errorf("unexpected JSON frame: %s", s)
+	}
+	if strings.Contains(s, "reply_to") {
+		t.Errorf("expected empty optional fields to be omitted: %s", s)
+	}
+
+	// 脚本手写的请求
+	msg, err := codec.Decode([]byte(`{"type": 2, "seq": 8, "payload": {"conversation_id": 3, "type": "text", "content": "hello", "client_msg_id": "c-1"}}`))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	var chat protocol.ChatPayload
+	if err := codec.DecodePayload(msg.Payload, &chat); err != nil {
+		t.Fatalf("DecodePayload failed: %v", err)
+	}
+	if msg.Type != protocol.MsgChat || msg.Seq != 8 || chat.ConversationID != 3 || chat.Content != "hello" || chat.ClientMsgID != "c-1" {
+		t.Errorf("unexpected chat request: %+v %+v", msg, chat)
+	}
+
+	// 非 JSON 数据
+	if _, err := codec.Decode([]byte{0x82, 0xa4}); err == nil {
+		t.Error("expected error for a non-JSON frame")
+	}
+}
@@ -0,0 +1,294 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/group"
+	"zmessage/server/modules/message"
+	"zmessage/server/modules/typing"
+	"zmessage/server/pkg/protocol"
+)
+
+// deliver 将事件中心的事件转换为协议消息，推送给用户的所有连接（跳过发起事件的连接）
+// 没有专用协议消息的事件以通用事件推送下发，保证 WebSocket 与 SSE 收到相同的事件
+func (h *handler) deliver(userID int64, event *hub.Event) bool {
+	if h.mgr == nil {
+		return false
+	}
+
+	msg := h.toWSMessage(event)
+	if msg == nil {
+		return false
+	}
+
+	report, err := h.mgr.BroadcastToUser(userID, msg, event.Origin)
+	if err != nil {
+		return false
+	}
+	return report.Delivered() > 0
+}
+
+// toWSMessage 按事件数据类型构建协议消息，没有专用协议消息的事件转为通用事件推送
+// 事件数据无法编码时返回 nil
+func (h *handler) toWSMessage(event *hub.Event) *protocol.WSMessage {
+	switch data := event.Data.(type) {
+	case *message.ChatEvent:
+		payload := &protocol.ChatPushPayload{
+			Seq:            event.Seq,
+			MessageID:      data.MessageID,
+			ConversationID: data.ConversationID,
+			From:           data.SenderID,
+			To:             data.ReceiverID,
+			Type:           data.Type,
+			Content:        data.Content,
+			CreatedAt:      data.CreatedAt,
+			ReplyTo:        toProtocolReply(data.ReplyTo),
+			ForwardedFrom:  toProtocolForward(data.ForwardedFrom),
+		}
+		if data.ExpiresAt != nil {
+			payload.ExpiresAt = *data.ExpiresAt
+		}
+		return &protocol.WSMessage{Type: protocol.MsgChatPush, Body: payload}
+
+	case *message.MessageEditedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgEditPush,
+			Body: &protocol.EditPushPayload{
+				Seq:            event.Seq,
+				MessageID:      data.MessageID,
+				ConversationID: data.ConversationID,
+				From:           data.SenderID,
+				Content:        data.Content,
+				EditedAt:       data.EditedAt,
+			},
+		}
+
+	case *message.MessageRecalledEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgRecallPush,
+			Body: &protocol.RecallPushPayload{
+				Seq:            event.Seq,
+				MessageID:      data.MessageID,
+				ConversationID: data.ConversationID,
+				From:           data.SenderID,
+				RecalledAt:     data.RecalledAt,
+			},
+		}
+
+	case *message.MessageDeletedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgDeletePush,
+			Body: &protocol.DeletePushPayload{
+				Seq:            event.Seq,
+				MessageID:      data.MessageID,
+				ConversationID: data.ConversationID,
+			},
+		}
+
+	case *message.ReactionEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgReactionPush,
+			Body: &protocol.ReactionPushPayload{
+				Seq:            event.Seq,
+				MessageID:      data.MessageID,
+				ConversationID: data.ConversationID,
+				UserID:         data.UserID,
+				Emoji:          data.Emoji,
+				Action:         data.Action,
+			},
+		}
+
+	case *message.PinEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgPinPush,
+			Body: &protocol.PinPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				MessageID:      data.MessageID,
+				UserID:         data.UserID,
+				Action:         data.Action,
+				UpdatedAt:      data.UpdatedAt,
+			},
+		}
+
+	case *models.MessageReceipt:
+		return &protocol.WSMessage{
+			Type: protocol.MsgStatusPush,
+			Body: &protocol.StatusPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				MessageIDs:     data.MessageIDs,
+				UserID:         data.UserID,
+				Status:         data.Status,
+				UpdatedAt:      data.UpdatedAt,
+			},
+		}
+
+	case *models.Draft:
+		return &protocol.WSMessage{
+			Type: protocol.MsgDraftPush,
+			Body: &protocol.DraftPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				Content:        data.Content,
+				ReplyToID:      data.ReplyToID,
+				UpdatedAt:      data.UpdatedAt,
+			},
+		}
+
+	case *typing.Event:
+		return &protocol.WSMessage{
+			Type: protocol.MsgTypingPush,
+			Body: &protocol.TypingPushPayload{
+				ConversationID: data.ConversationID,
+				UserID:         data.UserID,
+				Typing:         data.Typing,
+			},
+		}
+
+	case *models.PollResults:
+		options := make([]protocol.PollOptionResult, len(data.Options))
+		for i, o := range data.Options {
+			options[i] = protocol.PollOptionResult{Index: o.Index, Count: o.Count, Voters: o.Voters}
+		}
+		return &protocol.WSMessage{
+			Type: protocol.MsgPollPush,
+			Body: &protocol.PollPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				MessageID:      data.MessageID,
+				Options:        options,
+				TotalVoters:    data.TotalVoters,
+				Closed:         data.Closed,
+				ClosedAt:       data.ClosedAt,
+			},
+		}
+
+	case *group.GroupCreatedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgGroupCreatedPush,
+			Body: &protocol.GroupCreatedPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				Title:          data.Title,
+				AvatarID:       avatarID(data.AvatarID),
+				CreatedBy:      data.CreatedBy,
+				CreatedAt:      data.CreatedAt,
+			},
+		}
+
+	case *group.GroupUpdatedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgGroupUpdatedPush,
+			Body: &protocol.GroupUpdatedPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				Title:          data.Title,
+				AvatarID:       avatarID(data.AvatarID),
+				UpdatedBy:      data.UpdatedBy,
+				UpdatedAt:      data.UpdatedAt,
+			},
+		}
+
+	case *group.MembersAddedEvent:
+		members := make([]protocol.GroupMember, len(data.Members))
+		for i, m := range data.Members {
+			members[i] = protocol.GroupMember{
+				UserID:   m.ID,
+				Username: m.Username,
+				Nickname: m.Nickname,
+				Avatar:   m.Avatar,
+				Role:     m.Role,
+				JoinedAt: m.JoinedAt,
+			}
+		}
+		return &protocol.WSMessage{
+			Type: protocol.MsgGroupMembersPush,
+			Body: &protocol.GroupMembersPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				Members:        members,
+				AddedBy:        data.AddedBy,
+			},
+		}
+
+	case *group.MemberRemovedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgGroupRemovedPush,
+			Body: &protocol.GroupRemovedPushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				UserID:         data.UserID,
+				RemovedBy:      data.RemovedBy,
+			},
+		}
+
+	case *group.RoleChangedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgGroupRolePush,
+			Body: &protocol.GroupRolePushPayload{
+				Seq:            event.Seq,
+				ConversationID: data.ConversationID,
+				UserID:         data.UserID,
+				Role:           data.Role,
+				ChangedBy:      data.ChangedBy,
+			},
+		}
+
+	case *models.ReadCursor:
+		return &protocol.WSMessage{
+			Type: protocol.MsgReadCursorPush,
+			Body: &protocol.ReadCursorPushPayload{
+				Seq:               event.Seq,
+				ConversationID:    data.ConversationID,
+				UserID:            data.UserID,
+				LastReadMessageID: data.LastReadMessageID,
+			},
+		}
+
+	case *message.SettingsEvent:
+		payload := &protocol.SettingsPushPayload{Seq: event.Seq, ConversationID: data.ConversationID}
+		if data.Settings != nil {
+			payload.Archived = data.Settings.Archived
+			payload.MutedUntil = data.Settings.MutedUntil
+			payload.Hidden = data.Settings.Hidden
+			payload.PinnedAt = data.Settings.PinnedAt
+		}
+		return &protocol.WSMessage{Type: protocol.MsgSettingsPush, Body: payload}
+
+	case *message.HistoryClearedEvent:
+		return &protocol.WSMessage{
+			Type: protocol.MsgHistoryClearedPush,
+			Body: &protocol.HistoryClearedPushPayload{
+				Seq:              event.Seq,
+				ConversationID:   data.ConversationID,
+				ClearedMessageID: data.ClearedMessageID,
+			},
+		}
+	}
+
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		fmt.Printf("[WS] Encode event %s failed: %v\n", event.Type, err)
+		return nil
+	}
+	return &protocol.WSMessage{
+		Type: protocol.MsgEventPush,
+		Body: &protocol.EventPushPayload{
+			Seq:   event.Seq,
+			Event: event.Type,
+			Data:  string(data),
+		},
+	}
+}
+
+// avatarID 获取头像媒体ID（未设置为0）
+func avatarID(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
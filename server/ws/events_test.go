@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+
+	"zmessage/server/hub"
+	"zmessage/server/models"
+	"zmessage/server/modules/group"
+	"zmessage/server/modules/message"
+	"zmessage/server/pkg/protocol"
+)
+
+func TestHandler_ToWSMessage_Group(t *testing.T) {
+	h := &handler{}
+
+	avatar := int64(7)
+	tests := []struct {
+		data interface{}
+		want protocol.MessageType
+	}{
+		{&group.GroupCreatedEvent{ConversationID: 1, Title: "Team", AvatarID: &avatar}, protocol.MsgGroupCreatedPush},
+		{&group.GroupUpdatedEvent{ConversationID: 1, Title: "Team"}, protocol.MsgGroupUpdatedPush},
+		{&group.MembersAddedEvent{ConversationID: 1, Members: []*models.MemberInfo{{ID: 2, Role: models.MemberRoleMember}}}, protocol.MsgGroupMembersPush},
+		{&group.MemberRemovedEvent{ConversationID: 1, UserID: 2, RemovedBy: 1}, protocol.MsgGroupRemovedPush},
+		{&group.RoleChangedEvent{ConversationID: 1, UserID: 2, Role: models.MemberRoleAdmin}, protocol.MsgGroupRolePush},
+	}
+	for _, tt := range tests {
+		msg := h.toWSMessage(&hub.Event{Data: tt.data})
+		if msg == nil || msg.Type != tt.want {
+			t.Errorf("expected message type %d for %T, got %+v", tt.want, tt.data, msg)
+		}
+	}
+
+	msg := h.toWSMessage(&hub.Event{Data: &group.MembersAddedEvent{ConversationID: 1, Members: []*models.MemberInfo{{ID: 2, Nickname: "Bob"}}}})
+	payload := msg.Body.(*protocol.GroupMembersPushPayload)
+	if len(payload.Members) != 1 || payload.Members[0].UserID != 2 || payload.Members[0].Nickname != "Bob" {
+		t.Errorf("unexpected members payload: %+v", payload)
+	}
+}
+
+func TestHandler_ToWSMessage_AllEvents(t *testing.T) {
+	h := &handler{}
+
+	tests := []struct {
+		data interface{}
+		want protocol.MessageType
+	}{
+		{&models.ReadCursor{ConversationID: 1, UserID: 2, LastReadMessageID: 10}, protocol.MsgReadCursorPush},
+		{&message.SettingsEvent{ConversationID: 1, Settings: &models.ConversationSettings{Archived: true}}, protocol.MsgSettingsPush},
+		{&message.HistoryClearedEvent{ConversationID: 1, ClearedMessageID: 10}, protocol.MsgHistoryClearedPush},
+	}
+	for _, tt := range tests {
+		msg := h.toWSMessage(&hub.Event{Data: tt.data})
+		if msg == nil || msg.Type != tt.want {
+			t.Errorf("expected message type %d for %T, got %+v", tt.want, tt.data, msg)
+		}
+	}
+
+	// 没有专用协议消息的事件以通用事件推送下发
+	msg := h.toWSMessage(&hub.Event{Type: "custom", Seq: 5, Data: map[string]int64{"id": 1}})
+	if msg == nil || msg.Type != protocol.MsgEventPush {
+		t.Fatalf("expected generic event push, got %+v", msg)
+	}
+	payload := msg.Body.(*protocol.EventPushPayload)
+	if payload.Event != "custom" || payload.Seq != 5 || payload.Data != `{"id":1}` {
+		t.Errorf("unexpected generic payload: %+v", payload)
+	}
+}
+
+func TestHandler_ToWSMessage_Seq(t *testing.T) {
+	h := &handler{}
+
+	// 变更日志事件的专用推送携带事件序号，客户端据此推进增量同步游标
+	events := []interface{}{
+		&message.ChatEvent{MessageID: 1, ConversationID: 1},
+		&message.MessageEditedEvent{MessageID: 1, ConversationID: 1},
+		&message.MessageRecalledEvent{MessageID: 1, ConversationID: 1},
+		&message.MessageDeletedEvent{MessageID: 1, ConversationID: 1},
+		&message.ReactionEvent{MessageID: 1, ConversationID: 1},
+		&message.PinEvent{MessageID: 1, ConversationID: 1},
+		&models.MessageReceipt{ConversationID: 1, MessageIDs: []int64{1}},
+		&models.Draft{ConversationID: 1},
+		&models.PollResults{MessageID: 1, ConversationID: 1},
+		&group.GroupCreatedEvent{ConversationID: 1},
+		&group.GroupUpdatedEvent{ConversationID: 1},
+		&group.MembersAddedEvent{ConversationID: 1},
+		&group.MemberRemovedEvent{ConversationID: 1},
+		&group.RoleChangedEvent{ConversationID: 1},
+		&models.ReadCursor{ConversationID: 1},
+		&message.SettingsEvent{ConversationID: 1},
+		&message.HistoryClearedEvent{ConversationID: 1},
+	}
+	for _, data := range events {
+		msg := h.toWSMessage(&hub.Event{Seq: 42, Data: data})
+		if msg == nil {
+			t.Fatalf("expected message for %T", data)
+		}
+		if seq := reflect.ValueOf(msg.Body).Elem().FieldByName("Seq"); !seq.IsValid() || seq.Int() != 42 {
+			t.Errorf("expected seq 42 on %T push, got %v", data, seq)
+		}
+	}
+}
@@ -2,9 +2,13 @@ package ws
 
 import (
 	"fmt"
+	"strings"
 
+	"zmessage/server/hub"
 	"zmessage/server/models"
+	"zmessage/server/modules/changelog"
 	"zmessage/server/modules/message"
+	"zmessage/server/modules/typing"
 	"zmessage/server/modules/user"
 	"zmessage/server/pkg/protocol"
 )
@@ -20,9 +24,12 @@ func NewHandler(msgSvc message.Service, userSvc user.Service) MessageHandler {
 
 // handler 消息处理器实现
 type handler struct {
-	msgSvc  message.Service
-	userSvc user.Service
-	mgr     Manager
+	msgSvc    message.Service
+	userSvc   user.Service
+	typingSvc typing.Service    // 可选，未配置时忽略输入状态消息
+	hub       hub.Hub           // 可选，未配置时不推送服务发布的事件
+	changes   changelog.Service // 可选，未配置时不支持增量同步
+	mgr       Manager
 }
 
 // SetManager 设置连接管理器
@@ -41,10 +48,24 @@ func (h *handler) HandleMessage(conn Connection, msg *protocol.WSMessage) error
 		return h.handleAck(conn, msg)
 	case protocol.MsgSyncReq:
 		return h.handleSync(conn, msg)
+	case protocol.MsgSyncReqV2:
+		return h.handleSyncV2(conn, msg)
 	case protocol.MsgPresence:
 		return h.handlePresence(conn, msg)
 	case protocol.MsgPing:
 		return h.handlePing(conn)
+	case protocol.MsgEdit:
+		return h.handleEdit(conn, msg)
+	case protocol.MsgRecall:
+		return h.handleRecall(conn, msg)
+	case protocol.MsgDelete:
+		return h.handleDelete(conn, msg)
+	case protocol.MsgReaction:
+		return h.handleReaction(conn, msg)
+	case protocol.MsgTyping:
+		return h.handleTyping(conn, msg)
+	case protocol.MsgDraft:
+		return h.handleDraft(conn, msg)
 	default:
 		return fmt.Errorf("unknown message type: %d", msg.Type)
 	}
@@ -53,7 +74,7 @@ func (h *handler) HandleMessage(conn Connection, msg *protocol.WSMessage) error
 // handleAuth 处理认证消息
 func (h *handler) handleAuth(conn Connection, msg *protocol.WSMessage) error {
 	var payload protocol.AuthPayload
-	if err := decodePayload(msg.Payload, &payload); err != nil {
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
 		return err
 	}
 
@@ -61,9 +82,9 @@ func (h *handler) handleAuth(conn Connection, msg *protocol.WSMessage) error {
 	userID, err := h.userSvc.ValidateToken(payload.Token)
 	if err != nil {
 		conn.Send(&protocol.WSMessage{
-			Type:    protocol.MsgAuthRsp,
-			Seq:     msg.Seq,
-			Payload: h.encodeError("invalid_token"),
+			Type: protocol.MsgAuthRsp,
+			Seq:  msg.Seq,
+			Body: errorPayload("invalid_token"),
 		})
 		return nil
 	}
@@ -78,12 +99,12 @@ func (h *handler) handleAuth(conn Connection, msg *protocol.WSMessage) error {
 
 	// 返回成功响应
 	conn.Send(&protocol.WSMessage{
-		Type:    protocol.MsgAuthRsp,
-		Seq:     msg.Seq,
-		Payload: h.encodeSuccess(&protocol.AuthResponsePayload{
+		Type: protocol.MsgAuthRsp,
+		Seq:  msg.Seq,
+		Body: &protocol.AuthResponsePayload{
 			Success: true,
 			UserID:  userID,
-		}),
+		},
 	})
 	return nil
 }
@@ -91,84 +112,366 @@ func (h *handler) handleAuth(conn Connection, msg *protocol.WSMessage) error {
 // handleChat 处理聊天消息
 func (h *handler) handleChat(conn Connection, msg *protocol.WSMessage) error {
 	var payload protocol.ChatPayload
-	if err := decodePayload(msg.Payload, &payload); err != nil {
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
 		return err
 	}
 
 	from := conn.UserID()
 	if from == 0 {
 		conn.Send(&protocol.WSMessage{
-			Type:    protocol.MsgError,
-			Seq:     msg.Seq,
-			Payload: h.encodeError("not_authenticated"),
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+
+	// 内容须符合消息类型的格式
+	if err := message.ValidateContent(payload.Type, payload.Content); err != nil {
+		code := "invalid_content"
+		if err == message.ErrInvalidMessageType {
+			code = "invalid_message_type"
+		}
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload(code),
 		})
 		return nil
 	}
 
 	// 发送消息
 	sentMsg, err := h.msgSvc.SendMessage(&message.SendMessageRequest{
-		From:    from,
-		To:      payload.To,
-		Type:    payload.Type,
-		Content: payload.Content,
+		From:           from,
+		To:             payload.To,
+		ConversationID: payload.ConversationID,
+		Type:           payload.Type,
+		Content:        payload.Content,
+		ReplyToID:      payload.ReplyToID,
+		ClientMsgID:    payload.ClientMsgID,
+		Origin:         conn.ID(),
 	})
 	if err != nil {
 		conn.Send(&protocol.WSMessage{
-			Type:    protocol.MsgError,
-			Seq:     msg.Seq,
-			Payload: h.encodeError("send_failed"),
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("send_failed"),
 		})
 		return nil
 	}
 
-	// 推送给接收者
-	if h.mgr != nil {
-		if toConn := h.mgr.GetConnection(payload.To); toConn != nil {
-			toConn.Send(&protocol.WSMessage{
-				Type: protocol.MsgChatPush,
-				Payload: h.encodeChatPush(&protocol.ChatPushPayload{
-					MessageID: sentMsg.ID,
-					From:       from,
-					To:         payload.To,
-					Type:       payload.Type,
-					Content:    sentMsg.Content,
-					CreatedAt:  sentMsg.CreatedAt,
-				}),
-			})
+	// 回复发送者服务端ID与时间（重发时返回的是首次存储的结果）
+	conn.Send(&protocol.WSMessage{
+		Type: protocol.MsgChatRsp,
+		Seq:  msg.Seq,
+		Body: &protocol.ChatRspPayload{
+			MessageID:      sentMsg.ID,
+			ConversationID: sentMsg.ConversationID,
+			ClientMsgID:    sentMsg.ClientMsgID,
+			CreatedAt:      sentMsg.CreatedAt,
+		},
+	})
+
+	return nil
+}
+
+// handleEdit 处理编辑消息
+func (h *handler) handleEdit(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.EditPayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+
+	edited, err := h.msgSvc.EditMessage(&message.EditMessageRequest{
+		UserID:    from,
+		MessageID: payload.MessageID,
+		Content:   payload.Content,
+	})
+	if err != nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("edit_failed"),
+		})
+		return nil
+	}
+
+	// 回复编辑结果（推送会跳过发起请求的连接）
+	var editedAt int64
+	if edited.EditedAt != nil {
+		editedAt = *edited.EditedAt
+	}
+	conn.Send(&protocol.WSMessage{
+		Type: protocol.MsgEditRsp,
+		Seq:  msg.Seq,
+		Body: &protocol.EditPushPayload{
+			MessageID:      edited.ID,
+			ConversationID: edited.ConversationID,
+			From:           edited.SenderID,
+			Content:        edited.Content,
+			EditedAt:       editedAt,
+		},
+	})
+	return nil
+}
+
+// handleRecall 处理撤回消息
+func (h *handler) handleRecall(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.RecallPayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+
+	recalled, err := h.msgSvc.RecallMessage(0, payload.MessageID, from)
+	if err != nil {
+		code := "recall_failed"
+		if err == message.ErrRecallWindowExpired {
+			code = "recall_window_expired"
 		}
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload(code),
+		})
+		return nil
+	}
+
+	var recalledAt int64
+	if recalled.RecalledAt != nil {
+		recalledAt = *recalled.RecalledAt
+	}
+	conn.Send(&protocol.WSMessage{
+		Type: protocol.MsgRecallRsp,
+		Seq:  msg.Seq,
+		Body: &protocol.RecallPushPayload{
+			MessageID:      recalled.ID,
+			ConversationID: recalled.ConversationID,
+			From:           recalled.SenderID,
+			RecalledAt:     recalledAt,
+		},
+	})
+	return nil
+}
+
+// handleDelete 处理删除消息（仅自己）
+func (h *handler) handleDelete(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.DeletePayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+
+	deleted, err := h.msgSvc.DeleteMessageForMe(0, payload.MessageID, from)
+	if err != nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("delete_failed"),
+		})
+		return nil
 	}
 
+	conn.Send(&protocol.WSMessage{
+		Type: protocol.MsgDeleteRsp,
+		Seq:  msg.Seq,
+		Body: &protocol.DeletePushPayload{
+			MessageID:      deleted.ID,
+			ConversationID: deleted.ConversationID,
+		},
+	})
+	return nil
+}
+
+// handleReaction 处理表情回应
+func (h *handler) handleReaction(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.ReactionPayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+
+	var reacted *models.Message
+	var err error
+	action := "add"
+	if payload.Remove {
+		action = "remove"
+		reacted, err = h.msgSvc.RemoveReaction(0, payload.MessageID, from, payload.Emoji)
+	} else {
+		reacted, err = h.msgSvc.AddReaction(0, payload.MessageID, from, payload.Emoji)
+	}
+	if err != nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("reaction_failed"),
+		})
+		return nil
+	}
+
+	conn.Send(&protocol.WSMessage{
+		Type: protocol.MsgReactionRsp,
+		Seq:  msg.Seq,
+		Body: &protocol.ReactionPushPayload{
+			MessageID:      reacted.ID,
+			ConversationID: reacted.ConversationID,
+			UserID:         from,
+			Emoji:          strings.TrimSpace(payload.Emoji),
+			Action:         action,
+		},
+	})
 	return nil
 }
 
-// handleAck 处理确认消息
+// handleAck 处理确认消息（仅接收者可推进消息状态）
 func (h *handler) handleAck(conn Connection, msg *protocol.WSMessage) error {
 	var payload protocol.AckPayload
-	if err := decodePayload(msg.Payload, &payload); err != nil {
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
 		return err
 	}
 
-	// 更新消息状态
-	if err := h.msgSvc.UpdateMessageStatus(payload.MessageID, payload.Status); err != nil {
-		return fmt.Errorf("update message status: %w", err)
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
 	}
 
+	// 更新消息状态（回执由消息服务推送给发送者）
+	if _, err := h.msgSvc.UpdateMessageStatus(payload.MessageID, from, payload.Status); err != nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("ack_failed"),
+		})
+		return nil
+	}
+	return nil
+}
+
+// handleTyping 处理输入状态（只在内存中记录，不写入消息表）
+func (h *handler) handleTyping(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.TypingPayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+	if h.typingSvc == nil {
+		return nil
+	}
+
+	var err error
+	if payload.Typing {
+		err = h.typingSvc.Start(payload.ConversationID, from)
+	} else {
+		err = h.typingSvc.Stop(payload.ConversationID, from)
+	}
+	if err != nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("typing_failed"),
+		})
+	}
+	return nil
+}
+
+// handleDraft 处理保存草稿（变更经事件中心同步到自己的其他连接）
+func (h *handler) handleDraft(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.DraftPayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+
+	_, err := h.msgSvc.SaveDraft(&message.SaveDraftRequest{
+		UserID:         from,
+		ConversationID: payload.ConversationID,
+		Content:        payload.Content,
+		ReplyToID:      payload.ReplyToID,
+		Origin:         conn.ID(),
+	})
+	if err != nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("draft_failed"),
+		})
+	}
 	return nil
 }
 
 // handleSync 处理同步请求
 func (h *handler) handleSync(conn Connection, msg *protocol.WSMessage) error {
 	var payload protocol.SyncRequestPayload
-	if err := decodePayload(msg.Payload, &payload); err != nil {
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
 		return err
 	}
 
 	from := conn.UserID()
 	if from == 0 {
 		conn.Send(&protocol.WSMessage{
-			Type:    protocol.MsgError,
-			Seq:     msg.Seq,
-			Payload: h.encodeError("not_authenticated"),
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
 		})
 		return nil
 	}
@@ -177,9 +480,9 @@ func (h *handler) handleSync(conn Connection, msg *protocol.WSMessage) error {
 	messages, err := h.msgSvc.GetOfflineMessages(from, payload.LastMessageID, 100)
 	if err != nil {
 		conn.Send(&protocol.WSMessage{
-			Type:    protocol.MsgError,
-			Seq:     msg.Seq,
-			Payload: h.encodeError("sync_failed"),
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("sync_failed"),
 		})
 		return nil
 	}
@@ -188,10 +491,81 @@ func (h *handler) handleSync(conn Connection, msg *protocol.WSMessage) error {
 	conn.Send(&protocol.WSMessage{
 		Type: protocol.MsgSyncRsp,
 		Seq:  msg.Seq,
-		Payload: h.encodeSync(&protocol.SyncResponsePayload{
+		Body: &protocol.SyncResponsePayload{
 			Messages: h.encodeMessages(messages),
 			HasMore:   len(messages) >= 100,
-		}),
+		},
+	})
+
+	// 同步下发的消息视为已投递（回执由消息服务推送给发送者）
+	if len(messages) > 0 {
+		ids := make([]int64, len(messages))
+		for i, m := range messages {
+			ids[i] = m.ID
+		}
+		h.msgSvc.MarkDelivered(from, ids)
+	}
+	return nil
+}
+
+// handleSyncV2 处理增量同步请求
+// 按变更序号返回游标之后的全部变更（包括自己发出的消息、状态回执、编辑、撤回、删除等）
+func (h *handler) handleSyncV2(conn Connection, msg *protocol.WSMessage) error {
+	var payload protocol.SyncV2RequestPayload
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
+		return err
+	}
+
+	from := conn.UserID()
+	if from == 0 {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("not_authenticated"),
+		})
+		return nil
+	}
+	if h.changes == nil {
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload("sync_unsupported"),
+		})
+		return nil
+	}
+
+	set, err := h.changes.GetChanges(from, payload.Cursor, payload.Limit)
+	if err != nil {
+		code := "sync_failed"
+		if err == changelog.ErrInvalidCursor {
+			code = "invalid_cursor"
+		}
+		conn.Send(&protocol.WSMessage{
+			Type: protocol.MsgError,
+			Seq:  msg.Seq,
+			Body: errorPayload(code),
+		})
+		return nil
+	}
+
+	changes := make([]protocol.SyncChange, len(set.Changes))
+	for i, c := range set.Changes {
+		changes[i] = protocol.SyncChange{
+			Seq:       c.Seq,
+			Type:      c.Type,
+			Data:      string(c.Data),
+			CreatedAt: c.CreatedAt,
+		}
+	}
+	conn.Send(&protocol.WSMessage{
+		Type: protocol.MsgSyncRspV2,
+		Seq:  msg.Seq,
+		Body: &protocol.SyncV2ResponsePayload{
+			Changes:        changes,
+			Cursor:         set.Cursor,
+			HasMore:        set.HasMore,
+			ResyncRequired: set.ResyncRequired,
+		},
 	})
 	return nil
 }
@@ -199,7 +573,7 @@ func (h *handler) handleSync(conn Connection, msg *protocol.WSMessage) error {
 // handlePresence 处理在线状态
 func (h *handler) handlePresence(conn Connection, msg *protocol.WSMessage) error {
 	var payload protocol.PresencePayload
-	if err := decodePayload(msg.Payload, &payload); err != nil {
+	if err := decodePayload(conn, msg.Payload, &payload); err != nil {
 		return err
 	}
 
@@ -211,20 +585,18 @@ func (h *handler) handlePresence(conn Connection, msg *protocol.WSMessage) error
 	// 更新用户在线状态
 	h.userSvc.OnlineStatus().SetOnline(from, payload.Status == "online")
 
-	// 广播在线状态变化给其他在线用户
+	// 广播在线状态变化给其他在线用户的所有连接
 	if h.mgr != nil {
-		users := h.mgr.GetOnlineUsers()
-		for _, uid := range users {
+		push := &protocol.WSMessage{
+			Type: protocol.MsgPresencePush,
+			Body: &protocol.PresencePushPayload{
+				UserID: from,
+				Status: payload.Status,
+			},
+		}
+		for _, uid := range h.mgr.GetOnlineUsers() {
 			if uid != from {
-				if userConn := h.mgr.GetConnection(uid); userConn != nil {
-					userConn.Send(&protocol.WSMessage{
-						Type: protocol.MsgPresencePush,
-						Payload: h.encodePresence(&protocol.PresencePushPayload{
-							UserID: uid,
-							Status: payload.Status,
-						}),
-					})
-				}
+				h.mgr.BroadcastToUser(uid, push)
 			}
 		}
 	}
@@ -241,62 +613,76 @@ func (h *handler) handlePing(conn Connection) error {
 	return nil
 }
 
-// encodePayload 编码负载
-func decodePayload(data []byte, dest interface{}) error {
-	dec := NewDecoder()
-	return dec.DecodePayload(data, dest)
-}
-
-// encodeSuccess 编码成功响应
-func (h *handler) encodeSuccess(resp interface{}) []byte {
-	enc := NewEncoder()
-	data, _ := enc.EncodePayload(resp)
-	return data
+// decodePayload 按连接的编码格式解码负载
+func decodePayload(conn Connection, data []byte, dest interface{}) error {
+	return conn.Codec().DecodePayload(data, dest)
 }
 
-// encodeError 编码错误响应
-func (h *handler) encodeError(err string) []byte {
-	enc := NewEncoder()
-	data, _ := enc.EncodePayload(&protocol.ErrorPayload{
-		Code:    err,
+// errorPayload 构建错误响应负载（发送时按连接的编码格式编码）
+func errorPayload(code string) *protocol.ErrorPayload {
+	return &protocol.ErrorPayload{
+		Code:    code,
 		Message: "",
-	})
-	return data
-}
-
-// encodeChatPush 编码聊天推送消息
-func (h *handler) encodeChatPush(msg *protocol.ChatPushPayload) []byte {
-	enc := NewEncoder()
-	data, _ := enc.EncodePayload(msg)
-	return data
-}
-
-// encodeSync 编码同步响应消息
-func (h *handler) encodeSync(msg *protocol.SyncResponsePayload) []byte {
-	enc := NewEncoder()
-	data, _ := enc.EncodePayload(msg)
-	return data
+	}
 }
 
 // encodeMessages 编码消息列表
 func (h *handler) encodeMessages(messages []*models.Message) []protocol.ChatPushPayload {
 	result := make([]protocol.ChatPushPayload, len(messages))
 	for i, msg := range messages {
+		var editedAt int64
+		if msg.EditedAt != nil {
+			editedAt = *msg.EditedAt
+		}
 		result[i] = protocol.ChatPushPayload{
-			MessageID: msg.ID,
-			From:       msg.SenderID,
-			To:         msg.ReceiverID,
-			Type:       msg.Type,
-			Content:    msg.Content,
-			CreatedAt:  msg.CreatedAt,
+			MessageID:      msg.ID,
+			ConversationID: msg.ConversationID,
+			From:           msg.SenderID,
+			To:             msg.ReceiverID,
+			Type:           msg.Type,
+			Content:        msg.Content,
+			CreatedAt:      msg.CreatedAt,
+			EditedAt:       editedAt,
+			ReplyTo:        toProtocolReply(msg.ReplyTo),
+			ForwardedFrom:  toProtocolForward(msg.ForwardedFrom),
+			ExpiresAt:      expiresAt(msg),
 		}
 	}
 	return result
 }
 
-// encodePresence 编码在线状态
-func (h *handler) encodePresence(msg *protocol.PresencePushPayload) []byte {
-	enc := NewEncoder()
-	data, _ := enc.EncodePayload(msg)
-	return data
+// expiresAt 获取消息的过期时间（未开启阅后即焚为0）
+func expiresAt(msg *models.Message) int64 {
+	if msg.ExpiresAt == nil {
+		return 0
+	}
+	return *msg.ExpiresAt
+}
+
+// toProtocolForward 转换转发来源
+func toProtocolForward(fwd *models.ForwardInfo) *protocol.ForwardInfo {
+	if fwd == nil {
+		return nil
+	}
+	return &protocol.ForwardInfo{
+		MessageID:      fwd.MessageID,
+		From:           fwd.SenderID,
+		SenderNickname: fwd.SenderNickname,
+		CreatedAt:      fwd.CreatedAt,
+	}
+}
+
+// toProtocolReply 转换引用预览
+func toProtocolReply(preview *models.ReplyPreview) *protocol.ReplyPreview {
+	if preview == nil {
+		return nil
+	}
+	return &protocol.ReplyPreview{
+		MessageID:      preview.MessageID,
+		From:           preview.SenderID,
+		SenderNickname: preview.SenderNickname,
+		Type:           preview.Type,
+		Content:        preview.Content,
+		Recalled:       preview.Recalled,
+	}
 }
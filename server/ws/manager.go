@@ -6,7 +6,10 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"zmessage/server/hub"
+	"zmessage/server/modules/changelog"
 	"zmessage/server/modules/message"
+	"zmessage/server/modules/typing"
 	"zmessage/server/modules/user"
 	"zmessage/server/pkg/protocol"
 )
@@ -20,18 +23,50 @@ const (
 	MaxConnectionsPerUser = 3
 )
 
+// Option 连接管理器配置项
+type Option func(*handler)
+
+// WithTypingService 启用输入状态：处理客户端的输入状态消息（状态变化经事件中心推送）
+func WithTypingService(typingSvc typing.Service) Option {
+	return func(h *handler) {
+		h.typingSvc = typingSvc
+	}
+}
+
+// WithHub 订阅实时事件中心，将各服务发布的事件推送给用户的 WS 连接
+func WithHub(eventHub hub.Hub) Option {
+	return func(h *handler) {
+		h.hub = eventHub
+	}
+}
+
+// WithChangeLog 启用增量同步：处理客户端的 MsgSyncReqV2 请求
+func WithChangeLog(changes changelog.Service) Option {
+	return func(h *handler) {
+		h.changes = changes
+	}
+}
+
 // NewManager 创建连接管理器
-func NewManager(msgSvc message.Service, userSvc user.Service) Manager {
+func NewManager(msgSvc message.Service, userSvc user.Service, opts ...Option) Manager {
+	h := &handler{
+		msgSvc:  msgSvc,
+		userSvc: userSvc,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
 	mgr := &connectionManager{
 		connections: make(map[string]*connection),
 		userConns:   make(map[int64]map[string]*connection),
-		msgHandler:  &handler{
-			msgSvc:  msgSvc,
-			userSvc: userSvc,
-		},
+		msgHandler:  h,
 	}
 	// 注入manager到handler
-	mgr.msgHandler.(*handler).SetManager(mgr)
+	h.SetManager(mgr)
+	if h.hub != nil {
+		h.hub.Subscribe(hub.AllUsers, nil, h.deliver)
+	}
 	return mgr
 }
 
@@ -44,6 +79,7 @@ type connectionManager struct {
 }
 
 // HandleConnection 处理新的WebSocket连接
+// 编码格式由握手时协商的子协议决定（见 Subprotocols），未协商时使用 MessagePack
 func (m *connectionManager) HandleConnection(conn interface{}) {
 	wsConn, ok := conn.(*websocket.Conn)
 	if !ok {
@@ -51,13 +87,14 @@ func (m *connectionManager) HandleConnection(conn interface{}) {
 	}
 	// 创建连接对象
 	c := &connection{
-		id:       generateConnID(),
-		conn:     wsConn,
-		send:     make(chan []byte, 256),
-		mgr:      m,
-		userID:   0,
-	 createdAt: time.Now(),
-	 pongTime:  time.Now(),
+		id:        generateConnID(),
+		conn:      wsConn,
+		codec:     CodecFor(wsConn.Subprotocol()),
+		send:      make(chan []byte, 256),
+		mgr:       m,
+		userID:    0,
+		createdAt: time.Now(),
+		pongTime:  time.Now(),
 	}
 
 	m.mu.Lock()
@@ -98,32 +135,51 @@ func (m *connectionManager) GetConnections(userID int64) []Connection {
 	return nil
 }
 
-// BroadcastToUser 向用户的所有连接发送消息
-func (m *connectionManager) BroadcastToUser(userID int64, msg *protocol.WSMessage) error {
+// BroadcastToUser 向用户的所有连接发送消息，逐个连接记录投递结果
+func (m *connectionManager) BroadcastToUser(userID int64, msg *protocol.WSMessage, exclude ...string) (DeliveryReport, error) {
 	m.mu.RLock()
-	defer m.mu.RUnlock()
+	conns := make([]*connection, 0, len(m.userConns[userID]))
+	for _, c := range m.userConns[userID] {
+		conns = append(conns, c)
+	}
+	m.mu.RUnlock()
 
-	conns, ok := m.userConns[userID]
-	if !ok || len(conns) == 0 {
-		return nil // 用户离线，不报错
+	if len(conns) == 0 {
+		return nil, nil // 用户离线，不报错
 	}
 
-	// 编码消息
-	enc := NewEncoder()
-	data, err := enc.Encode(msg)
-	if err != nil {
-		return err
+	// 按连接的编码格式编码消息（相同格式的连接共用）
+	encoded := make(map[string][]byte)
+	for _, c := range conns {
+		codec := c.Codec()
+		if _, ok := encoded[codec.Subprotocol()]; ok {
+			continue
+		}
+		data, err := codec.Encode(msg)
+		if err != nil {
+			return nil, err
+		}
+		encoded[codec.Subprotocol()] = data
 	}
 
+	report := make(DeliveryReport, 0, len(conns))
 	for _, c := range conns {
-		select {
-		case c.send <- data:
-			// 发送成功
-		default:
-			return fmt.Errorf("user %d channel full", userID)
+		if isExcluded(c.id, exclude) {
+			continue
 		}
+		report = append(report, Delivery{ConnID: c.id, Err: c.trySend(encoded[c.Codec().Subprotocol()])})
 	}
-	return nil
+	return report, nil
+}
+
+// isExcluded 连接是否在排除列表中
+func isExcluded(connID string, exclude []string) bool {
+	for _, id := range exclude {
+		if id == connID {
+			return true
+		}
+	}
+	return false
 }
 
 // IsOnline 检查用户是否在线
@@ -217,6 +273,7 @@ func generateConnID() string {
 type connection struct {
 	id        string
 	conn      *websocket.Conn
+	codec     Codec // 握手时协商的编码格式
 	send      chan []byte
 	mgr       *connectionManager
 	userID    int64
@@ -268,7 +325,7 @@ func (c *connection) writePump() {
 			if !ok {
 				return
 			}
-			if err := c.conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+			if err := c.conn.WriteMessage(c.Codec().FrameType(), data); err != nil {
 				return
 			}
 		}
@@ -276,7 +333,7 @@ func (c *connection) writePump() {
 }
 
 func (c *connection) handleMessage(data []byte) error {
-	msg, err := decodeWSMessage(data)
+	msg, err := c.Codec().Decode(data)
 	if err != nil {
 		return fmt.Errorf("decode message: %w", err)
 	}
@@ -285,10 +342,9 @@ func (c *connection) handleMessage(data []byte) error {
 }
 
 func (c *connection) sendError(errMsg string) {
-	enc := NewEncoder()
-	data, _ := enc.Encode(&protocol.WSMessage{
-		Type:    protocol.MsgError,
-		Payload: encodeErrorPayload(errMsg),
+	data, _ := c.Codec().Encode(&protocol.WSMessage{
+		Type: protocol.MsgError,
+		Body: errorPayload(errMsg),
 	})
 	select {
 	case c.send <- data:
@@ -296,20 +352,6 @@ func (c *connection) sendError(errMsg string) {
 	}
 }
 
-func decodeWSMessage(data []byte) (*protocol.WSMessage, error) {
-	dec := NewDecoder()
-	return dec.Decode(data)
-}
-
-func encodeErrorPayload(message string) []byte {
-	enc := NewEncoder()
-	data, _ := enc.EncodePayload(&protocol.ErrorPayload{
-		Code:    message,
-		Message: "",
-	})
-	return data
-}
-
 // ID 获取连接ID
 func (c *connection) ID() string {
 	return c.id
@@ -322,14 +364,32 @@ func (c *connection) UserID() int64 {
 	return c.userID
 }
 
-// Send 发送消息
+// Codec 获取连接的编解码器（未协商时为 MessagePack）
+func (c *connection) Codec() Codec {
+	if c.codec == nil {
+		return NewMsgpackCodec()
+	}
+	return c.codec
+}
+
+// Send 按连接的编码格式发送消息
 func (c *connection) Send(msg *protocol.WSMessage) error {
-	enc := NewEncoder()
-	data, err := enc.Encode(msg)
+	data, err := c.Codec().Encode(msg)
 	if err != nil {
 		return err
 	}
 
+	return c.trySend(data)
+}
+
+// trySend 非阻塞地写入发送缓冲（连接已关闭或缓冲已满时返回错误）
+func (c *connection) trySend(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.send == nil {
+		return fmt.Errorf("connection %s closed", c.id)
+	}
 	select {
 	case c.send <- data:
 		return nil
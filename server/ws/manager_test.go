@@ -8,17 +8,18 @@ import (
 	"zmessage/server/models"
 	"zmessage/server/modules/message"
 	"zmessage/server/modules/user"
+	"zmessage/server/pkg/protocol"
 )
 
 // MockMessageService 消息服务模拟
 type MockMessageService struct {
 	sendMessageFunc  func(*message.SendMessageRequest) (*models.Message, error)
 	getOfflineFunc   func(int64, int64, int) ([]*models.Message, error)
-	updateStatusFunc func(int64, string) error
-	getConvFunc     func(int64, int64) (*models.ConversationWithInfo, error)
-	getMsgsFunc     func(int64, int64, int) ([]*models.Message, bool, error)
-	markReadFunc    func(int64, int64) error
-	getUnreadFunc   func(int64, int64) (int, error)
+	updateStatusFunc func(int64, int64, string) (*models.MessageReceipt, error)
+	getConvFunc      func(int64, int64) (*models.ConversationWithInfo, error)
+	getMsgsFunc      func(int64, int64, int) ([]*models.Message, bool, error)
+	markReadFunc     func(int64, int64) (*models.MessageReceipt, error)
+	getUnreadFunc    func(int64, int64) (int, error)
 }
 
 func (m *MockMessageService) SendMessage(req *message.SendMessageRequest) (*models.Message, error) {
@@ -35,11 +36,19 @@ func (m *MockMessageService) GetOfflineMessages(userID int64, lastMessageID int6
 	return []*models.Message{}, nil
 }
 
-func (m *MockMessageService) UpdateMessageStatus(messageID int64, status string) error {
+func (m *MockMessageService) UpdateMessageStatus(messageID int64, userID int64, status string) (*models.MessageReceipt, error) {
 	if m.updateStatusFunc != nil {
-		return m.updateStatusFunc(messageID, status)
+		return m.updateStatusFunc(messageID, userID, status)
 	}
-	return nil
+	return nil, nil
+}
+
+func (m *MockMessageService) MarkDelivered(userID int64, messageIDs []int64) ([]*models.MessageReceipt, error) {
+	return nil, nil
+}
+
+func (m *MockMessageService) ForwardMessages(req *message.ForwardMessagesRequest) ([]*models.Message, error) {
+	return nil, nil
 }
 
 func (m *MockMessageService) GetConversation(id int64, userID int64) (*models.ConversationWithInfo, error) {
@@ -63,11 +72,11 @@ func (m *MockMessageService) GetMessages(conversationID int64, userID int64, bef
 	return []*models.Message{}, false, nil
 }
 
-func (m *MockMessageService) MarkAsRead(conversationID int64, userID int64) error {
+func (m *MockMessageService) MarkAsRead(conversationID int64, userID int64) (*models.MessageReceipt, error) {
 	if m.markReadFunc != nil {
 		return m.markReadFunc(conversationID, userID)
 	}
-	return nil
+	return nil, nil
 }
 
 func (m *MockMessageService) GetUnreadCount(userID int64, conversationID int64) (int, error) {
@@ -81,6 +90,86 @@ func (m *MockMessageService) GetConversations(userID int64, page, limit int) ([]
 	return []*models.ConversationWithInfo{}, 0, nil
 }
 
+func (m *MockMessageService) EditMessage(req *message.EditMessageRequest) (*models.Message, error) {
+	return &models.Message{ID: req.MessageID, Content: req.Content}, nil
+}
+
+func (m *MockMessageService) GetMessageRevisions(conversationID int64, messageID int64, userID int64) ([]*models.MessageRevision, error) {
+	return []*models.MessageRevision{}, nil
+}
+
+func (m *MockMessageService) RecallMessage(conversationID int64, messageID int64, userID int64) (*models.Message, error) {
+	return &models.Message{ID: messageID, ConversationID: conversationID}, nil
+}
+
+func (m *MockMessageService) DeleteMessageForMe(conversationID int64, messageID int64, userID int64) (*models.Message, error) {
+	return &models.Message{ID: messageID, ConversationID: conversationID}, nil
+}
+
+func (m *MockMessageService) AddReaction(conversationID int64, messageID int64, userID int64, emoji string) (*models.Message, error) {
+	return &models.Message{ID: messageID, ConversationID: conversationID}, nil
+}
+
+func (m *MockMessageService) RemoveReaction(conversationID int64, messageID int64, userID int64, emoji string) (*models.Message, error) {
+	return &models.Message{ID: messageID, ConversationID: conversationID}, nil
+}
+
+func (m *MockMessageService) SearchMessages(req *message.SearchMessagesRequest) ([]*models.MessageSearchHit, bool, error) {
+	return nil, false, nil
+}
+
+func (m *MockMessageService) PinMessage(conversationID int64, messageID int64, userID int64) ([]*models.PinnedMessage, error) {
+	return nil, nil
+}
+
+func (m *MockMessageService) UnpinMessage(conversationID int64, messageID int64, userID int64) ([]*models.PinnedMessage, error) {
+	return nil, nil
+}
+
+func (m *MockMessageService) GetPinnedMessages(conversationID int64, userID int64) ([]*models.PinnedMessage, error) {
+	return nil, nil
+}
+
+func (m *MockMessageService) SaveDraft(req *message.SaveDraftRequest) (*models.Draft, error) {
+	return nil, nil
+}
+
+func (m *MockMessageService) StarMessage(conversationID int64, messageID int64, userID int64) error {
+	return nil
+}
+
+func (m *MockMessageService) UnstarMessage(conversationID int64, messageID int64, userID int64) error {
+	return nil
+}
+
+func (m *MockMessageService) GetStarredMessages(req *message.StarredMessagesRequest) ([]*models.StarredMessage, bool, error) {
+	return nil, false, nil
+}
+
+func (m *MockMessageService) ListConversations(req *message.ConversationListRequest) ([]*models.ConversationWithInfo, int, error) {
+	return nil, 0, nil
+}
+
+func (m *MockMessageService) UpdateConversationSettings(req *message.UpdateConversationSettingsRequest) (*models.ConversationSettings, error) {
+	return &models.ConversationSettings{}, nil
+}
+
+func (m *MockMessageService) ClearHistory(conversationID int64, userID int64, upToMessageID int64) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockMessageService) GetTotalUnreadCount(userID int64) (int, error) {
+	return 0, nil
+}
+
+func (m *MockMessageService) SetDisappearingTimer(conversationID int64, userID int64, seconds int64) (*models.Message, error) {
+	return nil, nil
+}
+
+func (m *MockMessageService) GetParticipantIDs(conversationID int64) ([]int64, error) {
+	return []int64{}, nil
+}
+
 // MockUserService 用户服务模拟
 type MockUserService struct {
 	validateTokenFunc func(string) (int64, error)
@@ -292,6 +381,88 @@ func TestConnectionManager_GetConnection(t *testing.T) {
 	}
 }
 
+func TestConnectionManager_BroadcastToUser(t *testing.T) {
+	msgSvc := &MockMessageService{}
+	userSvc := &MockUserService{}
+	mgr := NewManager(msgSvc, userSvc).(*connectionManager)
+
+	// 离线用户没有投递结果
+	report, err := mgr.BroadcastToUser(1, &protocol.WSMessage{Type: protocol.MsgChatPush})
+	if err != nil || len(report) != 0 {
+		t.Errorf("expected empty report for offline user, got %v, %v", report, err)
+	}
+
+	// 同一用户的三个连接，其中一个发送缓冲已满
+	full := &connection{id: "full", userID: 1, mgr: mgr, send: make(chan []byte, 1)}
+	full.send <- []byte("pending")
+	conn1 := &connection{id: "conn1", userID: 1, mgr: mgr, send: make(chan []byte, 10)}
+	conn2 := &connection{id: "conn2", userID: 1, mgr: mgr, send: make(chan []byte, 10)}
+	mgr.addConnection(full)
+	mgr.addConnection(conn1)
+	mgr.addConnection(conn2)
+
+	// 缓冲已满的连接不影响其他连接
+	report, err = mgr.BroadcastToUser(1, &protocol.WSMessage{Type: protocol.MsgChatPush})
+	if err != nil {
+		t.Fatalf("broadcast failed: %v", err)
+	}
+	if len(report) != 3 || report.Delivered() != 2 || report.Err() == nil {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	for _, d := range report {
+		if (d.ConnID == "full") != (d.Err != nil) {
+			t.Errorf("unexpected delivery for %s: %v", d.ConnID, d.Err)
+		}
+	}
+	if len(conn1.send) != 1 || len(conn2.send) != 1 {
+		t.Errorf("expected both healthy connections to receive the message")
+	}
+
+	// 跳过发起的连接
+	<-full.send
+	report, _ = mgr.BroadcastToUser(1, &protocol.WSMessage{Type: protocol.MsgChatPush}, "conn1")
+	if len(report) != 2 || report.Delivered() != 2 || report.Err() != nil {
+		t.Errorf("unexpected report with exclusion: %+v", report)
+	}
+	if len(conn1.send) != 1 || len(conn2.send) != 2 {
+		t.Errorf("expected the excluded connection to be skipped")
+	}
+}
+
+func TestConnectionManager_BroadcastToUser_Codecs(t *testing.T) {
+	msgSvc := &MockMessageService{}
+	userSvc := &MockUserService{}
+	mgr := NewManager(msgSvc, userSvc).(*connectionManager)
+
+	// 同一用户的 MessagePack 和 JSON 连接各自收到本格式编码的消息
+	binary := &connection{id: "binary", userID: 1, mgr: mgr, codec: NewMsgpackCodec(), send: make(chan []byte, 10)}
+	text := &connection{id: "text", userID: 1, mgr: mgr, codec: NewJSONCodec(), send: make(chan []byte, 10)}
+	mgr.addConnection(binary)
+	mgr.addConnection(text)
+
+	report, err := mgr.BroadcastToUser(1, &protocol.WSMessage{
+		Type: protocol.MsgTypingPush,
+		Body: &protocol.TypingPushPayload{ConversationID: 3, UserID: 2, Typing: true},
+	})
+	if err != nil || report.Delivered() != 2 {
+		t.Fatalf("unexpected broadcast result: %+v, %v", report, err)
+	}
+
+	for _, c := range []*connection{binary, text} {
+		msg, err := c.Codec().Decode(<-c.send)
+		if err != nil {
+			t.Fatalf("%s: decode failed: %v", c.id, err)
+		}
+		var payload protocol.TypingPushPayload
+		if err := decodePayload(c, msg.Payload, &payload); err != nil {
+			t.Fatalf("%s: decode payload failed: %v", c.id, err)
+		}
+		if msg.Type != protocol.MsgTypingPush || payload.ConversationID != 3 || payload.UserID != 2 || !payload.Typing {
+			t.Errorf("%s: unexpected message: %+v %+v", c.id, msg, payload)
+		}
+	}
+}
+
 func TestConnectionManager_Disconnect(t *testing.T) {
 	msgSvc := &MockMessageService{}
 	userSvc := &MockUserService{}
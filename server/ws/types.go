@@ -1,6 +1,10 @@
 package ws
 
-import "zmessage/server/pkg/protocol"
+import (
+	"errors"
+
+	"zmessage/server/pkg/protocol"
+)
 
 // Connection WebSocket连接接口
 type Connection interface {
@@ -8,7 +12,9 @@ type Connection interface {
 	ID() string
 	// UserID 获取用户ID
 	UserID() int64
-	// Send 发送消息
+	// Codec 获取连接的编解码器（握手时通过子协议协商）
+	Codec() Codec
+	// Send 发送消息（按连接的编码格式编码）
 	Send(msg *protocol.WSMessage) error
 	// authenticate 认证连接
 	authenticate(userID int64) error
@@ -22,8 +28,9 @@ type Manager interface {
 	GetConnection(userID int64) Connection
 	// GetConnections 获取用户的所有连接
 	GetConnections(userID int64) []Connection
-	// BroadcastToUser 向用户的所有连接发送消息
-	BroadcastToUser(userID int64, msg *protocol.WSMessage) error
+	// BroadcastToUser 向用户的所有连接发送消息（跳过 exclude 中的连接），返回每个连接的投递结果
+	// 消息按各连接的编码格式分别编码；某个连接发送失败不影响其他连接，error 仅表示消息编码失败
+	BroadcastToUser(userID int64, msg *protocol.WSMessage, exclude ...string) (DeliveryReport, error)
 	// IsOnline 检查用户是否在线
 	IsOnline(userID int64) bool
 	// GetOnlineUsers 获取在线用户列表
@@ -34,6 +41,37 @@ type Manager interface {
 	DisconnectUser(userID int64)
 }
 
+// Delivery 单个连接的投递结果
+type Delivery struct {
+	ConnID string
+	Err    error // 为nil表示已写入连接的发送缓冲
+}
+
+// DeliveryReport 广播的投递结果（每个连接一项，用户离线时为空）
+type DeliveryReport []Delivery
+
+// Delivered 投递成功的连接数
+func (r DeliveryReport) Delivered() int {
+	n := 0
+	for _, d := range r {
+		if d.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Err 汇总投递失败的连接（全部成功时为nil）
+func (r DeliveryReport) Err() error {
+	var errs []error
+	for _, d := range r {
+		if d.Err != nil {
+			errs = append(errs, d.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // MessageHandler 消息处理器接口
 type MessageHandler interface {
 	// HandleMessage 处理消息